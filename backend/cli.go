@@ -0,0 +1,198 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"chefly/config"
+	"chefly/database"
+	"chefly/models"
+	"chefly/services"
+)
+
+// runCommand runs a CLI subcommand instead of the server and returns the process exit code
+func runCommand(cfg *config.Config, db *sql.DB, dialect database.Dialect, args []string) int {
+	switch args[0] {
+	case "migrate":
+		return runMigrateCommand(db, dialect, args[1:])
+	case "backup":
+		return runBackupCommand(cfg, db, dialect, args[1:])
+	case "restore":
+		return runRestoreCommand(cfg, db, dialect, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		printUsage()
+		return 2
+	}
+}
+
+// runMigrateCommand applies, rolls back or prints the status of schema migrations
+func runMigrateCommand(db *sql.DB, dialect database.Dialect, args []string) int {
+	migrator := database.NewMigrator(db, dialect)
+
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		applied, err := migrator.Migrate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Migration failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("✅ Applied %d migration(s)\n", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintln(os.Stderr, "❌ Number of migrations to roll back must be a positive integer")
+				return 2
+			}
+			steps = n
+		}
+		rolledBack, err := migrator.Rollback(steps)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Rollback failed after %d migration(s): %v\n", rolledBack, err)
+			return 1
+		}
+		fmt.Printf("✅ Rolled back %d migration(s)\n", rolledBack)
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to read migration status: %v\n", err)
+			return 1
+		}
+		fmt.Printf("%-8s %-32s %-10s %s\n", "VERSION", "NAME", "STATE", "APPLIED AT")
+		for _, status := range statuses {
+			state := "pending"
+			appliedAt := "-"
+			if status.Applied {
+				state = "applied"
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Modified {
+				state = "modified"
+			}
+			fmt.Printf("%-8d %-32s %-10s %s\n", status.Version, status.Name, state, appliedAt)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown migrate action %q\n\n", action)
+		printUsage()
+		return 2
+	}
+
+	return 0
+}
+
+// runBackupCommand writes a backup archive to the given file (default: a new archive in BACKUP_PATH)
+func runBackupCommand(cfg *config.Config, db *sql.DB, dialect database.Dialect, args []string) int {
+	imageStore, err := newImageStore(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Backup failed: %v\n", err)
+		return 1
+	}
+	backupService := services.NewBackupService(db, dialect, cfg.DBPath, imageStore, cfg.BackupPath, cfg.BackupCompress)
+
+	var backup *models.BackupInfo
+	if len(args) > 0 {
+		backup, err = backupService.CreateAt(args[0])
+	} else {
+		backup, err = backupService.Create()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Backup failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("✅ Backup written to %s (%d bytes, %d images, schema version %d)\n",
+		backup.Path, backup.SizeBytes, backup.Manifest.ImageCount, backup.Manifest.SchemaVersion)
+	return 0
+}
+
+// runRestoreCommand replaces the database and images with the contents of a backup archive
+// The server must not be running while restoring
+func runRestoreCommand(cfg *config.Config, db *sql.DB, dialect database.Dialect, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "❌ Usage: chefly restore <archive>")
+		return 2
+	}
+
+	// Release the database file so it can be replaced
+	db.Close()
+
+	imageStore, err := newImageStore(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Restore failed, nothing was replaced: %v\n", err)
+		return 1
+	}
+	backupService := services.NewBackupService(nil, dialect, cfg.DBPath, imageStore, cfg.BackupPath, cfg.BackupCompress)
+	report, err := backupService.Restore(args[0])
+	if err != nil {
+		printRestoreFailure(err, cfg.DBPath)
+		return 1
+	}
+
+	fmt.Printf("✅ Restored backup from %s (schema version %d, %d migration(s) applied, %d image(s) restored)\n",
+		report.Manifest.CreatedAt.Format("2006-01-02 15:04:05"), report.Manifest.SchemaVersion, report.MigrationsApplied, report.ImagesRestored)
+	if report.PreviousDatabase != "" {
+		fmt.Printf("📦 Previous database kept at %s\n", report.PreviousDatabase)
+	}
+	if report.ForeignKeyViolations > 0 {
+		fmt.Printf("⚠️  %d row(s) reference missing parent rows\n", report.ForeignKeyViolations)
+	}
+	if len(report.MissingImages) > 0 {
+		fmt.Printf("⚠️  %d image(s) referenced by recipes are missing on disk:\n", len(report.MissingImages))
+		for _, image := range report.MissingImages {
+			fmt.Printf("   %s\n", image)
+		}
+	}
+	return 0
+}
+
+// printRestoreFailure reports the failed restore stage and what had already been replaced
+func printRestoreFailure(err error, dbPath string) {
+	var restoreErr *services.RestoreError
+	if !errors.As(err, &restoreErr) {
+		fmt.Fprintf(os.Stderr, "❌ Restore failed, nothing was replaced: %v\n", err)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "❌ Restore failed at stage %q: %v\n", restoreErr.Stage, restoreErr.Err)
+	if restoreErr.ImagesRestored == 0 && !restoreErr.DatabaseReplaced && restoreErr.PreviousDatabase == "" {
+		fmt.Fprintln(os.Stderr, "   Nothing was replaced")
+		return
+	}
+	if restoreErr.ImagesRestored > 0 {
+		fmt.Fprintf(os.Stderr, "   %d image(s) were already restored, replacing images with the same name\n", restoreErr.ImagesRestored)
+	}
+	switch {
+	case restoreErr.DatabaseReplaced && restoreErr.PreviousDatabase != "":
+		fmt.Fprintf(os.Stderr, "   The database was replaced by the backup, the previous database is kept at %s\n", restoreErr.PreviousDatabase)
+	case restoreErr.DatabaseReplaced:
+		fmt.Fprintln(os.Stderr, "   The database was installed from the backup")
+	case restoreErr.PreviousDatabase != "":
+		fmt.Fprintf(os.Stderr, "   No database is installed at %s, the previous database was left at %s\n", dbPath, restoreErr.PreviousDatabase)
+	default:
+		fmt.Fprintf(os.Stderr, "   The current database at %s was not replaced\n", dbPath)
+	}
+}
+
+// printUsage prints the available CLI subcommands
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  chefly                     Start the server (applies pending migrations)")
+	fmt.Fprintln(os.Stderr, "  chefly migrate [up]        Apply pending migrations")
+	fmt.Fprintln(os.Stderr, "  chefly migrate down [n]    Roll back the last n migrations (default 1)")
+	fmt.Fprintln(os.Stderr, "  chefly migrate status      Print migration status")
+	fmt.Fprintln(os.Stderr, "  chefly backup [file]       Write a backup of the database and images (default: BACKUP_PATH)")
+	fmt.Fprintln(os.Stderr, "  chefly restore <file>      Restore a backup (stop the server first)")
+}
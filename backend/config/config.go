@@ -2,43 +2,171 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 )
 
 // Config holds application configuration
 type Config struct {
-	DBPath                 string
+	DBDriver              string        // Database driver: sqlite or postgres
+	DBPath                string        // SQLite database file (DB_DRIVER=sqlite)
+	DBURL                 string        // PostgreSQL connection URL (DB_DRIVER=postgres)
+	DBMaxOpenConns        int           // Maximum open connections in the pool (0 = unlimited)
+	DBMaxIdleConns        int           // Maximum idle connections kept in the pool
+	DBConnMaxLifetime     time.Duration // Maximum time a connection is reused (0 = forever)
+	SQLiteBusyTimeout     time.Duration // How long SQLite waits for a lock before failing with "database is locked"
 	Port                   string
 	JWTSecret              string
 	ClaudeAPIKey           string
 	ClaudeModel            string // Claude AI model (e.g: claude-3-haiku-20240307)
 	OpenAIAPIKey           string
 	OpenAIModel            string // OpenAI model (e.g: dall-e-3)
+	OpenAIBaseURL         string        // Optional OpenAI API endpoint (e.g: a proxy), empty = https://api.openai.com/v1
+	ImageGenerator        string // Image generation backend: dalle, stable-diffusion or offline
+	SDAPIURL              string // Base URL of the Automatic1111-compatible Stable Diffusion API (e.g: http://localhost:7860)
+	SDAPIAuth             string // Optional "username:password" of the Stable Diffusion API (--api-auth)
+	ImageGenSize          string // Size of generated images (e.g: 1024x1024)
+	ImageGenQuality       string // Quality of generated images: standard or hd
+	AIRecipeTimeout       time.Duration // Timeout of one recipe generation call to Claude (0 = until the client disconnects)
+	AINutritionTimeout    time.Duration // Timeout of one nutrition estimation call to Claude
+	AIImageTimeout        time.Duration // Timeout of generating one image (including the DALL-E download)
+	ImageDownloadTimeout  time.Duration // Timeout of downloading a remote image (DALL-E URLs, external recipe images)
+	ImageDownloadMaxMB    int           // Maximum size of a downloaded remote image in megabytes
+	AIRetryMaxAttempts    int           // Requests per AI call including the first (1 = no retries)
+	AIRetryBaseDelay      time.Duration // Backoff before the first retry, doubled for every further retry
+	AIRetryMaxDelay       time.Duration // Maximum backoff, a longer Retry-After of the provider fails the call
+	AICircuitThreshold    int           // Consecutive provider failures that open the circuit breaker (0 = disabled)
+	AICircuitOpenDuration time.Duration // How long AI calls fail fast before the provider is tried again
+	AIPrices              string        // Price overrides for AI cost estimates (e.g: claude-sonnet-4=3/15,dall-e-3@1024x1024=0.04)
 	ImageStoragePath       string
+	ImageStore            string // Image storage backend: local or s3
+	S3Endpoint            string // S3-compatible endpoint without scheme (e.g: s3.amazonaws.com, minio:9000)
+	S3Region              string
+	S3Bucket              string
+	S3AccessKeyID         string
+	S3SecretAccessKey     string
+	S3UseSSL              bool
+	S3Prefix              string        // Optional key prefix inside the bucket
+	S3PresignExpiry       time.Duration // > 0: redirect image requests to pre-signed URLs valid this long (0 = proxy through Chefly)
+	S3Timeout             time.Duration // Deadline of each S3 request, including streaming a downloaded image
+	ImageVariantWidths    string        // Comma separated widths of responsive image variants (e.g: 200,400,800)
+	ImageVariantFormats   string        // Comma separated formats of image variants: jpeg, webp (JPEG is always produced)
+	ImageJPEGQuality      int
+	ImageWebPQuality      int
+	ImageMigrateUnmanaged bool          // Move data: URL and external recipe images into the image store on startup
+	ImageGCEnabled        bool          // Periodically delete images no recipe refers to
+	ImageGCInterval       time.Duration // Time between orphaned image garbage collection runs
+	ImageGCGracePeriod    time.Duration // Unreferenced images younger than this are kept (recipe may still be saving)
+	ImageGCQuarantine     bool          // Move orphans below quarantine/ instead of deleting them
+	PhotoMaxUploadMB      int           // Maximum size of an uploaded recipe photo in megabytes
+	PhotoMaxPerRecipe     int           // Maximum number of photos per recipe
+	PhotoMinDimension     int           // Minimum length of the shorter side of uploaded photos in pixels
+	PhotoMaxDimension     int           // Maximum length of the longer side of uploaded photos in pixels
+	BackupPath            string        // Directory for backup archives (admin endpoint and scheduler)
+	BackupEnabled         bool          // Enable scheduled automatic backups (SQLite only)
+	BackupInterval        time.Duration // Time between scheduled backups
+	BackupCompress        bool          // Gzip compress backup archives
+	BackupKeepLast        int           // Retention: newest backups always kept
+	BackupKeepDaily       int           // Retention: newest backup of each of the last N days
+	BackupKeepWeekly      int           // Retention: newest backup of each of the last N weeks
+	BackupKeepMonthly     int           // Retention: newest backup of each of the last N months
 	Environment            string
 	AuditLogEnabled        bool   // Enable audit logging
 	AuditLogLevel          string // Log level: debug, info, warn, error
 	AuditLogFormat         string // Log format: json or pretty
 	RegistrationEnabled    bool
-	RecipeGenerationLimit  string // Global recipe generation limit: "unlimited", "0", or number (e.g. "10")
+	RecipeGenerationLimit string        // Global recipe quota policy: "unlimited", "0", a lifetime number (e.g. "10") or rules (e.g. "10/day,100/month")
+	ImageRegenLimit       string        // Global image regeneration quota policy: "unlimited", "0", a number per ImageRegenWindow or rules (e.g. "5/hour")
+	ImageRegenWindow      time.Duration // Rolling window of a plain number image regeneration limit
+	AppBaseURL            string // Public base URL used in outbound links (e.g: https://chefly.example.com)
+	DigestEnabled         bool   // Enable weekly email digests
+	SMTPHost              string
+	SMTPPort              string
+	SMTPUsername          string
+	SMTPPassword          string
+	SMTPFrom              string // Sender address for outbound email (e.g: Chefly <chefly@example.com>)
 }
 
 // Load loads configuration from environment variables
 func Load() *Config {
 	return &Config{
+		DBDriver:              getEnv("DB_DRIVER", "sqlite"),
 		DBPath:                getEnv("DB_PATH", "./data/chefly.db"),
+		DBURL:                 getEnv("DB_URL", ""),
+		DBMaxOpenConns:        getEnvInt("DB_MAX_OPEN_CONNS", 10),
+		DBMaxIdleConns:        getEnvInt("DB_MAX_IDLE_CONNS", 5),
+		DBConnMaxLifetime:     getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		SQLiteBusyTimeout:     getEnvDuration("SQLITE_BUSY_TIMEOUT", 5*time.Second),
 		Port:                  getEnv("PORT", "8080"),
 		JWTSecret:             getEnv("JWT_SECRET", "change-me-in-production"),
 		ClaudeAPIKey:          getEnv("CLAUDE_API_KEY", ""),
 		ClaudeModel:           getEnv("CLAUDE_MODEL", "claude-3-haiku-20240307"),
 		OpenAIAPIKey:          getEnv("OPENAI_API_KEY", ""),
 		OpenAIModel:           getEnv("OPENAI_MODEL", "dall-e-3"),
+		OpenAIBaseURL:         getEnv("OPENAI_BASE_URL", ""),
+		ImageGenerator:        getEnv("IMAGE_GENERATOR", "dalle"),
+		SDAPIURL:              getEnv("SD_API_URL", "http://localhost:7860"),
+		SDAPIAuth:             getEnv("SD_API_AUTH", ""),
+		ImageGenSize:          getEnv("IMAGE_GENERATION_SIZE", "1024x1024"),
+		ImageGenQuality:       getEnv("IMAGE_GENERATION_QUALITY", "standard"),
+		AIRecipeTimeout:       getEnvDuration("AI_RECIPE_TIMEOUT", 2*time.Minute),
+		AINutritionTimeout:    getEnvDuration("AI_NUTRITION_TIMEOUT", 30*time.Second),
+		AIImageTimeout:        getEnvDuration("AI_IMAGE_TIMEOUT", 2*time.Minute),
+		ImageDownloadTimeout:  getEnvDuration("IMAGE_DOWNLOAD_TIMEOUT", 60*time.Second),
+		ImageDownloadMaxMB:    getEnvInt("IMAGE_DOWNLOAD_MAX_MB", 20),
+		AIRetryMaxAttempts:    getEnvInt("AI_RETRY_MAX_ATTEMPTS", 3),
+		AIRetryBaseDelay:      getEnvDuration("AI_RETRY_BASE_DELAY", time.Second),
+		AIRetryMaxDelay:       getEnvDuration("AI_RETRY_MAX_DELAY", 30*time.Second),
+		AICircuitThreshold:    getEnvInt("AI_CIRCUIT_FAILURE_THRESHOLD", 5),
+		AICircuitOpenDuration: getEnvDuration("AI_CIRCUIT_OPEN_DURATION", 30*time.Second),
+		AIPrices:              getEnv("AI_PRICES", ""),
 		ImageStoragePath:      getEnv("IMAGE_STORAGE_PATH", "./data/images"),
+		ImageStore:            getEnv("IMAGE_STORE", "local"),
+		S3Endpoint:            getEnv("S3_ENDPOINT", ""),
+		S3Region:              getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:              getEnv("S3_BUCKET", ""),
+		S3AccessKeyID:         getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey:     getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3UseSSL:              getEnvBool("S3_USE_SSL", true),
+		S3Prefix:              getEnv("S3_PREFIX", ""),
+		S3PresignExpiry:       getEnvDuration("S3_PRESIGN_EXPIRY", 0),
+		S3Timeout:             getEnvDuration("S3_TIMEOUT", 30*time.Second),
+		ImageVariantWidths:    getEnv("IMAGE_VARIANT_WIDTHS", "200,400,800"),
+		ImageVariantFormats:   getEnv("IMAGE_VARIANT_FORMATS", "jpeg,webp"),
+		ImageJPEGQuality:      getEnvInt("IMAGE_JPEG_QUALITY", 85),
+		ImageWebPQuality:      getEnvInt("IMAGE_WEBP_QUALITY", 80),
+		ImageMigrateUnmanaged: getEnvBool("IMAGE_MIGRATE_UNMANAGED", true), // Default: enabled
+		ImageGCEnabled:        getEnvBool("IMAGE_GC_ENABLED", false), // Default: disabled
+		ImageGCInterval:       getEnvDuration("IMAGE_GC_INTERVAL", 24*time.Hour),
+		ImageGCGracePeriod:    getEnvDuration("IMAGE_GC_GRACE_PERIOD", 24*time.Hour),
+		ImageGCQuarantine:     getEnvBool("IMAGE_GC_QUARANTINE", false),
+		PhotoMaxUploadMB:      getEnvInt("PHOTO_MAX_UPLOAD_MB", 10),
+		PhotoMaxPerRecipe:     getEnvInt("PHOTO_MAX_PER_RECIPE", 10),
+		PhotoMinDimension:     getEnvInt("PHOTO_MIN_DIMENSION", 200),
+		PhotoMaxDimension:     getEnvInt("PHOTO_MAX_DIMENSION", 8000),
+		BackupPath:            getEnv("BACKUP_PATH", "./data/backups"),
+		BackupEnabled:         getEnvBool("BACKUP_ENABLED", false), // Default: disabled
+		BackupInterval:        getEnvDuration("BACKUP_INTERVAL", 24*time.Hour),
+		BackupCompress:        getEnvBool("BACKUP_COMPRESS", true),
+		BackupKeepLast:        getEnvInt("BACKUP_KEEP_LAST", 3),
+		BackupKeepDaily:       getEnvInt("BACKUP_KEEP_DAILY", 7),
+		BackupKeepWeekly:      getEnvInt("BACKUP_KEEP_WEEKLY", 4),
+		BackupKeepMonthly:     getEnvInt("BACKUP_KEEP_MONTHLY", 6),
 		Environment:           getEnv("ENVIRONMENT", "development"),
 		AuditLogEnabled:       getEnvBool("AUDIT_LOG_ENABLED", true),          // Default: enabled
 		AuditLogLevel:         getEnv("AUDIT_LOG_LEVEL", "info"),              // Default: info
 		AuditLogFormat:        getEnv("AUDIT_LOG_FORMAT", "json"),             // Default: json
 		RegistrationEnabled:   getEnvBool("REGISTRATION_ENABLED", true),       // Default: enabled
 		RecipeGenerationLimit: getEnv("RECIPE_GENERATION_LIMIT", "unlimited"), // Default: unlimited
+		ImageRegenLimit:       getEnv("IMAGE_REGENERATION_LIMIT", "10"),
+		ImageRegenWindow:      getEnvDuration("IMAGE_REGENERATION_WINDOW", 24*time.Hour),
+		AppBaseURL:            getEnv("APP_BASE_URL", "http://localhost:8080"),
+		DigestEnabled:         getEnvBool("DIGEST_ENABLED", false), // Default: disabled
+		SMTPHost:              getEnv("SMTP_HOST", ""),
+		SMTPPort:              getEnv("SMTP_PORT", "587"),
+		SMTPUsername:          getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:          getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:              getEnv("SMTP_FROM", "chefly@localhost"),
 	}
 }
 
@@ -57,3 +185,23 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// getEnvInt gets integer environment variable with fallback default (also used when the value is invalid)
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDuration gets duration environment variable (e.g: 30s, 5m) with fallback default (also used when the value is invalid)
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
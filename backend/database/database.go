@@ -3,168 +3,88 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// InitDB initializes SQLite database connection
-func InitDB(dbPath string) (*sql.DB, error) {
-	// Ensure data directory exists
-	dir := filepath.Dir(dbPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create database directory: %w", err)
+// Options tunes the connection pool and SQLite locking behaviour
+type Options struct {
+	MaxOpenConns    int           // 0 = unlimited
+	MaxIdleConns    int           // Idle connections kept open for reuse
+	ConnMaxLifetime time.Duration // 0 = connections are reused forever
+	BusyTimeout     time.Duration // SQLite only: how long to wait for a lock before "database is locked"
+}
+
+// InitDB initializes the database connection for the dialect
+// dsn is the database file path for SQLite and a connection URL for PostgreSQL
+func InitDB(dialect Dialect, dsn string, options Options) (*sql.DB, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("no database location configured for driver %s", dialect)
+	}
+
+	if dialect == SQLite {
+		// Ensure data directory exists
+		dir := filepath.Dir(dsn)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+		dsn = sqliteDSN(dsn, options.BusyTimeout)
 	}
 
 	// Open database connection
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open(dialect.DriverName(), dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	db.SetMaxOpenConns(options.MaxOpenConns)
+	db.SetMaxIdleConns(options.MaxIdleConns)
+	db.SetConnMaxLifetime(options.ConnMaxLifetime)
+
 	// Test connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Enable foreign keys
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
-	}
-
-	fmt.Println("✅ Database initialized successfully")
-	return db, nil
-}
-
-// RunMigrations runs database migrations
-func RunMigrations(db *sql.DB) error {
-	migrations := []string{
-		// Create users table
-		`CREATE TABLE IF NOT EXISTS users (
-			id TEXT PRIMARY KEY,
-			email TEXT UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL,
-			username TEXT NOT NULL,
-			is_admin INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-
-		// Create recipes table
-		`CREATE TABLE IF NOT EXISTS recipes (
-			id TEXT PRIMARY KEY,
-			user_id TEXT NOT NULL,
-			title TEXT NOT NULL,
-			description TEXT,
-			ingredients TEXT NOT NULL,
-			steps TEXT NOT NULL,
-			cooking_time INTEGER,
-			difficulty TEXT,
-			cuisine_type TEXT,
-			meat_type TEXT,
-			dietary_tags TEXT,
-			is_favorite INTEGER DEFAULT 0,
-			image_path TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		)`,
-
-		// Create indexes for recipes
-		`CREATE INDEX IF NOT EXISTS idx_recipes_user_id ON recipes(user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_recipes_created_at ON recipes(created_at DESC)`,
-		`CREATE INDEX IF NOT EXISTS idx_recipes_favorite ON recipes(user_id, is_favorite)`,
-
-		// Create recipe_filters table
-		`CREATE TABLE IF NOT EXISTS recipe_filters (
-			id TEXT PRIMARY KEY,
-			recipe_id TEXT NOT NULL,
-			meat_category TEXT,
-			side_ingredients TEXT,
-			country TEXT,
-			dietary_preferences TEXT,
-			FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE CASCADE
-		)`,
-
-		// Create indexes for recipe_filters
-		`CREATE INDEX IF NOT EXISTS idx_filters_recipe_id ON recipe_filters(recipe_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_filters_meat ON recipe_filters(meat_category)`,
-		`CREATE INDEX IF NOT EXISTS idx_filters_country ON recipe_filters(country)`,
-
-		// Create shopping_list_items table
-		`CREATE TABLE IF NOT EXISTS shopping_list_items (
-			id TEXT PRIMARY KEY,
-			user_id TEXT NOT NULL,
-			recipe_id TEXT,
-			recipe_title TEXT,
-			ingredient_name TEXT NOT NULL,
-			quantity TEXT NOT NULL,
-			unit TEXT NOT NULL,
-			is_checked INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-			FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE SET NULL
-		)`,
-
-		// Create indexes for shopping_list_items
-		`CREATE INDEX IF NOT EXISTS idx_shopping_user_id ON shopping_list_items(user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_shopping_recipe_id ON shopping_list_items(recipe_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_shopping_checked ON shopping_list_items(user_id, is_checked)`,
-
-		// Migration: Add is_admin column if it doesn't exist (for existing databases)
-		`ALTER TABLE users ADD COLUMN is_admin INTEGER DEFAULT 0`,
-
-		// Migration: Add recipe_limit column to users table
-		// NULL = use global limit, -1 = unlimited, 0 = blocked, >0 = custom limit
-		`ALTER TABLE users ADD COLUMN recipe_limit INTEGER DEFAULT NULL`,
-
-		// Create refresh_tokens table for JWT refresh token management
-		`CREATE TABLE IF NOT EXISTS refresh_tokens (
-			id TEXT PRIMARY KEY,
-			user_id TEXT NOT NULL,
-			token TEXT NOT NULL UNIQUE,
-			expires_at DATETIME NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			revoked INTEGER DEFAULT 0,
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		)`,
-
-		// Create indexes for refresh_tokens
-		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_token ON refresh_tokens(token)`,
-		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at)`,
-
-		// Migration: Add thumbnail_path column to recipes table for optimized images
-		`ALTER TABLE recipes ADD COLUMN thumbnail_path TEXT DEFAULT ''`,
-	}
-
-	for i, migration := range migrations {
-		if _, err := db.Exec(migration); err != nil {
-			// Ignore "duplicate column" errors for ALTER TABLE migrations (migrations 13 and 14 are ALTER TABLE)
-			// This allows the app to work with existing databases
-			errorStr := err.Error()
-			if contains(errorStr, "duplicate column") || contains(errorStr, "already exists") {
-				// It's an ALTER TABLE that already ran, skip it
-				continue
-			}
-			return fmt.Errorf("migration %d failed: %w", i, err)
+	if dialect == SQLite {
+		// WAL is persistent in the database file, verify it was actually enabled (not possible on some filesystems)
+		var journalMode string
+		if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to read journal mode: %w", err)
+		}
+		if !strings.EqualFold(journalMode, "wal") {
+			fmt.Printf("⚠️  SQLite journal mode is %s, WAL could not be enabled\n", journalMode)
 		}
 	}
 
-	fmt.Println("✅ Database migrations completed successfully")
-	return nil
-}
-
-// Helper function to check if string contains substring
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && containsHelper(s, substr))
+	fmt.Printf("✅ Database initialized successfully (%s)\n", dialect)
+	return db, nil
 }
 
-func containsHelper(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
+// sqliteDSN appends the connection parameters go-sqlite3 applies to every pooled connection
+// A PRAGMA executed with db.Exec only affects the one connection that happened to run it
+func sqliteDSN(path string, busyTimeout time.Duration) string {
+	params := url.Values{}
+	params.Set("_foreign_keys", "on")
+	params.Set("_journal_mode", "WAL")
+	params.Set("_synchronous", "NORMAL")
+	params.Set("_busy_timeout", strconv.FormatInt(busyTimeout.Milliseconds(), 10))
+	// Write transactions take the lock up front, a deferred transaction upgrading to a
+	// write lock fails immediately with SQLITE_BUSY instead of waiting for busy_timeout
+	params.Set("_txlock", "immediate")
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
 	}
-	return false
+	return path + separator + params.Encode()
 }
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// isLocked reports whether an error is SQLite giving up on a lock
+func isLocked(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked) {
+		return true
+	}
+	return err != nil && strings.Contains(err.Error(), "database is locked")
+}
+
+func openTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := InitDB(SQLite, filepath.Join(t.TempDir(), "chefly.db"), Options{
+		MaxOpenConns: 8,
+		MaxIdleConns: 8,
+		BusyTimeout:  10 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteConnectionSettings(t *testing.T) {
+	db := openTestSQLite(t)
+
+	// Every pooled connection gets the settings, not just the one that ran a PRAGMA
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for i := 0; i < 4; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("Conn: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	for i, conn := range conns {
+		var journalMode string
+		var foreignKeys, busyTimeout int
+		if err := conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode); err != nil {
+			t.Fatalf("journal_mode: %v", err)
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+			t.Fatalf("foreign_keys: %v", err)
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+			t.Fatalf("busy_timeout: %v", err)
+		}
+		if journalMode != "wal" || foreignKeys != 1 || busyTimeout != 10000 {
+			t.Errorf("connection %d: journal_mode=%s foreign_keys=%d busy_timeout=%d, want wal, 1, 10000",
+				i, journalMode, foreignKeys, busyTimeout)
+		}
+		conn.Close()
+	}
+}
+
+func TestSQLiteConcurrentWriteTransactions(t *testing.T) {
+	db := openTestSQLite(t)
+	for _, statement := range []string{
+		"CREATE TABLE counters (id INTEGER PRIMARY KEY, value INTEGER NOT NULL)",
+		"CREATE TABLE events (id INTEGER PRIMARY KEY AUTOINCREMENT, worker INTEGER NOT NULL, value INTEGER NOT NULL)",
+		"INSERT INTO counters (id, value) VALUES (1, 0)",
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	const workers = 16
+	const iterations = 25
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*iterations+2)
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				errs <- increment(db, worker)
+			}
+		}(worker)
+	}
+
+	// Readers run alongside the writers, WAL lets them proceed without blocking
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for reader := 0; reader < 2; reader++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				var count int
+				if err := db.QueryRow("SELECT COUNT(*) FROM events").Scan(&count); err != nil {
+					errs <- fmt.Errorf("read: %w", err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+	close(errs)
+
+	for err := range errs {
+		if isLocked(err) {
+			t.Errorf("transaction failed on a lock: %v", err)
+		} else if err != nil {
+			t.Errorf("transaction failed: %v", err)
+		}
+	}
+
+	// Read-then-write transactions did not lose updates
+	var value, events int
+	if err := db.QueryRow("SELECT value FROM counters WHERE id = 1").Scan(&value); err != nil {
+		t.Fatalf("read counter: %v", err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM events").Scan(&events); err != nil {
+		t.Fatalf("count events: %v", err)
+	}
+	if value != workers*iterations || events != workers*iterations {
+		t.Errorf("counter = %d, events = %d, want %d", value, events, workers*iterations)
+	}
+}
+
+// increment reads the counter and writes it back in one transaction, the pattern
+// that fails with SQLITE_BUSY when a deferred transaction upgrades to a write lock
+func increment(db *sql.DB, worker int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	var value int
+	if err := tx.QueryRow("SELECT value FROM counters WHERE id = 1").Scan(&value); err != nil {
+		return fmt.Errorf("select: %w", err)
+	}
+	// Holding the read lock for a moment makes the writers overlap
+	time.Sleep(time.Millisecond)
+	if _, err := tx.Exec("UPDATE counters SET value = ? WHERE id = 1", value+1); err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO events (worker, value) VALUES (?, ?)", worker, value+1); err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
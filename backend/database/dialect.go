@@ -0,0 +1,79 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect identifies the SQL database engine behind a connection
+type Dialect string
+
+const (
+	// SQLite is the embedded single file database (default)
+	SQLite Dialect = "sqlite"
+	// Postgres is a PostgreSQL server, required when running several replicas
+	Postgres Dialect = "postgres"
+)
+
+// sqliteTimeFormat is the format SQLite's CURRENT_TIMESTAMP uses, needed to compare against DATETIME columns
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// ParseDialect validates a DB_DRIVER value
+func ParseDialect(driver string) (Dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(driver)) {
+	case "", "sqlite", "sqlite3":
+		return SQLite, nil
+	case "postgres", "postgresql":
+		return Postgres, nil
+	default:
+		return "", fmt.Errorf("unsupported database driver %q (supported: sqlite, postgres)", driver)
+	}
+}
+
+// DriverName returns the database/sql driver registered for the dialect
+func (d Dialect) DriverName() string {
+	if d == Postgres {
+		return "postgres"
+	}
+	return "sqlite3"
+}
+
+// Rebind rewrites "?" placeholders to the dialect's bind variable syntax ($1, $2, ... for PostgreSQL)
+// Queries must not contain literal question marks
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var rebound strings.Builder
+	rebound.Grow(len(query) + 16)
+	position := 0
+	for _, char := range query {
+		if char == '?' {
+			position++
+			rebound.WriteString("$" + strconv.Itoa(position))
+			continue
+		}
+		rebound.WriteRune(char)
+	}
+	return rebound.String()
+}
+
+// TimeArg converts a time to a query argument comparable with the dialect's timestamp columns
+// SQLite stores CURRENT_TIMESTAMP as text, so times are compared in the same UTC text format
+func (d Dialect) TimeArg(t time.Time) interface{} {
+	if d == Postgres {
+		return t.UTC()
+	}
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// DayExpr returns an SQL expression for the UTC day (YYYY-MM-DD) of a timestamp column, used to group rows by day
+func (d Dialect) DayExpr(column string) string {
+	if d == Postgres {
+		return "to_char(" + column + " AT TIME ZONE 'UTC', 'YYYY-MM-DD')"
+	}
+	return "substr(" + column + ", 1, 10)"
+}
@@ -0,0 +1,303 @@
+package database
+
+// sqliteMigrations lists every SQLite schema migration in version order
+// Applied migrations must never be edited (their checksum is verified on every run), add a new version instead
+// Every version must also be added to postgresMigrations so both dialects share the same schema history
+var sqliteMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: `
+			-- Create users table
+			-- recipe_limit: NULL = use global limit, -1 = unlimited, 0 = blocked, >0 = custom limit
+			-- digest_opt_in: 0 = not subscribed (default), 1 = subscribed
+			CREATE TABLE IF NOT EXISTS users (
+				id TEXT PRIMARY KEY,
+				email TEXT UNIQUE NOT NULL,
+				password_hash TEXT NOT NULL,
+				username TEXT NOT NULL,
+				is_admin INTEGER DEFAULT 0,
+				recipe_limit INTEGER DEFAULT NULL,
+				digest_opt_in INTEGER DEFAULT 0,
+				digest_unsubscribe_token TEXT DEFAULT NULL,
+				digest_last_sent_at DATETIME DEFAULT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_users_digest_token ON users(digest_unsubscribe_token);
+
+			-- Create recipes table
+			-- servings: 0 = unknown, allergens: JSON encoded array of EU allergen codes
+			CREATE TABLE IF NOT EXISTS recipes (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				title TEXT NOT NULL,
+				description TEXT,
+				ingredients TEXT NOT NULL,
+				steps TEXT NOT NULL,
+				cooking_time INTEGER,
+				difficulty TEXT,
+				cuisine_type TEXT,
+				meat_type TEXT,
+				dietary_tags TEXT,
+				is_favorite INTEGER DEFAULT 0,
+				image_path TEXT,
+				thumbnail_path TEXT DEFAULT '',
+				servings INTEGER DEFAULT 0,
+				allergens TEXT DEFAULT '[]',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_recipes_user_id ON recipes(user_id);
+			CREATE INDEX IF NOT EXISTS idx_recipes_created_at ON recipes(created_at DESC);
+			CREATE INDEX IF NOT EXISTS idx_recipes_favorite ON recipes(user_id, is_favorite);
+
+			-- Create recipe_filters table
+			CREATE TABLE IF NOT EXISTS recipe_filters (
+				id TEXT PRIMARY KEY,
+				recipe_id TEXT NOT NULL,
+				meat_category TEXT,
+				side_ingredients TEXT,
+				country TEXT,
+				dietary_preferences TEXT,
+				FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_filters_recipe_id ON recipe_filters(recipe_id);
+			CREATE INDEX IF NOT EXISTS idx_filters_meat ON recipe_filters(meat_category);
+			CREATE INDEX IF NOT EXISTS idx_filters_country ON recipe_filters(country);
+
+			-- Create shopping_list_items table
+			CREATE TABLE IF NOT EXISTS shopping_list_items (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				recipe_id TEXT,
+				recipe_title TEXT,
+				ingredient_name TEXT NOT NULL,
+				quantity TEXT NOT NULL,
+				unit TEXT NOT NULL,
+				is_checked INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+				FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE SET NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_shopping_user_id ON shopping_list_items(user_id);
+			CREATE INDEX IF NOT EXISTS idx_shopping_recipe_id ON shopping_list_items(recipe_id);
+			CREATE INDEX IF NOT EXISTS idx_shopping_checked ON shopping_list_items(user_id, is_checked);
+
+			-- Create refresh_tokens table for JWT refresh token management
+			CREATE TABLE IF NOT EXISTS refresh_tokens (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				token TEXT NOT NULL UNIQUE,
+				expires_at DATETIME NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				revoked INTEGER DEFAULT 0,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_refresh_tokens_token ON refresh_tokens(token);
+			CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+			CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+
+			-- Create recipe_nutrition table (per-serving values are stored as columns for filtering)
+			CREATE TABLE IF NOT EXISTS recipe_nutrition (
+				recipe_id TEXT PRIMARY KEY,
+				servings INTEGER NOT NULL DEFAULT 0,
+				calories REAL NOT NULL DEFAULT 0,
+				protein REAL NOT NULL DEFAULT 0,
+				fat REAL NOT NULL DEFAULT 0,
+				carbohydrates REAL NOT NULL DEFAULT 0,
+				fiber REAL NOT NULL DEFAULT 0,
+				sodium REAL NOT NULL DEFAULT 0,
+				details TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_nutrition_calories ON recipe_nutrition(calories);
+
+			-- Create user_preferences table (list columns are JSON encoded arrays)
+			CREATE TABLE IF NOT EXISTS user_preferences (
+				user_id TEXT PRIMARY KEY,
+				allergies TEXT DEFAULT '[]',
+				diets TEXT DEFAULT '[]',
+				disliked_ingredients TEXT DEFAULT '[]',
+				preferred_cuisines TEXT DEFAULT '[]',
+				household_size INTEGER DEFAULT 0,
+				skill_level TEXT DEFAULT '',
+				kitchen_equipment TEXT DEFAULT '[]',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS user_preferences;
+			DROP TABLE IF EXISTS recipe_nutrition;
+			DROP TABLE IF EXISTS refresh_tokens;
+			DROP TABLE IF EXISTS shopping_list_items;
+			DROP TABLE IF EXISTS recipe_filters;
+			DROP TABLE IF EXISTS recipes;
+			DROP TABLE IF EXISTS users;
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "recipe_image_variants",
+		Up: `
+			-- JSON encoded array of responsive image renditions (models.ImageVariant)
+			ALTER TABLE recipes ADD COLUMN image_variants TEXT DEFAULT '[]';
+		`,
+		Down: `
+			ALTER TABLE recipes DROP COLUMN image_variants;
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "recipe_photos",
+		Up: `
+			-- Photos uploaded by the cook, the cover photo replaces the generated image of the recipe
+			-- image_variants: JSON encoded array of responsive image renditions (models.ImageVariant)
+			CREATE TABLE IF NOT EXISTS recipe_photos (
+				id TEXT PRIMARY KEY,
+				recipe_id TEXT NOT NULL,
+				user_id TEXT NOT NULL,
+				image_path TEXT NOT NULL,
+				thumbnail_path TEXT NOT NULL,
+				image_variants TEXT DEFAULT '[]',
+				width INTEGER NOT NULL DEFAULT 0,
+				height INTEGER NOT NULL DEFAULT 0,
+				is_cover INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE CASCADE,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_recipe_photos_recipe ON recipe_photos(recipe_id, created_at);
+			CREATE INDEX IF NOT EXISTS idx_recipe_photos_user ON recipe_photos(user_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS recipe_photos;
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "image_regenerations",
+		Up: `
+			-- On demand image regenerations, counted against the image regeneration limit
+			-- recipe_id has no foreign key: deleting the recipe must not give the quota back
+			CREATE TABLE IF NOT EXISTS image_regenerations (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				recipe_id TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_image_regenerations_user ON image_regenerations(user_id, created_at);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS image_regenerations;
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "ai_usage",
+		Up: `
+			-- One row per successful AI provider request with its token and image usage
+			-- user_id is kept NULL when the user is deleted so the cost history stays complete
+			-- cost_usd: estimated from the price table when the request was made, NULL for unknown models
+			CREATE TABLE IF NOT EXISTS ai_usage (
+				id TEXT PRIMARY KEY,
+				user_id TEXT,
+				provider TEXT NOT NULL,
+				model TEXT NOT NULL,
+				operation TEXT NOT NULL,
+				input_tokens INTEGER NOT NULL DEFAULT 0,
+				output_tokens INTEGER NOT NULL DEFAULT 0,
+				image_count INTEGER NOT NULL DEFAULT 0,
+				image_size TEXT NOT NULL DEFAULT '',
+				image_quality TEXT NOT NULL DEFAULT '',
+				cost_usd REAL,
+				latency_ms INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_ai_usage_created ON ai_usage(created_at);
+			CREATE INDEX IF NOT EXISTS idx_ai_usage_user ON ai_usage(user_id, created_at);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS ai_usage;
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "generation_quotas",
+		Up: `
+			-- Recipe (text) and image generations counted by quotas, replaces image_regenerations
+			-- recipe_id has no foreign key: deleting the recipe must not give the quota back
+			CREATE TABLE IF NOT EXISTS generation_events (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				kind TEXT NOT NULL,
+				recipe_id TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_generation_events_user ON generation_events(user_id, kind, created_at);
+
+			-- Existing recipes were generated and keep counting against lifetime limits
+			INSERT INTO generation_events (id, user_id, kind, recipe_id, created_at)
+			SELECT id, user_id, 'text', id, created_at FROM recipes;
+			INSERT INTO generation_events (id, user_id, kind, recipe_id, created_at)
+			SELECT id, user_id, 'image', recipe_id, created_at FROM image_regenerations;
+			DROP TABLE IF EXISTS image_regenerations;
+
+			-- Personal quota policies (e.g: "10/day,100/month"), NULL = use the global policy
+			-- recipe_limit becomes a lifetime text quota: -1 = unlimited, 0 = blocked
+			ALTER TABLE users ADD COLUMN text_quota TEXT DEFAULT NULL;
+			ALTER TABLE users ADD COLUMN image_quota TEXT DEFAULT NULL;
+			UPDATE users
+			SET text_quota = CASE WHEN recipe_limit = -1 THEN 'unlimited' ELSE CAST(recipe_limit AS TEXT) END
+			WHERE recipe_limit IS NOT NULL;
+			ALTER TABLE users DROP COLUMN recipe_limit;
+		`,
+		Down: `
+			-- Windowed personal policies cannot be expressed as recipe_limit and fall back to the global limit
+			ALTER TABLE users ADD COLUMN recipe_limit INTEGER DEFAULT NULL;
+			UPDATE users
+			SET recipe_limit = CASE WHEN text_quota = 'unlimited' THEN -1 ELSE CAST(text_quota AS INTEGER) END
+			WHERE text_quota = 'unlimited' OR (text_quota <> '' AND text_quota NOT GLOB '*[^0-9]*');
+			ALTER TABLE users DROP COLUMN text_quota;
+			ALTER TABLE users DROP COLUMN image_quota;
+
+			CREATE TABLE IF NOT EXISTS image_regenerations (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				recipe_id TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_image_regenerations_user ON image_regenerations(user_id, created_at);
+			INSERT INTO image_regenerations (id, user_id, recipe_id, created_at)
+			SELECT id, user_id, COALESCE(recipe_id, ''), created_at FROM generation_events WHERE kind = 'image';
+			DROP TABLE IF EXISTS generation_events;
+		`,
+	},
+}
+
+// legacyColumn is a column added with ALTER TABLE by the unversioned migration runner
+type legacyColumn struct {
+	table      string
+	column     string
+	definition string
+}
+
+// legacyColumns lists columns that databases created before schema versioning may lack
+// They are added before the initial schema is recorded so the database matches version 1
+var legacyColumns = []legacyColumn{
+	{"users", "is_admin", "INTEGER DEFAULT 0"},
+	{"users", "recipe_limit", "INTEGER DEFAULT NULL"},
+	{"users", "digest_opt_in", "INTEGER DEFAULT 0"},
+	{"users", "digest_unsubscribe_token", "TEXT DEFAULT NULL"},
+	{"users", "digest_last_sent_at", "DATETIME DEFAULT NULL"},
+	{"recipes", "thumbnail_path", "TEXT DEFAULT ''"},
+	{"recipes", "servings", "INTEGER DEFAULT 0"},
+	{"recipes", "allergens", "TEXT DEFAULT '[]'"},
+}
@@ -0,0 +1,378 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrChecksumMismatch is returned when an applied migration was edited afterwards
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	// ErrUnknownMigration is returned when the database has a migration this build does not know
+	ErrUnknownMigration = errors.New("unknown migration applied")
+	// ErrIrreversibleMigration is returned when rolling back a migration without a down script
+	ErrIrreversibleMigration = errors.New("migration cannot be rolled back")
+)
+
+// Migration represents a versioned schema change with up and down scripts
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum returns the SHA-256 of the up script, used to detect edits to applied migrations
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(m.Up)))
+	return hex.EncodeToString(sum[:])
+}
+
+// MigrationStatus represents the state of a migration in the database
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Modified  bool // Applied checksum differs from the current up script
+}
+
+// migrationLockID is the PostgreSQL advisory lock held while migrating, shared by every replica
+const migrationLockID int64 = 0x636865666c79
+
+// appliedMigration is a row of the schema_migrations table
+type appliedMigration struct {
+	version   int
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrator applies and rolls back schema migrations, recording them in schema_migrations
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the built-in migrations of the dialect
+func NewMigrator(db *sql.DB, dialect Dialect) *Migrator {
+	source := sqliteMigrations
+	if dialect == Postgres {
+		source = postgresMigrations
+	}
+
+	sorted := make([]Migration, len(source))
+	copy(sorted, source)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	return &Migrator{db: db, dialect: dialect, migrations: sorted}
+}
+
+// RunMigrations applies all pending migrations
+func RunMigrations(db *sql.DB, dialect Dialect) error {
+	applied, err := NewMigrator(db, dialect).Migrate()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Database migrations completed successfully (%d applied)\n", applied)
+	return nil
+}
+
+// Migrate applies all pending migrations in version order, each in its own transaction
+// Returns the number of migrations applied
+func (m *Migrator) Migrate() (int, error) {
+	conn, unlock, err := m.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if err := m.ensureTable(conn); err != nil {
+		return 0, err
+	}
+
+	applied, err := m.applied(conn)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.verify(applied); err != nil {
+		return 0, err
+	}
+
+	// SQLite databases created before versioning have tables but no recorded migrations
+	if len(applied) == 0 && m.dialect == SQLite {
+		if err := m.upgradeLegacySchema(conn); err != nil {
+			return 0, err
+		}
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.apply(conn, migration); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// Rollback reverts the given number of most recently applied migrations, each in its own transaction
+// Returns the number of migrations rolled back
+func (m *Migrator) Rollback(steps int) (int, error) {
+	conn, unlock, err := m.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if err := m.ensureTable(conn); err != nil {
+		return 0, err
+	}
+
+	applied, err := m.applied(conn)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.verify(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := m.revert(conn, migration); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// Status returns the state of every known migration
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	conn, unlock, err := m.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := m.ensureTable(conn); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.appliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.Modified = row.checksum != migration.Checksum()
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// lock reserves a connection for a migration run and, on PostgreSQL, takes an advisory lock on it
+// Replicas starting together then migrate one after another instead of applying the same migration
+// The returned function releases the lock and the connection
+func (m *Migrator) lock() (*sql.Conn, func(), error) {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to reserve a migration connection: %w", err)
+	}
+	if m.dialect != Postgres {
+		return conn, func() { conn.Close() }, nil
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	return conn, func() {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+			// Closing the session releases the lock as well
+			fmt.Printf("⚠️  Failed to release the migration lock: %v\n", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// ensureTable creates the schema_migrations table
+func (m *Migrator) ensureTable(conn *sql.Conn) error {
+	_, err := conn.ExecContext(context.Background(), `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// applied returns the recorded migrations keyed by version
+func (m *Migrator) applied(conn *sql.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var row appliedMigration
+		if err := rows.Scan(&row.version, &row.name, &row.checksum, &row.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[row.version] = row
+	}
+
+	return applied, rows.Err()
+}
+
+// verify checks applied migrations against the known ones
+func (m *Migrator) verify(applied map[int]appliedMigration) error {
+	known := make(map[int]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	for version, row := range applied {
+		migration, ok := known[version]
+		if !ok {
+			return fmt.Errorf("%w: version %d (%s)", ErrUnknownMigration, version, row.name)
+		}
+		if row.checksum != migration.Checksum() {
+			return fmt.Errorf("%w: version %d (%s) was modified after being applied", ErrChecksumMismatch, version, migration.Name)
+		}
+	}
+
+	return nil
+}
+
+// apply runs a migration's up script and records it in one transaction
+func (m *Migrator) apply(conn *sql.Conn, migration Migration) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migration.Up); err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.Exec(
+		m.dialect.Rebind("INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)"),
+		migration.Version, migration.Name, migration.Checksum(),
+	); err != nil {
+		return fmt.Errorf("migration %d (%s) failed to record: %w", migration.Version, migration.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %d (%s) failed to commit: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// revert runs a migration's down script and removes its record in one transaction
+func (m *Migrator) revert(conn *sql.Conn, migration Migration) error {
+	if strings.TrimSpace(migration.Down) == "" {
+		return fmt.Errorf("%w: version %d (%s) has no down script", ErrIrreversibleMigration, migration.Version, migration.Name)
+	}
+
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("rollback of migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migration.Down); err != nil {
+		return fmt.Errorf("rollback of migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.Exec(m.dialect.Rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version); err != nil {
+		return fmt.Errorf("rollback of migration %d (%s) failed to record: %w", migration.Version, migration.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("rollback of migration %d (%s) failed to commit: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// upgradeLegacySchema adds columns missing from databases created by the unversioned migration runner
+// so the initial schema migration can be recorded on top of them
+func (m *Migrator) upgradeLegacySchema(conn *sql.Conn) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("legacy schema upgrade failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, legacy := range legacyColumns {
+		columns, err := tableColumns(tx, legacy.table)
+		if err != nil {
+			return fmt.Errorf("legacy schema upgrade failed: %w", err)
+		}
+		// Table does not exist yet (fresh database) or already has the column
+		if len(columns) == 0 || columns[legacy.column] {
+			continue
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", legacy.table, legacy.column, legacy.definition)); err != nil {
+			return fmt.Errorf("legacy schema upgrade failed for %s.%s: %w", legacy.table, legacy.column, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// tableColumns returns the column names of a table (empty if the table does not exist)
+func tableColumns(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
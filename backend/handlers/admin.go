@@ -1,55 +1,50 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"chefly/models"
 	"chefly/services"
+	"chefly/store"
 
 	"github.com/gin-gonic/gin"
 )
 
 // AdminHandler handles admin operations
 type AdminHandler struct {
-	db           *sql.DB
+	users        store.UserStore
+	recipes      store.RecipeStore
+	photos       store.PhotoStore
+	shoppingList store.ShoppingListStore
 	imageCleanup *services.ImageCleanupService
+	backup       *services.BackupService
+	imageGC      *services.ImageGCService
+	migrator     *services.UnmanagedImageMigrator
+	aiProviders  []*services.AIResilience
+	aiUsage      store.AIUsageStore
+	quotas       *services.QuotaService
 }
 
 // NewAdminHandler creates a new admin handler
-func NewAdminHandler(db *sql.DB, auditLogger *services.AuditLogger) *AdminHandler {
+func NewAdminHandler(users store.UserStore, recipes store.RecipeStore, photos store.PhotoStore, shoppingList store.ShoppingListStore, imageStore services.ImageStore, backup *services.BackupService, imageGC *services.ImageGCService, migrator *services.UnmanagedImageMigrator, aiProviders []*services.AIResilience, aiUsage store.AIUsageStore, quotas *services.QuotaService, auditLogger *services.AuditLogger) *AdminHandler {
 	return &AdminHandler{
-		db:           db,
-		imageCleanup: services.NewImageCleanupService("./uploads", auditLogger),
+		users:        users,
+		recipes:      recipes,
+		photos:       photos,
+		shoppingList: shoppingList,
+		imageCleanup: services.NewImageCleanupService(imageStore, auditLogger),
+		backup:       backup,
+		imageGC:      imageGC,
+		migrator:     migrator,
+		aiProviders:  aiProviders,
+		aiUsage:      aiUsage,
+		quotas:       quotas,
 	}
 }
 
-// UserWithStats represents a user with additional statistics
-type UserWithStats struct {
-	ID              string    `json:"id"`
-	Email           string    `json:"email"`
-	Username        string    `json:"username"`
-	IsAdmin         bool      `json:"is_admin"`
-	CreatedAt       time.Time `json:"created_at"`
-	RecipeCount     int       `json:"recipe_count"`
-	ShoppingItems   int       `json:"shopping_items"`
-	LastRecipeDate  *string   `json:"last_recipe_date"`
-	RecipeLimit     *int      `json:"recipe_limit,omitempty"` // null = use global, -1 = unlimited, 0 = blocked, >0 = custom
-}
-
-// AdminStats represents admin dashboard statistics
-type AdminStats struct {
-	TotalUsers          int       `json:"total_users"`
-	TotalRecipes        int       `json:"total_recipes"`
-	TotalShoppingItems  int       `json:"total_shopping_items"`
-	AverageRecipesUser  float64   `json:"average_recipes_per_user"`
-	MostActiveUser      *string   `json:"most_active_user"`
-	MostActiveUserCount int       `json:"most_active_user_count"`
-	RecentRegistrations int       `json:"recent_registrations"`
-	FirstUserDate       time.Time `json:"first_user_date"`
-}
-
 // GetAllUsers returns all users with their statistics
 func (h *AdminHandler) GetAllUsers(c *gin.Context) {
 	// Get audit logger from context
@@ -59,64 +54,11 @@ func (h *AdminHandler) GetAllUsers(c *gin.Context) {
 	adminID := c.GetString("user_id")
 
 	// Query all users with recipe count, shopping items, last recipe date, and recipe limit
-	query := `
-		SELECT
-			u.id,
-			u.email,
-			u.username,
-			u.is_admin,
-			u.created_at,
-			u.recipe_limit,
-			COALESCE(COUNT(DISTINCT r.id), 0) as recipe_count,
-			COALESCE(COUNT(DISTINCT s.id), 0) as shopping_items,
-			MAX(r.created_at) as last_recipe_date
-		FROM users u
-		LEFT JOIN recipes r ON u.id = r.user_id
-		LEFT JOIN shopping_list_items s ON u.id = s.user_id
-		GROUP BY u.id, u.email, u.username, u.is_admin, u.created_at, u.recipe_limit
-		ORDER BY u.created_at ASC
-	`
-
-	rows, err := h.db.Query(query)
+	users, err := h.users.ListWithStats()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
-	defer rows.Close()
-
-	users := []UserWithStats{}
-	for rows.Next() {
-		var user UserWithStats
-		var isAdminInt int
-		var lastRecipeDate sql.NullString
-		var recipeLimit sql.NullInt64
-
-		err := rows.Scan(
-			&user.ID,
-			&user.Email,
-			&user.Username,
-			&isAdminInt,
-			&user.CreatedAt,
-			&recipeLimit,
-			&user.RecipeCount,
-			&user.ShoppingItems,
-			&lastRecipeDate,
-		)
-		if err != nil {
-			continue
-		}
-
-		user.IsAdmin = isAdminInt == 1
-		if lastRecipeDate.Valid {
-			user.LastRecipeDate = &lastRecipeDate.String
-		}
-		if recipeLimit.Valid {
-			limit := int(recipeLimit.Int64)
-			user.RecipeLimit = &limit
-		}
-
-		users = append(users, user)
-	}
 
 	// Log admin viewing user list
 	if logger != nil {
@@ -149,56 +91,37 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	// Get user info before deleting for audit log
-	var username, email string
-	var recipeCount, shoppingCount int
-	h.db.QueryRow("SELECT username, email FROM users WHERE id = ?", userID).Scan(&username, &email)
-	h.db.QueryRow("SELECT COUNT(*) FROM recipes WHERE user_id = ?", userID).Scan(&recipeCount)
-	h.db.QueryRow("SELECT COUNT(*) FROM shopping_list_items WHERE user_id = ?", userID).Scan(&shoppingCount)
-
-	// Get all user's recipe images before deletion (for cleanup)
-	rows, _ := h.db.Query("SELECT id, image_path, thumbnail_path FROM recipes WHERE user_id = ?", userID)
-	var recipeImages []struct{ ImagePath, ThumbnailPath, RecipeID string }
-	if rows != nil {
-		defer rows.Close()
-		for rows.Next() {
-			var recipeID, imgPath, thumbPath string
-			rows.Scan(&recipeID, &imgPath, &thumbPath)
-			recipeImages = append(recipeImages, struct{ ImagePath, ThumbnailPath, RecipeID string }{
-				ImagePath:     imgPath,
-				ThumbnailPath: thumbPath,
-				RecipeID:      recipeID,
-			})
-		}
+	// Check if user exists and get user info before deleting for audit log
+	user, err := h.users.GetByID(userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
 	}
-
-	// Check if user exists
-	var exists bool
-	err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", userID).Scan(&exists)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
-	if !exists {
+	recipeCount, _ := h.recipes.CountByUser(userID)
+	shoppingCount, _ := h.shoppingList.CountByUser(userID)
+
+	// Get all user's recipe images and photos before deletion (for cleanup)
+	recipeImages, _ := h.recipes.ListImages(userID)
+	photoImages, _ := h.photos.ListImages(userID)
+	recipeImages = append(recipeImages, photoImages...)
+
+	// Delete user (CASCADE will handle recipes and shopping items)
+	err = h.users.Delete(userID)
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
-
-	// Delete user (CASCADE will handle recipes and shopping items)
-	result, err := h.db.Exec("DELETE FROM users WHERE id = ?", userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
 	// Delete all user's image files after successful database deletion
-	if rowsAffected > 0 && len(recipeImages) > 0 {
+	if len(recipeImages) > 0 {
 		h.imageCleanup.DeleteUserImages(recipeImages, userID, requestID)
 	}
 
@@ -210,8 +133,8 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 			IPAddress: c.ClientIP(),
 			Metadata: map[string]interface{}{
 				"deleted_user_id":       userID,
-				"deleted_username":      username,
-				"deleted_email":         email,
+				"deleted_username":       user.Username,
+				"deleted_email":          user.Email,
 				"deleted_recipe_count":  recipeCount,
 				"deleted_shopping_count": shoppingCount,
 			},
@@ -229,60 +152,17 @@ func (h *AdminHandler) GetAdminStats(c *gin.Context) {
 	requestID := c.GetString("request_id")
 	adminID := c.GetString("user_id")
 
-	var stats AdminStats
-
-	// Total users
-	err := h.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&stats.TotalUsers)
+	// Recent registrations cover the last 7 days
+	weekAgo := time.Now().AddDate(0, 0, -7)
+	stats, err := h.users.AdminStats(weekAgo)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats"})
 		return
 	}
 
-	// Total recipes
-	err = h.db.QueryRow("SELECT COUNT(*) FROM recipes").Scan(&stats.TotalRecipes)
-	if err != nil {
-		stats.TotalRecipes = 0
-	}
-
-	// Total shopping items
-	err = h.db.QueryRow("SELECT COUNT(*) FROM shopping_list_items").Scan(&stats.TotalShoppingItems)
-	if err != nil {
-		stats.TotalShoppingItems = 0
-	}
-
-	// Average recipes per user
-	if stats.TotalUsers > 0 {
-		stats.AverageRecipesUser = float64(stats.TotalRecipes) / float64(stats.TotalUsers)
-	}
-
-	// Most active user (user with most recipes)
-	var mostActiveUser sql.NullString
-	err = h.db.QueryRow(`
-		SELECT u.username, COUNT(r.id) as recipe_count
-		FROM users u
-		LEFT JOIN recipes r ON u.id = r.user_id
-		GROUP BY u.id, u.username
-		ORDER BY recipe_count DESC
-		LIMIT 1
-	`).Scan(&mostActiveUser, &stats.MostActiveUserCount)
-	if err == nil && mostActiveUser.Valid {
-		stats.MostActiveUser = &mostActiveUser.String
-	}
-
-	// Recent registrations (last 7 days)
-	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
-	err = h.db.QueryRow(
-		"SELECT COUNT(*) FROM users WHERE created_at >= ?",
-		sevenDaysAgo.Format("2006-01-02 15:04:05"),
-	).Scan(&stats.RecentRegistrations)
-	if err != nil {
-		stats.RecentRegistrations = 0
-	}
-
-	// First user registration date
-	err = h.db.QueryRow("SELECT MIN(created_at) FROM users").Scan(&stats.FirstUserDate)
-	if err != nil {
-		stats.FirstUserDate = time.Now()
+	// AI usage of the same 7 days, the dashboard still works without it
+	if aiUsage, err := h.aiUsage.Totals(weekAgo); err == nil {
+		stats.AIUsage = aiUsage
 	}
 
 	// Log admin viewing stats
@@ -297,8 +177,8 @@ func (h *AdminHandler) GetAdminStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
-// UpdateUserRecipeLimit updates a user's recipe generation limit
-func (h *AdminHandler) UpdateUserRecipeLimit(c *gin.Context) {
+// UpdateUserQuota sets or clears a user's personal quota policy for recipe (text) or image generations
+func (h *AdminHandler) UpdateUserQuota(c *gin.Context) {
 	userID := c.Param("id")
 	adminID := c.GetString("user_id")
 
@@ -308,92 +188,295 @@ func (h *AdminHandler) UpdateUserRecipeLimit(c *gin.Context) {
 	requestID := c.GetString("request_id")
 
 	var req struct {
-		RecipeLimit *int `json:"recipe_limit"`
+		Kind   string  `json:"kind" binding:"required,oneof=text image"`
+		Policy *string `json:"policy"` // null = use the global policy
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
 		return
 	}
 
-	// Validate recipe_limit value if not null
-	if req.RecipeLimit != nil {
-		limit := *req.RecipeLimit
-		// Must be: null (use global), -1 (unlimited), 0 (blocked), or positive number
-		if limit < -1 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipe limit value. Must be null, -1 (unlimited), 0 (blocked), or positive number"})
+	// Validate and normalize the policy if not null
+	var policy *string
+	if req.Policy != nil {
+		parsed, err := h.quotas.ParsePolicy(req.Kind, *req.Policy)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quota policy: " + err.Error()})
 			return
 		}
+		normalized := parsed.String()
+		policy = &normalized
 	}
 
-	// Check if user exists
-	var exists bool
-	err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", userID).Scan(&exists)
+	// Check if user exists and get user info before update for audit log
+	user, err := h.users.GetByID(userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
-	if !exists {
+
+	err = h.users.SetQuotaPolicy(userID, req.Kind, policy)
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quota"})
+		return
+	}
+
+	// Log quota update
+	if logger != nil {
+		oldPolicy := user.TextQuota
+		if req.Kind == models.QuotaImage {
+			oldPolicy = user.ImageQuota
+		}
 
-	// Get user info before update for audit log
-	var username, email string
-	var oldLimit sql.NullInt64
-	h.db.QueryRow("SELECT username, email, recipe_limit FROM users WHERE id = ?", userID).Scan(&username, &email, &oldLimit)
+		metadata := map[string]interface{}{
+			"target_user_id":  userID,
+			"target_username": user.Username,
+			"target_email":    user.Email,
+			"kind":            req.Kind,
+			"old_policy":      "global",
+			"new_policy":      "global",
+		}
+		if oldPolicy != nil {
+			metadata["old_policy"] = *oldPolicy
+		}
+		if policy != nil {
+			metadata["new_policy"] = *policy
+		}
 
-	// Update user's recipe_limit
-	var result sql.Result
-	if req.RecipeLimit == nil {
-		// Set to NULL (use global limit)
-		result, err = h.db.Exec("UPDATE users SET recipe_limit = NULL WHERE id = ?", userID)
-	} else {
-		// Set to specific value
-		result, err = h.db.Exec("UPDATE users SET recipe_limit = ? WHERE id = ?", *req.RecipeLimit, userID)
+		logger.Info("admin.user_quota_update", "Admin updated user quota", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    adminID,
+			IPAddress: c.ClientIP(),
+			Metadata:  metadata,
+		})
 	}
 
+	// The user's allowance under the new policy
+	quota, err := h.quotas.Status(userID, req.Kind)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recipe limit"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quota"})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Quota updated successfully",
+		"user_id": userID,
+		"kind":    req.Kind,
+		"policy":  policy,
+		"quota":   quota,
+	})
+}
+
+// CreateBackup creates a backup archive of the database and images and returns it as a download
+// The archive is also kept in the backup directory on the server
+func (h *AdminHandler) CreateBackup(c *gin.Context) {
+	adminID := c.GetString("user_id")
+
+	// Get audit logger from context
+	auditLogger, _ := c.Get("audit_logger")
+	logger, _ := auditLogger.(*services.AuditLogger)
+	requestID := c.GetString("request_id")
+
+	startedAt := time.Now()
+	backup, err := h.backup.Create()
+	if errors.Is(err, services.ErrBackupUnsupported) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Backups are only supported for SQLite, use pg_dump for PostgreSQL"})
+		return
+	}
+	if err != nil {
+		if logger != nil {
+			logger.Error("admin.backup.failed", "Failed to create backup", err, &models.AuditContext{
+				RequestID: requestID,
+				UserID:    adminID,
+				IPAddress: c.ClientIP(),
+			})
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create backup"})
 		return
 	}
 
-	// Log recipe limit update
+	// Log backup creation
 	if logger != nil {
-		metadata := map[string]interface{}{
-			"target_user_id": userID,
-			"target_username": username,
-			"target_email":    email,
+		logger.Info("admin.backup.created", "Admin created a backup", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    adminID,
+			IPAddress: c.ClientIP(),
+			Metadata: map[string]interface{}{
+				"backup_name":    backup.Name,
+				"size_bytes":     backup.SizeBytes,
+				"image_count":    backup.Manifest.ImageCount,
+				"schema_version": backup.Manifest.SchemaVersion,
+				"duration_ms":    time.Since(startedAt).Milliseconds(),
+			},
+		})
+	}
+
+	c.FileAttachment(backup.Path, backup.Name)
+}
+
+// ListOrphanedImages reports stored images no recipe refers to, without removing anything (dry run)
+func (h *AdminHandler) ListOrphanedImages(c *gin.Context) {
+	h.runImageGC(c, true)
+}
+
+// CollectOrphanedImages deletes (or quarantines) orphaned images older than the grace period
+func (h *AdminHandler) CollectOrphanedImages(c *gin.Context) {
+	h.runImageGC(c, false)
+}
+
+// runImageGC runs the image garbage collector and responds with its report
+func (h *AdminHandler) runImageGC(c *gin.Context, dryRun bool) {
+	adminID := c.GetString("user_id")
+
+	// Get audit logger from context
+	auditLogger, _ := c.Get("audit_logger")
+	logger, _ := auditLogger.(*services.AuditLogger)
+	requestID := c.GetString("request_id")
+
+	report, err := h.imageGC.Run(dryRun)
+	if err != nil {
+		if logger != nil {
+			logger.Error("admin.images.gc_failed", "Failed to collect orphaned images", err, &models.AuditContext{
+				RequestID: requestID,
+				UserID:    adminID,
+				IPAddress: c.ClientIP(),
+				Metadata: map[string]interface{}{
+					"dry_run": dryRun,
+				},
+			})
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to collect orphaned images"})
+		return
+	}
+
+	// Dry runs are read-only, only removals are audited
+	if logger != nil && !dryRun {
+		logger.Info("admin.images.gc", "Admin collected orphaned images", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    adminID,
+			IPAddress: c.ClientIP(),
+			Metadata: map[string]interface{}{
+				"orphan_count":  len(report.Orphans),
+				"orphan_bytes":  report.OrphanBytes,
+				"removed_count": report.RemovedCount,
+				"failed_keys":   report.FailedKeys,
+				"quarantine":    report.Quarantine,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// StartImageMigration starts moving data: URL and external recipe images into the image store
+func (h *AdminHandler) StartImageMigration(c *gin.Context) {
+	adminID := c.GetString("user_id")
+
+	// Get audit logger from context
+	auditLogger, _ := c.Get("audit_logger")
+	logger, _ := auditLogger.(*services.AuditLogger)
+	requestID := c.GetString("request_id")
+
+	if err := h.migrator.Start(); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Image migration is already running"})
+		return
+	}
+
+	// Log migration start
+	if logger != nil {
+		logger.Info("admin.images.migration_started", "Admin started image migration", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    adminID,
+			IPAddress: c.ClientIP(),
+		})
+	}
+
+	c.JSON(http.StatusAccepted, h.migrator.Progress())
+}
 
-		if oldLimit.Valid {
-			metadata["old_limit"] = oldLimit.Int64
-		} else {
-			metadata["old_limit"] = "global"
+// GetImageMigrationProgress returns the progress of the current or last image migration
+func (h *AdminHandler) GetImageMigrationProgress(c *gin.Context) {
+	c.JSON(http.StatusOK, h.migrator.Progress())
+}
+
+// GetAIMetrics returns retry and circuit breaker counters of the AI providers since startup
+func (h *AdminHandler) GetAIMetrics(c *gin.Context) {
+	providers := make([]models.AIProviderMetrics, 0, len(h.aiProviders))
+	for _, provider := range h.aiProviders {
+		providers = append(providers, provider.Metrics())
+	}
+	c.JSON(http.StatusOK, gin.H{"providers": providers})
+}
+
+// aiUsageTopUsers is how many users the AI usage report lists
+const aiUsageTopUsers = 50
+
+// GetAIUsage returns AI token usage and estimated cost per day, user and model
+// Query parameter days selects the period (default 30, at most 365)
+func (h *AdminHandler) GetAIUsage(c *gin.Context) {
+	// Get audit logger from context
+	auditLogger, _ := c.Get("audit_logger")
+	logger, _ := auditLogger.(*services.AuditLogger)
+	requestID := c.GetString("request_id")
+	adminID := c.GetString("user_id")
+
+	days := 30
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 365 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+			return
 		}
+		days = parsed
+	}
+
+	// Whole UTC days, today included
+	now := time.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1-days)
 
-		if req.RecipeLimit == nil {
-			metadata["new_limit"] = "global"
-		} else {
-			metadata["new_limit"] = *req.RecipeLimit
+	report := models.AIUsageReport{Since: since}
+	totals, err := h.aiUsage.Totals(since)
+	if err == nil {
+		report.Totals = *totals
+		report.ByDay, err = h.aiUsage.ByDay(since)
+	}
+	if err == nil {
+		report.ByUser, err = h.aiUsage.ByUser(since, aiUsageTopUsers)
+	}
+	if err == nil {
+		report.ByModel, err = h.aiUsage.ByModel(since)
+	}
+	if err != nil {
+		if logger != nil {
+			logger.Error("admin.ai_usage_failed", "Failed to fetch AI usage", err, &models.AuditContext{
+				RequestID: requestID,
+				UserID:    adminID,
+				IPAddress: c.ClientIP(),
+			})
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch AI usage"})
+		return
+	}
 
-		logger.Info("admin.user_recipe_limit_update", "Admin updated user recipe limit", &models.AuditContext{
+	// Log admin viewing AI usage
+	if logger != nil {
+		logger.Info("admin.ai_usage_view", "Admin viewed AI usage", &models.AuditContext{
 			RequestID: requestID,
 			UserID:    adminID,
 			IPAddress: c.ClientIP(),
-			Metadata:  metadata,
+			Metadata: map[string]interface{}{
+				"days": days,
+			},
 		})
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Recipe limit updated successfully",
-		"user_id": userID,
-		"recipe_limit": req.RecipeLimit,
-	})
+	c.JSON(http.StatusOK, report)
 }
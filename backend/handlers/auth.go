@@ -1,12 +1,13 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
 	"chefly/models"
 	"chefly/services"
+	"chefly/store"
 	"chefly/utils"
 
 	"github.com/gin-gonic/gin"
@@ -15,15 +16,19 @@ import (
 
 // AuthHandler handles authentication operations
 type AuthHandler struct {
-	db                  *sql.DB
+	users               store.UserStore
+	recipes             store.RecipeStore
+	tokens              store.TokenStore
 	jwtSecret           string
 	registrationEnabled bool
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(db *sql.DB, jwtSecret string, registrationEnabled bool) *AuthHandler {
+func NewAuthHandler(users store.UserStore, recipes store.RecipeStore, tokens store.TokenStore, jwtSecret string, registrationEnabled bool) *AuthHandler {
 	return &AuthHandler{
-		db:                  db,
+		users:               users,
+		recipes:             recipes,
+		tokens:              tokens,
 		jwtSecret:           jwtSecret,
 		registrationEnabled: registrationEnabled,
 	}
@@ -70,8 +75,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	requestID := c.GetString("request_id")
 
 	// Check if user already exists
-	var exists bool
-	err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)", req.Email).Scan(&exists)
+	exists, err := h.users.EmailExists(req.Email)
 	if err != nil {
 		if logger != nil {
 			logger.Error("auth.register.failed", "Registration failed: database error", err, &models.AuditContext{
@@ -106,38 +110,19 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Check if this is the first user (will be admin)
-	var userCount int
-	err = h.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&userCount)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
-	}
-	isAdmin := userCount == 0 // First user becomes admin
-
-	// Create user
+	// Create user (first user becomes admin)
 	userID := uuid.New().String()
-	_, err = h.db.Exec(
-		"INSERT INTO users (id, email, password_hash, username, is_admin) VALUES (?, ?, ?, ?, ?)",
-		userID, req.Email, passwordHash, req.Username, isAdmin,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-		return
+	user := models.User{
+		ID:           userID,
+		Email:        req.Email,
+		PasswordHash: passwordHash,
+		Username:     req.Username,
 	}
-
-	// Fetch created user
-	var user models.User
-	var isAdminInt int
-	err = h.db.QueryRow(
-		"SELECT id, email, username, is_admin, created_at FROM users WHERE id = ?",
-		userID,
-	).Scan(&user.ID, &user.Email, &user.Username, &isAdminInt, &user.CreatedAt)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+	if err := h.users.Create(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
-	user.IsAdmin = isAdminInt == 1
+	isAdmin := user.IsAdmin
 
 	// Generate access token (15 minutes)
 	accessToken, err := utils.GenerateJWT(userID, req.Email, user.Username, user.IsAdmin, h.jwtSecret)
@@ -188,13 +173,8 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	requestID := c.GetString("request_id")
 
 	// Fetch user
-	var user models.User
-	var isAdminInt int
-	err := h.db.QueryRow(
-		"SELECT id, email, password_hash, username, is_admin, created_at FROM users WHERE email = ?",
-		req.Email,
-	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Username, &isAdminInt, &user.CreatedAt)
-	if err == sql.ErrNoRows {
+	user, err := h.users.GetByEmail(req.Email)
+	if errors.Is(err, store.ErrNotFound) {
 		// Log failed login attempt for non-registered user
 		if logger != nil {
 			logger.Warn("auth.login.failed", "Login failed: user not found", &models.AuditContext{
@@ -221,7 +201,6 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
-	user.IsAdmin = isAdminInt == 1
 
 	// Check password
 	if !utils.CheckPassword(req.Password, user.PasswordHash) {
@@ -277,17 +256,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 func (h *AuthHandler) GetProfile(c *gin.Context) {
 	userID := c.GetString("user_id")
 
-	var user models.User
-	var isAdminInt int
-	err := h.db.QueryRow(
-		"SELECT id, email, username, is_admin, created_at FROM users WHERE id = ?",
-		userID,
-	).Scan(&user.ID, &user.Email, &user.Username, &isAdminInt, &user.CreatedAt)
+	user, err := h.users.GetByID(userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
-	user.IsAdmin = isAdminInt == 1
 
 	c.JSON(http.StatusOK, user.ToResponse())
 }
@@ -313,11 +286,7 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	// Sanitize username
 	req.Username = utils.SanitizeHTML(req.Username)
 
-	_, err := h.db.Exec(
-		"UPDATE users SET username = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
-		req.Username, userID,
-	)
-	if err != nil {
+	if err := h.users.UpdateUsername(userID, req.Username); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
 		return
 	}
@@ -329,20 +298,16 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 func (h *AuthHandler) GetStats(c *gin.Context) {
 	userID := c.GetString("user_id")
 
-	var totalRecipes int
-	var favoriteRecipes sql.NullInt64
-	err := h.db.QueryRow(
-		"SELECT COUNT(*), COALESCE(SUM(CASE WHEN is_favorite = 1 THEN 1 ELSE 0 END), 0) FROM recipes WHERE user_id = ?",
-		userID,
-	).Scan(&totalRecipes, &favoriteRecipes)
+	totalRecipes, err := h.recipes.CountByUser(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats"})
 		return
 	}
 
-	favCount := int64(0)
-	if favoriteRecipes.Valid {
-		favCount = favoriteRecipes.Int64
+	favCount, err := h.recipes.CountFavorites(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -367,23 +332,8 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	requestID := c.GetString("request_id")
 
 	// Validate refresh token
-	var tokenRecord models.RefreshToken
-	var revokedInt int
-	err := h.db.QueryRow(`
-		SELECT id, user_id, token, expires_at, created_at, revoked
-		FROM refresh_tokens
-		WHERE token = ?
-	`, req.RefreshToken).Scan(
-		&tokenRecord.ID,
-		&tokenRecord.UserID,
-		&tokenRecord.Token,
-		&tokenRecord.ExpiresAt,
-		&tokenRecord.CreatedAt,
-		&revokedInt,
-	)
-	tokenRecord.Revoked = revokedInt == 1
-
-	if err == sql.ErrNoRows {
+	tokenRecord, err := h.tokens.Get(req.RefreshToken)
+	if errors.Is(err, store.ErrNotFound) {
 		if logger != nil {
 			logger.Warn("auth.refresh.invalid_token", "Refresh token not found", &models.AuditContext{
 				RequestID: requestID,
@@ -425,25 +375,17 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	// Revoke old refresh token (rotating tokens)
-	_, err = h.db.Exec("UPDATE refresh_tokens SET revoked = 1 WHERE id = ?", tokenRecord.ID)
-	if err != nil {
+	if err := h.tokens.Revoke(tokenRecord.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke old token"})
 		return
 	}
 
 	// Get user details
-	var user models.User
-	var isAdminInt int
-	err = h.db.QueryRow(`
-		SELECT id, email, username, is_admin
-		FROM users
-		WHERE id = ?
-	`, tokenRecord.UserID).Scan(&user.ID, &user.Email, &user.Username, &isAdminInt)
+	user, err := h.users.GetByID(tokenRecord.UserID)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
 	}
-	user.IsAdmin = isAdminInt == 1
 
 	// Generate new access token
 	accessToken, err := utils.GenerateJWT(user.ID, user.Email, user.Username, user.IsAdmin, h.jwtSecret)
@@ -493,14 +435,13 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	userID := c.GetString("user_id")
 
 	// Revoke refresh token
-	result, err := h.db.Exec("UPDATE refresh_tokens SET revoked = 1 WHERE token = ?", req.RefreshToken)
+	revoked, err := h.tokens.RevokeByToken(req.RefreshToken)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	if !revoked {
 		// Token not found, but that's OK - user is logging out anyway
 		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 		return
@@ -525,14 +466,12 @@ func (h *AuthHandler) createRefreshToken(userID string) (string, error) {
 		return "", err
 	}
 
-	tokenID := uuid.New().String()
-	expiresAt := time.Now().Add(7 * 24 * time.Hour) // 7 days
-
-	_, err = h.db.Exec(`
-		INSERT INTO refresh_tokens (id, user_id, token, expires_at)
-		VALUES (?, ?, ?, ?)
-	`, tokenID, userID, token, expiresAt)
-
+	err = h.tokens.Create(&models.RefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Token:     token,
+		ExpiresAt: time.Now().Add(7 * 24 * time.Hour), // 7 days
+	})
 	if err != nil {
 		return "", err
 	}
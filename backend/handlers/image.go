@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"chefly/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// imageCacheControl lets clients cache images forever, image keys are never reused for different content
+const imageCacheControl = "public, max-age=31536000, immutable"
+
+// ImageHandler serves recipe images from the image store
+type ImageHandler struct {
+	images services.ImageStore
+}
+
+// NewImageHandler creates a new image handler
+func NewImageHandler(images services.ImageStore) *ImageHandler {
+	return &ImageHandler{images: images}
+}
+
+// ServeImage serves an image below /uploads
+// Stores handing out direct URLs (S3 with pre-signing) redirect there, others stream the image through Chefly
+// JPEG variants are swapped for their WebP sibling when the Accept header allows it
+func (h *ImageHandler) ServeImage(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	candidates := []string{key}
+	if alternative, negotiable := services.NegotiatedImageKey(key, c.GetHeader("Accept")); negotiable {
+		c.Header("Vary", "Accept")
+		if alternative != "" {
+			candidates = []string{alternative, key}
+		}
+	}
+
+	for i, candidate := range candidates {
+		fallback := i < len(candidates)-1
+
+		directURL, err := h.images.URL(candidate)
+		if errors.Is(err, services.ErrInvalidImageKey) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load image"})
+			return
+		}
+		if directURL != "" {
+			// Pre-signing does not check existence, only redirect to an alternative format that exists
+			if fallback {
+				if exists, err := h.images.Exists(candidate); err != nil || !exists {
+					continue
+				}
+			}
+			c.Redirect(http.StatusFound, directURL)
+			return
+		}
+
+		reader, image, err := h.images.Get(candidate)
+		if errors.Is(err, services.ErrImageNotFound) && fallback {
+			continue
+		}
+		if errors.Is(err, services.ErrImageNotFound) || errors.Is(err, services.ErrInvalidImageKey) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load image"})
+			return
+		}
+		defer reader.Close()
+
+		c.Header("Cache-Control", imageCacheControl)
+		c.Header("Content-Type", image.ContentType)
+
+		// Seekable images (local files, S3 objects) support conditional and range requests
+		if seeker, ok := reader.(io.ReadSeeker); ok {
+			http.ServeContent(c.Writer, c.Request, image.Key, image.ModTime, seeker)
+			return
+		}
+
+		c.DataFromReader(http.StatusOK, image.Size, image.ContentType, reader, map[string]string{
+			"Last-Modified": image.ModTime.UTC().Format(http.TimeFormat),
+		})
+		return
+	}
+}
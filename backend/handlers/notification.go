@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"chefly/models"
+	"chefly/services"
+	"chefly/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationHandler handles notification subscription settings
+type NotificationHandler struct {
+	users store.UserStore
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(users store.UserStore) *NotificationHandler {
+	return &NotificationHandler{users: users}
+}
+
+// GetDigestSettings returns the user's weekly digest subscription
+func (h *NotificationHandler) GetDigestSettings(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	settings, err := h.users.GetDigestSettings(userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch digest settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateDigestSettings opts the user in or out of the weekly digest
+func (h *NotificationHandler) UpdateDigestSettings(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	// Get audit logger from context
+	auditLogger, _ := c.Get("audit_logger")
+	logger, _ := auditLogger.(*services.AuditLogger)
+	requestID := c.GetString("request_id")
+
+	var req struct {
+		OptIn *bool `json:"opt_in" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	// Issue a fresh unsubscribe token on every opt-in so old links stop working
+	var token string
+	if *req.OptIn {
+		var err error
+		token, err = services.NewUnsubscribeToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update digest settings"})
+			return
+		}
+	}
+	if err := h.users.SetDigestOptIn(userID, *req.OptIn, token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update digest settings"})
+		return
+	}
+
+	// Log subscription change
+	if logger != nil {
+		logger.Info("digest.settings_update", "Digest subscription updated", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    userID,
+			IPAddress: c.ClientIP(),
+			Metadata: map[string]interface{}{
+				"opt_in": *req.OptIn,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Digest settings updated",
+		"opt_in":  *req.OptIn,
+	})
+}
+
+// unsubscribePage is the page of digest unsubscribe links
+// The form posts back to the link itself, so the token never has to be rendered
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Chefly weekly digest</title>
+</head>
+<body style="font-family: sans-serif; max-width: 32rem; margin: 4rem auto; padding: 0 1rem; text-align: center;">
+<h1>Chefly weekly digest</h1>
+<p>{{.Message}}</p>
+{{if .Confirm}}<form method="post">
+<button type="submit" style="padding: 0.5rem 1.5rem; font-size: 1rem;">Unsubscribe</button>
+</form>{{end}}
+</body>
+</html>
+`))
+
+// renderUnsubscribePage responds with the unsubscribe page
+func renderUnsubscribePage(c *gin.Context, status int, message string, confirm bool) {
+	c.Header("Cache-Control", "no-store")
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	_ = unsubscribePage.Execute(c.Writer, gin.H{"Message": message, "Confirm": confirm})
+}
+
+// ConfirmUnsubscribe shows the page of a digest unsubscribe link with a button to confirm (no auth required)
+// It never changes the subscription, mail link scanners and prefetchers open links with GET
+func (h *NotificationHandler) ConfirmUnsubscribe(c *gin.Context) {
+	token := c.Param("token")
+	if token == "" {
+		renderUnsubscribePage(c, http.StatusBadRequest, "This unsubscribe link is invalid.", false)
+		return
+	}
+
+	_, err := h.users.FindByUnsubscribeToken(token)
+	if errors.Is(err, store.ErrNotFound) {
+		renderUnsubscribePage(c, http.StatusNotFound, "This unsubscribe link is invalid or has expired.", false)
+		return
+	}
+	if err != nil {
+		renderUnsubscribePage(c, http.StatusInternalServerError, "Something went wrong, please try again later.", false)
+		return
+	}
+
+	renderUnsubscribePage(c, http.StatusOK, "Do you want to stop receiving the weekly digest?", true)
+}
+
+// Unsubscribe opts a user out of the digest using the token from the email link (no auth required)
+// Called by the confirmation page and by mail clients supporting RFC 8058 one-click unsubscribe,
+// browsers get a page and other clients JSON
+func (h *NotificationHandler) Unsubscribe(c *gin.Context) {
+	token := c.Param("token")
+
+	// Get audit logger from context
+	auditLogger, _ := c.Get("audit_logger")
+	logger, _ := auditLogger.(*services.AuditLogger)
+	requestID := c.GetString("request_id")
+
+	respond := func(status int, message string) {
+		if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+			renderUnsubscribePage(c, status, message, false)
+			return
+		}
+		if status >= http.StatusBadRequest {
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+		c.JSON(status, gin.H{"message": message})
+	}
+
+	if token == "" {
+		respond(http.StatusBadRequest, "Invalid unsubscribe link")
+		return
+	}
+
+	userID, err := h.users.FindByUnsubscribeToken(token)
+	if errors.Is(err, store.ErrNotFound) {
+		respond(http.StatusNotFound, "Invalid or expired unsubscribe link")
+		return
+	}
+	if err != nil {
+		respond(http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := h.users.SetDigestOptIn(userID, false, ""); err != nil {
+		respond(http.StatusInternalServerError, "Failed to unsubscribe")
+		return
+	}
+
+	// Log unsubscribe via email link
+	if logger != nil {
+		logger.Info("digest.unsubscribe", "User unsubscribed from digest via link", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    userID,
+			IPAddress: c.ClientIP(),
+			Metadata: map[string]interface{}{
+				"one_click": c.PostForm("List-Unsubscribe") == "One-Click",
+			},
+		})
+	}
+
+	respond(http.StatusOK, "You have been unsubscribed from the weekly digest")
+}
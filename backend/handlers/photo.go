@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"chefly/models"
+	"chefly/services"
+	"chefly/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// multipartOverhead is allowed on top of the photo size for multipart boundaries and other form fields
+const multipartOverhead = 1 << 20
+
+// PhotoHandler handles photos uploaded for recipes
+type PhotoHandler struct {
+	recipes        store.RecipeStore
+	photos         store.PhotoStore
+	imageOptimizer *services.ImageOptimizer
+	imageCleanup   *services.ImageCleanupService
+	limits         services.PhotoLimits
+	maxUploadSize  int64
+	maxPerRecipe   int
+}
+
+// NewPhotoHandler creates a new photo handler
+// maxUploadSize is in bytes, maxPerRecipe limits the number of photos of a recipe
+func NewPhotoHandler(recipes store.RecipeStore, photos store.PhotoStore, imageStore services.ImageStore, imageOptimizer *services.ImageOptimizer, limits services.PhotoLimits, maxUploadSize int64, maxPerRecipe int, auditLogger *services.AuditLogger) *PhotoHandler {
+	return &PhotoHandler{
+		recipes:        recipes,
+		photos:         photos,
+		imageOptimizer: imageOptimizer,
+		imageCleanup:   services.NewImageCleanupService(imageStore, auditLogger),
+		limits:         limits,
+		maxUploadSize:  maxUploadSize,
+		maxPerRecipe:   maxPerRecipe,
+	}
+}
+
+// UploadPhoto adds a photo to a recipe
+// Expects multipart/form-data with the image in the "photo" field and an optional "cover" field (true/false)
+// The first photo of a recipe always becomes its cover
+func (h *PhotoHandler) UploadPhoto(c *gin.Context) {
+	recipeID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	// Get audit logger from context
+	auditLogger, _ := c.Get("audit_logger")
+	logger, _ := auditLogger.(*services.AuditLogger)
+	requestID := c.GetString("request_id")
+
+	if _, err := h.recipes.GetForUser(recipeID, userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload photo"})
+		return
+	}
+
+	count, err := h.photos.CountByRecipe(recipeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload photo"})
+		return
+	}
+	if count >= h.maxPerRecipe {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A recipe can have at most %d photos", h.maxPerRecipe)})
+		return
+	}
+
+	// Reject oversized bodies while reading instead of buffering them
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadSize+multipartOverhead)
+	fileHeader, err := c.FormFile("photo")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Photo must not be larger than %d MB", h.maxUploadSize>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Photo is required (multipart form field \"photo\")"})
+		return
+	}
+	if fileHeader.Size > h.maxUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Photo must not be larger than %d MB", h.maxUploadSize>>20)})
+		return
+	}
+
+	isCover := count == 0
+	if value := c.PostForm("cover"); value != "" && !isCover {
+		isCover, err = strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cover value"})
+			return
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read photo"})
+		return
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read photo"})
+		return
+	}
+
+	optimized, err := h.imageOptimizer.OptimizeUploadedPhoto(data, h.limits)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to process photo"
+		if errors.Is(err, services.ErrUnsupportedImageType) {
+			status = http.StatusUnsupportedMediaType
+			message = "Photo must be a JPEG, PNG or WebP image"
+		} else if errors.Is(err, services.ErrInvalidImageDimensions) {
+			status = http.StatusUnprocessableEntity
+			message = fmt.Sprintf("Photo must be at least %dpx and at most %dpx on each side", h.limits.MinDimension, h.limits.MaxDimension)
+		}
+
+		if logger != nil {
+			logger.Warn("recipe.photo_upload_rejected", "Recipe photo upload rejected", &models.AuditContext{
+				RequestID: requestID,
+				UserID:    userID,
+				IPAddress: c.ClientIP(),
+				Metadata: map[string]interface{}{
+					"recipe_id":  recipeID,
+					"size_bytes": len(data),
+					"error":      err.Error(),
+				},
+			})
+		}
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	photo := &models.RecipePhoto{
+		ID:            uuid.New().String(),
+		RecipeID:      recipeID,
+		UserID:        userID,
+		ImagePath:     optimized.FullImageURL,
+		ThumbnailPath: optimized.ThumbnailURL,
+		ImageVariants: optimized.Variants,
+		Width:         optimized.SourceWidth,
+		Height:        optimized.SourceHeight,
+		IsCover:       isCover,
+	}
+	if err := h.photos.Create(photo); err != nil {
+		// The recipe may have been deleted meanwhile, do not leave the stored variants behind
+		h.imageCleanup.DeleteRecipeImages(photo.ImagePath, photo.ThumbnailPath, photo.ImageVariants, recipeID, userID, requestID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save photo"})
+		return
+	}
+
+	if logger != nil {
+		logger.Info("recipe.photo_upload", "Recipe photo uploaded", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    userID,
+			IPAddress: c.ClientIP(),
+			Metadata: map[string]interface{}{
+				"recipe_id":     recipeID,
+				"photo_id":      photo.ID,
+				"is_cover":      photo.IsCover,
+				"size_bytes":    len(data),
+				"width":         photo.Width,
+				"height":        photo.Height,
+				"variant_count": len(photo.ImageVariants),
+			},
+		})
+	}
+
+	c.JSON(http.StatusCreated, photo)
+}
+
+// GetPhotos lists the photos of a recipe, oldest first
+func (h *PhotoHandler) GetPhotos(c *gin.Context) {
+	recipeID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	if _, err := h.recipes.GetForUser(recipeID, userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
+		return
+	}
+
+	photos, err := h.photos.List(recipeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"photos": photos})
+}
+
+// SetCoverPhoto makes a photo the recipe's cover
+func (h *PhotoHandler) SetCoverPhoto(c *gin.Context) {
+	recipeID := c.Param("id")
+	photoID := c.Param("photoId")
+	userID := c.GetString("user_id")
+
+	// Get audit logger from context
+	auditLogger, _ := c.Get("audit_logger")
+	logger, _ := auditLogger.(*services.AuditLogger)
+	requestID := c.GetString("request_id")
+
+	if _, err := h.recipes.GetForUser(recipeID, userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cover photo"})
+		return
+	}
+
+	err := h.photos.SetCover(photoID, recipeID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cover photo"})
+		return
+	}
+
+	if logger != nil {
+		logger.Info("recipe.photo_cover", "Recipe cover photo changed", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    userID,
+			IPAddress: c.ClientIP(),
+			Metadata: map[string]interface{}{
+				"recipe_id": recipeID,
+				"photo_id":  photoID,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cover photo updated"})
+}
+
+// DeletePhoto deletes a photo and its image files
+// When the cover is deleted, the oldest remaining photo becomes the cover
+func (h *PhotoHandler) DeletePhoto(c *gin.Context) {
+	recipeID := c.Param("id")
+	photoID := c.Param("photoId")
+	userID := c.GetString("user_id")
+
+	// Get audit logger from context
+	auditLogger, _ := c.Get("audit_logger")
+	logger, _ := auditLogger.(*services.AuditLogger)
+	requestID := c.GetString("request_id")
+
+	if _, err := h.recipes.GetForUser(recipeID, userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photo"})
+		return
+	}
+
+	// Get photo before deleting (for image cleanup)
+	photo, err := h.photos.Get(photoID, recipeID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photo"})
+		return
+	}
+
+	err = h.photos.Delete(photoID, recipeID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photo"})
+		return
+	}
+
+	// Delete image files after successful database deletion
+	h.imageCleanup.DeleteRecipeImages(photo.ImagePath, photo.ThumbnailPath, photo.ImageVariants, recipeID, userID, requestID)
+
+	if logger != nil {
+		logger.Info("recipe.photo_delete", "Recipe photo deleted", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    userID,
+			IPAddress: c.ClientIP(),
+			Metadata: map[string]interface{}{
+				"recipe_id": recipeID,
+				"photo_id":  photoID,
+				"was_cover": photo.IsCover,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Photo deleted successfully"})
+}
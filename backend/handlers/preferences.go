@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"chefly/models"
+	"chefly/services"
+	"chefly/store"
+	"chefly/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PreferencesHandler handles the user's dietary profile
+type PreferencesHandler struct {
+	users store.UserStore
+}
+
+// NewPreferencesHandler creates a new preferences handler
+func NewPreferencesHandler(users store.UserStore) *PreferencesHandler {
+	return &PreferencesHandler{users: users}
+}
+
+// GetPreferences returns the user's dietary profile (empty profile if never saved)
+func (h *PreferencesHandler) GetPreferences(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	prefs, err := h.users.GetPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+	if prefs == nil {
+		prefs = &models.UserPreferences{
+			Allergies:           []string{},
+			Diets:               []string{},
+			DislikedIngredients: []string{},
+			PreferredCuisines:   []string{},
+			KitchenEquipment:    []string{},
+		}
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdatePreferences replaces the user's dietary profile
+func (h *PreferencesHandler) UpdatePreferences(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	// Get audit logger from context
+	auditLogger, _ := c.Get("audit_logger")
+	logger, _ := auditLogger.(*services.AuditLogger)
+	requestID := c.GetString("request_id")
+
+	var req models.UserPreferences
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	// Validate and sanitize
+	if err := utils.ValidatePreferenceList("allergies", req.Allergies); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := utils.ValidatePreferenceList("diets", req.Diets); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := utils.ValidatePreferenceList("disliked ingredients", req.DislikedIngredients); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := utils.ValidatePreferenceList("preferred cuisines", req.PreferredCuisines); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := utils.ValidatePreferenceList("kitchen equipment", req.KitchenEquipment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := utils.ValidateHouseholdSize(req.HouseholdSize); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.SkillLevel = strings.ToLower(strings.TrimSpace(req.SkillLevel))
+	if err := utils.ValidateSkillLevel(req.SkillLevel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Allergies = sanitizeList(req.Allergies)
+	req.Diets = sanitizeList(req.Diets)
+	req.DislikedIngredients = sanitizeList(req.DislikedIngredients)
+	req.PreferredCuisines = sanitizeList(req.PreferredCuisines)
+	req.KitchenEquipment = sanitizeList(req.KitchenEquipment)
+
+	if err := h.users.SavePreferences(userID, &req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preferences"})
+		return
+	}
+
+	// Log preferences update
+	if logger != nil {
+		logger.Info("user.preferences_update", "User dietary preferences updated", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    userID,
+			IPAddress: c.ClientIP(),
+			Metadata: map[string]interface{}{
+				"allergies":      req.Allergies,
+				"diets":          req.Diets,
+				"household_size": req.HouseholdSize,
+				"skill_level":    req.SkillLevel,
+			},
+		})
+	}
+
+	prefs, err := h.users.GetPreferences(userID)
+	if err != nil || prefs == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Preferences updated successfully"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// sanitizeList strips HTML and drops empty and duplicate entries
+func sanitizeList(values []string) []string {
+	result := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		value = utils.SanitizeHTML(value)
+		key := strings.ToLower(value)
+		if value == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, value)
+	}
+	return result
+}
@@ -1,14 +1,16 @@
 package handlers
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
+	"strings"
 
 	"chefly/models"
 	"chefly/services"
+	"chefly/store"
+	"chefly/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -16,23 +18,41 @@ import (
 
 // RecipeHandler handles recipe operations
 type RecipeHandler struct {
-	db                    *sql.DB
+	recipes               store.RecipeStore
+	photos                store.PhotoStore
+	users                 store.UserStore
 	claudeService         *services.ClaudeService
-	openaiService         *services.OpenAIService
+	imageGenerator        services.ImageGenerator
+	imageSpec             services.ImageSpec
 	imageOptimizer        *services.ImageOptimizer
 	imageCleanup          *services.ImageCleanupService
-	recipeGenerationLimit string
+	nutritionService      *services.NutritionService
+	dietaryChecker        *services.DietaryChecker
+	equipmentChecker      *services.EquipmentChecker
+	quotas           *services.QuotaService
 }
 
+// maxGenerationAttempts is how many times a recipe is generated before giving up on failed validation
+const maxGenerationAttempts = 3
+
+// statusClientClosedRequest is the (nginx) status logged when the client disconnected before the response
+const statusClientClosedRequest = 499
+
 // NewRecipeHandler creates a new recipe handler
-func NewRecipeHandler(db *sql.DB, claudeAPIKey, claudeModel, openaiAPIKey, openaiModel, recipeGenerationLimit string, auditLogger *services.AuditLogger) *RecipeHandler {
+func NewRecipeHandler(recipes store.RecipeStore, photos store.PhotoStore, users store.UserStore, claudeService *services.ClaudeService, quotas *services.QuotaService, imageGenerator services.ImageGenerator, imageSpec services.ImageSpec, imageStore services.ImageStore, imageOptimizer *services.ImageOptimizer, auditLogger *services.AuditLogger) *RecipeHandler {
 	return &RecipeHandler{
-		db:                    db,
-		claudeService:         services.NewClaudeService(claudeAPIKey, claudeModel),
-		openaiService:         services.NewOpenAIService(openaiAPIKey, openaiModel),
-		imageOptimizer:        services.NewImageOptimizer("./uploads"),
-		imageCleanup:          services.NewImageCleanupService("./uploads", auditLogger),
-		recipeGenerationLimit: recipeGenerationLimit,
+		recipes:               recipes,
+		photos:                photos,
+		users:                 users,
+		claudeService:         claudeService,
+		imageGenerator:        imageGenerator,
+		imageSpec:             imageSpec,
+		imageOptimizer:        imageOptimizer,
+		imageCleanup:          services.NewImageCleanupService(imageStore, auditLogger),
+		nutritionService:      services.NewNutritionService(claudeService),
+		dietaryChecker:        services.NewDietaryChecker(),
+		equipmentChecker:      services.NewEquipmentChecker(),
+		quotas:           quotas,
 	}
 }
 
@@ -45,18 +65,39 @@ func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 	logger, _ := auditLogger.(*services.AuditLogger)
 	requestID := c.GetString("request_id")
 
-	// Check recipe generation limit
-	if !h.canGenerateRecipe(userID, logger, requestID, c.ClientIP()) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Recipe generation limit reached"})
-		return
-	}
-
 	var req models.RecipeGenerationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
 		return
 	}
 
+	// Free text ends up in the prompt, bound it like the saved dietary profile
+	if err := validateGenerationRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Reserve the generation before calling Claude, released again if no recipe is saved
+	recipeID := uuid.New().String()
+	reservation, quota, allowed := h.reserveQuota(userID, models.QuotaText, recipeID, logger, requestID, c.ClientIP())
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Recipe generation limit reached", "quota": quota})
+		return
+	}
+
+	// Apply the user's saved dietary profile
+	if prefs, err := h.users.GetPreferences(userID); err == nil && prefs != nil {
+		prefs.MergeInto(&req)
+	} else if err != nil && logger != nil {
+		logger.Warn("recipe.preferences_load_failed", "Failed to load user preferences", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    userID,
+			Metadata: map[string]interface{}{
+				"error": err.Error(),
+			},
+		})
+	}
+
 	// Log recipe generation start
 	if logger != nil {
 		logger.Info("recipe.generate_start", "Recipe generation initiated", &models.AuditContext{
@@ -71,8 +112,26 @@ func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 		})
 	}
 
-	// Generate recipe using Claude
-	recipe, err := h.claudeService.GenerateRecipe(req)
+	// AI usage of this request is attributed to the user
+	ctx := services.WithAIUsageUser(c.Request.Context(), userID)
+
+	// Generate recipe using Claude (regenerated if it violates the requested diets)
+	recipe, err := h.generateValidatedRecipe(ctx, req, logger, requestID, userID)
+	if err != nil {
+		h.releaseQuota(reservation, userID, recipeID, logger, requestID)
+	}
+	if errors.Is(err, services.ErrRequestCanceled) {
+		// The client is gone (e.g. closed the tab), the AI call was aborted and there is no one to respond to
+		if logger != nil {
+			logger.Info("recipe.generate_canceled", "Recipe generation canceled by the client", &models.AuditContext{
+				RequestID: requestID,
+				UserID:    userID,
+				IPAddress: c.ClientIP(),
+			})
+		}
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	}
 	if err != nil {
 		// Determine specific error message based on error type
 		var errorMessage string
@@ -81,6 +140,9 @@ func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 		if errors.Is(err, services.ErrRateLimit) {
 			errorMessage = "Rate limit reached. Please wait a moment before generating another recipe."
 			statusCode = http.StatusTooManyRequests
+		} else if errors.Is(err, services.ErrAITimeout) {
+			errorMessage = "AI service took too long to respond. Please try again."
+			statusCode = http.StatusGatewayTimeout
 		} else if errors.Is(err, services.ErrEmptyResponse) {
 			errorMessage = "AI service returned an empty response. Please try again."
 			statusCode = http.StatusInternalServerError
@@ -90,6 +152,12 @@ func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 		} else if errors.Is(err, services.ErrParsingFailed) {
 			errorMessage = "Failed to process AI response. Please try again with different settings."
 			statusCode = http.StatusInternalServerError
+		} else if errors.Is(err, services.ErrDietaryViolation) {
+			errorMessage = "Could not generate a recipe that satisfies your dietary preferences. Please try again or adjust your selection."
+			statusCode = http.StatusUnprocessableEntity
+		} else if errors.Is(err, services.ErrEquipmentViolation) {
+			errorMessage = "Could not generate a recipe for your kitchen equipment. Please try again or adjust your selection."
+			statusCode = http.StatusUnprocessableEntity
 		} else if errors.Is(err, services.ErrAPIConnection) {
 			errorMessage = "AI service is temporarily unavailable. Please check your connection and try again."
 			statusCode = http.StatusServiceUnavailable
@@ -117,8 +185,12 @@ func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 		return
 	}
 
-	// Generate realistic food image using OpenAI DALL-E 3
-	imageDataURL, err := h.openaiService.GenerateFoodImage(recipe.Title, recipe.CuisineType, recipe.Description)
+	// Generate realistic food image with the configured image generator
+	imageDataURL, err := h.imageGenerator.GenerateFoodImage(ctx, services.FoodImageRequest{
+		Title:       recipe.Title,
+		CuisineType: recipe.CuisineType,
+		Description: recipe.Description,
+	}, h.imageSpec)
 	var optimizedImages *services.OptimizedImages
 	if err != nil {
 		// Log image generation failure
@@ -134,7 +206,7 @@ func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 		}
 	} else if imageDataURL != "" {
 		// Optimize the generated image (resize and compress)
-		optimizedImages, err = h.imageOptimizer.OptimizeRecipeImage(imageDataURL)
+		optimizedImages, err = h.imageOptimizer.OptimizeRecipeImage(ctx, imageDataURL)
 		if err != nil {
 			// Log optimization failure
 			if logger != nil {
@@ -147,12 +219,12 @@ func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 					},
 				})
 			}
-			// Fallback to original URL if optimization fails
-			recipe.ImagePath = imageDataURL
+			// Save the recipe without an image (the placeholder is shown) rather than storing a data URL in the database
 		} else {
 			// Use optimized image URLs
 			recipe.ImagePath = optimizedImages.FullImageURL
 			recipe.ThumbnailPath = optimizedImages.ThumbnailURL
+			recipe.ImageVariants = optimizedImages.Variants
 
 			// Log successful image optimization
 			if logger != nil {
@@ -163,6 +235,7 @@ func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 						"recipe_title":    recipe.Title,
 						"full_image_path": optimizedImages.FullImagePath,
 						"thumbnail_path":  optimizedImages.ThumbnailPath,
+						"variant_count":   len(optimizedImages.Variants),
 					},
 				})
 			}
@@ -170,45 +243,41 @@ func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 	}
 
 	// Save recipe to database
-	recipeID := uuid.New().String()
 	recipe.ID = recipeID
 	recipe.UserID = userID
 
-	// Encode ingredients and steps as JSON
-	ingredientsJSON, err := json.Marshal(recipe.Ingredients)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode ingredients"})
-		return
-	}
-
-	stepsJSON, err := json.Marshal(recipe.Steps)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode steps"})
+	if err := h.recipes.Create(recipe); err != nil {
+		h.releaseQuota(reservation, userID, recipeID, logger, requestID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save recipe",
+		})
 		return
 	}
 
-	dietaryTagsJSON, err := json.Marshal(recipe.DietaryTags)
-	if err != nil {
-		dietaryTagsJSON = []byte("[]")
+	// The reservation counts the generation, deleting the recipe later does not give it back
+	// Without one (the quota could not be checked) the generation is recorded now
+	if reservation == "" {
+		if err := h.quotas.Record(userID, models.QuotaText, recipeID); err != nil && logger != nil {
+			logger.Error("recipe.generate_record_failed", "Failed to record recipe generation", err, &models.AuditContext{
+				RequestID: requestID,
+				UserID:    userID,
+				Metadata: map[string]interface{}{
+					"recipe_id": recipeID,
+				},
+			})
+		}
 	}
 
-	// Insert into database
-	_, err = h.db.Exec(`
-		INSERT INTO recipes (
-			id, user_id, title, description, ingredients, steps,
-			cooking_time, difficulty, cuisine_type, meat_type,
-			dietary_tags, is_favorite, image_path, thumbnail_path
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?)
-	`, recipeID, userID, recipe.Title, recipe.Description,
-		string(ingredientsJSON), string(stepsJSON),
-		recipe.CookingTime, recipe.Difficulty, recipe.CuisineType,
-		recipe.MeatType, string(dietaryTagsJSON), recipe.ImagePath, recipe.ThumbnailPath)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to save recipe",
+	// Store nutrition (non-fatal, it is recomputed on read if missing)
+	if err := h.recipes.SaveNutrition(recipeID, recipe.Nutrition); err != nil && logger != nil {
+		logger.Warn("recipe.nutrition_save_failed", "Failed to store recipe nutrition", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    userID,
+			Metadata: map[string]interface{}{
+				"recipe_id": recipeID,
+				"error":     err.Error(),
+			},
 		})
-		return
 	}
 
 	// Log successful recipe generation
@@ -227,50 +296,43 @@ func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 		})
 	}
 
+	// Recipes without image (DALL-E failed) show a placeholder until the image is regenerated
+	h.applyPlaceholder(recipe)
+
 	// Return the generated recipe
 	c.JSON(http.StatusCreated, recipe)
 }
 
 // GetRecipes gets user's recipes
+// Supports optional per-serving nutrition filters (e.g. ?max_calories=600&min_protein=30)
 func (h *RecipeHandler) GetRecipes(c *gin.Context) {
 	userID := c.GetString("user_id")
 
-	rows, err := h.db.Query(`
-		SELECT id, title, description, cuisine_type, difficulty, cooking_time, is_favorite, image_path, thumbnail_path, created_at
-		FROM recipes
-		WHERE user_id = ?
-		ORDER BY created_at DESC
-	`, userID)
+	var filter models.NutritionFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid nutrition filter"})
+		return
+	}
+
+	recipes, err := h.recipes.List(userID, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recipes"})
 		return
 	}
-	defer rows.Close()
-
-	recipes := []gin.H{}
-	for rows.Next() {
-		var id, title, description, cuisineType, difficulty, imagePath, thumbnailPath string
-		var cookingTime int
-		var isFavorite bool
-		var createdAt string
 
-		err := rows.Scan(&id, &title, &description, &cuisineType, &difficulty, &cookingTime, &isFavorite, &imagePath, &thumbnailPath, &createdAt)
-		if err != nil {
-			continue
+	// Show cover photos instead of generated images
+	covers, err := h.photos.ListCovers(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recipes"})
+		return
+	}
+	for i := range recipes {
+		if cover, ok := covers[recipes[i].ID]; ok {
+			recipes[i].ApplyCoverPhoto(cover)
+		}
+		if recipes[i].ImagePath == "" {
+			recipes[i].ImagePath, recipes[i].ThumbnailPath, recipes[i].ImageVariants = h.imageOptimizer.PlaceholderImages(recipes[i].ID)
 		}
-
-		recipes = append(recipes, gin.H{
-			"id":             id,
-			"title":          title,
-			"description":    description,
-			"cuisine_type":   cuisineType,
-			"difficulty":     difficulty,
-			"cooking_time":   cookingTime,
-			"is_favorite":    isFavorite,
-			"image_path":     imagePath,
-			"thumbnail_path": thumbnailPath,
-			"created_at":     createdAt,
-		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{"recipes": recipes})
@@ -281,18 +343,8 @@ func (h *RecipeHandler) GetRecipe(c *gin.Context) {
 	recipeID := c.Param("id")
 	userID := c.GetString("user_id")
 
-	var id, title, description, ingredientsJSON, stepsJSON, cuisineType, meatType, difficulty, dietaryTagsJSON, imagePath, thumbnailPath string
-	var cookingTime int
-	var isFavorite bool
-	var createdAt string
-
-	err := h.db.QueryRow(`
-		SELECT id, title, description, ingredients, steps, cuisine_type, meat_type, difficulty, dietary_tags, cooking_time, is_favorite, image_path, thumbnail_path, created_at
-		FROM recipes
-		WHERE id = ? AND user_id = ?
-	`, recipeID, userID).Scan(&id, &title, &description, &ingredientsJSON, &stepsJSON, &cuisineType, &meatType, &difficulty, &dietaryTagsJSON, &cookingTime, &isFavorite, &imagePath, &thumbnailPath, &createdAt)
-
-	if err == sql.ErrNoRows {
+	recipe, err := h.recipes.GetForUser(recipeID, userID)
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
 		return
 	}
@@ -301,31 +353,14 @@ func (h *RecipeHandler) GetRecipe(c *gin.Context) {
 		return
 	}
 
-	// Parse JSON fields
-	var ingredients []models.Ingredient
-	var steps []models.CookingStep
-	var dietaryTags []string
-
-	json.Unmarshal([]byte(ingredientsJSON), &ingredients)
-	json.Unmarshal([]byte(stepsJSON), &steps)
-	json.Unmarshal([]byte(dietaryTagsJSON), &dietaryTags)
-
-	recipe := models.RecipeDetail{
-		ID:            id,
-		UserID:        userID,
-		Title:         title,
-		Description:   description,
-		Ingredients:   ingredients,
-		Steps:         steps,
-		CookingTime:   cookingTime,
-		Difficulty:    difficulty,
-		CuisineType:   cuisineType,
-		MeatType:      meatType,
-		DietaryTags:   dietaryTags,
-		IsFavorite:    isFavorite,
-		ImagePath:     imagePath,
-		ThumbnailPath: thumbnailPath,
+	photos, err := h.photos.List(recipe.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recipe"})
+		return
 	}
+	recipe.ApplyPhotos(photos)
+	h.applyPlaceholder(recipe)
+	recipe.Nutrition = h.recipeNutrition(services.WithAIUsageUser(c.Request.Context(), userID), recipe.ID, recipe.Ingredients, recipe.Servings)
 
 	c.JSON(http.StatusOK, recipe)
 }
@@ -341,25 +376,36 @@ func (h *RecipeHandler) DeleteRecipe(c *gin.Context) {
 	requestID := c.GetString("request_id")
 
 	// Get recipe info before deleting (for audit log and image cleanup)
-	var recipeTitle, imagePath, thumbnailPath string
-	h.db.QueryRow("SELECT title, image_path, thumbnail_path FROM recipes WHERE id = ? AND user_id = ?",
-		recipeID, userID).Scan(&recipeTitle, &imagePath, &thumbnailPath)
-
-	result, err := h.db.Exec("DELETE FROM recipes WHERE id = ? AND user_id = ?", recipeID, userID)
+	recipe, err := h.recipes.GetForUser(recipeID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete recipe"})
+		return
+	}
+	photos, err := h.photos.List(recipeID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete recipe"})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	// Photos are deleted with the recipe (ON DELETE CASCADE)
+	err = h.recipes.Delete(recipeID, userID)
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete recipe"})
+		return
+	}
 
 	// Delete image files from disk after successful database deletion
-	if rowsAffected > 0 {
-		h.imageCleanup.DeleteRecipeImages(imagePath, thumbnailPath, recipeID, userID, requestID)
+	h.imageCleanup.DeleteRecipeImages(recipe.ImagePath, recipe.ThumbnailPath, recipe.ImageVariants, recipeID, userID, requestID)
+	for _, photo := range photos {
+		h.imageCleanup.DeleteRecipeImages(photo.ImagePath, photo.ThumbnailPath, photo.ImageVariants, recipeID, userID, requestID)
 	}
 
 	// Log recipe deletion
@@ -370,7 +416,7 @@ func (h *RecipeHandler) DeleteRecipe(c *gin.Context) {
 			IPAddress: c.ClientIP(),
 			Metadata: map[string]interface{}{
 				"recipe_id":    recipeID,
-				"recipe_title": recipeTitle,
+				"recipe_title": recipe.Title,
 			},
 		})
 	}
@@ -388,17 +434,18 @@ func (h *RecipeHandler) ToggleFavorite(c *gin.Context) {
 	logger, _ := auditLogger.(*services.AuditLogger)
 	requestID := c.GetString("request_id")
 
-	// Get recipe title and current favorite status for audit log
-	var recipeTitle string
-	var isFavorite bool
-	h.db.QueryRow("SELECT title, is_favorite FROM recipes WHERE id = ? AND user_id = ?", recipeID, userID).Scan(&recipeTitle, &isFavorite)
-
-	_, err := h.db.Exec(`
-		UPDATE recipes
-		SET is_favorite = NOT is_favorite
-		WHERE id = ? AND user_id = ?
-	`, recipeID, userID)
+	// Get recipe title for audit log
+	recipe, err := h.recipes.GetForUser(recipeID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update favorite status"})
+		return
+	}
 
+	isFavorite, err := h.recipes.ToggleFavorite(recipeID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update favorite status"})
 		return
@@ -412,8 +459,8 @@ func (h *RecipeHandler) ToggleFavorite(c *gin.Context) {
 			IPAddress: c.ClientIP(),
 			Metadata: map[string]interface{}{
 				"recipe_id":     recipeID,
-				"recipe_title":  recipeTitle,
-				"new_is_favorite": !isFavorite,
+				"recipe_title":    recipe.Title,
+				"new_is_favorite": isFavorite,
 			},
 		})
 	}
@@ -421,6 +468,162 @@ func (h *RecipeHandler) ToggleFavorite(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Favorite status updated"})
 }
 
+// RegenerateImage generates a new image for a recipe, e.g. when DALL-E failed during generation
+// Accepts optional style hints (plating, angle, background), replaces the recipe's previous image files and
+// counts against the image regeneration limit instead of the recipe limit
+// A cover photo keeps being shown instead of the generated image
+func (h *RecipeHandler) RegenerateImage(c *gin.Context) {
+	recipeID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	// Get audit logger from context
+	auditLogger, _ := c.Get("audit_logger")
+	logger, _ := auditLogger.(*services.AuditLogger)
+	requestID := c.GetString("request_id")
+
+	// Style hints are optional, an empty body keeps the default style
+	var style models.ImageStyle
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&style); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid style hints (at most 100 characters each)"})
+			return
+		}
+	}
+
+	recipe, err := h.recipes.GetForUser(recipeID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate image"})
+		return
+	}
+
+	// Reserve the regeneration before calling the image generator, released again if no image is generated
+	reservation, quota, allowed := h.reserveQuota(userID, models.QuotaImage, recipeID, logger, requestID, c.ClientIP())
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Image regeneration limit reached", "quota": quota})
+		return
+	}
+
+	imageDataURL, err := h.imageGenerator.GenerateFoodImage(services.WithAIUsageUser(c.Request.Context(), userID), services.FoodImageRequest{
+		Title:       recipe.Title,
+		CuisineType: recipe.CuisineType,
+		Description: recipe.Description,
+		Style:       style,
+	}, h.imageSpec)
+	if err != nil {
+		h.releaseQuota(reservation, userID, recipeID, logger, requestID)
+	}
+	if errors.Is(err, services.ErrRequestCanceled) {
+		// Aborted before an image was generated, so it does not count against the limit
+		if logger != nil {
+			logger.Info("recipe.image_regenerate_canceled", "Image regeneration canceled by the client", &models.AuditContext{
+				RequestID: requestID,
+				UserID:    userID,
+				IPAddress: c.ClientIP(),
+				Metadata: map[string]interface{}{
+					"recipe_id": recipeID,
+				},
+			})
+		}
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	}
+	if err != nil {
+		if logger != nil {
+			logger.Warn("recipe.image_regenerate_failed", "Failed to regenerate recipe image", &models.AuditContext{
+				RequestID: requestID,
+				UserID:    userID,
+				IPAddress: c.ClientIP(),
+				Metadata: map[string]interface{}{
+					"recipe_id": recipeID,
+					"error":     err.Error(),
+				},
+			})
+		}
+		if errors.Is(err, services.ErrAITimeout) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Image generation took too long. Please try again."})
+			return
+		}
+		if errors.Is(err, services.ErrCircuitOpen) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Image generation is temporarily unavailable. Please try again later."})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to generate image. Please try again."})
+		return
+	}
+
+	// The image was generated (and paid for), the reservation counts even if storing it fails
+	// Without one (the quota could not be checked) the regeneration is recorded now
+	if reservation == "" {
+		if err := h.quotas.Record(userID, models.QuotaImage, recipeID); err != nil && logger != nil {
+			logger.Error("recipe.image_regenerate_record_failed", "Failed to record image regeneration", err, &models.AuditContext{
+				RequestID: requestID,
+				UserID:    userID,
+				Metadata: map[string]interface{}{
+					"recipe_id": recipeID,
+				},
+			})
+		}
+	}
+
+	optimizedImages, err := h.imageOptimizer.OptimizeRecipeImage(c.Request.Context(), imageDataURL)
+	if err != nil {
+		if logger != nil {
+			logger.Warn("recipe.image_optimization_failed", "Failed to optimize recipe image", &models.AuditContext{
+				RequestID: requestID,
+				UserID:    userID,
+				Metadata: map[string]interface{}{
+					"recipe_id": recipeID,
+					"error":     err.Error(),
+				},
+			})
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process generated image"})
+		return
+	}
+
+	err = h.recipes.UpdateImages(recipeID, optimizedImages.FullImageURL, optimizedImages.ThumbnailURL, optimizedImages.Variants)
+	if err != nil {
+		// Recipe deleted meanwhile or database error, do not leave the new files behind
+		h.imageCleanup.DeleteRecipeImages(optimizedImages.FullImageURL, optimizedImages.ThumbnailURL, optimizedImages.Variants, recipeID, userID, requestID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
+
+	// Delete the previous image files after the recipe points to the new ones
+	h.imageCleanup.DeleteRecipeImages(recipe.ImagePath, recipe.ThumbnailPath, recipe.ImageVariants, recipeID, userID, requestID)
+
+	if logger != nil {
+		logger.Info("recipe.image_regenerate", "Recipe image regenerated", &models.AuditContext{
+			RequestID: requestID,
+			UserID:    userID,
+			IPAddress: c.ClientIP(),
+			Metadata: map[string]interface{}{
+				"recipe_id":        recipeID,
+				"had_image":        recipe.ImagePath != "",
+				"style_plating":    style.Plating,
+				"style_angle":      style.Angle,
+				"style_background": style.Background,
+				"variant_count":    len(optimizedImages.Variants),
+			},
+		})
+	}
+
+	response := gin.H{
+		"image_path":     optimizedImages.FullImageURL,
+		"thumbnail_path": optimizedImages.ThumbnailURL,
+		"image_variants": optimizedImages.Variants,
+	}
+	// Read after this regeneration was counted, concurrent regenerations are included
+	if quota, err := h.quotas.Status(userID, models.QuotaImage); err == nil && !quota.Unlimited {
+		response["regenerations_remaining"] = quota.Remaining
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 // GetCountries returns available countries
 func (h *RecipeHandler) GetCountries(c *gin.Context) {
 	countries := []string{
@@ -449,146 +652,283 @@ func (h *RecipeHandler) GetIngredients(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ingredients": ingredients})
 }
 
+// validateGenerationRequest validates the lists and servings of a generation request and sanitizes the lists
+func validateGenerationRequest(req *models.RecipeGenerationRequest) error {
+	lists := []struct {
+		name   string
+		values *[]string
+	}{
+		{"side ingredients", &req.SideIngredients},
+		{"dietary preferences", &req.DietaryPreferences},
+		{"allergies", &req.Allergies},
+		{"disliked ingredients", &req.DislikedIngredients},
+		{"preferred cuisines", &req.PreferredCuisines},
+		{"available equipment", &req.AvailableEquipment},
+		{"excluded equipment", &req.ExcludedEquipment},
+	}
+	for _, list := range lists {
+		if err := utils.ValidatePreferenceList(list.name, *list.values); err != nil {
+			return err
+		}
+	}
+	if err := utils.ValidateServings(req.Servings); err != nil {
+		return err
+	}
+	req.SkillLevel = strings.ToLower(strings.TrimSpace(req.SkillLevel))
+	if err := utils.ValidateSkillLevel(req.SkillLevel); err != nil {
+		return err
+	}
+
+	for _, list := range lists {
+		*list.values = sanitizeList(*list.values)
+	}
+	return nil
+}
+
 // GetPublicRecipe gets a recipe by ID without authentication (for sharing)
 func (h *RecipeHandler) GetPublicRecipe(c *gin.Context) {
 	recipeID := c.Param("id")
 
-	var id, title, description, ingredientsJSON, stepsJSON, cuisineType, meatType, difficulty, dietaryTagsJSON, imagePath string
-	var cookingTime int
-	var createdAt string
+	recipe, err := h.recipes.Get(recipeID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recipe"})
+		return
+	}
+	photos, err := h.photos.List(recipe.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recipe"})
+		return
+	}
+	recipe.ApplyPhotos(photos)
+	h.applyPlaceholder(recipe)
 
-	err := h.db.QueryRow(`
-		SELECT id, title, description, ingredients, steps, cuisine_type, meat_type, difficulty, dietary_tags, cooking_time, image_path, created_at
-		FROM recipes
-		WHERE id = ?
-	`, recipeID).Scan(&id, &title, &description, &ingredientsJSON, &stepsJSON, &cuisineType, &meatType, &difficulty, &dietaryTagsJSON, &cookingTime, &imagePath, &createdAt)
+	// Only stored nutrition is shared: estimating missing nutrition may call Claude at the owner's expense,
+	// which anyone with the link could repeat. It is computed when the owner opens the recipe
+	nutrition, err := h.recipes.GetNutrition(recipe.ID)
+	if err != nil {
+		nutrition = nil
+	}
+
+	// Owner specific fields (user_id, is_favorite) are not shared
+	c.JSON(http.StatusOK, gin.H{
+		"id":           recipe.ID,
+		"title":        recipe.Title,
+		"description":  recipe.Description,
+		"ingredients":  recipe.Ingredients,
+		"steps":        recipe.Steps,
+		"cooking_time": recipe.CookingTime,
+		"difficulty":   recipe.Difficulty,
+		"cuisine_type": recipe.CuisineType,
+		"meat_type":    recipe.MeatType,
+		"dietary_tags": recipe.DietaryTags,
+		"allergens":    recipe.Allergens,
+		"image_path":     recipe.ImagePath,
+		"image_variants": recipe.ImageVariants,
+		"photos":         recipe.Photos,
+		"servings":     recipe.Servings,
+		"nutrition":    nutrition,
+		"created_at":   recipe.CreatedAt,
+	})
+}
 
-	if err == sql.ErrNoRows {
+// GetPlaceholderImage serves the placeholder of a recipe without an image (public, like shared recipes)
+// Placeholders are rendered from the recipe's title and cuisine and kept in memory, see services.RenderPlaceholder
+func (h *RecipeHandler) GetPlaceholderImage(c *gin.Context) {
+	recipe, err := h.recipes.Get(c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recipe"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load image"})
 		return
 	}
 
-	// Parse JSON fields
-	var ingredients []models.Ingredient
-	var steps []models.CookingStep
-	var dietaryTags []string
+	// The placeholder only changes with the recipe's title and cuisine, which are never edited
+	etag := services.PlaceholderETag(recipe.Title, recipe.CuisineType, recipe.MeatType, c.Param("file"))
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=86400")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
 
-	json.Unmarshal([]byte(ingredientsJSON), &ingredients)
-	json.Unmarshal([]byte(stepsJSON), &steps)
-	json.Unmarshal([]byte(dietaryTagsJSON), &dietaryTags)
+	data, contentType, err := h.imageOptimizer.RenderPlaceholder(recipe.Title, recipe.CuisineType, recipe.MeatType, c.Param("file"))
+	if errors.Is(err, services.ErrInvalidPlaceholder) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load image"})
+		return
+	}
 
-	recipe := gin.H{
-		"id":           id,
-		"title":        title,
-		"description":  description,
-		"ingredients":  ingredients,
-		"steps":        steps,
-		"cooking_time": cookingTime,
-		"difficulty":   difficulty,
-		"cuisine_type": cuisineType,
-		"meat_type":    meatType,
-		"dietary_tags": dietaryTags,
-		"image_path":   imagePath,
-		"created_at":   createdAt,
+	// The SVG contains user provided text, never let it run scripts when opened directly
+	if contentType == "image/svg+xml" {
+		c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
 	}
+	c.Data(http.StatusOK, contentType, data)
+}
 
-	c.JSON(http.StatusOK, recipe)
+// applyPlaceholder shows a placeholder for recipes without an image (and without a cover photo)
+// Only used for responses, placeholder paths are never stored
+func (h *RecipeHandler) applyPlaceholder(recipe *models.RecipeDetail) {
+	if recipe.ImagePath == "" {
+		recipe.ImagePath, recipe.ThumbnailPath, recipe.ImageVariants = h.imageOptimizer.PlaceholderImages(recipe.ID)
+	}
 }
 
-// canGenerateRecipe checks if user can generate a recipe based on limits
-func (h *RecipeHandler) canGenerateRecipe(userID string, logger *services.AuditLogger, requestID, ipAddress string) bool {
-	// Get user's recipe_limit setting from database
-	var recipeLimit sql.NullInt64
-	err := h.db.QueryRow("SELECT recipe_limit FROM users WHERE id = ?", userID).Scan(&recipeLimit)
+// reserveQuota counts a generation of a kind (models.QuotaText or models.QuotaImage) against the user's quota before it runs
+// Returns the reservation ("" if the quota could not be checked, the generation is then allowed),
+// and the exhausted quota and false if the limit is reached
+func (h *RecipeHandler) reserveQuota(userID, kind, recipeID string, logger *services.AuditLogger, requestID, ipAddress string) (string, *models.QuotaStatus, bool) {
+	eventPrefix, subject := "recipe.limit", "Recipe generation"
+	if kind == models.QuotaImage {
+		eventPrefix, subject = "recipe.image_limit", "Image regeneration"
+	}
+
+	reservation, err := h.quotas.Reserve(userID, kind, recipeID)
+	if errors.Is(err, store.ErrLimitReached) {
+		// The exhausted status only informs the client, nil if it cannot be read
+		quota, _ := h.quotas.Status(userID, kind)
+		if logger != nil && quota != nil {
+			metadata := map[string]interface{}{
+				"policy": quota.Policy,
+				"source": quota.Source,
+			}
+			if quota.ResetsAt != nil {
+				metadata["resets_at"] = quota.ResetsAt
+			}
+			logger.Warn(eventPrefix+"_reached", subject+" limit reached", &models.AuditContext{
+				RequestID: requestID,
+				UserID:    userID,
+				IPAddress: ipAddress,
+				Metadata:  metadata,
+			})
+		}
+		return "", quota, false
+	}
+
 	if err != nil {
 		// If error, allow generation (fail open, but log error)
 		if logger != nil {
-			logger.Error("recipe.limit_check_failed", "Failed to check recipe limit", err, &models.AuditContext{
+			logger.Error(eventPrefix+"_check_failed", "Failed to check quota: "+subject, err, &models.AuditContext{
 				RequestID: requestID,
 				UserID:    userID,
 				IPAddress: ipAddress,
 			})
 		}
-		return true
+		return "", nil, true
 	}
 
-	// Determine effective limit
-	var effectiveLimit int
-	var hasLimit bool
+	return reservation, nil, true
+}
 
-	if recipeLimit.Valid {
-		// User has a personal limit set
-		effectiveLimit = int(recipeLimit.Int64)
-		hasLimit = true
+// releaseQuota gives back the reservation of a generation that failed
+func (h *RecipeHandler) releaseQuota(reservation, userID, recipeID string, logger *services.AuditLogger, requestID string) {
+	if reservation == "" {
+		return
+	}
+	if err := h.quotas.Release(reservation); err != nil && logger != nil {
+		logger.Error("recipe.quota_release_failed", "Failed to release quota reservation of a failed generation", err, &models.AuditContext{
+			RequestID: requestID,
+			UserID:    userID,
+			Metadata: map[string]interface{}{
+				"recipe_id": recipeID,
+			},
+		})
+	}
+}
 
-		// -1 means unlimited for this user
-		if effectiveLimit == -1 {
-			return true
-		}
-	} else {
-		// Use global limit from config
-		globalLimit := h.recipeGenerationLimit
+// GetQuota returns the user's remaining recipe and image generations and when they reset
+func (h *RecipeHandler) GetQuota(c *gin.Context) {
+	userID := c.GetString("user_id")
 
-		// Check if global limit is "unlimited" or empty
-		if globalLimit == "unlimited" || globalLimit == "" {
-			return true
-		}
+	quota, err := h.quotas.UserQuota(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quota"})
+		return
+	}
+
+	c.JSON(http.StatusOK, quota)
+}
 
-		// Try to parse as number
-		parsedLimit, err := strconv.Atoi(globalLimit)
+// generateValidatedRecipe generates a recipe and verifies it against the requested diets and kitchen equipment
+// Violations are fed back into the prompt and the recipe is regenerated up to maxGenerationAttempts times
+func (h *RecipeHandler) generateValidatedRecipe(ctx context.Context, req models.RecipeGenerationRequest, logger *services.AuditLogger, requestID, userID string) (*models.RecipeDetail, error) {
+	var violations []models.DietViolation
+	var equipmentViolations []models.EquipmentViolation
+
+	for attempt := 1; attempt <= maxGenerationAttempts; attempt++ {
+		recipe, err := h.claudeService.GenerateRecipe(ctx, req)
 		if err != nil {
-			// Invalid config, default to unlimited
-			return true
+			return nil, err
 		}
 
-		effectiveLimit = parsedLimit
-		hasLimit = true
-	}
+		// Nutrition is needed to verify carb-restricted diets
+		recipe.Nutrition = h.nutritionService.CalculateRecipeNutrition(ctx, recipe.Ingredients, recipe.Servings)
 
-	// If limit is 0, block generation
-	if hasLimit && effectiveLimit == 0 {
-		if logger != nil {
-			logger.Warn("recipe.limit_blocked", "Recipe generation blocked by limit", &models.AuditContext{
+		violations = h.dietaryChecker.CheckDiets(recipe, req.DietaryPreferences)
+		violations = append(violations, h.dietaryChecker.CheckAllergies(recipe.Ingredients, req.Allergies)...)
+		violations = append(violations, h.dietaryChecker.CheckExcludedIngredients(recipe.Ingredients, req.DislikedIngredients, "without disliked ingredients")...)
+		equipmentViolations = h.equipmentChecker.CheckSteps(recipe.Steps, req.AvailableEquipment, req.ExcludedEquipment)
+		if len(violations) == 0 && len(equipmentViolations) == 0 {
+			recipe.Allergens = h.dietaryChecker.DetectAllergens(recipe.Ingredients)
+			return recipe, nil
+		}
+
+		// Log rejected attempt
+		if logger != nil && len(violations) > 0 {
+			logger.Warn("recipe.dietary_violation", "Generated recipe violates dietary preferences", &models.AuditContext{
 				RequestID: requestID,
 				UserID:    userID,
-				IPAddress: ipAddress,
 				Metadata: map[string]interface{}{
-					"effective_limit": effectiveLimit,
-					"has_personal_limit": recipeLimit.Valid,
+					"attempt":      attempt,
+					"recipe_title": recipe.Title,
+					"violations":   violations,
 				},
 			})
 		}
-		return false
-	}
-
-	// Count user's existing recipes
-	var recipeCount int
-	err = h.db.QueryRow("SELECT COUNT(*) FROM recipes WHERE user_id = ?", userID).Scan(&recipeCount)
-	if err != nil {
-		// If error, allow generation (fail open)
-		return true
-	}
-
-	// Check if user has reached their limit
-	if hasLimit && recipeCount >= effectiveLimit {
-		if logger != nil {
-			logger.Warn("recipe.limit_reached", "Recipe generation limit reached", &models.AuditContext{
+		if logger != nil && len(equipmentViolations) > 0 {
+			logger.Warn("recipe.equipment_violation", "Generated recipe requires unavailable kitchen equipment", &models.AuditContext{
 				RequestID: requestID,
 				UserID:    userID,
-				IPAddress: ipAddress,
 				Metadata: map[string]interface{}{
-					"current_count":      recipeCount,
-					"effective_limit":    effectiveLimit,
-					"has_personal_limit": recipeLimit.Valid,
+					"attempt":      attempt,
+					"recipe_title": recipe.Title,
+					"violations":   equipmentViolations,
 				},
 			})
 		}
-		return false
+
+		req.Corrections = append(services.DescribeViolations(violations), services.DescribeEquipmentViolations(equipmentViolations)...)
 	}
 
-	return true
+	if len(violations) > 0 {
+		return nil, fmt.Errorf("%w: %d violations after %d attempts", services.ErrDietaryViolation, len(violations), maxGenerationAttempts)
+	}
+	return nil, fmt.Errorf("%w: %d violations after %d attempts", services.ErrEquipmentViolation, len(equipmentViolations), maxGenerationAttempts)
+}
+
+// recipeNutrition returns stored nutrition for a recipe, computing and storing it if missing
+// (recipes generated before nutrition estimation was added)
+func (h *RecipeHandler) recipeNutrition(ctx context.Context, recipeID string, ingredients []models.Ingredient, servings int) *models.RecipeNutrition {
+	if nutrition, err := h.recipes.GetNutrition(recipeID); err == nil && nutrition != nil {
+		return nutrition
+	}
+
+	if len(ingredients) == 0 {
+		return nil
+	}
+
+	nutrition := h.nutritionService.CalculateRecipeNutrition(ctx, ingredients, servings)
+	// Estimation of unknown ingredients was cut short, compute it again next time
+	if ctx.Err() == nil {
+		h.recipes.SaveNutrition(recipeID, nutrition)
+	}
+	return nutrition
 }
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chefly/models"
+	"chefly/services"
+	"chefly/store"
+	"chefly/store/memstore"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testClock is a settable clock of the in-memory stores
+// The quota service counts from the real time, so tests set it relative to time.Now
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// fakeImageGenerator returns a small PNG, or err if set
+type fakeImageGenerator struct {
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+func (g *fakeImageGenerator) GenerateFoodImage(ctx context.Context, recipe services.FoodImageRequest, spec services.ImageSpec) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls++
+	if g.err != nil {
+		return "", g.err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 32, 32))); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// recipeHandlerTest is a recipe handler backed by in-memory stores
+type recipeHandlerTest struct {
+	t       *testing.T
+	clock   *testClock
+	stores  *store.Store
+	images  *fakeImageGenerator
+	handler *RecipeHandler
+}
+
+func newRecipeHandlerTest(t *testing.T, textPolicy, imagePolicy string) *recipeHandlerTest {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	clock := &testClock{now: time.Now().UTC()}
+	stores := memstore.New(clock.Now)
+	quotas, err := services.NewQuotaService(stores.Users, stores.Generations, textPolicy, imagePolicy, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewQuotaService: %v", err)
+	}
+
+	// No Claude or audit services: the tested paths must not reach them
+	images := &fakeImageGenerator{}
+	imageStore := services.NewLocalImageStore(filepath.Join(t.TempDir(), "uploads"))
+	optimizer := services.NewImageOptimizer(imageStore, services.DefaultImageVariantConfig(), nil)
+	handler := NewRecipeHandler(stores.Recipes, nil, stores.Users, nil, quotas, images, services.ImageSpec{}, imageStore, optimizer, nil)
+	return &recipeHandlerTest{t: t, clock: clock, stores: stores, images: images, handler: handler}
+}
+
+func (h *recipeHandlerTest) createUser(id string) {
+	h.t.Helper()
+	err := h.stores.Users.Create(&models.User{ID: id, Email: id + "@example.com", Username: id, PasswordHash: "x"})
+	if err != nil {
+		h.t.Fatalf("failed to create user: %v", err)
+	}
+}
+
+func (h *recipeHandlerTest) createRecipe(id, userID string) {
+	h.t.Helper()
+	err := h.stores.Recipes.Create(&models.RecipeDetail{ID: id, UserID: userID, Title: "Recipe " + id})
+	if err != nil {
+		h.t.Fatalf("failed to create recipe: %v", err)
+	}
+}
+
+// serve sends a request as the given user to a handler mounted at the route
+func (h *recipeHandlerTest) serve(method, route, path, userID string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	h.t.Helper()
+	return h.serveJSON(method, route, path, userID, "", handler)
+}
+
+// serveJSON sends a request with a JSON body as the given user to a handler mounted at the route
+func (h *recipeHandlerTest) serveJSON(method, route, path, userID, body string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	h.t.Helper()
+	router := gin.New()
+	router.Handle(method, route, func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	}, handler)
+
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func decodeJSON(t *testing.T, recorder *httptest.ResponseRecorder, value interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(recorder.Body.Bytes(), value); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", recorder.Body.String(), err)
+	}
+}
+
+func TestToggleFavorite(t *testing.T) {
+	h := newRecipeHandlerTest(t, "unlimited", "unlimited")
+	h.createUser("owner")
+	h.createUser("other")
+	h.createRecipe("r1", "owner")
+
+	for _, want := range []bool{true, false} {
+		recorder := h.serve(http.MethodPost, "/recipes/:id/favorite", "/recipes/r1/favorite", "owner", h.handler.ToggleFavorite)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body.String())
+		}
+		recipe, err := h.stores.Recipes.Get("r1")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if recipe.IsFavorite != want {
+			t.Errorf("is_favorite = %v, want %v", recipe.IsFavorite, want)
+		}
+	}
+
+	// Recipes of other users and unknown recipes look the same
+	for _, request := range []struct{ userID, path string }{
+		{"other", "/recipes/r1/favorite"},
+		{"owner", "/recipes/missing/favorite"},
+	} {
+		recorder := h.serve(http.MethodPost, "/recipes/:id/favorite", request.path, request.userID, h.handler.ToggleFavorite)
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("%s as %s: status = %d, want 404", request.path, request.userID, recorder.Code)
+		}
+	}
+	if count, _ := h.stores.Recipes.CountFavorites("owner"); count != 0 {
+		t.Errorf("favorites = %d after another user's toggle, want 0", count)
+	}
+}
+
+func TestGetQuota(t *testing.T) {
+	h := newRecipeHandlerTest(t, "2/day", "unlimited")
+	h.createUser("cook")
+
+	started := time.Now().UTC().Add(-90 * time.Minute)
+	for _, at := range []time.Time{started, started.Add(time.Hour)} {
+		h.clock.now = at
+		if err := h.stores.Generations.Record("cook", models.QuotaText, ""); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	recorder := h.serve(http.MethodGet, "/user/quota", "/user/quota", "cook", h.handler.GetQuota)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body.String())
+	}
+	var quota models.UserQuota
+	decodeJSON(t, recorder, &quota)
+
+	if quota.Text.Policy != "2/day" || quota.Text.Source != "global" || quota.Text.Remaining != 0 {
+		t.Errorf("text quota = %+v, want the exhausted global 2/day policy", quota.Text)
+	}
+	// Exhausted until the oldest generation leaves the window
+	if quota.Text.ResetsAt == nil || !quota.Text.ResetsAt.Equal(started.Add(24*time.Hour)) {
+		t.Errorf("text resets_at = %v, want %v", quota.Text.ResetsAt, started.Add(24*time.Hour))
+	}
+	if len(quota.Text.Rules) != 1 || quota.Text.Rules[0].Used != 2 {
+		t.Errorf("text rules = %+v, want one rule with 2 used", quota.Text.Rules)
+	}
+	if !quota.Image.Unlimited || quota.Image.Remaining != -1 {
+		t.Errorf("image quota = %+v, want unlimited", quota.Image)
+	}
+
+	// A personal policy overrides the global one
+	policy := "5/hour"
+	if err := h.stores.Users.SetQuotaPolicy("cook", models.QuotaText, &policy); err != nil {
+		t.Fatalf("SetQuotaPolicy: %v", err)
+	}
+	recorder = h.serve(http.MethodGet, "/user/quota", "/user/quota", "cook", h.handler.GetQuota)
+	decodeJSON(t, recorder, &quota)
+	if quota.Text.Policy != "5/hour" || quota.Text.Source != "user" || quota.Text.Remaining != 4 {
+		t.Errorf("text quota = %+v, want the personal 5/hour policy with 4 remaining", quota.Text)
+	}
+}
+
+func TestGenerateRecipeRejectsExhaustedQuota(t *testing.T) {
+	h := newRecipeHandlerTest(t, "1/day", "unlimited")
+	h.createUser("cook")
+	if err := h.stores.Generations.Record("cook", models.QuotaText, ""); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	// The handler has no Claude service, reaching it would panic
+	recorder := h.serveJSON(http.MethodPost, "/recipes/generate", "/recipes/generate", "cook", `{"meat_type": "chicken"}`, h.handler.GenerateRecipe)
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", recorder.Code, recorder.Body.String())
+	}
+	var response struct {
+		Error string              `json:"error"`
+		Quota *models.QuotaStatus `json:"quota"`
+	}
+	decodeJSON(t, recorder, &response)
+	if response.Quota == nil || response.Quota.Remaining != 0 || response.Quota.ResetsAt == nil {
+		t.Errorf("quota = %+v, want the exhausted status with a reset time", response.Quota)
+	}
+}
+
+func TestRegenerateImageCountsQuota(t *testing.T) {
+	h := newRecipeHandlerTest(t, "unlimited", "3/day")
+	h.createUser("cook")
+	h.createRecipe("r1", "cook")
+	regenerate := func() *httptest.ResponseRecorder {
+		return h.serve(http.MethodPost, "/recipes/:id/regenerate-image", "/recipes/r1/regenerate-image", "cook", h.handler.RegenerateImage)
+	}
+	used := func() int {
+		count, err := h.stores.Generations.CountSince("cook", models.QuotaImage, time.Time{})
+		if err != nil {
+			t.Fatalf("CountSince: %v", err)
+		}
+		return count
+	}
+
+	// A failed generation gives the reservation back
+	h.images.err = errors.New("provider down")
+	if recorder := regenerate(); recorder.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502: %s", recorder.Code, recorder.Body.String())
+	}
+	if count := used(); count != 0 {
+		t.Errorf("regenerations counted after a failure = %d, want 0", count)
+	}
+
+	// The remaining count is read after this regeneration was counted
+	h.images.err = nil
+	if err := h.stores.Generations.Record("cook", models.QuotaImage, "r1"); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	recorder := regenerate()
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body.String())
+	}
+	var response struct {
+		ImagePath              string `json:"image_path"`
+		RegenerationsRemaining *int   `json:"regenerations_remaining"`
+	}
+	decodeJSON(t, recorder, &response)
+	if response.ImagePath == "" || response.RegenerationsRemaining == nil || *response.RegenerationsRemaining != 1 {
+		t.Errorf("response = %s, want a new image and 1 regeneration remaining", recorder.Body.String())
+	}
+	if count := used(); count != 2 {
+		t.Errorf("regenerations counted = %d, want 2", count)
+	}
+}
+
+func TestRegenerateImageConcurrentRequestsRespectQuota(t *testing.T) {
+	h := newRecipeHandlerTest(t, "unlimited", "2/day")
+	h.createUser("cook")
+	h.createRecipe("r1", "cook")
+
+	const requests = 8
+	var wg sync.WaitGroup
+	codes := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recorder := h.serve(http.MethodPost, "/recipes/:id/regenerate-image", "/recipes/r1/regenerate-image", "cook", h.handler.RegenerateImage)
+			codes <- recorder.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	allowed := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			allowed++
+		case http.StatusForbidden:
+		default:
+			t.Errorf("status = %d, want 200 or 403", code)
+		}
+	}
+	if allowed != 2 || h.images.calls != 2 {
+		t.Errorf("%d requests allowed and %d images generated, want 2", allowed, h.images.calls)
+	}
+}
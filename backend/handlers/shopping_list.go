@@ -1,12 +1,12 @@
 package handlers
 
 import (
-	"database/sql"
-	"encoding/json"
+	"errors"
 	"net/http"
 
 	"chefly/models"
 	"chefly/services"
+	"chefly/store"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,57 +14,24 @@ import (
 
 // ShoppingListHandler handles shopping list operations
 type ShoppingListHandler struct {
-	db *sql.DB
+	shoppingList store.ShoppingListStore
+	recipes      store.RecipeStore
 }
 
 // NewShoppingListHandler creates a new shopping list handler
-func NewShoppingListHandler(db *sql.DB) *ShoppingListHandler {
-	return &ShoppingListHandler{db: db}
+func NewShoppingListHandler(shoppingList store.ShoppingListStore, recipes store.RecipeStore) *ShoppingListHandler {
+	return &ShoppingListHandler{shoppingList: shoppingList, recipes: recipes}
 }
 
 // GetShoppingList gets all shopping list items for the user
 func (h *ShoppingListHandler) GetShoppingList(c *gin.Context) {
 	userID := c.GetString("user_id")
 
-	rows, err := h.db.Query(`
-		SELECT id, user_id, COALESCE(recipe_id, ''), COALESCE(recipe_title, ''),
-		       ingredient_name, quantity, unit, is_checked, created_at
-		FROM shopping_list_items
-		WHERE user_id = ?
-		ORDER BY is_checked ASC, created_at DESC
-	`, userID)
+	items, err := h.shoppingList.List(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shopping list"})
 		return
 	}
-	defer rows.Close()
-
-	var items []models.ShoppingListItem
-	for rows.Next() {
-		var item models.ShoppingListItem
-		var isChecked int
-		err := rows.Scan(
-			&item.ID,
-			&item.UserID,
-			&item.RecipeID,
-			&item.RecipeTitle,
-			&item.IngredientName,
-			&item.Quantity,
-			&item.Unit,
-			&isChecked,
-			&item.CreatedAt,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan shopping list item"})
-			return
-		}
-		item.IsChecked = isChecked == 1
-		items = append(items, item)
-	}
-
-	if items == nil {
-		items = []models.ShoppingListItem{}
-	}
 
 	c.JSON(http.StatusOK, gin.H{"items": items})
 }
@@ -80,31 +47,8 @@ func (h *ShoppingListHandler) AddRecipeToShoppingList(c *gin.Context) {
 	}
 
 	// Fetch recipe
-	var recipe models.Recipe
-	var ingredientsJSON, stepsJSON, dietaryTagsJSON string
-	err := h.db.QueryRow(`
-		SELECT id, user_id, title, description, ingredients, steps,
-		       cooking_time, difficulty, cuisine_type, meat_type, dietary_tags,
-		       is_favorite, image_path, created_at
-		FROM recipes
-		WHERE id = ? AND user_id = ?
-	`, req.RecipeID, userID).Scan(
-		&recipe.ID,
-		&recipe.UserID,
-		&recipe.Title,
-		&recipe.Description,
-		&ingredientsJSON,
-		&stepsJSON,
-		&recipe.CookingTime,
-		&recipe.Difficulty,
-		&recipe.CuisineType,
-		&recipe.MeatType,
-		&dietaryTagsJSON,
-		&recipe.IsFavorite,
-		&recipe.ImagePath,
-		&recipe.CreatedAt,
-	)
-	if err == sql.ErrNoRows {
+	recipe, err := h.recipes.GetForUser(req.RecipeID, userID)
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
 		return
 	}
@@ -112,13 +56,7 @@ func (h *ShoppingListHandler) AddRecipeToShoppingList(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recipe"})
 		return
 	}
-
-	// Parse ingredients
-	var ingredients []models.Ingredient
-	if err := json.Unmarshal([]byte(ingredientsJSON), &ingredients); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse ingredients"})
-		return
-	}
+	ingredients := recipe.Ingredients
 
 	// Get audit logger from context
 	auditLogger, _ := c.Get("audit_logger")
@@ -126,17 +64,22 @@ func (h *ShoppingListHandler) AddRecipeToShoppingList(c *gin.Context) {
 	requestID := c.GetString("request_id")
 
 	// Add each ingredient to shopping list
-	addedCount := 0
+	items := make([]models.ShoppingListItem, 0, len(ingredients))
 	for _, ingredient := range ingredients {
-		itemID := uuid.New().String()
-		_, err := h.db.Exec(`
-			INSERT INTO shopping_list_items (id, user_id, recipe_id, recipe_title, ingredient_name, quantity, unit)
-			VALUES (?, ?, ?, ?, ?, ?, ?)
-		`, itemID, userID, recipe.ID, recipe.Title, ingredient.Name, ingredient.Quantity, ingredient.Unit)
-		if err != nil {
-			continue // Skip if fails (e.g., duplicate)
-		}
-		addedCount++
+		items = append(items, models.ShoppingListItem{
+			ID:             uuid.New().String(),
+			UserID:         userID,
+			RecipeID:       recipe.ID,
+			RecipeTitle:    recipe.Title,
+			IngredientName: ingredient.Name,
+			Quantity:       ingredient.Quantity,
+			Unit:           ingredient.Unit,
+		})
+	}
+	addedCount, err := h.shoppingList.AddItems(items)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add ingredients"})
+		return
 	}
 
 	// Log adding recipe to shopping list
@@ -166,19 +109,13 @@ func (h *ShoppingListHandler) ToggleItemChecked(c *gin.Context) {
 	itemID := c.Param("id")
 
 	// Toggle the is_checked field
-	result, err := h.db.Exec(`
-		UPDATE shopping_list_items
-		SET is_checked = CASE WHEN is_checked = 0 THEN 1 ELSE 0 END
-		WHERE id = ? AND user_id = ?
-	`, itemID, userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to toggle item"})
+	err := h.shoppingList.ToggleChecked(itemID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to toggle item"})
 		return
 	}
 
@@ -190,18 +127,13 @@ func (h *ShoppingListHandler) DeleteItem(c *gin.Context) {
 	userID := c.GetString("user_id")
 	itemID := c.Param("id")
 
-	result, err := h.db.Exec(`
-		DELETE FROM shopping_list_items
-		WHERE id = ? AND user_id = ?
-	`, itemID, userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete item"})
+	err := h.shoppingList.Delete(itemID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete item"})
 		return
 	}
 
@@ -217,17 +149,12 @@ func (h *ShoppingListHandler) ClearCheckedItems(c *gin.Context) {
 	logger, _ := auditLogger.(*services.AuditLogger)
 	requestID := c.GetString("request_id")
 
-	result, err := h.db.Exec(`
-		DELETE FROM shopping_list_items
-		WHERE user_id = ? AND is_checked = 1
-	`, userID)
+	rowsAffected, err := h.shoppingList.ClearChecked(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear checked items"})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-
 	// Log clearing checked items
 	if logger != nil {
 		logger.Info("shopping.clear_checked", "Cleared checked shopping items", &models.AuditContext{
@@ -255,17 +182,12 @@ func (h *ShoppingListHandler) ClearAllItems(c *gin.Context) {
 	logger, _ := auditLogger.(*services.AuditLogger)
 	requestID := c.GetString("request_id")
 
-	result, err := h.db.Exec(`
-		DELETE FROM shopping_list_items
-		WHERE user_id = ?
-	`, userID)
+	rowsAffected, err := h.shoppingList.ClearAll(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear shopping list"})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-
 	// Log clearing all items
 	if logger != nil {
 		logger.Info("shopping.clear_all", "Cleared all shopping items", &models.AuditContext{
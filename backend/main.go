@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"embed"
 	"fmt"
 	"io/fs"
@@ -10,6 +9,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,7 +18,9 @@ import (
 	"chefly/database"
 	"chefly/handlers"
 	"chefly/middleware"
+	"chefly/models"
 	"chefly/services"
+	"chefly/store"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -28,6 +31,9 @@ var (
 	BuildTime = "unknown"
 )
 
+// legacyUploadsDir is where images were stored before IMAGE_STORAGE_PATH was honored
+const legacyUploadsDir = "./uploads"
+
 //go:embed frontend/dist/*
 var frontendFS embed.FS
 
@@ -35,18 +41,53 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
-	// Initialize database
-	db, err := database.InitDB(cfg.DBPath)
+	// Initialize database (DB_DRIVER selects SQLite or PostgreSQL)
+	dialect, err := database.ParseDialect(cfg.DBDriver)
+	if err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+	dsn := cfg.DBPath
+	if dialect == database.Postgres {
+		dsn = cfg.DBURL
+	}
+	db, err := database.InitDB(dialect, dsn, database.Options{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		BusyTimeout:     cfg.SQLiteBusyTimeout,
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
 
+	// Run CLI subcommand (e.g. "chefly migrate status") instead of the server
+	if len(os.Args) > 1 {
+		code := runCommand(cfg, db, dialect, os.Args[1:])
+		db.Close()
+		os.Exit(code)
+	}
+
 	// Run migrations
-	if err := database.RunMigrations(db); err != nil {
+	if err := database.RunMigrations(db, dialect); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Initialize repositories
+	stores := store.New(db, dialect)
+
+	// Initialize image storage (IMAGE_STORE selects the local filesystem or an S3-compatible bucket)
+	imageStore, err := newImageStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize image storage: %v", err)
+	}
+	imageVariants, err := services.ParseImageVariantConfig(cfg.ImageVariantWidths, cfg.ImageVariantFormats, cfg.ImageJPEGQuality, cfg.ImageWebPQuality)
+	if err != nil {
+		log.Fatalf("Invalid image variant configuration: %v", err)
+	}
+	imageDownloader := services.NewImageDownloader(cfg.ImageDownloadTimeout, int64(cfg.ImageDownloadMaxMB)<<20)
+	imageOptimizer := services.NewImageOptimizer(imageStore, imageVariants, imageDownloader)
+
 	// Initialize audit logger
 	auditLogger := services.NewAuditLogger(
 		cfg.AuditLogEnabled,
@@ -54,11 +95,82 @@ func main() {
 		cfg.AuditLogFormat,
 	)
 
+	// Retries and circuit breakers of AI providers
+	aiResilience := services.ResilienceConfig{
+		MaxAttempts:      cfg.AIRetryMaxAttempts,
+		BaseDelay:        cfg.AIRetryBaseDelay,
+		MaxDelay:         cfg.AIRetryMaxDelay,
+		FailureThreshold: cfg.AICircuitThreshold,
+		OpenDuration:     cfg.AICircuitOpenDuration,
+	}
+	// Token usage and estimated cost of AI requests
+	aiPrices, err := services.ParseAIPriceTable(cfg.AIPrices)
+	if err != nil {
+		log.Fatalf("Invalid AI price configuration: %v", err)
+	}
+	aiUsage := services.NewAIUsageRecorder(stores.AIUsage, aiPrices, auditLogger)
+
+	claudeResilience := services.NewAIResilience("claude", aiResilience, auditLogger)
+	imageResilience := services.NewAIResilience(strings.ToLower(cfg.ImageGenerator), aiResilience, auditLogger)
+	claudeService := services.NewClaudeService(cfg.ClaudeAPIKey, cfg.ClaudeModel, cfg.AIRecipeTimeout, cfg.AINutritionTimeout, claudeResilience, aiUsage, auditLogger)
+	imageGenerator, err := newImageGenerator(cfg, imageDownloader, imageResilience, aiUsage)
+	if err != nil {
+		log.Fatalf("Failed to initialize image generator: %v", err)
+	}
+	imageSpec, err := services.ParseImageSpec(cfg.ImageGenSize, cfg.ImageGenQuality)
+	if err != nil {
+		log.Fatalf("Invalid image generation configuration: %v", err)
+	}
+
+	// Recipe and image generation quotas, counted from the generation event log
+	quotaService, err := services.NewQuotaService(stores.Users, stores.Generations, cfg.RecipeGenerationLimit, cfg.ImageRegenLimit, cfg.ImageRegenWindow)
+	if err != nil {
+		log.Fatalf("Invalid quota configuration: %v", err)
+	}
+
 	// Log system startup
 	auditLogger.Info("system.startup", "Application started", nil)
 
+	// Move images left in the legacy uploads directory into the image store (one-time, the directory is removed once empty)
+	migrateLegacyImages(cfg, imageStore, stores.Recipes, auditLogger)
+
 	// Start automatic token cleanup goroutine (runs every hour)
-	go cleanupExpiredTokens(db, auditLogger)
+	go cleanupExpiredTokens(stores.Tokens, auditLogger)
+
+	// Start weekly digest scheduler (checks every hour for users due a digest)
+	if cfg.DigestEnabled && cfg.SMTPHost != "" {
+		notifier := services.NewSMTPNotifier(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+		digestService := services.NewDigestService(stores.Users, stores.Recipes, stores.ShoppingList, quotaService, notifier, auditLogger, cfg.AppBaseURL)
+		go sendWeeklyDigests(digestService, auditLogger)
+	} else if cfg.DigestEnabled {
+		auditLogger.Warn("digest.disabled", "Digest enabled but SMTP_HOST is not configured", nil)
+	}
+
+	// Start backup scheduler (writes a backup every BACKUP_INTERVAL and prunes old ones)
+	backupService := services.NewBackupService(db, dialect, cfg.DBPath, imageStore, cfg.BackupPath, cfg.BackupCompress)
+	if cfg.BackupEnabled && dialect == database.SQLite && cfg.BackupInterval > 0 {
+		retention := models.BackupRetention{
+			KeepLast:    cfg.BackupKeepLast,
+			KeepDaily:   cfg.BackupKeepDaily,
+			KeepWeekly:  cfg.BackupKeepWeekly,
+			KeepMonthly: cfg.BackupKeepMonthly,
+		}
+		go runScheduledBackups(backupService, cfg.BackupInterval, retention, auditLogger)
+	} else if cfg.BackupEnabled {
+		auditLogger.Warn("system.backup.disabled", "Scheduled backups require SQLite and a positive BACKUP_INTERVAL", nil)
+	}
+
+	// Move data: URL and external recipe images into the image store in the background
+	imageMigrator := services.NewUnmanagedImageMigrator(stores.Recipes, imageOptimizer, imageStore, auditLogger)
+	if cfg.ImageMigrateUnmanaged {
+		imageMigrator.Start()
+	}
+
+	// Start orphaned image garbage collector (removes images no recipe refers to every IMAGE_GC_INTERVAL)
+	imageGCService := services.NewImageGCService(imageStore, stores.Recipes, stores.Photos, cfg.ImageGCGracePeriod, cfg.ImageGCQuarantine)
+	if cfg.ImageGCEnabled && cfg.ImageGCInterval > 0 {
+		go collectOrphanedImages(imageGCService, cfg.ImageGCInterval, auditLogger)
+	}
 
 	// Setup Gin router
 	if cfg.Environment == "production" {
@@ -91,10 +203,17 @@ func main() {
 	router.Use(cors.New(corsConfig))
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db, cfg.JWTSecret, cfg.RegistrationEnabled)
-	recipeHandler := handlers.NewRecipeHandler(db, cfg.ClaudeAPIKey, cfg.ClaudeModel, cfg.OpenAIAPIKey, cfg.OpenAIModel, cfg.RecipeGenerationLimit, auditLogger)
-	shoppingListHandler := handlers.NewShoppingListHandler(db)
-	adminHandler := handlers.NewAdminHandler(db, auditLogger)
+	authHandler := handlers.NewAuthHandler(stores.Users, stores.Recipes, stores.Tokens, cfg.JWTSecret, cfg.RegistrationEnabled)
+	recipeHandler := handlers.NewRecipeHandler(stores.Recipes, stores.Photos, stores.Users, claudeService, quotaService, imageGenerator, imageSpec, imageStore, imageOptimizer, auditLogger)
+	photoHandler := handlers.NewPhotoHandler(stores.Recipes, stores.Photos, imageStore, imageOptimizer, services.PhotoLimits{
+		MinDimension: cfg.PhotoMinDimension,
+		MaxDimension: cfg.PhotoMaxDimension,
+	}, int64(cfg.PhotoMaxUploadMB)<<20, cfg.PhotoMaxPerRecipe, auditLogger)
+	shoppingListHandler := handlers.NewShoppingListHandler(stores.ShoppingList, stores.Recipes)
+	imageHandler := handlers.NewImageHandler(imageStore)
+	adminHandler := handlers.NewAdminHandler(stores.Users, stores.Recipes, stores.Photos, stores.ShoppingList, imageStore, backupService, imageGCService, imageMigrator, []*services.AIResilience{claudeResilience, imageResilience}, stores.AIUsage, quotaService, auditLogger)
+	notificationHandler := handlers.NewNotificationHandler(stores.Users)
+	preferencesHandler := handlers.NewPreferencesHandler(stores.Users)
 
 	// Public routes
 	api := router.Group("/api")
@@ -109,6 +228,10 @@ func main() {
 		// Public recipe view (for sharing)
 		api.GET("/recipes/shared/:id", recipeHandler.GetPublicRecipe)
 
+		// Public digest unsubscribe link (from email)
+		api.GET("/notifications/unsubscribe/:token", notificationHandler.ConfirmUnsubscribe)
+		api.POST("/notifications/unsubscribe/:token", notificationHandler.Unsubscribe)
+
 		// Protected routes
 		protected := api.Group("")
 		protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
@@ -121,6 +244,11 @@ func main() {
 			protected.GET("/user/profile", authHandler.GetProfile)
 			protected.PUT("/user/profile", authHandler.UpdateProfile)
 			protected.GET("/user/stats", authHandler.GetStats)
+			protected.GET("/user/digest", notificationHandler.GetDigestSettings)
+			protected.PUT("/user/digest", notificationHandler.UpdateDigestSettings)
+			protected.GET("/user/preferences", preferencesHandler.GetPreferences)
+			protected.PUT("/user/preferences", preferencesHandler.UpdatePreferences)
+			protected.GET("/user/quota", recipeHandler.GetQuota)
 
 			// Recipe routes
 			recipes := protected.Group("/recipes")
@@ -130,6 +258,13 @@ func main() {
 				recipes.GET("/:id", recipeHandler.GetRecipe)
 				recipes.DELETE("/:id", recipeHandler.DeleteRecipe)
 				recipes.POST("/:id/favorite", recipeHandler.ToggleFavorite)
+				recipes.POST("/:id/image/regenerate", recipeHandler.RegenerateImage)
+
+				// Photos uploaded by the cook
+				recipes.GET("/:id/photos", photoHandler.GetPhotos)
+				recipes.POST("/:id/photos", photoHandler.UploadPhoto)
+				recipes.PUT("/:id/photos/:photoId/cover", photoHandler.SetCoverPhoto)
+				recipes.DELETE("/:id/photos/:photoId", photoHandler.DeletePhoto)
 			}
 
 			// Filter options routes
@@ -158,7 +293,14 @@ func main() {
 				admin.GET("/users", adminHandler.GetAllUsers)
 				admin.DELETE("/users/:id", adminHandler.DeleteUser)
 				admin.GET("/stats", adminHandler.GetAdminStats)
-				admin.PUT("/users/:id/recipe-limit", adminHandler.UpdateUserRecipeLimit)
+				admin.PUT("/users/:id/quota", adminHandler.UpdateUserQuota)
+				admin.POST("/backup", adminHandler.CreateBackup)
+				admin.GET("/images/orphans", adminHandler.ListOrphanedImages)
+				admin.POST("/images/gc", adminHandler.CollectOrphanedImages)
+				admin.GET("/images/migration", adminHandler.GetImageMigrationProgress)
+				admin.POST("/images/migration", adminHandler.StartImageMigration)
+				admin.GET("/ai/metrics", adminHandler.GetAIMetrics)
+				admin.GET("/ai/usage", adminHandler.GetAIUsage)
 			}
 		}
 	}
@@ -168,8 +310,13 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
-	// Serve uploaded images (recipe images, thumbnails)
-	router.Static("/uploads", "./uploads")
+	// Serve recipe images and thumbnails from the image store
+	router.GET("/uploads/*key", imageHandler.ServeImage)
+	router.HEAD("/uploads/*key", imageHandler.ServeImage)
+
+	// Serve rendered placeholders of recipes without an image
+	router.GET("/placeholders/:id/:file", recipeHandler.GetPlaceholderImage)
+	router.HEAD("/placeholders/:id/:file", recipeHandler.GetPlaceholderImage)
 
 	// Serve embedded frontend for SPA routing
 	// Note: Frontend is always embedded in the binary after build
@@ -233,7 +380,8 @@ func main() {
 	fmt.Printf("🌍 Environment: %s\n", cfg.Environment)
 	fmt.Printf("📝 Audit Logging: %v (level: %s, format: %s)\n", cfg.AuditLogEnabled, cfg.AuditLogLevel, cfg.AuditLogFormat)
 	fmt.Printf("👥 Registration: %v\n", cfg.RegistrationEnabled)
-	fmt.Printf("🍳 Recipe Generation Limit: %s\n", cfg.RecipeGenerationLimit)
+	fmt.Printf("🍳 Generation Quotas: recipes %s, images %s\n", quotaService.GlobalPolicy(models.QuotaText), quotaService.GlobalPolicy(models.QuotaImage))
+	fmt.Printf("📧 Weekly Digest: %v\n", cfg.DigestEnabled && cfg.SMTPHost != "")
 	fmt.Printf("📌 Version: %s (built: %s)\n", Version, BuildTime)
 
 	// Create HTTP server with timeouts
@@ -279,27 +427,100 @@ func main() {
 	log.Println("✅ Server stopped cleanly")
 }
 
+// newImageStore creates the image store selected by IMAGE_STORE
+func newImageStore(cfg *config.Config) (services.ImageStore, error) {
+	switch strings.ToLower(cfg.ImageStore) {
+	case "", "local":
+		return services.NewLocalImageStore(cfg.ImageStoragePath), nil
+	case "s3":
+		return services.NewS3ImageStore(services.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			UseSSL:          cfg.S3UseSSL,
+			Prefix:          cfg.S3Prefix,
+			PresignExpiry:   cfg.S3PresignExpiry,
+			Timeout:         cfg.S3Timeout,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported image store %q (supported: local, s3)", cfg.ImageStore)
+	}
+}
+
+// newImageGenerator creates the image generator selected by IMAGE_GENERATOR
+func newImageGenerator(cfg *config.Config, downloader *services.ImageDownloader, resilience *services.AIResilience, usage *services.AIUsageRecorder) (services.ImageGenerator, error) {
+	switch strings.ToLower(cfg.ImageGenerator) {
+	case "", "dalle", "openai":
+		return services.NewOpenAIService(cfg.OpenAIAPIKey, cfg.OpenAIModel, cfg.OpenAIBaseURL, cfg.AIImageTimeout, downloader, resilience, usage), nil
+	case "stable-diffusion", "sd":
+		if cfg.SDAPIURL == "" {
+			return nil, fmt.Errorf("SD_API_URL is required for the stable-diffusion image generator")
+		}
+		return services.NewStableDiffusionService(cfg.SDAPIURL, cfg.SDAPIAuth, cfg.AIImageTimeout, resilience, usage), nil
+	case "offline":
+		return services.NewOfflineImageGenerator(), nil
+	default:
+		return nil, fmt.Errorf("unsupported image generator %q (supported: dalle, stable-diffusion, offline)", cfg.ImageGenerator)
+	}
+}
+
+// migrateLegacyImages moves images from legacyUploadsDir into the image store and rewrites recipe image paths
+func migrateLegacyImages(cfg *config.Config, imageStore services.ImageStore, recipes store.RecipeStore, logger *services.AuditLogger) {
+	if _, err := os.Stat(legacyUploadsDir); os.IsNotExist(err) {
+		return
+	}
+	// Nothing to move when the local store still points at the legacy directory
+	if _, ok := imageStore.(*services.LocalImageStore); ok {
+		legacyDir, _ := filepath.Abs(legacyUploadsDir)
+		storageDir, _ := filepath.Abs(cfg.ImageStoragePath)
+		if legacyDir == storageDir {
+			return
+		}
+	}
+
+	report, err := services.MigrateLegacyImages(legacyUploadsDir, imageStore, recipes)
+	if err != nil {
+		logger.Error("system.images.migration_failed", "Failed to migrate legacy image directory", err, &models.AuditContext{
+			Metadata: map[string]interface{}{
+				"legacy_dir":  legacyUploadsDir,
+				"files_moved": report.FilesMoved,
+			},
+		})
+		return
+	}
+
+	if report.FilesMoved > 0 || report.RecipesUpdated > 0 || len(report.FilesSkipped) > 0 {
+		logger.Info("system.images.migrated", "Legacy images moved to image storage", &models.AuditContext{
+			Metadata: map[string]interface{}{
+				"legacy_dir":      legacyUploadsDir,
+				"storage":         cfg.ImageStore,
+				"files_moved":     report.FilesMoved,
+				"files_skipped":   report.FilesSkipped,
+				"recipes_updated": report.RecipesUpdated,
+			},
+		})
+	}
+}
+
 // cleanupExpiredTokens runs in a goroutine and cleans up expired/revoked tokens every hour
-func cleanupExpiredTokens(db *sql.DB, logger *services.AuditLogger) {
+func cleanupExpiredTokens(tokens store.TokenStore, logger *services.AuditLogger) {
 	ticker := time.NewTicker(1 * time.Hour)
 	defer ticker.Stop()
 
 	// Run cleanup immediately on startup
-	performCleanup(db, logger)
+	performCleanup(tokens, logger)
 
 	// Then run every hour
 	for range ticker.C {
-		performCleanup(db, logger)
+		performCleanup(tokens, logger)
 	}
 }
 
 // performCleanup deletes expired and revoked refresh tokens
-func performCleanup(db *sql.DB, logger *services.AuditLogger) {
-	result, err := db.Exec(`
-		DELETE FROM refresh_tokens
-		WHERE revoked = 1 OR expires_at < datetime('now')
-	`)
-
+func performCleanup(tokens store.TokenStore, logger *services.AuditLogger) {
+	rowsAffected, err := tokens.DeleteExpired()
 	if err != nil {
 		if logger != nil {
 			logger.Error("token.cleanup.failed", "Failed to cleanup expired tokens", err, nil)
@@ -307,8 +528,137 @@ func performCleanup(db *sql.DB, logger *services.AuditLogger) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected > 0 && logger != nil {
 		logger.Info("token.cleanup.success", fmt.Sprintf("Cleaned up %d expired/revoked tokens", rowsAffected), nil)
 	}
 }
+
+// sendWeeklyDigests runs in a goroutine and delivers due weekly digests every hour
+func sendWeeklyDigests(digestService *services.DigestService, logger *services.AuditLogger) {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	// Run immediately on startup
+	performDigestRun(digestService, logger)
+
+	// Then run every hour
+	for range ticker.C {
+		performDigestRun(digestService, logger)
+	}
+}
+
+// performDigestRun sends digests to all users who are due one
+func performDigestRun(digestService *services.DigestService, logger *services.AuditLogger) {
+	sent, err := digestService.SendDueDigests()
+	if err != nil {
+		if logger != nil {
+			logger.Error("digest.run_failed", "Failed to run digest delivery", err, nil)
+		}
+		return
+	}
+
+	if sent > 0 && logger != nil {
+		logger.Info("digest.run_success", fmt.Sprintf("Sent %d weekly digests", sent), nil)
+	}
+}
+
+// collectOrphanedImages runs in a goroutine and removes orphaned images every interval
+func collectOrphanedImages(imageGCService *services.ImageGCService, interval time.Duration, logger *services.AuditLogger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Run immediately on startup
+	performImageGC(imageGCService, logger)
+
+	for range ticker.C {
+		performImageGC(imageGCService, logger)
+	}
+}
+
+// performImageGC removes orphaned images older than the grace period
+func performImageGC(imageGCService *services.ImageGCService, logger *services.AuditLogger) {
+	report, err := imageGCService.Run(false)
+	if err != nil {
+		if logger != nil {
+			logger.Error("system.images.gc_failed", "Orphaned image collection failed", err, nil)
+		}
+		return
+	}
+
+	if logger != nil && (report.RemovedCount > 0 || len(report.FailedKeys) > 0) {
+		logger.Info("system.images.gc_completed", "Orphaned images removed", &models.AuditContext{
+			Metadata: map[string]interface{}{
+				"scanned_count": report.ScannedCount,
+				"removed_count": report.RemovedCount,
+				"orphan_bytes":  report.OrphanBytes,
+				"failed_keys":   report.FailedKeys,
+				"quarantine":    report.Quarantine,
+			},
+		})
+	}
+}
+
+// runScheduledBackups runs in a goroutine and writes a backup every interval
+func runScheduledBackups(backupService *services.BackupService, interval time.Duration, retention models.BackupRetention, logger *services.AuditLogger) {
+	// After a restart, wait for the remainder of the interval since the latest backup instead of backing up immediately
+	if backups, err := backupService.List(); err == nil && len(backups) > 0 {
+		if wait := interval - time.Since(backups[0].CreatedAt); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+
+	performScheduledBackup(backupService, retention, logger)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		performScheduledBackup(backupService, retention, logger)
+	}
+}
+
+// performScheduledBackup writes a backup and prunes backups not kept by the retention policy
+func performScheduledBackup(backupService *services.BackupService, retention models.BackupRetention, logger *services.AuditLogger) {
+	startedAt := time.Now()
+	backup, err := backupService.Create()
+	if err != nil {
+		if logger != nil {
+			logger.Error("system.backup.failed", "Scheduled backup failed", err, nil)
+		}
+		return
+	}
+
+	if logger != nil {
+		logger.Info("system.backup.completed", "Scheduled backup completed", &models.AuditContext{
+			Metadata: map[string]interface{}{
+				"backup_name":    backup.Name,
+				"size_bytes":     backup.SizeBytes,
+				"image_count":    backup.Manifest.ImageCount,
+				"schema_version": backup.Manifest.SchemaVersion,
+				"duration_ms":    time.Since(startedAt).Milliseconds(),
+			},
+		})
+	}
+
+	// Prune only after a successful backup, so a failing scheduler never deletes the last good backups
+	deleted, err := backupService.Prune(retention)
+	if err != nil {
+		if logger != nil {
+			logger.Error("system.backup.prune_failed", "Failed to prune old backups", err, nil)
+		}
+		return
+	}
+
+	if len(deleted) > 0 && logger != nil {
+		names := make([]string, 0, len(deleted))
+		for _, backup := range deleted {
+			names = append(names, backup.Name)
+		}
+		logger.Info("system.backup.pruned", fmt.Sprintf("Pruned %d old backups", len(deleted)), &models.AuditContext{
+			Metadata: map[string]interface{}{
+				"deleted_count":   len(deleted),
+				"deleted_backups": names,
+			},
+		})
+	}
+}
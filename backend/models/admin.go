@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// UserWithStats represents a user with additional statistics
+type UserWithStats struct {
+	ID             string    `json:"id"`
+	Email          string    `json:"email"`
+	Username       string    `json:"username"`
+	IsAdmin        bool      `json:"is_admin"`
+	CreatedAt      time.Time `json:"created_at"`
+	RecipeCount    int       `json:"recipe_count"`
+	ShoppingItems  int       `json:"shopping_items"`
+	LastRecipeDate *string   `json:"last_recipe_date"`
+	TextQuota      *string   `json:"text_quota"`  // Personal recipe quota policy, null = use global
+	ImageQuota     *string   `json:"image_quota"` // Personal image quota policy, null = use global
+}
+
+// AdminStats represents admin dashboard statistics
+type AdminStats struct {
+	TotalUsers          int            `json:"total_users"`
+	TotalRecipes        int            `json:"total_recipes"`
+	TotalShoppingItems  int            `json:"total_shopping_items"`
+	AverageRecipesUser  float64        `json:"average_recipes_per_user"`
+	MostActiveUser      *string        `json:"most_active_user"`
+	MostActiveUserCount int            `json:"most_active_user_count"`
+	RecentRegistrations int            `json:"recent_registrations"`
+	FirstUserDate       time.Time      `json:"first_user_date"`
+	AIUsage             *AIUsageTotals `json:"ai_usage,omitempty"` // AI usage of the last 7 days
+}
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// AI provider circuit breaker states
+const (
+	CircuitClosed   = "closed"    // Calls go through
+	CircuitOpen     = "open"      // Calls fail fast without reaching the provider
+	CircuitHalfOpen = "half-open" // One trial call decides whether the circuit closes again
+)
+
+// AIProviderMetrics are counters of calls to an AI provider since startup
+type AIProviderMetrics struct {
+	Provider            string     `json:"provider"`
+	Calls               int64      `json:"calls"`           // Calls made by Chefly, each may send several requests
+	Requests            int64      `json:"requests"`        // Requests sent to the provider, including retries
+	Retries             int64      `json:"retries"`         // Requests repeated after a retryable failure
+	Succeeded           int64      `json:"succeeded"`       // Calls that succeeded, possibly after retries
+	Failed              int64      `json:"failed"`          // Calls that failed after their last attempt
+	RateLimited         int64      `json:"rate_limited"`    // 429 responses
+	ShortCircuited      int64      `json:"short_circuited"` // Calls rejected while the circuit was open
+	CircuitState        string     `json:"circuit_state"`
+	CircuitOpenedAt     *time.Time `json:"circuit_opened_at,omitempty"`
+	CircuitOpenings     int64      `json:"circuit_openings"`
+	ConsecutiveFailures int        `json:"consecutive_failures"` // Provider failures (5xx, network) since the last success
+}
+
+// AIUsage is one successful request to an AI provider with its token and image usage
+type AIUsage struct {
+	ID           string    `json:"id"`
+	UserID       *string   `json:"user_id,omitempty"` // nil for calls not made on behalf of a user
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	Operation    string    `json:"operation"` // recipe, recipe_repair, nutrition, image
+	InputTokens  int64     `json:"input_tokens"`
+	OutputTokens int64     `json:"output_tokens"`
+	ImageCount   int       `json:"image_count"`
+	ImageSize    string    `json:"image_size,omitempty"` // e.g: 1024x1024
+	ImageQuality string    `json:"image_quality,omitempty"`
+	CostUSD      *float64  `json:"cost_usd"` // nil when the model is missing from the price table
+	LatencyMS    int64     `json:"latency_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AIUsageTotals sums AI usage over a period
+type AIUsageTotals struct {
+	Calls         int64   `json:"calls"`
+	InputTokens   int64   `json:"input_tokens"`
+	OutputTokens  int64   `json:"output_tokens"`
+	Images        int64   `json:"images"`
+	CostUSD       float64 `json:"cost_usd"`       // Estimated from the price table
+	UnpricedCalls int64   `json:"unpriced_calls"` // Calls to models missing from the price table, not included in the cost
+	AvgLatencyMS  int64   `json:"avg_latency_ms"`
+}
+
+// AIUsageByDay is the AI usage of one UTC day
+type AIUsageByDay struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	AIUsageTotals
+}
+
+// AIUsageByUser is the AI usage of one user
+// UserID is empty for calls not made on behalf of a user and for deleted users
+type AIUsageByUser struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	AIUsageTotals
+}
+
+// AIUsageByModel is the AI usage of one provider model
+type AIUsageByModel struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	AIUsageTotals
+}
+
+// AIUsageReport is the AI usage of the admin dashboard since a point in time
+type AIUsageReport struct {
+	Since   time.Time        `json:"since"`
+	Totals  AIUsageTotals    `json:"totals"`
+	ByDay   []AIUsageByDay   `json:"by_day"`
+	ByUser  []AIUsageByUser  `json:"by_user"`
+	ByModel []AIUsageByModel `json:"by_model"`
+}
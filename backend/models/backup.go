@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// BackupManifest describes the contents of a backup archive (stored as manifest.json inside it)
+type BackupManifest struct {
+	FormatVersion  int       `json:"format_version"`
+	CreatedAt      time.Time `json:"created_at"`
+	SchemaVersion  int       `json:"schema_version"`  // Latest migration applied to the database snapshot
+	DatabaseSHA256 string    `json:"database_sha256"` // Checksum of the database snapshot, verified on restore
+	DatabaseBytes  int64     `json:"database_bytes"`
+	ImageCount     int       `json:"image_count"`
+	ImageBytes     int64     `json:"image_bytes"`
+}
+
+// BackupInfo describes a backup archive on disk
+type BackupInfo struct {
+	Name      string         `json:"name"`
+	Path      string         `json:"-"`
+	CreatedAt time.Time      `json:"created_at"`
+	SizeBytes int64          `json:"size_bytes"`
+	Manifest  BackupManifest `json:"manifest"` // Only set for newly created backups
+}
+
+// BackupRetention is a grandfather-father-son retention policy
+// The newest KeepLast backups are kept, plus the newest backup of each of the last KeepDaily days,
+// KeepWeekly ISO weeks and KeepMonthly months. The newest backup is never pruned.
+type BackupRetention struct {
+	KeepLast    int
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+}
+
+// RestoreReport summarizes a completed restore
+type RestoreReport struct {
+	Manifest             BackupManifest `json:"manifest"`
+	MigrationsApplied    int            `json:"migrations_applied"`
+	ForeignKeyViolations int            `json:"foreign_key_violations"` // Rows referencing missing parent rows (reported, not repaired)
+	ImagesRestored       int            `json:"images_restored"`
+	MissingImages        []string       `json:"missing_images"`    // Image paths referenced by recipes but not found on disk
+	PreviousDatabase     string         `json:"previous_database"` // Where the replaced database was moved to ("" if there was none)
+}
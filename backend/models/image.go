@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// ImageVariant is one rendition of a recipe image (width and format), clients build srcset from them
+type ImageVariant struct {
+	URL         string `json:"url"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Format      string `json:"format"` // "webp" or "jpeg"
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+}
+
+// ImageStyle holds optional hints for generating a recipe image, empty hints keep the default style
+type ImageStyle struct {
+	Plating    string `json:"plating" binding:"max=100"`    // e.g. "rustic wooden board" (default: clean white plate)
+	Angle      string `json:"angle" binding:"max=100"`      // e.g. "45 degree angle" (default: top-down view)
+	Background string `json:"background" binding:"max=100"` // e.g. "dark slate" (default: not specified)
+}
+
+// ImageMigrationReport summarizes moving images from the legacy uploads directory into the image store
+type ImageMigrationReport struct {
+	FilesMoved     int      `json:"files_moved"`
+	FilesSkipped   []string `json:"files_skipped"` // Keys already present in the image store, left in the legacy directory
+	RecipesUpdated int      `json:"recipes_updated"`
+}
+
+// OrphanedImage is a stored image no recipe refers to
+type OrphanedImage struct {
+	Key       string    `json:"key"`
+	SizeBytes int64     `json:"size_bytes"`
+	ModTime   time.Time `json:"modified_at"`
+}
+
+// ImageGCReport summarizes an orphaned image garbage collection run
+type ImageGCReport struct {
+	DryRun          bool            `json:"dry_run"`
+	Quarantine      bool            `json:"quarantine"`    // Orphans are moved below the quarantine prefix instead of deleted
+	GracePeriod     string          `json:"grace_period"`  // Unreferenced images younger than this are kept (recipe may not be saved yet)
+	ScannedCount    int             `json:"scanned_count"` // Images in images/full, images/thumbnails and images/variants
+	ReferencedCount int             `json:"referenced_count"`
+	RecentCount     int             `json:"recent_count"` // Unreferenced images kept because of the grace period
+	Orphans         []OrphanedImage `json:"orphans"`
+	OrphanBytes     int64           `json:"orphan_bytes"`
+	RemovedCount    int             `json:"removed_count"` // Deleted or quarantined, always 0 for dry runs
+	FailedKeys      []string        `json:"failed_keys"`
+}
+
+// UnmanagedImageMigrationProgress reports the progress of moving data: URL and external recipe images into the image store
+type UnmanagedImageMigrationProgress struct {
+	Running    bool       `json:"running"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Total      int        `json:"total"`     // Recipes with a data: or external image URL when the run started
+	Processed  int        `json:"processed"` // Migrated + Cleared + Failed
+	Migrated   int        `json:"migrated"`  // Optimized into the image store, row rewritten
+	Cleared    int        `json:"cleared"`   // External URL no longer available (e.g. expired), image removed from the recipe
+	Failed     int        `json:"failed"`    // Left unchanged, retried on the next run
+	LastError  string     `json:"last_error,omitempty"`
+}
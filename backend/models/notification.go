@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Notification represents an outbound message delivered through a Notifier
+type Notification struct {
+	To             string `json:"to"`
+	Subject        string `json:"subject"`
+	Body           string `json:"body"`                      // Plain text body
+	UnsubscribeURL string `json:"unsubscribe_url,omitempty"` // Sent as List-Unsubscribe header when set
+}
+
+// DigestSettings represents a user's weekly digest subscription
+type DigestSettings struct {
+	OptIn      bool       `json:"opt_in"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+}
+
+// UserDigest represents the weekly activity summary sent to a user
+type UserDigest struct {
+	UserID           string    `json:"user_id"`
+	Username         string    `json:"username"`
+	Email            string    `json:"email"`
+	PeriodStart      time.Time `json:"period_start"`
+	PeriodEnd        time.Time `json:"period_end"`
+	RecipesGenerated int       `json:"recipes_generated"`
+	RecentTitles     []string  `json:"recent_titles"`
+	FavoriteRecipes  int       `json:"favorite_recipes"`
+	OpenShoppingList int       `json:"open_shopping_items"`
+	RemainingQuota   int       `json:"remaining_quota"` // -1 = unlimited
+}
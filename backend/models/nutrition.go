@@ -0,0 +1,30 @@
+package models
+
+// NutritionFacts represents nutritional values for an amount of food
+type NutritionFacts struct {
+	Calories      float64 `json:"calories"`        // kcal
+	Protein       float64 `json:"protein_g"`       // grams
+	Fat           float64 `json:"fat_g"`           // grams
+	Carbohydrates float64 `json:"carbohydrates_g"` // grams
+	Fiber         float64 `json:"fiber_g"`         // grams
+	Sodium        float64 `json:"sodium_mg"`       // milligrams
+}
+
+// RecipeNutrition represents the estimated nutrition of a recipe
+type RecipeNutrition struct {
+	Servings             int            `json:"servings"`
+	PerRecipe            NutritionFacts `json:"per_recipe"`
+	PerServing           NutritionFacts `json:"per_serving"`
+	EstimatedIngredients []string       `json:"estimated_ingredients"` // Not in the food table, estimated by AI
+	UnmatchedIngredients []string       `json:"unmatched_ingredients"` // Not counted in the totals
+}
+
+// NutritionFilter represents optional per-serving nutrition filters for listing recipes
+type NutritionFilter struct {
+	MaxCalories      *float64 `form:"max_calories"`
+	MinProtein       *float64 `form:"min_protein"`
+	MaxFat           *float64 `form:"max_fat"`
+	MaxCarbohydrates *float64 `form:"max_carbohydrates"`
+	MinFiber         *float64 `form:"min_fiber"`
+	MaxSodium        *float64 `form:"max_sodium"`
+}
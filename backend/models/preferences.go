@@ -0,0 +1,64 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// UserPreferences represents a user's dietary profile applied to every recipe generation
+type UserPreferences struct {
+	Allergies           []string  `json:"allergies"`
+	Diets               []string  `json:"diets"`
+	DislikedIngredients []string  `json:"disliked_ingredients"`
+	PreferredCuisines   []string  `json:"preferred_cuisines"`
+	HouseholdSize       int       `json:"household_size"` // 0 = not set
+	SkillLevel          string    `json:"skill_level"`    // "", "beginner", "intermediate", "advanced"
+	KitchenEquipment    []string  `json:"kitchen_equipment"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// skillDifficulty maps a cook's skill level to the matching recipe difficulty
+var skillDifficulty = map[string]string{
+	"beginner":     "easy",
+	"intermediate": "medium",
+	"advanced":     "hard",
+}
+
+// MergeInto applies the profile to a generation request
+// Lists are merged with the request, scalar values only fill fields the request left empty
+func (p *UserPreferences) MergeInto(req *RecipeGenerationRequest) {
+	req.DietaryPreferences = mergeUnique(req.DietaryPreferences, p.Diets)
+	req.Allergies = mergeUnique(req.Allergies, p.Allergies)
+	req.DislikedIngredients = mergeUnique(req.DislikedIngredients, p.DislikedIngredients)
+	req.AvailableEquipment = mergeUnique(req.AvailableEquipment, p.KitchenEquipment)
+
+	if req.CuisineType == "" && len(req.PreferredCuisines) == 0 {
+		req.PreferredCuisines = p.PreferredCuisines
+	}
+	if req.Servings == 0 {
+		req.Servings = p.HouseholdSize
+	}
+	if req.SkillLevel == "" {
+		req.SkillLevel = p.SkillLevel
+	}
+	if req.Difficulty == "" {
+		req.Difficulty = skillDifficulty[p.SkillLevel]
+	}
+}
+
+// mergeUnique appends values from extra that are not already present (case-insensitive)
+func mergeUnique(base, extra []string) []string {
+	seen := make(map[string]bool, len(base))
+	for _, value := range base {
+		seen[strings.ToLower(value)] = true
+	}
+	for _, value := range extra {
+		key := strings.ToLower(value)
+		if value == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		base = append(base, value)
+	}
+	return base
+}
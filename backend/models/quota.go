@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Kinds of generations counted by quotas
+const (
+	QuotaText  = "text"  // Recipe generations
+	QuotaImage = "image" // On demand image regenerations
+)
+
+// QuotaRule is one limit of a quota policy with the user's usage of it
+type QuotaRule struct {
+	Limit     int        `json:"limit"`
+	Window    string     `json:"window"` // hour, day, week, month (30 days), a duration (e.g: 6h) or lifetime
+	Used      int        `json:"used"`
+	Remaining int        `json:"remaining"`
+	ResetsAt  *time.Time `json:"resets_at,omitempty"` // When the oldest counted generation leaves the window, nil for lifetime limits
+}
+
+// QuotaStatus is a user's allowance for one kind of generation
+type QuotaStatus struct {
+	Policy    string      `json:"policy"` // e.g: "10/day,100/month" or "unlimited"
+	Source    string      `json:"source"` // user (personal policy) or global
+	Unlimited bool        `json:"unlimited"`
+	Remaining int         `json:"remaining"`           // -1 = unlimited
+	ResetsAt  *time.Time  `json:"resets_at,omitempty"` // When the allowance grows again, nil if it never does
+	Rules     []QuotaRule `json:"rules"`
+}
+
+// UserQuota is the allowance of a user for all kinds of generations
+type UserQuota struct {
+	Text  *QuotaStatus `json:"text"`
+	Image *QuotaStatus `json:"image"`
+}
@@ -30,6 +30,14 @@ type RecipeGenerationRequest struct {
 	CookingTime         string   `json:"cooking_time"`  // "quick", "medium", "long"
 	Difficulty          string   `json:"difficulty"`    // "easy", "medium", "hard"
 	Language            string   `json:"language"`      // "en" or "sk"
+	Allergies           []string `json:"allergies"`
+	DislikedIngredients []string `json:"disliked_ingredients"`
+	PreferredCuisines   []string `json:"preferred_cuisines"`  // Used when cuisine_type is empty
+	Servings            int      `json:"servings"`            // 0 = let the model decide
+	SkillLevel          string   `json:"skill_level"`         // "beginner", "intermediate", "advanced"
+	AvailableEquipment  []string `json:"available_equipment"` // Empty = no restriction
+	ExcludedEquipment   []string `json:"excluded_equipment"`
+	Corrections         []string `json:"-"` // Issues from a previous attempt the model must fix
 }
 
 // RecipeFilter represents filters for searching recipes
@@ -59,19 +67,128 @@ type CookingStep struct {
 
 // RecipeDetail represents the full recipe with parsed ingredients and steps
 type RecipeDetail struct {
-	ID            string        `json:"id"`
-	UserID        string        `json:"user_id"`
-	Title         string        `json:"title"`
-	Description   string        `json:"description"`
-	Ingredients   []Ingredient  `json:"ingredients"`
-	Steps         []CookingStep `json:"steps"`
-	CookingTime   int           `json:"cooking_time"`
-	Difficulty    string        `json:"difficulty"`
-	CuisineType   string        `json:"cuisine_type"`
-	MeatType      string        `json:"meat_type"`
-	DietaryTags   []string      `json:"dietary_tags"`
-	IsFavorite    bool          `json:"is_favorite"`
-	ImagePath     string        `json:"image_path"`
-	ThumbnailPath string        `json:"thumbnail_path"`
-	CreatedAt     time.Time     `json:"created_at"`
+	ID            string           `json:"id"`
+	UserID        string           `json:"user_id"`
+	Title         string           `json:"title"`
+	Description   string           `json:"description"`
+	Ingredients   []Ingredient     `json:"ingredients"`
+	Steps         []CookingStep    `json:"steps"`
+	CookingTime   int              `json:"cooking_time"`
+	Difficulty    string           `json:"difficulty"`
+	CuisineType   string           `json:"cuisine_type"`
+	MeatType      string           `json:"meat_type"`
+	DietaryTags   []string         `json:"dietary_tags"`
+	Allergens     []string         `json:"allergens"` // EU major allergen codes (e.g. "gluten", "milk")
+	IsFavorite    bool             `json:"is_favorite"`
+	ImagePath     string           `json:"image_path"`
+	ThumbnailPath string           `json:"thumbnail_path"`
+	ImageVariants []ImageVariant   `json:"image_variants"` // Responsive renditions of the image, empty for older recipes
+	Servings      int              `json:"servings"`
+	Nutrition     *RecipeNutrition `json:"nutrition,omitempty"`
+	Photos        []RecipePhoto    `json:"photos,omitempty"` // Photos uploaded by the cook
+	CreatedAt     time.Time        `json:"created_at"`
+}
+
+// ApplyPhotos sets the recipe's photos, the cover photo replaces the generated image
+func (r *RecipeDetail) ApplyPhotos(photos []RecipePhoto) {
+	r.Photos = photos
+	for _, photo := range photos {
+		if photo.IsCover {
+			r.ImagePath = photo.ImagePath
+			r.ThumbnailPath = photo.ThumbnailPath
+			r.ImageVariants = photo.ImageVariants
+		}
+	}
+}
+
+// DietViolation describes why a recipe does not satisfy a requested diet
+type DietViolation struct {
+	Diet       string `json:"diet"`
+	Ingredient string `json:"ingredient,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+// EquipmentViolation describes a cooking step that needs equipment the user does not have
+type EquipmentViolation struct {
+	Equipment  string `json:"equipment"`
+	StepNumber int    `json:"step_number"`
+	Reason     string `json:"reason"`
+}
+
+// RecipeSummary represents a recipe in the recipe list
+type RecipeSummary struct {
+	ID            string         `json:"id"`
+	Title         string         `json:"title"`
+	Description   string         `json:"description"`
+	CuisineType   string         `json:"cuisine_type"`
+	Difficulty    string         `json:"difficulty"`
+	CookingTime   int            `json:"cooking_time"`
+	IsFavorite    bool           `json:"is_favorite"`
+	ImagePath     string         `json:"image_path"`
+	ThumbnailPath string         `json:"thumbnail_path"`
+	ImageVariants []ImageVariant `json:"image_variants"`
+	Calories      *float64       `json:"calories"` // Per serving, nil if nutrition is unknown
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// ApplyCoverPhoto replaces the generated image with the recipe's cover photo
+func (r *RecipeSummary) ApplyCoverPhoto(photo RecipePhoto) {
+	r.ImagePath = photo.ImagePath
+	r.ThumbnailPath = photo.ThumbnailPath
+	r.ImageVariants = photo.ImageVariants
+}
+
+// RecipeImage represents the image files of a recipe
+type RecipeImage struct {
+	RecipeID      string
+	ImagePath     string
+	ThumbnailPath string
+	Variants      []ImageVariant
+}
+
+// Paths returns the image path, thumbnail path and variant URLs of the recipe, without duplicates
+func (i RecipeImage) Paths() []string {
+	paths := []string{}
+	seen := map[string]bool{"": true}
+	for _, path := range append([]string{i.ImagePath, i.ThumbnailPath}, variantURLs(i.Variants)...) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// RecipePhoto is a photo of the cooked dish uploaded by the recipe's owner
+// Uploads are stored as image variants like generated images, EXIF metadata is not kept
+type RecipePhoto struct {
+	ID            string         `json:"id"`
+	RecipeID      string         `json:"recipe_id"`
+	UserID        string         `json:"-"`
+	ImagePath     string         `json:"image_path"`
+	ThumbnailPath string         `json:"thumbnail_path"`
+	ImageVariants []ImageVariant `json:"image_variants"`
+	Width         int            `json:"width"` // Of the uploaded photo
+	Height        int            `json:"height"`
+	IsCover       bool           `json:"is_cover"` // The cover photo is shown instead of the generated image
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// Image returns the image files of the photo
+func (p RecipePhoto) Image() RecipeImage {
+	return RecipeImage{
+		RecipeID:      p.RecipeID,
+		ImagePath:     p.ImagePath,
+		ThumbnailPath: p.ThumbnailPath,
+		Variants:      p.ImageVariants,
+	}
+}
+
+// variantURLs returns the URLs of image variants
+func variantURLs(variants []ImageVariant) []string {
+	urls := make([]string, 0, len(variants))
+	for _, variant := range variants {
+		urls = append(urls, variant.URL)
+	}
+	return urls
 }
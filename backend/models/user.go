@@ -9,7 +9,8 @@ type User struct {
 	PasswordHash string    `json:"-"` // Never expose password hash in JSON
 	Username     string    `json:"username"`
 	IsAdmin      bool      `json:"is_admin"`
-	RecipeLimit  *int      `json:"recipe_limit,omitempty"` // NULL = use global, -1 = unlimited, 0 = blocked, >0 = custom
+	TextQuota    *string   `json:"text_quota,omitempty"`  // Personal recipe quota policy, NULL = use global
+	ImageQuota   *string   `json:"image_quota,omitempty"` // Personal image quota policy, NULL = use global
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -29,22 +30,20 @@ type UserLogin struct {
 
 // UserResponse represents user data in responses (without sensitive info)
 type UserResponse struct {
-	ID          string    `json:"id"`
-	Email       string    `json:"email"`
-	Username    string    `json:"username"`
-	IsAdmin     bool      `json:"is_admin"`
-	RecipeLimit *int      `json:"recipe_limit,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Username  string    `json:"username"`
+	IsAdmin   bool      `json:"is_admin"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // ToResponse converts User to UserResponse
 func (u *User) ToResponse() UserResponse {
 	return UserResponse{
-		ID:          u.ID,
-		Email:       u.Email,
-		Username:    u.Username,
-		IsAdmin:     u.IsAdmin,
-		RecipeLimit: u.RecipeLimit,
-		CreatedAt:   u.CreatedAt,
+		ID:        u.ID,
+		Email:     u.Email,
+		Username:  u.Username,
+		IsAdmin:   u.IsAdmin,
+		CreatedAt: u.CreatedAt,
 	}
 }
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Errors of AI calls that ended because of their context
+var (
+	ErrRequestCanceled = errors.New("AI request canceled by the client")
+	ErrAITimeout       = errors.New("AI service timed out")
+)
+
+// withTimeout derives a context for a single AI call, a timeout <= 0 only inherits the deadline of ctx
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// contextError maps the error of a call whose context ended to ErrRequestCanceled or ErrAITimeout
+// Returns nil if the context is still active (the call failed for another reason)
+func contextError(ctx context.Context) error {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return ErrRequestCanceled
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return ErrAITimeout
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"chefly/models"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ErrCircuitOpen is returned without calling the provider while its circuit breaker is open
+var ErrCircuitOpen = errors.New("AI provider unavailable, circuit breaker open")
+
+// ProviderStatusError is returned for error responses of providers without an SDK error type (Stable Diffusion)
+type ProviderStatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ProviderStatusError) Error() string {
+	return fmt.Sprintf("status code %d: %s", e.StatusCode, e.Message)
+}
+
+// ResilienceConfig configures retries and the circuit breaker of an AI provider
+type ResilienceConfig struct {
+	MaxAttempts      int           // Requests per call including the first (1 = no retries)
+	BaseDelay        time.Duration // Backoff before the first retry, doubled for every further retry
+	MaxDelay         time.Duration // Upper bound of the backoff, a longer Retry-After gives up instead of waiting
+	FailureThreshold int           // Consecutive provider failures that open the circuit (0 = no circuit breaker)
+	OpenDuration     time.Duration // How long the circuit stays open before a trial call
+}
+
+// breakerOutcome is how a request counts for the circuit breaker
+type breakerOutcome int
+
+const (
+	breakerSuccess breakerOutcome = iota // The provider answered (including client errors like 400)
+	breakerFailure                       // The provider is down or broken (5xx, network errors)
+	breakerNeutral                       // Says nothing about the provider (rate limit, canceled by the client)
+)
+
+// aiFailure classifies the error of a request to an AI provider
+type aiFailure struct {
+	retryable   bool
+	rateLimited bool
+	outcome     breakerOutcome
+}
+
+// AIResilience retries failed requests to an AI provider with exponential backoff and
+// stops calling the provider for a while once it keeps failing (circuit breaker)
+type AIResilience struct {
+	provider    string
+	config      ResilienceConfig
+	auditLogger *AuditLogger
+
+	mu       sync.Mutex
+	state    string
+	openedAt time.Time
+	probing  bool // A trial call is running while half-open
+	metrics  models.AIProviderMetrics
+}
+
+// NewAIResilience creates the retry and circuit breaker state of one AI provider
+func NewAIResilience(provider string, config ResilienceConfig, auditLogger *AuditLogger) *AIResilience {
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+	return &AIResilience{
+		provider:    provider,
+		config:      config,
+		auditLogger: auditLogger,
+		state:       models.CircuitClosed,
+		metrics:     models.AIProviderMetrics{Provider: provider},
+	}
+}
+
+// Do runs call, retrying retryable failures (rate limits, 5xx, network errors) with backoff
+// A nil AIResilience runs call once
+func (r *AIResilience) Do(ctx context.Context, operation string, call func(ctx context.Context) error) error {
+	if r == nil {
+		return call(ctx)
+	}
+
+	r.mu.Lock()
+	r.metrics.Calls++
+	r.mu.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		if !r.allow() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.metrics.Failed++
+			r.metrics.ShortCircuited++
+			return fmt.Errorf("%w (%s, retry after %s)", ErrCircuitOpen, r.provider, r.openedAt.Add(r.config.OpenDuration).Format(time.RFC3339))
+		}
+
+		retryAfter := &retryAfterHolder{}
+		err := call(context.WithValue(ctx, retryAfterKey{}, retryAfter))
+		failure := classifyAIError(ctx, err)
+		r.record(failure, err)
+		if err == nil {
+			return nil
+		}
+
+		// No retry once the failures opened the circuit
+		if !failure.retryable || attempt >= r.config.MaxAttempts || r.circuitOpen() {
+			r.countFailed()
+			return err
+		}
+		delay, ok := r.backoff(attempt, retryAfter.get())
+		if deadline, hasDeadline := ctx.Deadline(); !ok || (hasDeadline && time.Until(deadline) < delay) {
+			// The provider asks to wait longer than we are willing (or able) to
+			r.countFailed()
+			return err
+		}
+
+		r.mu.Lock()
+		r.metrics.Retries++
+		r.mu.Unlock()
+		if r.auditLogger != nil {
+			r.auditLogger.Warn("ai.retry", "Retrying failed AI request", &models.AuditContext{
+				Metadata: map[string]interface{}{
+					"provider":  r.provider,
+					"operation": operation,
+					"attempt":   attempt,
+					"delay_ms":  delay.Milliseconds(),
+					"error":     err.Error(),
+				},
+			})
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			r.countFailed()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Metrics returns a snapshot of the provider's counters
+func (r *AIResilience) Metrics() models.AIProviderMetrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	metrics := r.metrics
+	metrics.CircuitState = r.state
+	if r.state != models.CircuitClosed {
+		openedAt := r.openedAt
+		metrics.CircuitOpenedAt = &openedAt
+	}
+	return metrics
+}
+
+// allow checks the circuit breaker before a request, false if the request must not be sent
+func (r *AIResilience) allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.state {
+	case models.CircuitOpen:
+		if time.Since(r.openedAt) < r.config.OpenDuration {
+			return false
+		}
+		r.state = models.CircuitHalfOpen
+		fallthrough
+	case models.CircuitHalfOpen:
+		// Only one trial call at a time
+		if r.probing {
+			return false
+		}
+		r.probing = true
+	}
+
+	r.metrics.Requests++
+	return true
+}
+
+// circuitOpen reports whether requests are currently rejected
+func (r *AIResilience) circuitOpen() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state == models.CircuitOpen
+}
+
+// record updates the circuit breaker with the result of a request
+func (r *AIResilience) record(failure aiFailure, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if failure.rateLimited {
+		r.metrics.RateLimited++
+	}
+	wasProbing := r.probing
+	r.probing = false
+
+	switch failure.outcome {
+	case breakerSuccess:
+		r.metrics.ConsecutiveFailures = 0
+		if r.state != models.CircuitClosed {
+			r.state = models.CircuitClosed
+			if r.auditLogger != nil {
+				r.auditLogger.Info("ai.circuit_closed", "AI provider recovered, circuit breaker closed", &models.AuditContext{
+					Metadata: map[string]interface{}{
+						"provider": r.provider,
+					},
+				})
+			}
+		}
+		if err == nil {
+			r.metrics.Succeeded++
+		}
+	case breakerFailure:
+		r.metrics.ConsecutiveFailures++
+		if r.config.FailureThreshold <= 0 {
+			return
+		}
+		if wasProbing || (r.state == models.CircuitClosed && r.metrics.ConsecutiveFailures >= r.config.FailureThreshold) {
+			r.state = models.CircuitOpen
+			r.openedAt = time.Now()
+			r.metrics.CircuitOpenings++
+			if r.auditLogger != nil {
+				r.auditLogger.Error("ai.circuit_open", "AI provider keeps failing, circuit breaker opened", err, &models.AuditContext{
+					Metadata: map[string]interface{}{
+						"provider":             r.provider,
+						"consecutive_failures": r.metrics.ConsecutiveFailures,
+						"open_duration":        r.config.OpenDuration.String(),
+					},
+				})
+			}
+		}
+	}
+}
+
+// countFailed counts a call that gave up
+func (r *AIResilience) countFailed() {
+	r.mu.Lock()
+	r.metrics.Failed++
+	r.mu.Unlock()
+}
+
+// backoff returns the delay before retrying after the given attempt
+// Retry-After of the provider is honored, false if it exceeds MaxDelay
+func (r *AIResilience) backoff(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > 0 {
+		return retryAfter, retryAfter <= r.config.MaxDelay
+	}
+
+	delay := r.config.BaseDelay << (attempt - 1)
+	if delay > r.config.MaxDelay || delay <= 0 {
+		delay = r.config.MaxDelay
+	}
+	// Equal jitter: half of the delay is random so clients failing together do not retry together
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int64N(half+1)), true
+}
+
+// classifyAIError decides whether a failed request is retried and how it counts for the circuit breaker
+func classifyAIError(ctx context.Context, err error) aiFailure {
+	if err == nil {
+		return aiFailure{outcome: breakerSuccess}
+	}
+	// Canceled by the client or out of time, retrying cannot help
+	if ctx.Err() != nil {
+		return aiFailure{outcome: breakerNeutral}
+	}
+
+	statusCode := aiStatusCode(err)
+	switch {
+	case statusCode == 0:
+		// No response: connection refused, reset, DNS, ...
+		return aiFailure{retryable: true, outcome: breakerFailure}
+	case statusCode == http.StatusTooManyRequests:
+		return aiFailure{retryable: true, rateLimited: true, outcome: breakerNeutral}
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusConflict:
+		return aiFailure{retryable: true, outcome: breakerNeutral}
+	case statusCode >= 500:
+		// Includes Anthropic's 529 overloaded
+		return aiFailure{retryable: true, outcome: breakerFailure}
+	default:
+		// Invalid request, authentication, unknown model: the provider works, the request is wrong
+		return aiFailure{outcome: breakerSuccess}
+	}
+}
+
+// aiStatusCode returns the HTTP status of a provider error response, 0 if there was no response
+func aiStatusCode(err error) int {
+	var anthropicErr *anthropic.Error
+	var openaiErr *openai.APIError
+	var openaiRequestErr *openai.RequestError
+	var statusErr *ProviderStatusError
+	switch {
+	case errors.As(err, &anthropicErr):
+		return anthropicErr.StatusCode
+	case errors.As(err, &openaiErr):
+		return openaiErr.HTTPStatusCode
+	case errors.As(err, &openaiRequestErr):
+		return openaiRequestErr.HTTPStatusCode
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
+// retryAfterKey is the context key of the retryAfterHolder of a request
+type retryAfterKey struct{}
+
+// retryAfterHolder receives the Retry-After of a response, SDK errors do not expose response headers
+type retryAfterHolder struct {
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+func (h *retryAfterHolder) set(delay time.Duration) {
+	h.mu.Lock()
+	h.delay = delay
+	h.mu.Unlock()
+}
+
+func (h *retryAfterHolder) get() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.delay
+}
+
+// retryAfterTransport records the Retry-After of responses in the retryAfterHolder of the request context
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		if holder, ok := req.Context().Value(retryAfterKey{}).(*retryAfterHolder); ok {
+			holder.set(parseRetryAfter(resp.Header, time.Now()))
+		}
+	}
+	return resp, err
+}
+
+// NewAIHTTPClient creates the HTTP client of AI providers, it makes Retry-After available to AIResilience
+// Timeouts come from the request context
+func NewAIHTTPClient() *http.Client {
+	return &http.Client{Transport: &retryAfterTransport{base: http.DefaultTransport}}
+}
+
+// parseRetryAfter reads retry-after-ms (OpenAI, Anthropic) or Retry-After in seconds or as HTTP date
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"chefly/models"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// scriptedResponse is one response of a scriptedProvider
+type scriptedResponse struct {
+	status     int
+	retryAfter string
+	wait       chan struct{} // Blocks the response until closed
+}
+
+// scriptedProvider is an AI provider answering requests with a script, the last response repeats
+type scriptedProvider struct {
+	server    *httptest.Server
+	mu        sync.Mutex
+	responses []scriptedResponse
+	requests  int
+	received  chan struct{} // Signaled for every request
+}
+
+func newScriptedProvider(t *testing.T, responses ...scriptedResponse) *scriptedProvider {
+	t.Helper()
+	provider := &scriptedProvider{responses: responses, received: make(chan struct{}, 100)}
+	provider.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provider.mu.Lock()
+		response := provider.responses[0]
+		if len(provider.responses) > 1 {
+			provider.responses = provider.responses[1:]
+		}
+		provider.requests++
+		provider.mu.Unlock()
+		provider.received <- struct{}{}
+
+		if response.wait != nil {
+			<-response.wait
+		}
+		if response.retryAfter != "" {
+			w.Header().Set("Retry-After", response.retryAfter)
+		}
+		w.WriteHeader(response.status)
+	}))
+	t.Cleanup(provider.server.Close)
+	return provider
+}
+
+func (p *scriptedProvider) requestCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests
+}
+
+// call sends one request like the Stable Diffusion generator does
+func (p *scriptedProvider) call(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.server.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := NewAIHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &ProviderStatusError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+	return nil
+}
+
+func statuses(codes ...int) []scriptedResponse {
+	responses := make([]scriptedResponse, len(codes))
+	for i, code := range codes {
+		responses[i] = scriptedResponse{status: code}
+	}
+	return responses
+}
+
+func TestAIResilienceRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		responses    []scriptedResponse
+		wantRequests int
+		wantStatus   int // 0 = success
+		wantLimited  int64
+	}{
+		{"rate limited then success", statuses(429, 429, 200), 3, 0, 2},
+		{"server errors then success", statuses(500, 503, 200), 3, 0, 0},
+		{"overloaded then success", statuses(529, 200), 2, 0, 0},
+		{"server errors until out of attempts", statuses(502), 3, 502, 0},
+		{"bad request is not retried", statuses(400, 200), 1, 400, 0},
+		{"unauthorized is not retried", statuses(401, 200), 1, 401, 0},
+		{"not found is not retried", statuses(404, 200), 1, 404, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := newScriptedProvider(t, test.responses...)
+			resilience := NewAIResilience("test", ResilienceConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}, nil)
+
+			err := resilience.Do(context.Background(), "recipe", provider.call)
+
+			var statusErr *ProviderStatusError
+			switch {
+			case test.wantStatus == 0 && err != nil:
+				t.Errorf("Do error = %v, want success", err)
+			case test.wantStatus != 0 && (!errors.As(err, &statusErr) || statusErr.StatusCode != test.wantStatus):
+				t.Errorf("Do error = %v, want status %d", err, test.wantStatus)
+			}
+			if requests := provider.requestCount(); requests != test.wantRequests {
+				t.Errorf("requests = %d, want %d", requests, test.wantRequests)
+			}
+			metrics := resilience.Metrics()
+			if metrics.Retries != int64(test.wantRequests-1) || metrics.RateLimited != test.wantLimited {
+				t.Errorf("metrics = %+v, want %d retries and %d rate limited", metrics, test.wantRequests-1, test.wantLimited)
+			}
+		})
+	}
+}
+
+func TestAIResilienceHonorsRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter func() string
+		minDelay   time.Duration
+	}{
+		{"seconds", func() string { return "1" }, time.Second},
+		// HTTP dates have whole seconds, two seconds from now is at least one second away
+		{"HTTP date", func() string { return time.Now().Add(2 * time.Second).UTC().Format(http.TimeFormat) }, time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			provider := newScriptedProvider(t, scriptedResponse{status: 429, retryAfter: test.retryAfter()}, scriptedResponse{status: 200})
+			// Without Retry-After the backoff would be at most a millisecond
+			resilience := NewAIResilience("test", ResilienceConfig{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Second}, nil)
+
+			started := time.Now()
+			if err := resilience.Do(context.Background(), "recipe", provider.call); err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			if elapsed := time.Since(started); elapsed < test.minDelay {
+				t.Errorf("retried after %s, want at least %s", elapsed, test.minDelay)
+			}
+			if requests := provider.requestCount(); requests != 2 {
+				t.Errorf("requests = %d, want 2", requests)
+			}
+		})
+	}
+
+	t.Run("longer than the maximum delay", func(t *testing.T) {
+		t.Parallel()
+		provider := newScriptedProvider(t, scriptedResponse{status: 429, retryAfter: "120"}, scriptedResponse{status: 200})
+		resilience := NewAIResilience("test", ResilienceConfig{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Second}, nil)
+
+		started := time.Now()
+		if err := resilience.Do(context.Background(), "recipe", provider.call); err == nil {
+			t.Fatal("Do succeeded, want the rate limit error")
+		}
+		if elapsed := time.Since(started); elapsed > time.Second {
+			t.Errorf("gave up after %s, want immediately", elapsed)
+		}
+		if requests := provider.requestCount(); requests != 1 {
+			t.Errorf("requests = %d, want 1", requests)
+		}
+	})
+}
+
+func TestAIResilienceCircuitBreaker(t *testing.T) {
+	config := ResilienceConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, FailureThreshold: 2, OpenDuration: time.Hour}
+	provider := newScriptedProvider(t, statuses(500)...)
+	resilience := NewAIResilience("test", config, nil)
+	ctx := context.Background()
+
+	// Closed until the threshold is reached
+	for i := 0; i < 2; i++ {
+		if state := resilience.Metrics().CircuitState; state != models.CircuitClosed {
+			t.Fatalf("state after %d failures = %s, want closed", i, state)
+		}
+		resilience.Do(ctx, "recipe", provider.call)
+	}
+	if state := resilience.Metrics().CircuitState; state != models.CircuitOpen {
+		t.Fatalf("state after 2 failures = %s, want open", state)
+	}
+
+	// Open: calls fail without a request
+	if err := resilience.Do(ctx, "recipe", provider.call); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Do while open error = %v, want ErrCircuitOpen", err)
+	}
+	if requests := provider.requestCount(); requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+
+	// Half-open after the open duration: exactly one probe, which fails and opens the circuit again
+	expireOpenDuration(resilience)
+	if err := resilience.Do(ctx, "recipe", provider.call); errors.Is(err, ErrCircuitOpen) || err == nil {
+		t.Errorf("probe error = %v, want the provider error", err)
+	}
+	if requests := provider.requestCount(); requests != 3 {
+		t.Errorf("requests = %d, want one probe", requests-2)
+	}
+	if metrics := resilience.Metrics(); metrics.CircuitState != models.CircuitOpen || metrics.CircuitOpenings != 2 {
+		t.Fatalf("after a failed probe: state = %s, openings = %d, want open again", metrics.CircuitState, metrics.CircuitOpenings)
+	}
+
+	// A successful probe closes the circuit, calls during the probe are rejected
+	release := make(chan struct{})
+	provider.mu.Lock()
+	provider.responses = []scriptedResponse{{status: 200, wait: release}}
+	provider.mu.Unlock()
+	expireOpenDuration(resilience)
+
+	for len(provider.received) > 0 {
+		<-provider.received
+	}
+	probe := make(chan error)
+	go func() { probe <- resilience.Do(ctx, "recipe", provider.call) }()
+	<-provider.received // The probe is waiting for its response
+	if state := resilience.Metrics().CircuitState; state != models.CircuitHalfOpen {
+		t.Fatalf("state during the probe = %s, want half-open", state)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := resilience.Do(ctx, "recipe", provider.call); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("Do during the probe error = %v, want ErrCircuitOpen", err)
+		}
+	}
+	close(release)
+	if err := <-probe; err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+
+	if requests := provider.requestCount(); requests != 4 {
+		t.Errorf("requests = %d, want 4 (2 failures and 2 probes)", requests)
+	}
+	metrics := resilience.Metrics()
+	if metrics.CircuitState != models.CircuitClosed || metrics.ConsecutiveFailures != 0 || metrics.ShortCircuited != 4 {
+		t.Errorf("metrics = %+v, want closed with 4 short-circuited calls", metrics)
+	}
+	if err := resilience.Do(ctx, "recipe", provider.call); err != nil {
+		t.Errorf("Do after closing: %v", err)
+	}
+}
+
+// expireOpenDuration makes an open circuit breaker ready for a probe
+func expireOpenDuration(r *AIResilience) {
+	r.mu.Lock()
+	r.openedAt = time.Now().Add(-r.config.OpenDuration)
+	r.mu.Unlock()
+}
+
+func TestAIResilienceCancelDuringBackoff(t *testing.T) {
+	provider := newScriptedProvider(t, statuses(503)...)
+	resilience := NewAIResilience("test", ResilienceConfig{MaxAttempts: 3, BaseDelay: 10 * time.Second, MaxDelay: 10 * time.Second}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-provider.received
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	started := time.Now()
+	err := resilience.Do(ctx, "recipe", provider.call)
+	var statusErr *ProviderStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 503 {
+		t.Errorf("Do error = %v, want the last provider error", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("Do returned after %s, want the backoff cut short", elapsed)
+	}
+	if requests := provider.requestCount(); requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"none", http.Header{}, 0},
+		{"seconds", http.Header{"Retry-After": {"30"}}, 30 * time.Second},
+		{"fractional seconds", http.Header{"Retry-After": {"1.5"}}, 1500 * time.Millisecond},
+		{"milliseconds first", http.Header{"Retry-After": {"30"}, "Retry-After-Ms": {"250"}}, 250 * time.Millisecond},
+		{"HTTP date", http.Header{"Retry-After": {now.Add(90 * time.Second).Format(http.TimeFormat)}}, 90 * time.Second},
+		{"HTTP date in the past", http.Header{"Retry-After": {now.Add(-time.Minute).Format(http.TimeFormat)}}, 0},
+		{"negative", http.Header{"Retry-After": {"-5"}}, 0},
+		{"invalid", http.Header{"Retry-After": {"soon"}}, 0},
+	}
+	for _, test := range tests {
+		if got := parseRetryAfter(test.header, now); got != test.want {
+			t.Errorf("%s: parseRetryAfter = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAIResilienceBackoff(t *testing.T) {
+	resilience := NewAIResilience("test", ResilienceConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}, nil)
+
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 5: time.Second, 80: time.Second} {
+		for i := 0; i < 20; i++ {
+			delay, ok := resilience.backoff(attempt, 0)
+			if !ok || delay < want/2 || delay > want {
+				t.Fatalf("backoff(%d) = %s, %v, want between %s and %s", attempt, delay, ok, want/2, want)
+			}
+		}
+	}
+
+	if delay, ok := resilience.backoff(1, 700*time.Millisecond); !ok || delay != 700*time.Millisecond {
+		t.Errorf("backoff with Retry-After = %s, %v, want exactly 700ms", delay, ok)
+	}
+	if _, ok := resilience.backoff(1, 2*time.Second); ok {
+		t.Error("backoff accepted a Retry-After longer than the maximum delay")
+	}
+}
+
+func TestClassifyAIError(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want aiFailure
+	}{
+		{"success", context.Background(), nil, aiFailure{outcome: breakerSuccess}},
+		{"network error", context.Background(), errors.New("connection refused"), aiFailure{retryable: true, outcome: breakerFailure}},
+		{"canceled", canceled, errors.New("context canceled"), aiFailure{outcome: breakerNeutral}},
+		{"anthropic overloaded", context.Background(), fmt.Errorf("wrapped: %w", &anthropic.Error{StatusCode: 529}), aiFailure{retryable: true, outcome: breakerFailure}},
+		{"anthropic bad request", context.Background(), &anthropic.Error{StatusCode: 400}, aiFailure{outcome: breakerSuccess}},
+		{"openai rate limited", context.Background(), &openai.APIError{HTTPStatusCode: 429}, aiFailure{retryable: true, rateLimited: true, outcome: breakerNeutral}},
+		{"openai unavailable", context.Background(), &openai.RequestError{HTTPStatusCode: 503}, aiFailure{retryable: true, outcome: breakerFailure}},
+		{"request timeout", context.Background(), &ProviderStatusError{StatusCode: 408}, aiFailure{retryable: true, outcome: breakerNeutral}},
+		{"forbidden", context.Background(), &ProviderStatusError{StatusCode: 403}, aiFailure{outcome: breakerSuccess}},
+	}
+	for _, test := range tests {
+		if got := classifyAIError(test.ctx, test.err); got != test.want {
+			t.Errorf("%s: classifyAIError = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
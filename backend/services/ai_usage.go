@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"chefly/models"
+	"chefly/store"
+)
+
+// AIPrice is the list price of an AI model in USD
+type AIPrice struct {
+	InputPerMTok  float64 // Per million input tokens
+	OutputPerMTok float64 // Per million output tokens
+	PerImage      float64 // Per generated image
+}
+
+// AIPriceTable maps models to prices
+// Keys are model names, text models match by prefix (the longest wins) so dated model versions share a price
+// Image prices may be specific to a size and quality: "dall-e-3@1024x1024@hd"
+type AIPriceTable map[string]AIPrice
+
+// DefaultAIPrices are the list prices of the models Chefly is usually configured with
+var DefaultAIPrices = AIPriceTable{
+	"claude-3-haiku":    {InputPerMTok: 0.25, OutputPerMTok: 1.25},
+	"claude-3-5-haiku":  {InputPerMTok: 0.8, OutputPerMTok: 4},
+	"claude-haiku-4-5":  {InputPerMTok: 1, OutputPerMTok: 5},
+	"claude-3-5-sonnet": {InputPerMTok: 3, OutputPerMTok: 15},
+	"claude-3-7-sonnet": {InputPerMTok: 3, OutputPerMTok: 15},
+	"claude-sonnet-4":   {InputPerMTok: 3, OutputPerMTok: 15},
+	"claude-3-opus":     {InputPerMTok: 15, OutputPerMTok: 75},
+	"claude-opus-4":     {InputPerMTok: 15, OutputPerMTok: 75},
+	"claude-opus-4-5":   {InputPerMTok: 5, OutputPerMTok: 25},
+
+	"dall-e-3@1024x1024":    {PerImage: 0.04},
+	"dall-e-3@1024x1024@hd": {PerImage: 0.08},
+	"dall-e-3@1792x1024":    {PerImage: 0.08},
+	"dall-e-3@1792x1024@hd": {PerImage: 0.12},
+	"dall-e-3@1024x1792":    {PerImage: 0.08},
+	"dall-e-3@1024x1792@hd": {PerImage: 0.12},
+	"dall-e-2@256x256":      {PerImage: 0.016},
+	"dall-e-2@512x512":      {PerImage: 0.018},
+	"dall-e-2@1024x1024":    {PerImage: 0.02},
+}
+
+// ParseAIPriceTable parses AI_PRICES and merges it over DefaultAIPrices
+// Entries are comma separated: "model=input/output" in USD per million tokens, "model=price" per image,
+// image prices may be limited to a size and quality: "dall-e-3@1024x1024@hd=0.08"
+func ParseAIPriceTable(spec string) (AIPriceTable, error) {
+	table := AIPriceTable{}
+	for key, price := range DefaultAIPrices {
+		table[key] = price
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid AI price %q, expected model=input/output or model=price_per_image", entry)
+		}
+
+		var price AIPrice
+		if input, output, isTokens := strings.Cut(value, "/"); isTokens {
+			inputPrice, inputErr := parsePrice(input)
+			outputPrice, outputErr := parsePrice(output)
+			if inputErr != nil || outputErr != nil {
+				return nil, fmt.Errorf("invalid token prices in %q", entry)
+			}
+			price = AIPrice{InputPerMTok: inputPrice, OutputPerMTok: outputPrice}
+		} else {
+			imagePrice, err := parsePrice(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image price in %q", entry)
+			}
+			price = AIPrice{PerImage: imagePrice}
+		}
+		table[key] = price
+	}
+	return table, nil
+}
+
+// parsePrice parses a non-negative USD amount
+func parsePrice(value string) (float64, error) {
+	price, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || price < 0 {
+		return 0, fmt.Errorf("invalid price %q", value)
+	}
+	return price, nil
+}
+
+// Cost estimates the cost of a request in USD, false if the model has no price
+func (t AIPriceTable) Cost(usage *models.AIUsage) (float64, bool) {
+	price, ok := t.lookup(strings.ToLower(usage.Model), strings.ToLower(usage.ImageSize), strings.ToLower(usage.ImageQuality))
+	if !ok {
+		return 0, false
+	}
+	cost := float64(usage.InputTokens)*price.InputPerMTok/1e6 +
+		float64(usage.OutputTokens)*price.OutputPerMTok/1e6 +
+		float64(usage.ImageCount)*price.PerImage
+	return cost, true
+}
+
+// lookup finds the most specific price of a model: size and quality, size, then the model itself
+func (t AIPriceTable) lookup(model, size, quality string) (AIPrice, bool) {
+	if size != "" {
+		if quality != "" && quality != ImageQualityStandard {
+			if price, ok := t[model+"@"+size+"@"+quality]; ok {
+				return price, true
+			}
+		}
+		if price, ok := t[model+"@"+size]; ok {
+			return price, true
+		}
+	}
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+
+	// Dated model versions (claude-sonnet-4-20250514) use the price of the longest matching prefix
+	keys := make([]string, 0, len(t))
+	for key := range t {
+		if !strings.Contains(key, "@") && strings.HasPrefix(model, key+"-") {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return AIPrice{}, false
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	return t[keys[0]], true
+}
+
+// aiUsageUserKey is the context key of the user an AI call is made for
+type aiUsageUserKey struct{}
+
+// WithAIUsageUser attributes the AI usage of calls made with the returned context to a user
+func WithAIUsageUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, aiUsageUserKey{}, userID)
+}
+
+// AIUsageRecorder stores the token and image usage of AI requests with their estimated cost
+type AIUsageRecorder struct {
+	store       store.AIUsageStore
+	prices      AIPriceTable
+	auditLogger *AuditLogger
+}
+
+// NewAIUsageRecorder creates a usage recorder
+func NewAIUsageRecorder(usageStore store.AIUsageStore, prices AIPriceTable, auditLogger *AuditLogger) *AIUsageRecorder {
+	return &AIUsageRecorder{
+		store:       usageStore,
+		prices:      prices,
+		auditLogger: auditLogger,
+	}
+}
+
+// Record stores the usage of one successful request, attributed to the user of ctx (see WithAIUsageUser)
+// Failing to store usage is logged and never fails the AI call, a nil recorder records nothing
+func (r *AIUsageRecorder) Record(ctx context.Context, usage models.AIUsage, started time.Time) {
+	if r == nil {
+		return
+	}
+
+	usage.LatencyMS = time.Since(started).Milliseconds()
+	if userID, ok := ctx.Value(aiUsageUserKey{}).(string); ok && userID != "" {
+		usage.UserID = &userID
+	}
+	if cost, ok := r.prices.Cost(&usage); ok {
+		usage.CostUSD = &cost
+	}
+
+	if err := r.store.Record(&usage); err != nil && r.auditLogger != nil {
+		r.auditLogger.Error("ai.usage_record_failed", "Failed to record AI usage", err, &models.AuditContext{
+			Metadata: map[string]interface{}{
+				"provider":      usage.Provider,
+				"model":         usage.Model,
+				"operation":     usage.Operation,
+				"input_tokens":  usage.InputTokens,
+				"output_tokens": usage.OutputTokens,
+				"image_count":   usage.ImageCount,
+			},
+		})
+	}
+}
@@ -0,0 +1,911 @@
+package services
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"chefly/database"
+	"chefly/models"
+	"chefly/store"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrBackupUnsupported is returned for databases that are not backed up by Chefly itself (PostgreSQL: use pg_dump)
+	ErrBackupUnsupported = errors.New("backup and restore are only supported for SQLite, use pg_dump for PostgreSQL")
+	// ErrInvalidBackup is returned when a backup archive fails validation
+	ErrInvalidBackup = errors.New("invalid backup archive")
+)
+
+// RestoreError is returned when a restore fails, it records how far the restore got
+type RestoreError struct {
+	Stage            string // The stage that failed, e.g. "restore images"
+	ImagesRestored   int    // Images already written to the image store, replacing images with the same key
+	DatabaseReplaced bool   // The restored database is in place of the previous one
+	PreviousDatabase string // Where the previous database was moved to ("" if it is still in place)
+	Err              error
+}
+
+func (e *RestoreError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *RestoreError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	// backupFormatVersion is bumped when the archive layout changes
+	backupFormatVersion = 1
+
+	backupManifestFile = "manifest.json"
+	backupDatabaseFile = "database/chefly.db"
+	backupUploadsDir   = "uploads/"
+
+	// backupNamePrefix and backupNameLayout name archives so they sort by creation time
+	// Archives created within the same second get a sequence number: chefly-backup-20250115-143045-2.tar.gz
+	backupNamePrefix       = "chefly-backup-"
+	backupNameLayout       = "20060102-150405"
+	backupNameMaxSequence  = 100
+	backupNameSuffix       = ".tar"
+	backupNameSuffixGzip   = ".tar.gz"
+	backupPartialExtension = ".partial"
+)
+
+// BackupService creates and restores archives of the SQLite database and recipe images
+type BackupService struct {
+	db        *sql.DB
+	dialect   database.Dialect
+	dbPath    string
+	images    ImageStore
+	backupDir string
+	compress  bool
+}
+
+// NewBackupService creates a new backup service
+// backupDir is where archives created by the admin endpoint and scheduler are written, gzip compressed if compress is set
+func NewBackupService(db *sql.DB, dialect database.Dialect, dbPath string, images ImageStore, backupDir string, compress bool) *BackupService {
+	return &BackupService{
+		db:        db,
+		dialect:   dialect,
+		dbPath:    dbPath,
+		images:    images,
+		backupDir: backupDir,
+		compress:  compress,
+	}
+}
+
+// Create writes a new backup archive to the backup directory
+func (s *BackupService) Create() (*models.BackupInfo, error) {
+	if err := os.MkdirAll(s.backupDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	suffix := backupNameSuffix
+	if s.compress {
+		suffix = backupNameSuffixGzip
+	}
+	archivePath, err := reserveBackupName(s.backupDir, time.Now().UTC(), suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	backup, err := s.CreateAt(archivePath)
+	if err != nil {
+		os.Remove(archivePath)
+		return nil, err
+	}
+	return backup, nil
+}
+
+// reserveBackupName creates an empty archive file with a name no other backup uses
+// The file is created exclusively, so concurrent backups within the same second never overwrite each other
+func reserveBackupName(backupDir string, createdAt time.Time, suffix string) (string, error) {
+	stamp := createdAt.Format(backupNameLayout)
+	for sequence := 1; sequence <= backupNameMaxSequence; sequence++ {
+		name := backupNamePrefix + stamp + suffix
+		if sequence > 1 {
+			name = fmt.Sprintf("%s%s-%d%s", backupNamePrefix, stamp, sequence, suffix)
+		}
+
+		archivePath := filepath.Join(backupDir, name)
+		file, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create backup archive: %w", err)
+		}
+		file.Close()
+		return archivePath, nil
+	}
+	return "", fmt.Errorf("failed to create backup archive: %d backups named %s already exist", backupNameMaxSequence, stamp)
+}
+
+// List returns the backups in the backup directory, newest first
+// Only archives named by Create are listed, their creation time is taken from the name
+func (s *BackupService) List() ([]models.BackupInfo, error) {
+	entries, err := os.ReadDir(s.backupDir)
+	if os.IsNotExist(err) {
+		return []models.BackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	backups := []models.BackupInfo{}
+	sequences := make(map[string]int)
+	for _, entry := range entries {
+		createdAt, sequence, ok := parseBackupName(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed meanwhile
+		}
+		if info.Size() == 0 {
+			continue // Name reserved by a backup still being written
+		}
+		sequences[entry.Name()] = sequence
+		backups = append(backups, models.BackupInfo{
+			Name:      entry.Name(),
+			Path:      filepath.Join(s.backupDir, entry.Name()),
+			CreatedAt: createdAt,
+			SizeBytes: info.Size(),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return sequences[backups[i].Name] > sequences[backups[j].Name]
+	})
+	return backups, nil
+}
+
+// Prune deletes backups that are not kept by the retention policy and returns the deleted backups
+func (s *BackupService) Prune(retention models.BackupRetention) ([]models.BackupInfo, error) {
+	backups, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := []models.BackupInfo{}
+	for _, backup := range backupsToPrune(backups, retention) {
+		if err := os.Remove(backup.Path); err != nil {
+			return deleted, fmt.Errorf("failed to delete backup %s: %w", backup.Name, err)
+		}
+		deleted = append(deleted, backup)
+	}
+	return deleted, nil
+}
+
+// CreateAt writes a backup archive to the given path
+// The database is copied with SQLite's online backup API, so the server keeps serving requests meanwhile
+func (s *BackupService) CreateAt(archivePath string) (*models.BackupInfo, error) {
+	if s.dialect != database.SQLite {
+		return nil, ErrBackupUnsupported
+	}
+
+	workDir, err := os.MkdirTemp(filepath.Dir(s.dbPath), ".backup-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	// Snapshot the database
+	snapshotPath := filepath.Join(workDir, "chefly.db")
+	if err := s.snapshotDatabase(snapshotPath); err != nil {
+		return nil, fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	manifest := models.BackupManifest{
+		FormatVersion: backupFormatVersion,
+		CreatedAt:     time.Now().UTC(),
+	}
+	manifest.SchemaVersion, err = schemaVersion(snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	manifest.DatabaseSHA256, manifest.DatabaseBytes, err = fileChecksum(snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum database snapshot: %w", err)
+	}
+
+	images, err := s.images.List("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	for _, image := range images {
+		manifest.ImageCount++
+		manifest.ImageBytes += image.Size
+	}
+
+	// Write to a temporary file first so a failed backup never leaves a truncated archive behind
+	tempPath := archivePath + backupPartialExtension
+	compress := s.compress || strings.HasSuffix(archivePath, ".gz")
+	if err := writeBackupArchive(tempPath, compress, manifest, snapshotPath, s.images, images); err != nil {
+		os.Remove(tempPath)
+		return nil, err
+	}
+	if err := os.Rename(tempPath, archivePath); err != nil {
+		os.Remove(tempPath)
+		return nil, fmt.Errorf("failed to finalize backup archive: %w", err)
+	}
+
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat backup archive: %w", err)
+	}
+
+	return &models.BackupInfo{
+		Name:      filepath.Base(archivePath),
+		Path:      archivePath,
+		CreatedAt: manifest.CreatedAt,
+		SizeBytes: info.Size(),
+		Manifest:  manifest,
+	}, nil
+}
+
+// Restore replaces the database with the one in the archive and copies its images into the image store
+// The archive is fully validated and migrated in a staging directory before anything is replaced
+// The server must be stopped: the database file is swapped underneath any open connection
+// A failed restore returns a *RestoreError telling which stage failed and what was already replaced
+func (s *BackupService) Restore(archivePath string) (*models.RestoreReport, error) {
+	if s.dialect != database.SQLite {
+		return nil, ErrBackupUnsupported
+	}
+
+	stagingDir, err := os.MkdirTemp(filepath.Dir(s.dbPath), ".restore-")
+	if err != nil {
+		return nil, &RestoreError{Stage: "create staging directory", Err: err}
+	}
+	defer os.RemoveAll(stagingDir)
+
+	// Extract and validate the archive
+	manifest, err := extractBackupArchive(archivePath, stagingDir)
+	if err != nil {
+		return nil, &RestoreError{Stage: "extract archive", Err: err}
+	}
+
+	stagedDB := filepath.Join(stagingDir, filepath.FromSlash(backupDatabaseFile))
+	checksum, _, err := fileChecksum(stagedDB)
+	if err != nil {
+		return nil, &RestoreError{Stage: "verify database checksum", Err: fmt.Errorf("%w: %v", ErrInvalidBackup, err)}
+	}
+	if checksum != manifest.DatabaseSHA256 {
+		return nil, &RestoreError{Stage: "verify database checksum", Err: fmt.Errorf("%w: database checksum does not match the manifest", ErrInvalidBackup)}
+	}
+
+	report := &models.RestoreReport{Manifest: *manifest, MissingImages: []string{}}
+
+	// Check integrity and bring the schema up to date before it replaces the live database
+	report.MigrationsApplied, report.ForeignKeyViolations, err = prepareRestoredDatabase(stagedDB)
+	if err != nil {
+		return nil, &RestoreError{Stage: "prepare database", Err: err}
+	}
+
+	// Copy images first: if this fails, the current database is still in place
+	stagedUploads := filepath.Join(stagingDir, filepath.FromSlash(strings.TrimSuffix(backupUploadsDir, "/")))
+	report.ImagesRestored, err = restoreImages(stagedUploads, s.images)
+	if err != nil {
+		return nil, &RestoreError{Stage: "restore images", ImagesRestored: report.ImagesRestored, Err: err}
+	}
+
+	report.PreviousDatabase, err = s.swapDatabase(stagedDB)
+	if err != nil {
+		return nil, &RestoreError{
+			Stage:            "swap database",
+			ImagesRestored:   report.ImagesRestored,
+			PreviousDatabase: report.PreviousDatabase,
+			Err:              err,
+		}
+	}
+
+	// Referential integrity between recipes and stored images
+	report.MissingImages, err = s.missingImages()
+	if err != nil {
+		return nil, &RestoreError{
+			Stage:            "verify recipe images",
+			ImagesRestored:   report.ImagesRestored,
+			DatabaseReplaced: true,
+			PreviousDatabase: report.PreviousDatabase,
+			Err:              err,
+		}
+	}
+
+	return report, nil
+}
+
+// snapshotDatabase copies the live database to destPath with SQLite's online backup API
+func (s *BackupService) snapshotDatabase(destPath string) error {
+	ctx := context.Background()
+
+	srcConn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	destDB, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return err
+	}
+	defer destDB.Close()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	err = destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			dest, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected destination driver connection %T", destDriverConn)
+			}
+			src, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected source driver connection %T", srcDriverConn)
+			}
+
+			backup, err := dest.Backup("main", src, "main")
+			if err != nil {
+				return err
+			}
+			// Copy all pages in one step, WAL readers do not block concurrent writers
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// The copied header keeps the source's WAL flag, switch back so the snapshot is a single self-contained file
+	_, err = destConn.ExecContext(ctx, "PRAGMA journal_mode = DELETE")
+	return err
+}
+
+// swapDatabase moves the current database aside and puts the restored one in its place
+// Returns the path the previous database was moved to, also on failure if it could not be moved back
+func (s *BackupService) swapDatabase(restoredPath string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(s.dbPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	previousPath := ""
+	if _, err := os.Stat(s.dbPath); err == nil {
+		previousPath, err = unusedPath(s.dbPath + ".pre-restore-" + time.Now().UTC().Format(backupNameLayout))
+		if err != nil {
+			return "", err
+		}
+		if err := moveDatabaseFiles(s.dbPath, previousPath); err != nil {
+			return s.undoSwap(previousPath, fmt.Errorf("failed to move current database aside: %w", err))
+		}
+	}
+
+	if err := os.Rename(restoredPath, s.dbPath); err != nil {
+		return s.undoSwap(previousPath, fmt.Errorf("failed to install restored database: %w", err))
+	}
+
+	return previousPath, nil
+}
+
+// undoSwap moves the previous database back after a failed swap
+// Returns where the previous database is left if that fails too
+func (s *BackupService) undoSwap(previousPath string, swapErr error) (string, error) {
+	if previousPath == "" {
+		return "", swapErr
+	}
+	if err := moveDatabaseFiles(previousPath, s.dbPath); err != nil {
+		return previousPath, fmt.Errorf("%w, moving the previous database back also failed: %v", swapErr, err)
+	}
+	return "", swapErr
+}
+
+// moveDatabaseFiles renames a SQLite database together with its WAL and shared memory files
+func moveDatabaseFiles(fromPath, toPath string) error {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if _, err := os.Stat(fromPath + suffix); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(fromPath+suffix, toPath+suffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unusedPath returns basePath, or basePath with a sequence number if a file with that name exists
+func unusedPath(basePath string) (string, error) {
+	for sequence := 1; sequence <= backupNameMaxSequence; sequence++ {
+		candidate := basePath
+		if sequence > 1 {
+			candidate = fmt.Sprintf("%s-%d", basePath, sequence)
+		}
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no unused file name for %s", basePath)
+}
+
+// missingImages returns image paths referenced by recipes and photos in the restored database that are not in the image store
+func (s *BackupService) missingImages() ([]string, error) {
+	db, err := sql.Open("sqlite3", s.dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	stores := store.New(db, database.SQLite)
+	images, err := stores.Recipes.ListAllImages()
+	if err != nil {
+		return nil, err
+	}
+	photoImages, err := stores.Photos.ListAllImages()
+	if err != nil {
+		return nil, err
+	}
+	images = append(images, photoImages...)
+
+	missing := []string{}
+	for _, image := range images {
+		for _, urlPath := range image.Paths() {
+			// Only images served from /uploads are held by the image store (not inline data: URLs)
+			key, ok := ImageKeyFromPath(urlPath)
+			if !ok {
+				continue
+			}
+			exists, err := s.images.Exists(key)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				missing = append(missing, urlPath)
+			}
+		}
+	}
+
+	return missing, nil
+}
+
+// writeBackupArchive writes the manifest, database snapshot and images to a tarball, optionally gzip compressed
+func writeBackupArchive(archivePath string, compress bool, manifest models.BackupManifest, snapshotPath string, imageStore ImageStore, images []ImageObject) error {
+	file, err := os.OpenFile(archivePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup archive: %w", err)
+	}
+	defer file.Close()
+
+	var archiveWriter io.Writer = file
+	var gzipWriter *gzip.Writer
+	if compress {
+		gzipWriter = gzip.NewWriter(file)
+		archiveWriter = gzipWriter
+	}
+	tarWriter := tar.NewWriter(archiveWriter)
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup manifest: %w", err)
+	}
+	if err := writeTarBytes(tarWriter, backupManifestFile, manifestJSON, manifest.CreatedAt); err != nil {
+		return err
+	}
+	if err := writeTarFile(tarWriter, backupDatabaseFile, snapshotPath); err != nil {
+		return err
+	}
+	for _, image := range images {
+		if err := writeTarImage(tarWriter, backupUploadsDir+image.Key, imageStore, image.Key); err != nil {
+			// Images deleted while the backup was running are skipped
+			if errors.Is(err, ErrImageNotFound) {
+				continue
+			}
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write backup archive: %w", err)
+	}
+	if gzipWriter != nil {
+		if err := gzipWriter.Close(); err != nil {
+			return fmt.Errorf("failed to write backup archive: %w", err)
+		}
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to write backup archive: %w", err)
+	}
+	return file.Close()
+}
+
+// writeTarBytes adds an in-memory file to the archive
+func writeTarBytes(tarWriter *tar.Writer, name string, content []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s to backup archive: %w", name, err)
+	}
+	if _, err := tarWriter.Write(content); err != nil {
+		return fmt.Errorf("failed to write %s to backup archive: %w", name, err)
+	}
+	return nil
+}
+
+// writeTarFile adds a file from disk to the archive
+func writeTarFile(tarWriter *tar.Writer, name, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+
+	header := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s to backup archive: %w", name, err)
+	}
+	// The size is fixed in the header, copy exactly that many bytes even if the file grows meanwhile
+	if _, err := io.CopyN(tarWriter, file, info.Size()); err != nil {
+		return fmt.Errorf("failed to write %s to backup archive: %w", name, err)
+	}
+	return nil
+}
+
+// writeTarImage adds an image from the image store to the archive
+func writeTarImage(tarWriter *tar.Writer, name string, imageStore ImageStore, key string) error {
+	reader, image, err := imageStore.Get(key)
+	if err != nil {
+		return fmt.Errorf("failed to read image %s: %w", key, err)
+	}
+	defer reader.Close()
+
+	header := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     image.Size,
+		ModTime:  image.ModTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s to backup archive: %w", name, err)
+	}
+	if _, err := io.CopyN(tarWriter, reader, image.Size); err != nil {
+		return fmt.Errorf("failed to write %s to backup archive: %w", name, err)
+	}
+	return nil
+}
+
+// extractBackupArchive validates the archive layout and extracts it into destDir
+func extractBackupArchive(archivePath, destDir string) (*models.BackupManifest, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup archive: %w", err)
+	}
+	defer file.Close()
+
+	// Archives may be plain or gzip compressed tarballs, detected by the gzip magic number
+	buffered := bufio.NewReader(file)
+	var archiveReader io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("%w: corrupt gzip stream: %v", ErrInvalidBackup, err)
+		}
+		defer gzipReader.Close()
+		archiveReader = gzipReader
+	}
+
+	var manifest *models.BackupManifest
+	hasDatabase := false
+	tarReader := tar.NewReader(archiveReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+
+		// Reject absolute paths and path traversal (e.g. "../../etc/passwd")
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("%w: unsafe path %q", ErrInvalidBackup, header.Name)
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: unsupported entry type for %q", ErrInvalidBackup, header.Name)
+		}
+
+		switch {
+		case name == backupManifestFile:
+			manifest = &models.BackupManifest{}
+			if err := json.NewDecoder(io.LimitReader(tarReader, 1<<20)).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("%w: unreadable manifest: %v", ErrInvalidBackup, err)
+			}
+		case name == backupDatabaseFile:
+			hasDatabase = true
+			if err := extractTarFile(tarReader, filepath.Join(destDir, filepath.FromSlash(name))); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(name, backupUploadsDir):
+			if err := extractTarFile(tarReader, filepath.Join(destDir, filepath.FromSlash(name))); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidBackup, header.Name)
+		}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidBackup, backupManifestFile)
+	}
+	if manifest.FormatVersion != backupFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrInvalidBackup, manifest.FormatVersion)
+	}
+	if !hasDatabase {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidBackup, backupDatabaseFile)
+	}
+
+	return manifest, nil
+}
+
+// extractTarFile writes the current archive entry to destPath
+func extractTarFile(tarReader *tar.Reader, destPath string) error {
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("failed to extract backup archive: %w", err)
+	}
+
+	file, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to extract backup archive: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, tarReader); err != nil {
+		return fmt.Errorf("%w: truncated entry %s: %v", ErrInvalidBackup, filepath.Base(destPath), err)
+	}
+	return file.Close()
+}
+
+// prepareRestoredDatabase checks the integrity of a restored database and applies pending migrations
+// Returns the number of migrations applied and the number of rows violating foreign keys
+func prepareRestoredDatabase(dbPath string) (int, int, error) {
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	var integrity string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return 0, 0, fmt.Errorf("%w: database is not readable: %v", ErrInvalidBackup, err)
+	}
+	if integrity != "ok" {
+		return 0, 0, fmt.Errorf("%w: database integrity check failed: %s", ErrInvalidBackup, integrity)
+	}
+
+	applied, err := database.NewMigrator(db, database.SQLite).Migrate()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to migrate restored database: %w", err)
+	}
+
+	// Rows pointing at missing parents (e.g. recipes of a deleted user) are reported, not rejected:
+	// databases created before foreign keys were enabled on every connection may contain them
+	rows, err := db.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	violations := 0
+	for rows.Next() {
+		violations++
+	}
+	return applied, violations, rows.Err()
+}
+
+// restoreImages stores every regular file below srcDir in the image store, replacing images with the same key
+// Returns the number of images restored
+func restoreImages(srcDir string, imageStore ImageStore) (int, error) {
+	count := 0
+
+	err := filepath.Walk(srcDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && filePath == srcDir {
+				return filepath.SkipDir // Backup without images
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(srcDir, filePath)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if err := imageStore.Put(key, data, imageContentType(key)); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+
+	return count, err
+}
+
+// fileChecksum returns the hex encoded SHA-256 and size of a file
+func fileChecksum(filePath string) (string, int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// schemaVersion returns the latest migration applied to a SQLite database file
+func schemaVersion(dbPath string) (int, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	statuses, err := database.NewMigrator(db, database.SQLite).Status()
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for _, status := range statuses {
+		if status.Applied && status.Version > version {
+			version = status.Version
+		}
+	}
+	return version, nil
+}
+
+// parseBackupName returns the creation time and sequence number (1 if none) encoded in a backup archive name
+func parseBackupName(name string) (time.Time, int, bool) {
+	if !strings.HasPrefix(name, backupNamePrefix) {
+		return time.Time{}, 0, false
+	}
+
+	stamp := strings.TrimPrefix(name, backupNamePrefix)
+	switch {
+	case strings.HasSuffix(stamp, backupNameSuffixGzip):
+		stamp = strings.TrimSuffix(stamp, backupNameSuffixGzip)
+	case strings.HasSuffix(stamp, backupNameSuffix):
+		stamp = strings.TrimSuffix(stamp, backupNameSuffix)
+	default:
+		return time.Time{}, 0, false
+	}
+
+	sequence := 1
+	if len(stamp) > len(backupNameLayout) {
+		if stamp[len(backupNameLayout)] != '-' {
+			return time.Time{}, 0, false
+		}
+		parsed, err := strconv.Atoi(stamp[len(backupNameLayout)+1:])
+		if err != nil || parsed < 2 {
+			return time.Time{}, 0, false
+		}
+		sequence = parsed
+		stamp = stamp[:len(backupNameLayout)]
+	}
+
+	createdAt, err := time.Parse(backupNameLayout, stamp)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return createdAt, sequence, true
+}
+
+// backupsToPrune applies a grandfather-father-son retention policy to backups sorted newest first
+// Returns the backups not kept by any rule
+func backupsToPrune(backups []models.BackupInfo, retention models.BackupRetention) []models.BackupInfo {
+	keep := make(map[string]bool)
+
+	// keepNewestPerPeriod keeps the newest backup of each of the most recent periods
+	keepNewestPerPeriod := func(periods int, period func(time.Time) string) {
+		seen := make(map[string]bool)
+		for _, backup := range backups {
+			if len(seen) >= periods {
+				return
+			}
+			key := period(backup.CreatedAt)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			keep[backup.Name] = true
+		}
+	}
+
+	// The newest backup is always kept, whatever the policy
+	keepLast := retention.KeepLast
+	if keepLast < 1 {
+		keepLast = 1
+	}
+	for i := 0; i < len(backups) && i < keepLast; i++ {
+		keep[backups[i].Name] = true
+	}
+
+	keepNewestPerPeriod(retention.KeepDaily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepNewestPerPeriod(retention.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	keepNewestPerPeriod(retention.KeepMonthly, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+
+	prune := []models.BackupInfo{}
+	for _, backup := range backups {
+		if !keep[backup.Name] {
+			prune = append(prune, backup)
+		}
+	}
+	return prune
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"chefly/database"
+)
+
+// newTestBackupService creates a backup service for a migrated SQLite database with two images
+func newTestBackupService(t *testing.T) (*BackupService, string) {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "chefly.db")
+
+	db, err := database.InitDB(database.SQLite, dbPath, database.Options{BusyTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.RunMigrations(db, database.SQLite); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+
+	images := NewLocalImageStore(filepath.Join(dir, "uploads"))
+	for _, key := range []string{"a.jpg", "b.jpg"} {
+		if err := images.Put(key, []byte("image "+key), "image/jpeg"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	return NewBackupService(db, database.SQLite, dbPath, images, filepath.Join(dir, "backups"), true), dbPath
+}
+
+// failingImageStore accepts a number of images and then fails
+type failingImageStore struct {
+	ImageStore
+	accept int
+}
+
+func (s *failingImageStore) Put(key string, data []byte, contentType string) error {
+	if s.accept == 0 {
+		return errors.New("disk full")
+	}
+	s.accept--
+	return s.ImageStore.Put(key, data, contentType)
+}
+
+func TestBackupNamesWithinOneSecond(t *testing.T) {
+	dir := t.TempDir()
+	createdAt := time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)
+
+	want := []string{
+		"chefly-backup-20250115-143045.tar.gz",
+		"chefly-backup-20250115-143045-2.tar.gz",
+		"chefly-backup-20250115-143045-3.tar.gz",
+	}
+	for i, name := range want {
+		archivePath, err := reserveBackupName(dir, createdAt, backupNameSuffixGzip)
+		if err != nil {
+			t.Fatalf("reserveBackupName: %v", err)
+		}
+		if filepath.Base(archivePath) != name {
+			t.Errorf("name %d = %s, want %s", i, filepath.Base(archivePath), name)
+		}
+		parsed, sequence, ok := parseBackupName(name)
+		if !ok || !parsed.Equal(createdAt) || sequence != i+1 {
+			t.Errorf("parseBackupName(%s) = %v, %d, %v", name, parsed, sequence, ok)
+		}
+	}
+
+	for _, name := range []string{
+		"chefly-backup-20250115-143045-1.tar.gz",
+		"chefly-backup-20250115-143045-x.tar.gz",
+		"chefly-backup-20250115-143045x2.tar",
+		"chefly-backup-20250115.tar",
+	} {
+		if _, _, ok := parseBackupName(name); ok {
+			t.Errorf("parseBackupName(%s) accepted an invalid name", name)
+		}
+	}
+}
+
+func TestBackupCreateDoesNotOverwrite(t *testing.T) {
+	service, _ := newTestBackupService(t)
+
+	first, err := service.Create()
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	second, err := service.Create()
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if first.Path == second.Path {
+		t.Fatalf("both backups were written to %s", first.Path)
+	}
+
+	// A name reserved by a backup still being written is not listed
+	if _, err := reserveBackupName(service.backupDir, time.Now().UTC(), backupNameSuffixGzip); err != nil {
+		t.Fatalf("reserveBackupName: %v", err)
+	}
+	backups, err := service.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(backups) != 2 || backups[0].Name != second.Name || backups[1].Name != first.Name {
+		t.Errorf("backups = %+v, want %s and %s, newest first", backups, second.Name, first.Name)
+	}
+}
+
+func TestRestoreReportsFailedStage(t *testing.T) {
+	service, dbPath := newTestBackupService(t)
+	backup, err := service.Create()
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	service.db.Close()
+
+	before, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	// An invalid archive fails before anything is touched
+	_, err = service.Restore(filepath.Join(t.TempDir(), "missing.tar.gz"))
+	var restoreErr *RestoreError
+	if !errors.As(err, &restoreErr) {
+		t.Fatalf("Restore(missing) error = %v, want a RestoreError", err)
+	}
+	if restoreErr.Stage != "extract archive" || restoreErr.ImagesRestored != 0 || restoreErr.DatabaseReplaced || restoreErr.PreviousDatabase != "" {
+		t.Errorf("restore error = %+v, want a failed extraction with nothing replaced", restoreErr)
+	}
+
+	// Images are restored before the database, a failure leaves the database in place
+	service.images = &failingImageStore{ImageStore: service.images, accept: 1}
+	_, err = service.Restore(backup.Path)
+	if !errors.As(err, &restoreErr) {
+		t.Fatalf("Restore error = %v, want a RestoreError", err)
+	}
+	if restoreErr.Stage != "restore images" || restoreErr.ImagesRestored != 1 || restoreErr.DatabaseReplaced || restoreErr.PreviousDatabase != "" {
+		t.Errorf("restore error = %+v, want a failed image restore after 1 image", restoreErr)
+	}
+	after, err := os.ReadFile(dbPath)
+	if err != nil || string(after) != string(before) {
+		t.Errorf("database changed by a failed restore (%v)", err)
+	}
+}
@@ -2,11 +2,11 @@ package services
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"chefly/models"
 
@@ -25,72 +25,159 @@ var (
 
 // ClaudeService handles Claude API interactions
 type ClaudeService struct {
-	client *anthropic.Client
-	model  string
+	client           *anthropic.Client
+	model            string
+	recipeTimeout    time.Duration
+	nutritionTimeout time.Duration
+	resilience       *AIResilience
+	usage            *AIUsageRecorder
+	auditLogger      *AuditLogger
 }
 
+// maxRecipeRepairs is how many times an invalid recipe response is sent back to Claude for correction
+const maxRecipeRepairs = 2
+
 // NewClaudeService creates a new Claude service
-func NewClaudeService(apiKey, model string) *ClaudeService {
+// The timeouts bound a single recipe generation and nutrition estimation call including retries (0 = no limit besides the request context)
+func NewClaudeService(apiKey, model string, recipeTimeout, nutritionTimeout time.Duration, resilience *AIResilience, usage *AIUsageRecorder, auditLogger *AuditLogger) *ClaudeService {
 	client := anthropic.NewClient(
 		option.WithAPIKey(apiKey),
+		option.WithHTTPClient(NewAIHTTPClient()),
+		option.WithMaxRetries(0), // Retried by AIResilience
 	)
 	return &ClaudeService{
-		client: &client,
-		model:  model,
+		client:           &client,
+		model:            model,
+		recipeTimeout:    recipeTimeout,
+		nutritionTimeout: nutritionTimeout,
+		resilience:       resilience,
+		usage:            usage,
+		auditLogger:      auditLogger,
 	}
 }
 
 // GenerateRecipe generates a recipe using Claude AI
-func (s *ClaudeService) GenerateRecipe(req models.RecipeGenerationRequest) (*models.RecipeDetail, error) {
+// Responses failing validation are sent back to Claude with the problems, up to maxRecipeRepairs times
+// Cancelling ctx (e.g. the client disconnected) aborts the API call
+func (s *ClaudeService) GenerateRecipe(ctx context.Context, req models.RecipeGenerationRequest) (*models.RecipeDetail, error) {
+	ctx, cancel := withTimeout(ctx, s.recipeTimeout)
+	defer cancel()
+
 	// Build the prompt based on filters
-	prompt := s.buildRecipePrompt(req)
-
-	// Call Claude API using configured model
-	message, err := s.client.Messages.New(context.Background(), anthropic.MessageNewParams{
-		Model:     anthropic.Model(s.model),
-		MaxTokens: 4096,
-		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
-		},
-	})
+	messages := []anthropic.MessageParam{
+		anthropic.NewUserMessage(anthropic.NewTextBlock(s.buildRecipePrompt(req))),
+	}
 
-	if err != nil {
-		// Check if it's a rate limit error
-		errStr := err.Error()
-		if strings.Contains(errStr, "429") || strings.Contains(strings.ToLower(errStr), "rate limit") {
-			return nil, fmt.Errorf("%w: please wait a moment before generating another recipe", ErrRateLimit)
+	for repair := 0; ; repair++ {
+		operation := "recipe"
+		if repair > 0 {
+			operation = "recipe_repair"
 		}
-		// Check for invalid model error
-		if strings.Contains(strings.ToLower(errStr), "model") || strings.Contains(errStr, "404") {
-			return nil, fmt.Errorf("%w: model '%s' not found or not accessible. API error: %v", ErrAPIConnection, s.model, err)
+		message, err := s.sendRecipeMessages(ctx, operation, messages)
+		if err != nil {
+			return nil, err
 		}
-		// Generic API connection error
-		return nil, fmt.Errorf("%w: %v", ErrAPIConnection, err)
-	}
 
-	// Extract text from response
-	var responseText string
-	for _, block := range message.Content {
-		if block.Type == "text" {
-			responseText += block.Text
+		// Extract text from response
+		var responseText string
+		for _, block := range message.Content {
+			if block.Type == "text" {
+				responseText += block.Text
+			}
+		}
+
+		if responseText == "" {
+			return nil, ErrEmptyResponse
+		}
+
+		// Parse and validate the JSON response
+		recipe, err := s.parseRecipeResponse(responseText, req)
+		if err == nil {
+			return recipe, nil
+		}
+
+		var validationErr *RecipeValidationError
+		if !errors.As(err, &validationErr) || repair >= maxRecipeRepairs {
+			return nil, err
+		}
+		if message.StopReason == anthropic.StopReasonMaxTokens {
+			validationErr.Problems = append(validationErr.Problems, "The response was cut off at the length limit, keep the recipe shorter")
+		}
+
+		// Log repair round-trip
+		if s.auditLogger != nil {
+			s.auditLogger.Warn("ai.recipe_repair", "Generated recipe failed validation, asking Claude to repair it", &models.AuditContext{
+				Metadata: map[string]interface{}{
+					"repair":   repair + 1,
+					"problems": validationErr.Problems,
+				},
+			})
 		}
-	}
 
-	if responseText == "" {
-		return nil, ErrEmptyResponse
+		// Continue the conversation so Claude corrects its own response
+		messages = append(messages,
+			anthropic.NewAssistantMessage(anthropic.NewTextBlock(responseText)),
+			anthropic.NewUserMessage(anthropic.NewTextBlock(buildRepairPrompt(validationErr.Problems))),
+		)
 	}
+}
+
+// sendRecipeMessages calls the Claude API with the configured model (retried on rate limits and server errors)
+// operation names the call in the usage records
+func (s *ClaudeService) sendRecipeMessages(ctx context.Context, operation string, messages []anthropic.MessageParam) (*anthropic.Message, error) {
+	var message *anthropic.Message
+	err := s.resilience.Do(ctx, "recipe", func(ctx context.Context) error {
+		var err error
+		message, err = s.createMessage(ctx, operation, anthropic.MessageNewParams{
+			Model:     anthropic.Model(s.model),
+			MaxTokens: 4096,
+			Messages:  messages,
+		})
+		return err
+	})
 
-	// Parse the JSON response
-	recipe, err := s.parseRecipeResponse(responseText, req)
 	if err != nil {
-		// Check if it's a JSON parsing error
-		if errors.Is(err, ErrInvalidJSON) {
-			return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+		// Canceled by the client or timed out
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return nil, fmt.Errorf("%w: %v", ctxErr, err)
 		}
-		return nil, fmt.Errorf("%w: %v", ErrParsingFailed, err)
+		switch aiStatusCode(err) {
+		case 429:
+			// Still rate limited after retrying
+			return nil, fmt.Errorf("%w: please wait a moment before generating another recipe", ErrRateLimit)
+		case 404:
+			return nil, fmt.Errorf("%w: model '%s' not found or not accessible. API error: %v", ErrAPIConnection, s.model, err)
+		}
+		// Generic API connection error (including an open circuit breaker)
+		return nil, fmt.Errorf("%w: %w", ErrAPIConnection, err)
 	}
 
-	return recipe, nil
+	return message, nil
+}
+
+// createMessage sends one request to the Claude API and records its token usage
+func (s *ClaudeService) createMessage(ctx context.Context, operation string, params anthropic.MessageNewParams) (*anthropic.Message, error) {
+	started := time.Now()
+	message, err := s.client.Messages.New(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	s.usage.Record(ctx, models.AIUsage{
+		Provider:     "claude",
+		Model:        s.modelName(message),
+		Operation:    operation,
+		InputTokens:  message.Usage.InputTokens,
+		OutputTokens: message.Usage.OutputTokens,
+	}, started)
+	return message, nil
+}
+
+// modelName returns the model that answered, which the API resolves from aliases (claude-sonnet-4-0)
+func (s *ClaudeService) modelName(message *anthropic.Message) string {
+	if message.Model != "" {
+		return string(message.Model)
+	}
+	return s.model
 }
 
 // buildRecipePrompt creates a detailed prompt for Claude
@@ -122,6 +209,8 @@ func (s *ClaudeService) buildRecipePrompt(req models.RecipeGenerationRequest) st
 	// Cuisine
 	if req.CuisineType != "" {
 		builder.WriteString(fmt.Sprintf("- Cuisine style: %s\n", req.CuisineType))
+	} else if len(req.PreferredCuisines) > 0 {
+		builder.WriteString(fmt.Sprintf("- Cuisine style: pick one of %s\n", strings.Join(req.PreferredCuisines, ", ")))
 	}
 
 	// Side ingredients
@@ -131,7 +220,22 @@ func (s *ClaudeService) buildRecipePrompt(req models.RecipeGenerationRequest) st
 
 	// Dietary preferences
 	if len(req.DietaryPreferences) > 0 {
-		builder.WriteString(fmt.Sprintf("- Dietary requirements: %s\n", strings.Join(req.DietaryPreferences, ", ")))
+		builder.WriteString(fmt.Sprintf("- Dietary requirements (STRICT, every ingredient must comply): %s\n", strings.Join(req.DietaryPreferences, ", ")))
+	}
+
+	// Allergies
+	if len(req.Allergies) > 0 {
+		builder.WriteString(fmt.Sprintf("- Allergies (NEVER use these or any ingredient containing them): %s\n", strings.Join(req.Allergies, ", ")))
+	}
+
+	// Disliked ingredients
+	if len(req.DislikedIngredients) > 0 {
+		builder.WriteString(fmt.Sprintf("- Do not use these ingredients: %s\n", strings.Join(req.DislikedIngredients, ", ")))
+	}
+
+	// Serving size
+	if req.Servings > 0 {
+		builder.WriteString(fmt.Sprintf("- Serving size: exactly %d people (scale ingredient quantities accordingly)\n", req.Servings))
 	}
 
 	// Cooking time
@@ -149,6 +253,30 @@ func (s *ClaudeService) buildRecipePrompt(req models.RecipeGenerationRequest) st
 		builder.WriteString(fmt.Sprintf("- Difficulty level: %s\n", req.Difficulty))
 	}
 
+	// Cook's skill level
+	if req.SkillLevel != "" {
+		builder.WriteString(fmt.Sprintf("- Cook's skill level: %s (adapt technique explanations accordingly)\n", req.SkillLevel))
+	}
+
+	// Kitchen equipment
+	if len(req.AvailableEquipment) > 0 {
+		builder.WriteString(fmt.Sprintf("- Available kitchen equipment (STRICT, cook using ONLY these appliances): %s\n", strings.Join(req.AvailableEquipment, ", ")))
+	}
+	if len(req.ExcludedEquipment) > 0 {
+		builder.WriteString(fmt.Sprintf("- Unavailable kitchen equipment (NEVER use it, not even for a single step): %s\n", strings.Join(req.ExcludedEquipment, ", ")))
+	}
+	if len(req.AvailableEquipment) > 0 || len(req.ExcludedEquipment) > 0 {
+		builder.WriteString("- Name the appliance used in every heated step and only set step temperatures for available appliances\n")
+	}
+
+	// Corrections from a rejected previous attempt
+	if len(req.Corrections) > 0 {
+		builder.WriteString("\nA previous version of this recipe was rejected. You MUST fix these problems:\n")
+		for _, correction := range req.Corrections {
+			builder.WriteString(fmt.Sprintf("- %s\n", correction))
+		}
+	}
+
 	builder.WriteString("\nPlease provide a recipe with:\n")
 	builder.WriteString("1. A creative and appetizing title\n")
 	builder.WriteString("2. A brief description (2-3 sentences)\n")
@@ -192,45 +320,12 @@ func (s *ClaudeService) buildRecipePrompt(req models.RecipeGenerationRequest) st
 	return builder.String()
 }
 
-// parseRecipeResponse parses Claude's JSON response into a Recipe struct
+// parseRecipeResponse parses and validates Claude's JSON response into a Recipe struct
+// Returns a *RecipeValidationError describing every problem found
 func (s *ClaudeService) parseRecipeResponse(response string, req models.RecipeGenerationRequest) (*models.RecipeDetail, error) {
-	// Try to extract JSON from the response (Claude sometimes adds text before/after)
-	jsonStart := strings.Index(response, "{")
-	jsonEnd := strings.LastIndex(response, "}")
-
-	if jsonStart == -1 || jsonEnd == -1 {
-		return nil, ErrInvalidJSON
-	}
-
-	jsonStr := response[jsonStart : jsonEnd+1]
-
-	// Parse into a temporary structure
-	// Using json.RawMessage to handle flexible quantity types
-	var temp struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		ServingSize int    `json:"serving_size"`
-		CookingTime int    `json:"cooking_time"`
-		PrepTime    int    `json:"prep_time"`
-		Difficulty  string `json:"difficulty"`
-		Ingredients []struct {
-			Name     string          `json:"name"`
-			Quantity json.RawMessage `json:"quantity"` // Can be string or number
-			Unit     string          `json:"unit"`
-		} `json:"ingredients"`
-		Steps []struct {
-			StepNumber  int    `json:"step_number"`
-			Instruction string `json:"instruction"`
-			Timing      string `json:"timing"`
-			Temperature string `json:"temperature"`
-		} `json:"steps"`
-		Tips        []string `json:"tips"`
-		CuisineType string   `json:"cuisine_type"`
-		MeatType    string   `json:"meat_type"`
-	}
-
-	if err := json.Unmarshal([]byte(jsonStr), &temp); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	temp, err := decodeRecipeResponse(response, req)
+	if err != nil {
+		return nil, err
 	}
 
 	// Convert to RecipeDetail
@@ -244,6 +339,7 @@ func (s *ClaudeService) parseRecipeResponse(response string, req models.RecipeGe
 		Ingredients: make([]models.Ingredient, len(temp.Ingredients)),
 		Steps:       make([]models.CookingStep, len(temp.Steps)),
 		DietaryTags: req.DietaryPreferences,
+		Servings:    temp.ServingSize,
 	}
 
 	// Convert ingredients (handle both string and number quantities)
@@ -281,72 +377,78 @@ func (s *ClaudeService) parseRecipeResponse(response string, req models.RecipeGe
 	return recipe, nil
 }
 
-// generateRecipeImageDescription generates a professional image description for the recipe
-// This creates a data URL with an SVG that represents the dish
-func (s *ClaudeService) generateRecipeImageDescription(title, cuisine, meatType string) string {
-	// Create a professional-looking SVG placeholder with recipe information
-	// Using food-related colors and professional styling
-
-	// Color schemes based on cuisine/meat type
-	colors := s.getRecipeColors(cuisine, meatType)
-
-	// Clean title for display
-	cleanTitle := title
-	if len(cleanTitle) > 30 {
-		cleanTitle = cleanTitle[:27] + "..."
-	}
-
-	// Generate SVG
-	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="800" height="600" viewBox="0 0 800 600">
-		<defs>
-			<linearGradient id="grad1" x1="0%%" y1="0%%" x2="100%%" y2="100%%">
-				<stop offset="0%%" style="stop-color:%s;stop-opacity:1" />
-				<stop offset="100%%" style="stop-color:%s;stop-opacity:1" />
-			</linearGradient>
-		</defs>
-		<rect width="800" height="600" fill="url(#grad1)"/>
-		<circle cx="400" cy="250" r="120" fill="rgba(255,255,255,0.1)" />
-		<circle cx="400" cy="250" r="100" fill="rgba(255,255,255,0.15)" />
-		<circle cx="400" cy="250" r="80" fill="rgba(255,255,255,0.2)" />
-		<text x="400" y="430" font-family="Arial, sans-serif" font-size="36" font-weight="bold" fill="white" text-anchor="middle">%s</text>
-		<text x="400" y="480" font-family="Arial, sans-serif" font-size="24" fill="rgba(255,255,255,0.9)" text-anchor="middle">%s Cuisine</text>
-		<text x="400" y="520" font-family="Arial, sans-serif" font-size="20" fill="rgba(255,255,255,0.8)" text-anchor="middle">🍽️ Professional Recipe</text>
-	</svg>`, colors[0], colors[1], cleanTitle, cuisine)
-
-	// Return as data URL
-	return "data:image/svg+xml;base64," + s.base64Encode(svg)
-}
+// EstimateFoodComposition asks Claude for per-100g nutrition of ingredients missing from the food table
+// Implements NutritionEstimator
+func (s *ClaudeService) EstimateFoodComposition(ctx context.Context, ingredientNames []string) (map[string]FoodComposition, error) {
+	if len(ingredientNames) == 0 {
+		return map[string]FoodComposition{}, nil
+	}
 
-// getRecipeColors returns color scheme based on cuisine and meat type
-func (s *ClaudeService) getRecipeColors(cuisine, meatType string) [2]string {
-	cuisineColors := map[string][2]string{
-		"Italian":        {"#E74C3C", "#C0392B"}, // Red tones
-		"Mexican":        {"#E67E22", "#D35400"}, // Orange tones
-		"Chinese":        {"#E74C3C", "#922B21"}, // Deep red
-		"Indian":         {"#F39C12", "#D68910"}, // Golden
-		"Japanese":       {"#EC7063", "#CB4335"}, // Salmon red
-		"Thai":           {"#28B463", "#1E8449"}, // Green
-		"Mediterranean":  {"#3498DB", "#2874A6"}, // Blue
-		"American":       {"#E67E22", "#CA6F1E"}, // Orange
-		"French":         {"#8E44AD", "#6C3483"}, // Purple
-		"Greek":          {"#3498DB", "#21618C"}, // Blue
-		"Korean":         {"#E74C3C", "#A93226"}, // Red
-		"Vietnamese":     {"#2ECC71", "#27AE60"}, // Green
-		"Spanish":        {"#E67E22", "#BA4A00"}, // Orange-red
-		"Middle Eastern": {"#F4D03F", "#D4AC0D"}, // Gold
-	}
-
-	// Return cuisine-specific colors or default
-	if colors, ok := cuisineColors[cuisine]; ok {
-		return colors
-	}
-
-	// Default professional food colors
-	return [2]string{"#FF6B6B", "#C44569"}
-}
+	var builder strings.Builder
+	builder.WriteString("You are a nutrition database. For each ingredient below, estimate typical nutritional values per 100 g of the raw ingredient as sold.\n")
+	builder.WriteString("Ingredient names may be in any language.\n\n")
+	builder.WriteString("Ingredients:\n")
+	for _, name := range ingredientNames {
+		builder.WriteString(fmt.Sprintf("- %s\n", name))
+	}
+	builder.WriteString("\nReturn ONLY valid JSON: an object keyed by the ingredient name EXACTLY as given above, with NUMBER values:\n")
+	builder.WriteString(`{
+  "ingredient name": {
+    "calories": 120,
+    "protein_g": 22.5,
+    "fat_g": 2.6,
+    "carbohydrates_g": 0,
+    "fiber_g": 0,
+    "sodium_mg": 45,
+    "grams_per_piece": 170,
+    "density_g_per_ml": 0
+  }
+}`)
+	builder.WriteString("\n\ngrams_per_piece is the weight of one typical piece/clove/slice (0 if not applicable). density_g_per_ml is 0 for solids.")
+
+	ctx, cancel := withTimeout(ctx, s.nutritionTimeout)
+	defer cancel()
+
+	var message *anthropic.Message
+	err := s.resilience.Do(ctx, "nutrition", func(ctx context.Context) error {
+		var err error
+		message, err = s.createMessage(ctx, "nutrition", anthropic.MessageNewParams{
+			Model:     anthropic.Model(s.model),
+			MaxTokens: 2048,
+			Messages: []anthropic.MessageParam{
+				anthropic.NewUserMessage(anthropic.NewTextBlock(builder.String())),
+			},
+		})
+		return err
+	})
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return nil, fmt.Errorf("%w: %v", ctxErr, err)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrAPIConnection, err)
+	}
 
-// base64Encode encodes a string to base64
-func (s *ClaudeService) base64Encode(str string) string {
-	return base64.StdEncoding.EncodeToString([]byte(str))
-}
+	var responseText string
+	for _, block := range message.Content {
+		if block.Type == "text" {
+			responseText += block.Text
+		}
+	}
 
+	object, err := extractJSONObject(responseText)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+
+	var estimates map[string]FoodComposition
+	if err := json.Unmarshal(object, &estimates); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+
+	for name, food := range estimates {
+		food.Name = name
+		estimates[name] = food
+	}
+
+	return estimates, nil
+}
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"chefly/models"
+)
+
+// ErrDietaryViolation is returned when a generated recipe keeps violating the requested diets
+var ErrDietaryViolation = errors.New("recipe violates dietary requirements")
+
+// Carbohydrate limits per serving used to verify carb-restricted diets
+const (
+	lowCarbMaxCarbsPerServing = 30.0 // grams of carbohydrates
+	ketoMaxNetCarbsPerServing = 20.0 // grams of carbohydrates minus fiber
+)
+
+// allergenRule describes how to detect one of the EU's 14 major allergens
+// (Regulation (EU) No 1169/2011, Annex II)
+type allergenRule struct {
+	code       string
+	keywords   []string // Matched at word start ("egg" also matches "eggs"), a trailing space requires a whole word
+	exclusions []string // Phrases removed before matching (e.g. "coconut milk" for milk)
+}
+
+var allergenRules = []allergenRule{
+	{
+		code: "gluten",
+		keywords: []string{"wheat", "flour", "bread", "pasta", "spaghetti", "penne", "fusilli", "tagliatelle",
+			"linguine", "macaroni", "fettuccine", "rigatoni", "farfalle", "lasagna", "lasagne", "noodle", "couscous",
+			"bulgur", "semolina", "barley", "rye", "spelt", "oats", "oatmeal", "breadcrumb", "panko", "tortilla",
+			"pita", "baguette", "ciabatta", "croissant", "soy sauce", "beer", "seitan", "gnocchi", "dumpling",
+			"pastry", "pizza dough", "cracker",
+			"múka", "chlieb", "cestoviny", "rezance", "strúhanka", "jačmeň", "raž", "pšen", "knedľ", "halušk"},
+		exclusions: []string{"gluten-free", "gluten free", "bezlepk", "pasta sauce", "rice flour", "rice noodle", "rice vermicelli",
+			"corn flour", "cornflour", "almond flour", "coconut flour", "buckwheat", "chickpea flour",
+			"potato flour", "tapioca flour", "tamari", "corn tortilla", "glass noodle"},
+	},
+	{
+		code: "crustaceans",
+		keywords: []string{"shrimp", "prawn", "crab", "lobster", "crayfish", "langoustine", "scampi", "krill",
+			"krevet", "krab", "homár", "rak"},
+	},
+	{
+		code:       "eggs",
+		keywords:   []string{"egg", "mayonnaise", "mayo", "meringue", "aioli", "vajc", "vajec", "vajíč", "žĺtk", "bielk", "majonéz"},
+		exclusions: []string{"eggplant", "egg-free", "egg free", "vegan mayo"},
+	},
+	{
+		code: "fish",
+		keywords: []string{"fish", "salmon", "cod", "tuna", "trout", "anchov", "sardine", "mackerel", "haddock",
+			"halibut", "tilapia", "sea bass", "seabass", "hake", "pollock", "herring", "carp", "pike", "catfish",
+			"swordfish", "worcestershire",
+			"ryb", "losos", "tuniak", "pstruh", "treska", "sardink", "makrel", "kapor", "sleď", "šťuk"},
+	},
+	{
+		code:     "peanuts",
+		keywords: []string{"peanut", "groundnut", "arašid", "búrsk"},
+	},
+	{
+		code:     "soybeans",
+		keywords: []string{"soy", "soya", "tofu", "tempeh", "edamame", "miso", "tamari", "sój"},
+	},
+	{
+		code: "milk",
+		keywords: []string{"milk", "butter", "cream", "cheese", "yogurt", "yoghurt", "parmesan", "mozzarella",
+			"cheddar", "feta", "ricotta", "mascarpone", "gouda", "brie", "camembert", "ghee", "whey", "buttermilk",
+			"creme fraiche", "crème fraîche", "kefir", "paneer", "halloumi", "grana padano", "pecorino", "gruyere",
+			"mliek", "mlieko", "maslo", "smotan", "syr ", "syra", "syre", "syrom", "syrov", "jogurt", "tvaroh", "bryndz", "parmezán", "mozzarell"},
+		exclusions: []string{"coconut milk", "coconut cream", "almond milk", "oat milk", "soy milk", "soya milk",
+			"rice milk", "cashew milk", "peanut butter", "almond butter", "cashew butter", "nut butter",
+			"butternut", "butter beans", "cocoa butter", "cream of tartar", "creamed coconut", "dairy-free", "dairy free", "vegan butter",
+			"vegan cheese", "plant-based", "kokosové mlieko", "kokosová smotana", "arašidové maslo", "sójové mlieko",
+			"ovsené mlieko", "mandľové mlieko"},
+	},
+	{
+		code: "nuts",
+		keywords: []string{"almond", "hazelnut", "walnut", "cashew", "pecan", "brazil nut", "pistachio",
+			"macadamia", "pine nut", "praline", "marzipan", "nut ", "nuts",
+			"mandl", "lieskov", "orech", "kešu", "pistác", "píniov"},
+		exclusions: []string{"nutmeg", "coconut", "butternut", "peanut", "groundnut", "nut-free", "nut free", "nutritional yeast",
+			"muškátový oriešok", "kokos"},
+	},
+	{
+		code:     "celery",
+		keywords: []string{"celery", "celeriac", "zeler"},
+	},
+	{
+		code:     "mustard",
+		keywords: []string{"mustard", "horčic"},
+	},
+	{
+		code:     "sesame",
+		keywords: []string{"sesame", "tahini", "sezam", "tahin"},
+	},
+	{
+		code:       "sulphites",
+		keywords:   []string{"wine", "sulphite", "sulfite", "dried apricot", "vinegar", "víno", "ocot", "ocet"},
+		exclusions: []string{"wine-free"},
+	},
+	{
+		code:     "lupin",
+		keywords: []string{"lupin", "lupine", "lupín", "vlčí bôb"},
+	},
+	{
+		code: "molluscs",
+		keywords: []string{"mussel", "clam", "oyster", "scallop", "squid", "calamari", "octopus", "snail",
+			"escargot", "cuttlefish", "oyster sauce", "mušl", "ustric", "kalamár", "chobotnic", "sépi", "slimák"},
+	},
+}
+
+// meatKeywords identifies meat, poultry and other non-vegetarian ingredients (fish and seafood
+// are covered by the fish, crustaceans and molluscs allergen rules)
+var meatKeywords = []string{"chicken", "beef", "pork", "bacon", "ham", "lamb", "mutton", "veal", "turkey", "duck",
+	"goose", "venison", "rabbit", "sausage", "salami", "chorizo", "prosciutto", "pancetta", "pepperoni",
+	"meat", "steak", "mince ", "gelatin", "gelatine", "lard", "suet", "bone broth", "chicken stock", "beef stock",
+	"chicken broth", "beef broth", "fish sauce",
+	"kurac", "kurč", "hovädz", "bravč", "slanin", "šunk", "jahn", "teľac", "morč", "moriak", "kačic",
+	"hus", "klobás", "saláma", "mäso", "mäs", "želatín", "masť", "vývar"}
+
+// meatExclusions are phrases that contain a meat keyword but are vegetarian
+var meatExclusions = []string{"vegetable stock", "vegetable broth", "vegan", "plant-based", "meatless",
+	"meat-free", "meat free", "cauliflower steak", "zeleninový vývar", "mushroom stock"}
+
+// animalProductKeywords are vegetarian but not vegan
+var animalProductKeywords = []string{"honey", "beeswax", "med "}
+
+// DietaryChecker detects allergens and verifies recipes against requested diets
+type DietaryChecker struct{}
+
+// NewDietaryChecker creates a new dietary checker
+func NewDietaryChecker() *DietaryChecker {
+	return &DietaryChecker{}
+}
+
+// DetectAllergens returns the EU allergen codes present in the ingredient list
+func (d *DietaryChecker) DetectAllergens(ingredients []models.Ingredient) []string {
+	allergens := []string{}
+	for _, rule := range allergenRules {
+		for _, ing := range ingredients {
+			if matchesKeywords(ing.Name, rule.keywords, rule.exclusions) {
+				allergens = append(allergens, rule.code)
+				break
+			}
+		}
+	}
+	return allergens
+}
+
+// CheckDiets verifies a recipe against requested dietary preferences
+// Unknown preferences are ignored; nutrition may be nil (carb-restricted diets are then not checked)
+func (d *DietaryChecker) CheckDiets(recipe *models.RecipeDetail, preferences []string) []models.DietViolation {
+	violations := []models.DietViolation{}
+
+	for _, preference := range preferences {
+		diet := normalizeDiet(preference)
+
+		switch diet {
+		case "vegetarian":
+			violations = append(violations, d.checkVegetarian(recipe.Ingredients, preference)...)
+		case "vegan":
+			violations = append(violations, d.checkVegetarian(recipe.Ingredients, preference)...)
+			violations = append(violations, d.checkAllergenFree(recipe.Ingredients, preference, "milk", "eggs")...)
+			for _, ing := range recipe.Ingredients {
+				if matchesKeywords(ing.Name, animalProductKeywords, nil) {
+					violations = append(violations, models.DietViolation{
+						Diet:       preference,
+						Ingredient: ing.Name,
+						Reason:     "animal product",
+					})
+				}
+			}
+		case "low-carb":
+			violations = append(violations, d.checkCarbs(recipe, preference, false, lowCarbMaxCarbsPerServing)...)
+		case "keto":
+			violations = append(violations, d.checkCarbs(recipe, preference, true, ketoMaxNetCarbsPerServing)...)
+		default:
+			// "<allergen>-free" diets (gluten-free, dairy-free, nut-free, egg-free, ...)
+			if allergen, ok := freeFromAllergen(diet); ok {
+				violations = append(violations, d.checkAllergenFree(recipe.Ingredients, preference, allergen)...)
+			}
+		}
+	}
+
+	return violations
+}
+
+// CheckAllergies verifies that a recipe contains none of the user's allergies
+// Known allergens use the allergen rules, anything else is matched by name
+func (d *DietaryChecker) CheckAllergies(ingredients []models.Ingredient, allergies []string) []models.DietViolation {
+	violations := []models.DietViolation{}
+	for _, allergy := range allergies {
+		diet := fmt.Sprintf("free of %s (allergy)", allergy)
+		if code, ok := allergenCode(allergy); ok {
+			violations = append(violations, d.checkAllergenFree(ingredients, diet, code)...)
+			continue
+		}
+		violations = append(violations, d.CheckExcludedIngredients(ingredients, []string{allergy}, diet)...)
+	}
+	return violations
+}
+
+// CheckExcludedIngredients flags ingredients whose name contains any of the excluded terms
+func (d *DietaryChecker) CheckExcludedIngredients(ingredients []models.Ingredient, excluded []string, diet string) []models.DietViolation {
+	violations := []models.DietViolation{}
+	for _, term := range excluded {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if term == "" {
+			continue
+		}
+		for _, ing := range ingredients {
+			if matchesKeywords(ing.Name, []string{term}, nil) {
+				violations = append(violations, models.DietViolation{
+					Diet:       diet,
+					Ingredient: ing.Name,
+					Reason:     fmt.Sprintf("contains %s", term),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// checkVegetarian flags meat, poultry, fish and seafood
+func (d *DietaryChecker) checkVegetarian(ingredients []models.Ingredient, diet string) []models.DietViolation {
+	violations := []models.DietViolation{}
+	for _, ing := range ingredients {
+		if matchesKeywords(ing.Name, meatKeywords, meatExclusions) {
+			violations = append(violations, models.DietViolation{Diet: diet, Ingredient: ing.Name, Reason: "contains meat"})
+		}
+	}
+	violations = append(violations, d.checkAllergenFree(ingredients, diet, "fish", "crustaceans", "molluscs")...)
+	return violations
+}
+
+// checkAllergenFree flags ingredients containing any of the given allergens
+func (d *DietaryChecker) checkAllergenFree(ingredients []models.Ingredient, diet string, allergenCodes ...string) []models.DietViolation {
+	violations := []models.DietViolation{}
+	for _, code := range allergenCodes {
+		rule, ok := findAllergenRule(code)
+		if !ok {
+			continue
+		}
+		for _, ing := range ingredients {
+			if matchesKeywords(ing.Name, rule.keywords, rule.exclusions) {
+				violations = append(violations, models.DietViolation{
+					Diet:       diet,
+					Ingredient: ing.Name,
+					Reason:     fmt.Sprintf("contains %s", code),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// checkCarbs flags recipes exceeding the per-serving carbohydrate limit
+func (d *DietaryChecker) checkCarbs(recipe *models.RecipeDetail, diet string, netCarbs bool, limit float64) []models.DietViolation {
+	if recipe.Nutrition == nil {
+		return nil
+	}
+
+	carbs := recipe.Nutrition.PerServing.Carbohydrates
+	label := "carbohydrates"
+	if netCarbs {
+		carbs -= recipe.Nutrition.PerServing.Fiber
+		label = "net carbohydrates"
+	}
+
+	if carbs <= limit {
+		return nil
+	}
+
+	return []models.DietViolation{{
+		Diet:   diet,
+		Reason: fmt.Sprintf("%.0f g %s per serving exceeds %.0f g", carbs, label, limit),
+	}}
+}
+
+// normalizeDiet maps a preference label to a canonical diet key (e.g. "Gluten-free" → "gluten-free")
+func normalizeDiet(preference string) string {
+	diet := strings.ToLower(strings.TrimSpace(preference))
+	diet = strings.ReplaceAll(diet, " ", "-")
+	diet = strings.ReplaceAll(diet, "_", "-")
+	switch diet {
+	case "ketogenic":
+		return "keto"
+	case "lowcarb":
+		return "low-carb"
+	}
+	return diet
+}
+
+// freeFromAllergen maps "<something>-free" diets to an allergen code
+func freeFromAllergen(diet string) (string, bool) {
+	prefix, found := strings.CutSuffix(diet, "-free")
+	if !found {
+		return "", false
+	}
+	return allergenCode(prefix)
+}
+
+// allergenCode maps an allergen name as users write it (e.g. "Peanuts", "dairy") to an allergen code
+func allergenCode(name string) (string, bool) {
+	name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "-")
+
+	aliases := map[string]string{
+		"gluten":      "gluten",
+		"wheat":       "gluten",
+		"dairy":       "milk",
+		"milk":        "milk",
+		"lactose":     "milk",
+		"egg":         "eggs",
+		"eggs":        "eggs",
+		"nut":         "nuts",
+		"nuts":        "nuts",
+		"tree-nut":    "nuts",
+		"peanut":      "peanuts",
+		"soy":         "soybeans",
+		"fish":        "fish",
+		"shellfish":   "crustaceans",
+		"sesame":      "sesame",
+		"celery":      "celery",
+		"mustard":     "mustard",
+		"sulphite":    "sulphites",
+		"sulfite":     "sulphites",
+		"lupin":       "lupin",
+		"peanuts":     "peanuts",
+		"tree-nuts":   "nuts",
+		"soya":        "soybeans",
+		"soybeans":    "soybeans",
+		"crustaceans": "crustaceans",
+		"molluscs":    "molluscs",
+		"mollusks":    "molluscs",
+		"sulphites":   "sulphites",
+		"sulfites":    "sulphites",
+	}
+	code, ok := aliases[name]
+	return code, ok
+}
+
+// findAllergenRule returns the rule for an allergen code
+func findAllergenRule(code string) (allergenRule, bool) {
+	for _, rule := range allergenRules {
+		if rule.code == code {
+			return rule, true
+		}
+	}
+	return allergenRule{}, false
+}
+
+// matchesKeywords reports whether an ingredient name contains a keyword at a word start,
+// after removing any exclusion phrases
+func matchesKeywords(name string, keywords, exclusions []string) bool {
+	text := " " + strings.ToLower(name) + " "
+	for _, exclusion := range exclusions {
+		text = strings.ReplaceAll(text, strings.ToLower(exclusion), " ")
+	}
+	text = strings.NewReplacer(",", " ", ".", " ", ";", " ", ":", " ", "(", " ", ")", " ", "/", " ", "-", " ").Replace(text)
+
+	for _, keyword := range keywords {
+		if strings.Contains(text, " "+keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// DescribeViolations renders violations as prompt corrections for a regeneration attempt
+func DescribeViolations(violations []models.DietViolation) []string {
+	corrections := make([]string, 0, len(violations))
+	for _, v := range violations {
+		if v.Ingredient != "" {
+			corrections = append(corrections, fmt.Sprintf("The recipe must be %s but \"%s\" %s. Replace or remove it.", v.Diet, v.Ingredient, v.Reason))
+		} else {
+			corrections = append(corrections, fmt.Sprintf("The recipe must be %s but %s. Adjust the ingredients.", v.Diet, v.Reason))
+		}
+	}
+	return corrections
+}
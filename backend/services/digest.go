@@ -0,0 +1,242 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"chefly/models"
+	"chefly/store"
+)
+
+// digestInterval is the minimum time between two digests for the same user
+const digestInterval = 7 * 24 * time.Hour
+
+// DigestService builds and delivers weekly per-user activity digests
+type DigestService struct {
+	users        store.UserStore
+	recipes      store.RecipeStore
+	shoppingList store.ShoppingListStore
+	quotas       *QuotaService
+	notifier     Notifier
+	auditLogger  *AuditLogger
+	baseURL      string
+}
+
+// NewDigestService creates a new digest service
+func NewDigestService(users store.UserStore, recipes store.RecipeStore, shoppingList store.ShoppingListStore, quotas *QuotaService, notifier Notifier, auditLogger *AuditLogger, baseURL string) *DigestService {
+	return &DigestService{
+		users:        users,
+		recipes:      recipes,
+		shoppingList: shoppingList,
+		quotas:       quotas,
+		notifier:     notifier,
+		auditLogger:  auditLogger,
+		baseURL:      strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// SendDueDigests sends a digest to every opted-in user whose last digest is older than a week
+// Returns the number of digests delivered
+func (s *DigestService) SendDueDigests() (int, error) {
+	// IDs are collected before sending so no result set stays open while recording sends
+	sentBefore := time.Now().UTC().Add(-digestInterval)
+	userIDs, err := s.users.ListDigestRecipients(sentBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query digest recipients: %w", err)
+	}
+
+	sent := 0
+	for _, userID := range userIDs {
+		delivered, err := s.sendDueDigest(userID, sentBefore)
+		if err != nil {
+			if s.auditLogger != nil {
+				s.auditLogger.Error("digest.send_failed", "Failed to send weekly digest", err, &models.AuditContext{
+					UserID: userID,
+				})
+			}
+			continue
+		}
+		if delivered {
+			sent++
+		}
+	}
+
+	return sent, nil
+}
+
+// sendDueDigest claims the user's digest and sends it, every replica lists the same recipients
+// but only the one whose claim succeeds sends. A failed send releases the claim so the next run retries
+func (s *DigestService) sendDueDigest(userID string, sentBefore time.Time) (bool, error) {
+	settings, err := s.users.GetDigestSettings(userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch digest settings: %w", err)
+	}
+
+	// Send times are stored with second precision, the claim is released by matching it
+	claimedAt := time.Now().UTC().Truncate(time.Second)
+	claimed, err := s.users.ClaimDigest(userID, claimedAt, sentBefore)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim digest: %w", err)
+	}
+	if !claimed {
+		return false, nil
+	}
+
+	if err := s.SendDigest(userID); err != nil {
+		if releaseErr := s.users.ReleaseDigestClaim(userID, claimedAt, settings.LastSentAt); releaseErr != nil {
+			return false, fmt.Errorf("%w (and failed to release the claim: %v)", err, releaseErr)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// SendDigest builds and delivers the digest for a single user
+func (s *DigestService) SendDigest(userID string) error {
+	digest, err := s.BuildDigest(userID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	unsubscribeToken, err := s.unsubscribeToken(userID)
+	if err != nil {
+		return err
+	}
+	unsubscribeURL := fmt.Sprintf("%s/api/notifications/unsubscribe/%s", s.baseURL, unsubscribeToken)
+
+	notification := models.Notification{
+		To:             digest.Email,
+		Subject:        "Your weekly Chefly digest",
+		Body:           s.renderDigest(digest, unsubscribeURL),
+		UnsubscribeURL: unsubscribeURL,
+	}
+
+	if err := s.notifier.Send(notification); err != nil {
+		return err
+	}
+
+	if s.auditLogger != nil {
+		s.auditLogger.Info("digest.sent", "Weekly digest sent", &models.AuditContext{
+			UserID: userID,
+			Metadata: map[string]interface{}{
+				"recipes_generated":   digest.RecipesGenerated,
+				"open_shopping_items": digest.OpenShoppingList,
+			},
+		})
+	}
+
+	return nil
+}
+
+// BuildDigest collects the activity summary for the week ending at periodEnd
+func (s *DigestService) BuildDigest(userID string, periodEnd time.Time) (*models.UserDigest, error) {
+	digest := &models.UserDigest{
+		UserID:       userID,
+		PeriodStart:  periodEnd.Add(-digestInterval),
+		PeriodEnd:    periodEnd,
+		RecentTitles: []string{},
+	}
+
+	user, err := s.users.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	digest.Username = user.Username
+	digest.Email = user.Email
+
+	// Recipes generated during the period
+	digest.RecipesGenerated, err = s.recipes.CountCreatedSince(userID, digest.PeriodStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count recipes: %w", err)
+	}
+
+	// Titles of the most recent recipes from the period
+	digest.RecentTitles, err = s.recipes.RecentTitles(userID, digest.PeriodStart, 5)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch recent recipes: %w", err)
+	}
+
+	// Favorites (all time)
+	digest.FavoriteRecipes, err = s.recipes.CountFavorites(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count favorites: %w", err)
+	}
+
+	// Shopping list items not yet checked off
+	digest.OpenShoppingList, err = s.shoppingList.CountUnchecked(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count shopping list items: %w", err)
+	}
+
+	digest.RemainingQuota = s.remainingQuota(userID)
+
+	return digest, nil
+}
+
+// remainingQuota returns how many recipes the user can still generate (-1 = unlimited)
+func (s *DigestService) remainingQuota(userID string) int {
+	status, err := s.quotas.Status(userID, models.QuotaText)
+	if err != nil {
+		return -1
+	}
+	return status.Remaining
+}
+
+// unsubscribeToken returns the user's unsubscribe token, creating one if missing
+func (s *DigestService) unsubscribeToken(userID string) (string, error) {
+	token, err := s.users.GetUnsubscribeToken(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch unsubscribe token: %w", err)
+	}
+	if token != "" {
+		return token, nil
+	}
+
+	newToken, err := NewUnsubscribeToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.users.SetUnsubscribeToken(userID, newToken); err != nil {
+		return "", fmt.Errorf("failed to store unsubscribe token: %w", err)
+	}
+	return newToken, nil
+}
+
+// NewUnsubscribeToken generates a random token used in digest unsubscribe links
+func NewUnsubscribeToken() (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate unsubscribe token: %w", err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// renderDigest renders the plain text email body
+func (s *DigestService) renderDigest(digest *models.UserDigest, unsubscribeURL string) string {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("Hi %s,\n\n", digest.Username))
+	builder.WriteString(fmt.Sprintf("Here is your Chefly summary for %s - %s.\n\n",
+		digest.PeriodStart.Format("Jan 2"), digest.PeriodEnd.Format("Jan 2, 2006")))
+
+	builder.WriteString(fmt.Sprintf("Recipes generated this week: %d\n", digest.RecipesGenerated))
+	for _, title := range digest.RecentTitles {
+		builder.WriteString(fmt.Sprintf("  - %s\n", title))
+	}
+	builder.WriteString(fmt.Sprintf("Favorite recipes: %d\n", digest.FavoriteRecipes))
+	builder.WriteString(fmt.Sprintf("Open shopping list items: %d\n", digest.OpenShoppingList))
+
+	if digest.RemainingQuota == -1 {
+		builder.WriteString("Remaining recipe generations: unlimited\n")
+	} else {
+		builder.WriteString(fmt.Sprintf("Remaining recipe generations: %d\n", digest.RemainingQuota))
+	}
+
+	builder.WriteString(fmt.Sprintf("\nHappy cooking!\n%s\n", s.baseURL))
+	builder.WriteString(fmt.Sprintf("\nTo stop receiving these emails, visit: %s\n", unsubscribeURL))
+
+	return builder.String()
+}
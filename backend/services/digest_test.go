@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"chefly/models"
+	"chefly/store"
+	"chefly/store/memstore"
+)
+
+// emptyShoppingList is a shopping list store without items
+type emptyShoppingList struct {
+	store.ShoppingListStore
+}
+
+func (emptyShoppingList) CountUnchecked(userID string) (int, error) {
+	return 0, nil
+}
+
+// recordingNotifier records sent notifications and fails while failing is set
+type recordingNotifier struct {
+	mu      sync.Mutex
+	sent    []models.Notification
+	failing bool
+}
+
+func (n *recordingNotifier) Send(notification models.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.failing {
+		return errors.New("smtp unavailable")
+	}
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+func (n *recordingNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.sent)
+}
+
+// listingTogether makes replicas list the digest recipients before any of them sends
+type listingTogether struct {
+	store.UserStore
+	listed *sync.WaitGroup
+}
+
+func (u listingTogether) ListDigestRecipients(sentBefore time.Time) ([]string, error) {
+	userIDs, err := u.UserStore.ListDigestRecipients(sentBefore)
+	u.listed.Done()
+	u.listed.Wait()
+	return userIDs, err
+}
+
+// newTestDigestStores returns stores with two opted-in users and a quota service
+func newTestDigestStores(t *testing.T) (*store.Store, *QuotaService) {
+	t.Helper()
+	stores := memstore.New(nil)
+	for _, id := range []string{"cook", "chef"} {
+		if err := stores.Users.Create(&models.User{ID: id, Email: id + "@example.com", Username: id}); err != nil {
+			t.Fatalf("Users.Create: %v", err)
+		}
+		if err := stores.Users.SetDigestOptIn(id, true, "token-"+id); err != nil {
+			t.Fatalf("SetDigestOptIn: %v", err)
+		}
+	}
+	quotas, err := NewQuotaService(stores.Users, stores.Generations, "", "", 0)
+	if err != nil {
+		t.Fatalf("NewQuotaService: %v", err)
+	}
+	return stores, quotas
+}
+
+func TestSendDueDigestsOncePerUserAcrossReplicas(t *testing.T) {
+	stores, quotas := newTestDigestStores(t)
+	notifier := &recordingNotifier{}
+
+	const count = 4
+	listed := &sync.WaitGroup{}
+	listed.Add(count)
+	replicas := make([]*DigestService, count)
+	for i := range replicas {
+		users := listingTogether{UserStore: stores.Users, listed: listed}
+		replicas[i] = NewDigestService(users, stores.Recipes, emptyShoppingList{}, quotas, notifier, nil, "https://chefly.example")
+	}
+
+	var wg sync.WaitGroup
+	for _, service := range replicas {
+		wg.Add(1)
+		go func(service *DigestService) {
+			defer wg.Done()
+			if _, err := service.SendDueDigests(); err != nil {
+				t.Errorf("SendDueDigests: %v", err)
+			}
+		}(service)
+	}
+	wg.Wait()
+
+	if sent := notifier.count(); sent != 2 {
+		t.Errorf("sent %d digests, want one per user", sent)
+	}
+	if recipients, err := stores.Users.ListDigestRecipients(time.Now().Add(-time.Hour)); err != nil || len(recipients) != 0 {
+		t.Errorf("recipients after sending = %v, %v, want nothing due", recipients, err)
+	}
+}
+
+func TestSendDueDigestsRetriesFailedSends(t *testing.T) {
+	stores, quotas := newTestDigestStores(t)
+	notifier := &recordingNotifier{}
+	service := NewDigestService(stores.Users, stores.Recipes, emptyShoppingList{}, quotas, notifier, nil, "https://chefly.example")
+
+	notifier.failing = true
+	if sent, err := service.SendDueDigests(); err != nil || sent != 0 {
+		t.Fatalf("SendDueDigests while failing = %d, %v, want 0", sent, err)
+	}
+	settings, err := stores.Users.GetDigestSettings("cook")
+	if err != nil || settings.LastSentAt != nil {
+		t.Errorf("digest settings after a failed send = %+v, %v, want never sent", settings, err)
+	}
+
+	notifier.failing = false
+	if sent, err := service.SendDueDigests(); err != nil || sent != 2 {
+		t.Errorf("SendDueDigests after recovering = %d, %v, want 2", sent, err)
+	}
+}
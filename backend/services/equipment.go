@@ -0,0 +1,227 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"chefly/models"
+)
+
+// ErrEquipmentViolation is returned when a generated recipe keeps using equipment the user does not have
+var ErrEquipmentViolation = errors.New("recipe requires unavailable kitchen equipment")
+
+// equipmentRule describes how to detect a piece of kitchen equipment in cooking steps
+type equipmentRule struct {
+	code       string
+	names      []string // Explicit mentions of the equipment ("oven", "skillet")
+	techniques []string // Techniques implying the equipment ("bake", "simmer"), ignored when the step names allowed equipment
+	exclusions []string // Phrases removed before matching
+}
+
+// equipmentRules lists detectable equipment, more specific appliances first
+// Keywords follow the allergen rules: matched at word start, a trailing space requires a whole word
+var equipmentRules = []equipmentRule{
+	{
+		code:  "air fryer",
+		names: []string{"air fryer", "air-fryer", "airfryer", "air fry", "teplovzdušn"},
+	},
+	{
+		code:  "slow cooker",
+		names: []string{"slow cooker", "slow cook", "crock pot", "crockpot", "crock-pot", "pomalý hrn", "pomalom hrn", "pomalého hrn"},
+	},
+	{
+		code:  "pressure cooker",
+		names: []string{"pressure cooker", "pressure cook", "instant pot", "tlakov"},
+	},
+	{
+		code:       "deep fryer",
+		names:      []string{"deep fryer", "deep-fryer", "fritéz", "fritovac"},
+		techniques: []string{"deep fry", "deep-fry", "deep fried", "deep-fried", "fritovan"},
+		exclusions: []string{"teplovzdušná fritéz", "teplovzdušnej fritéz", "teplovzdušnú fritéz"},
+	},
+	{
+		code:  "microwave",
+		names: []string{"microwave", "mikrovln"},
+	},
+	{
+		code:       "oven",
+		names:      []string{"oven", "broiler", "baking sheet", "baking tray", "baking dish", "roasting tin", "roasting pan", "sheet pan", "casserole dish", "rúr", "trúb", "pekáč", "plech"},
+		techniques: []string{"bake", "baking", "broil", "roast ", "roast until", "roast for", "gratin", "upeč", "zapeč", "zapek", "pečieme v", "pečte v"},
+		exclusions: []string{"pan-roast", "pan roast", "dry-roast", "dry roast", "toast"},
+	},
+	{
+		code:       "grill",
+		names:      []string{"grill", "barbecue", "bbq", "gril"},
+		exclusions: []string{"grill pan", "grilovacej panvic", "grilovaciu panvic"},
+	},
+	{
+		code: "stovetop",
+		names: []string{"stove", "hob", "burner", "saucepan", "frying pan", "skillet", "wok", "pot ", "pan ", "grill pan",
+			"sporák", "varn", "panvic", "hrniec", "hrnc", "kastról"},
+		techniques: []string{"simmer", "sauté", "saute", "stir-fry", "stir fry", "pan-fry", "pan fry", "bring to a boil", "bring to the boil",
+			"over low", "over medium", "over high", "on low heat", "on medium heat", "on high heat",
+			"na miernom ohni", "na strednom ohni", "na silnom ohni", "priveďte do varu", "privedieme do varu", "podusíme", "orestuj", "restujeme"},
+		exclusions: []string{"crock pot", "instant pot", "sheet pan", "roasting pan", "pomalý hrniec", "pomalom hrnci", "pomalého hrnca",
+			"tlakový hrniec", "tlakovom hrnci"},
+	},
+	{
+		code:       "blender",
+		names:      []string{"blender", "mixér", "mixer"},
+		techniques: []string{"blend ", "blend until", "purée", "puree", "rozmix"},
+	},
+	{
+		code:  "food processor",
+		names: []string{"food processor", "kuchynský robot", "kuchynskom robot"},
+	},
+}
+
+// temperaturePattern matches a temperature with a unit ("180°C", "350 F", "200 degrees", "180 stupňov")
+// A bare number is not a temperature, it may be a time or a weight
+var temperaturePattern = regexp.MustCompile(`(?i)\b(\d{2,3})\s*(?:°\s*([CF])?|(?:degrees?|stup\S*)\s*(celsius|fahrenheit|[CF]\b)?|([CF])\b)`)
+
+// minOvenCelsius is the lowest temperature treated as an oven setting, lower ones are simmering
+// water, holding or probe temperatures reached on a stovetop
+const minOvenCelsius = 120
+
+// EquipmentChecker verifies generated recipes against the user's kitchen equipment
+type EquipmentChecker struct{}
+
+// NewEquipmentChecker creates a new equipment checker
+func NewEquipmentChecker() *EquipmentChecker {
+	return &EquipmentChecker{}
+}
+
+// CheckSteps scans cooking steps for equipment that is excluded or missing from the available list
+// An empty available list means the user did not restrict their equipment
+func (c *EquipmentChecker) CheckSteps(steps []models.CookingStep, available, excluded []string) []models.EquipmentViolation {
+	forbidden := forbiddenEquipment(available, excluded)
+	if len(forbidden) == 0 {
+		return nil
+	}
+
+	var violations []models.EquipmentViolation
+	for _, step := range steps {
+		text := step.Instruction
+
+		// Equipment explicitly named in the step
+		mentioned := make(map[string]bool)
+		namesAllowed := false
+		for _, rule := range equipmentRules {
+			if matchesKeywords(text, rule.names, rule.exclusions) {
+				mentioned[rule.code] = true
+				if !forbidden[rule.code] {
+					namesAllowed = true
+				}
+			}
+		}
+
+		stepViolations := make(map[string]bool)
+		for _, rule := range equipmentRules {
+			if !forbidden[rule.code] {
+				continue
+			}
+			if mentioned[rule.code] {
+				stepViolations[rule.code] = true
+				violations = append(violations, models.EquipmentViolation{
+					Equipment:  rule.code,
+					StepNumber: step.StepNumber,
+					Reason:     fmt.Sprintf("step %d uses the %s", step.StepNumber, rule.code),
+				})
+			} else if !namesAllowed && matchesKeywords(text, rule.techniques, rule.exclusions) {
+				stepViolations[rule.code] = true
+				violations = append(violations, models.EquipmentViolation{
+					Equipment:  rule.code,
+					StepNumber: step.StepNumber,
+					Reason:     fmt.Sprintf("step %d requires the %s", step.StepNumber, rule.code),
+				})
+			}
+		}
+
+		// A temperature without any allowed appliance in the step is an oven setting
+		if forbidden["oven"] && !stepViolations["oven"] && !namesAllowed && len(mentioned) == 0 &&
+			isOvenTemperature(step.Temperature) {
+			violations = append(violations, models.EquipmentViolation{
+				Equipment:  "oven",
+				StepNumber: step.StepNumber,
+				Reason:     fmt.Sprintf("step %d sets a temperature of %s, which requires an oven", step.StepNumber, step.Temperature),
+			})
+		}
+	}
+
+	return violations
+}
+
+// isOvenTemperature reports whether a step temperature is above what a stovetop reaches
+// Temperatures without a unit letter are taken as Celsius
+func isOvenTemperature(temperature string) bool {
+	for _, match := range temperaturePattern.FindAllStringSubmatch(temperature, -1) {
+		value, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		unit := strings.ToLower(match[2] + match[3] + match[4])
+		celsius := value
+		if strings.HasPrefix(unit, "f") {
+			celsius = (value - 32) * 5 / 9
+		}
+		if celsius >= minOvenCelsius {
+			return true
+		}
+	}
+	return false
+}
+
+// forbiddenEquipment returns the equipment codes a recipe must not use
+func forbiddenEquipment(available, excluded []string) map[string]bool {
+	forbidden := make(map[string]bool)
+
+	for _, name := range excluded {
+		if code, ok := equipmentCode(name); ok {
+			forbidden[code] = true
+		}
+	}
+
+	// With an available list, every known appliance not on it is forbidden
+	availableCodes := make(map[string]bool)
+	for _, name := range available {
+		if code, ok := equipmentCode(name); ok {
+			availableCodes[code] = true
+		}
+	}
+	if len(availableCodes) > 0 {
+		for _, rule := range equipmentRules {
+			if !availableCodes[rule.code] {
+				forbidden[rule.code] = true
+			}
+		}
+	}
+
+	return forbidden
+}
+
+// equipmentCode maps a user-provided equipment name to an equipment code
+func equipmentCode(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", false
+	}
+
+	for _, rule := range equipmentRules {
+		if name == rule.code || matchesKeywords(name, rule.names, rule.exclusions) {
+			return rule.code, true
+		}
+	}
+	return "", false
+}
+
+// DescribeEquipmentViolations renders equipment violations as prompt corrections for a regeneration attempt
+func DescribeEquipmentViolations(violations []models.EquipmentViolation) []string {
+	corrections := make([]string, 0, len(violations))
+	for _, v := range violations {
+		corrections = append(corrections, fmt.Sprintf("The cook has no %s but %s. Rewrite the step using only the available equipment.", v.Equipment, v.Reason))
+	}
+	return corrections
+}
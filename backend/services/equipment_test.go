@@ -0,0 +1,88 @@
+package services
+
+import (
+	"testing"
+
+	"chefly/models"
+)
+
+func TestIsOvenTemperature(t *testing.T) {
+	tests := []struct {
+		temperature string
+		want        bool
+	}{
+		{"180°C", true},
+		{"180 °C", true},
+		{"350°F", true},
+		{"350 F", true},
+		{"200 degrees", true},
+		{"425 degrees Fahrenheit", true},
+		{"180 stupňov", true},
+		{"160-180°C", true},
+		{"120°C", true},
+		{"90°C", false},
+		{"100 °C", false},
+		{"165°F", false}, // Internal temperature of cooked chicken
+		{"212 F", false},
+		{"75", false},
+		{"180", false},
+		{"medium heat", false},
+		{"", false},
+		{"1800°C", false},
+	}
+
+	for _, test := range tests {
+		if got := isOvenTemperature(test.temperature); got != test.want {
+			t.Errorf("isOvenTemperature(%q) = %v, want %v", test.temperature, got, test.want)
+		}
+	}
+}
+
+func TestCheckSteps(t *testing.T) {
+	step := func(instruction, temperature string) []models.CookingStep {
+		return []models.CookingStep{{StepNumber: 1, Instruction: instruction, Temperature: temperature}}
+	}
+
+	tests := []struct {
+		name      string
+		steps     []models.CookingStep
+		available []string
+		excluded  []string
+		want      []string // Equipment codes of the violations
+	}{
+		{"no restriction", step("Bake in the oven for 20 minutes", "180°C"), nil, nil, nil},
+		{"named excluded equipment", step("Bake in the oven for 20 minutes", "180°C"), nil, []string{"oven"}, []string{"oven"}},
+		{"technique of excluded equipment", step("Bake until golden", ""), nil, []string{"oven"}, []string{"oven"}},
+		{"technique with allowed equipment named", step("Roast the peppers in a skillet", ""), []string{"stovetop"}, nil, nil},
+		{"equipment missing from the available list", step("Blend until smooth", ""), []string{"stovetop"}, nil, []string{"blender"}},
+		{"oven temperature without equipment", step("Cook until golden", "200°C"), []string{"stovetop"}, nil, []string{"oven"}},
+		{"fahrenheit oven temperature", step("Cook for 25 minutes", "375 F"), []string{"stovetop"}, nil, []string{"oven"}},
+		{"simmering temperature", step("Keep the water just below a boil", "90°C"), []string{"stovetop"}, nil, nil},
+		{"probe temperature", step("Cook until the chicken is done", "74°C"), []string{"stovetop"}, nil, nil},
+		{"fahrenheit probe temperature", step("Rest the meat", "145°F"), []string{"stovetop"}, nil, nil},
+		{"bare number", step("Stir well", "75"), []string{"stovetop"}, nil, nil},
+		{"oven temperature with allowed equipment named", step("Heat the oil in a saucepan", "180°C"), []string{"stovetop"}, nil, nil},
+		{"slovak oven", step("Pečte v rúre 30 minút", "180 stupňov"), []string{"sporák"}, nil, []string{"oven"}},
+		{"pan roast is not the oven", step("Pan-roast the cauliflower", ""), nil, []string{"oven"}, nil},
+		{"air fryer is not a deep fryer", step("Cook in the air fryer", "200°C"), []string{"air fryer"}, []string{"deep fryer"}, nil},
+	}
+
+	checker := NewEquipmentChecker()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			violations := checker.CheckSteps(test.steps, test.available, test.excluded)
+			var got []string
+			for _, violation := range violations {
+				got = append(got, violation.Equipment)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("violations = %+v, want %v", violations, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("violations = %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
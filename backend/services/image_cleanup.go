@@ -1,7 +1,7 @@
 package services
 
 import (
-	"os"
+	"errors"
 	"strings"
 
 	"chefly/models"
@@ -9,31 +9,31 @@ import (
 
 // ImageCleanupService handles cleanup of recipe image files
 type ImageCleanupService struct {
-	uploadsDir  string
+	store       ImageStore
 	auditLogger *AuditLogger
 }
 
 // NewImageCleanupService creates a new image cleanup service
-func NewImageCleanupService(uploadsDir string, auditLogger *AuditLogger) *ImageCleanupService {
+func NewImageCleanupService(store ImageStore, auditLogger *AuditLogger) *ImageCleanupService {
 	return &ImageCleanupService{
-		uploadsDir:  uploadsDir,
+		store:       store,
 		auditLogger: auditLogger,
 	}
 }
 
-// DeleteRecipeImages deletes the full image and thumbnail for a recipe
+// DeleteRecipeImages deletes the full image, thumbnail and image variants for a recipe
 // Always logs cleanup attempts for audit trail
-func (s *ImageCleanupService) DeleteRecipeImages(imagePath, thumbnailPath, recipeID, userID, requestID string) error {
+func (s *ImageCleanupService) DeleteRecipeImages(imagePath, thumbnailPath string, variants []models.ImageVariant, recipeID, userID, requestID string) error {
 	deletedFiles := []string{}
 	failedFiles := []string{}
-	var errors []string
+	var errorMessages []string
 
 	// Delete full image
 	if imagePath != "" && !strings.HasPrefix(imagePath, "data:") {
-		fullPath := s.getAbsolutePath(imagePath)
-		if err := os.Remove(fullPath); err != nil {
+		fullKey := imageKey(imagePath)
+		if err := s.deleteImage(fullKey); err != nil {
 			failedFiles = append(failedFiles, imagePath)
-			errors = append(errors, err.Error())
+			errorMessages = append(errorMessages, err.Error())
 
 			// Always log failure (even if file doesn't exist - could be wrong path)
 			if s.auditLogger != nil {
@@ -43,9 +43,9 @@ func (s *ImageCleanupService) DeleteRecipeImages(imagePath, thumbnailPath, recip
 					Metadata: map[string]interface{}{
 						"recipe_id":       recipeID,
 						"image_url":       imagePath,
-						"filesystem_path": fullPath,
+						"storage_key":  fullKey,
 						"error":           err.Error(),
-						"is_not_exist":    os.IsNotExist(err),
+						"is_not_exist": errors.Is(err, ErrImageNotFound),
 					},
 				})
 			}
@@ -56,10 +56,10 @@ func (s *ImageCleanupService) DeleteRecipeImages(imagePath, thumbnailPath, recip
 
 	// Delete thumbnail
 	if thumbnailPath != "" && !strings.HasPrefix(thumbnailPath, "data:") {
-		thumbPath := s.getAbsolutePath(thumbnailPath)
-		if err := os.Remove(thumbPath); err != nil {
+		thumbKey := imageKey(thumbnailPath)
+		if err := s.deleteImage(thumbKey); err != nil {
 			failedFiles = append(failedFiles, thumbnailPath)
-			errors = append(errors, err.Error())
+			errorMessages = append(errorMessages, err.Error())
 
 			// Always log failure
 			if s.auditLogger != nil {
@@ -69,9 +69,9 @@ func (s *ImageCleanupService) DeleteRecipeImages(imagePath, thumbnailPath, recip
 					Metadata: map[string]interface{}{
 						"recipe_id":       recipeID,
 						"thumbnail_url":   thumbnailPath,
-						"filesystem_path": thumbPath,
+						"storage_key":   thumbKey,
 						"error":           err.Error(),
-						"is_not_exist":    os.IsNotExist(err),
+						"is_not_exist":  errors.Is(err, ErrImageNotFound),
 					},
 				})
 			}
@@ -80,15 +80,39 @@ func (s *ImageCleanupService) DeleteRecipeImages(imagePath, thumbnailPath, recip
 		}
 	}
 
-	// Always log cleanup attempt (success, partial, or total failure)
-	if s.auditLogger != nil {
-		totalAttempted := 0
-		if imagePath != "" && !strings.HasPrefix(imagePath, "data:") {
-			totalAttempted++
+	// Delete the remaining variants (the full image and thumbnail are variants too)
+	variantPaths := models.RecipeImage{Variants: variants}.Paths()
+	for _, variantPath := range variantPaths {
+		if variantPath == imagePath || variantPath == thumbnailPath {
+			continue
 		}
-		if thumbnailPath != "" && !strings.HasPrefix(thumbnailPath, "data:") {
-			totalAttempted++
+		variantKey := imageKey(variantPath)
+		if err := s.deleteImage(variantKey); err != nil {
+			failedFiles = append(failedFiles, variantPath)
+			errorMessages = append(errorMessages, err.Error())
+
+			// Always log failure
+			if s.auditLogger != nil {
+				s.auditLogger.Warn("recipe.image_cleanup_failed", "Failed to delete recipe image variant", &models.AuditContext{
+					RequestID: requestID,
+					UserID:    userID,
+					Metadata: map[string]interface{}{
+						"recipe_id":    recipeID,
+						"variant_url":  variantPath,
+						"storage_key":  variantKey,
+						"error":        err.Error(),
+						"is_not_exist": errors.Is(err, ErrImageNotFound),
+					},
+				})
+			}
+		} else {
+			deletedFiles = append(deletedFiles, variantPath)
 		}
+	}
+
+	// Always log cleanup attempt (success, partial, or total failure)
+	if s.auditLogger != nil {
+		totalAttempted := len(deletedFiles) + len(failedFiles)
 
 		if len(failedFiles) > 0 && len(deletedFiles) == 0 {
 			// Total failure - all files failed to delete
@@ -100,7 +124,7 @@ func (s *ImageCleanupService) DeleteRecipeImages(imagePath, thumbnailPath, recip
 					"attempted_count": totalAttempted,
 					"failed_count":    len(failedFiles),
 					"failed_files":    failedFiles,
-					"errors":          errors,
+					"errors":          errorMessages,
 				},
 			})
 		} else if len(deletedFiles) > 0 && len(failedFiles) > 0 {
@@ -120,7 +144,7 @@ func (s *ImageCleanupService) DeleteRecipeImages(imagePath, thumbnailPath, recip
 			})
 		} else if len(deletedFiles) > 0 {
 			// Complete success
-			s.auditLogger.Info("recipe.image_cleanup", "Recipe images deleted from disk", &models.AuditContext{
+			s.auditLogger.Info("recipe.image_cleanup", "Recipe images deleted from storage", &models.AuditContext{
 				RequestID: requestID,
 				UserID:    userID,
 				Metadata: map[string]interface{}{
@@ -139,7 +163,7 @@ func (s *ImageCleanupService) DeleteRecipeImages(imagePath, thumbnailPath, recip
 
 // DeleteUserImages deletes all image files for a user's recipes
 // Takes a list of recipe image paths and deletes them in bulk
-func (s *ImageCleanupService) DeleteUserImages(recipes []struct{ ImagePath, ThumbnailPath, RecipeID string }, userID, requestID string) error {
+func (s *ImageCleanupService) DeleteUserImages(recipes []models.RecipeImage, userID, requestID string) error {
 	totalAttempted := 0
 	totalDeleted := 0
 	totalFailed := 0
@@ -147,11 +171,25 @@ func (s *ImageCleanupService) DeleteUserImages(recipes []struct{ ImagePath, Thum
 	failedFiles := []string{}
 
 	for _, recipe := range recipes {
+		// Delete variants not referenced as full image or thumbnail
+		for _, variantPath := range (models.RecipeImage{Variants: recipe.Variants}).Paths() {
+			if variantPath == recipe.ImagePath || variantPath == recipe.ThumbnailPath {
+				continue
+			}
+			totalAttempted++
+			if err := s.deleteImage(imageKey(variantPath)); err != nil {
+				totalFailed++
+				failedFiles = append(failedFiles, variantPath)
+			} else {
+				totalDeleted++
+				deletedFiles = append(deletedFiles, variantPath)
+			}
+		}
+
 		// Delete full image
 		if recipe.ImagePath != "" && !strings.HasPrefix(recipe.ImagePath, "data:") {
 			totalAttempted++
-			fullPath := s.getAbsolutePath(recipe.ImagePath)
-			if err := os.Remove(fullPath); err != nil {
+			if err := s.deleteImage(imageKey(recipe.ImagePath)); err != nil {
 				totalFailed++
 				failedFiles = append(failedFiles, recipe.ImagePath)
 			} else {
@@ -163,8 +201,7 @@ func (s *ImageCleanupService) DeleteUserImages(recipes []struct{ ImagePath, Thum
 		// Delete thumbnail
 		if recipe.ThumbnailPath != "" && !strings.HasPrefix(recipe.ThumbnailPath, "data:") {
 			totalAttempted++
-			thumbPath := s.getAbsolutePath(recipe.ThumbnailPath)
-			if err := os.Remove(thumbPath); err != nil {
+			if err := s.deleteImage(imageKey(recipe.ThumbnailPath)); err != nil {
 				totalFailed++
 				failedFiles = append(failedFiles, recipe.ThumbnailPath)
 			} else {
@@ -207,7 +244,7 @@ func (s *ImageCleanupService) DeleteUserImages(recipes []struct{ ImagePath, Thum
 			})
 		} else if totalDeleted > 0 {
 			// Complete success
-			s.auditLogger.Info("admin.user_images_cleanup", "All user images deleted from disk", &models.AuditContext{
+			s.auditLogger.Info("admin.user_images_cleanup", "All user images deleted from storage", &models.AuditContext{
 				RequestID: requestID,
 				UserID:    userID,
 				Metadata: map[string]interface{}{
@@ -224,10 +261,18 @@ func (s *ImageCleanupService) DeleteUserImages(recipes []struct{ ImagePath, Thum
 	return nil
 }
 
-// getAbsolutePath converts a URL path to absolute filesystem path
-// URL format: /uploads/images/full/abc.jpg → ./uploads/images/full/abc.jpg
-func (s *ImageCleanupService) getAbsolutePath(urlPath string) string {
-	// Simply prepend "." to convert URL path to relative filesystem path
-	// This works because the URL already includes the full path structure
-	return "." + urlPath
+// imageKey converts a stored image path to its image store key
+// URL format: /uploads/images/full/abc.jpg → images/full/abc.jpg
+// Returns "" for paths the image store does not hold (e.g. external URLs)
+func imageKey(imagePath string) string {
+	key, _ := ImageKeyFromPath(imagePath)
+	return key
+}
+
+// deleteImage removes an image from the store
+func (s *ImageCleanupService) deleteImage(key string) error {
+	if key == "" {
+		return ErrInvalidImageKey
+	}
+	return s.store.Delete(key)
 }
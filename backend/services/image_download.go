@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxDownloadRedirects is how many redirects an image download follows
+const maxDownloadRedirects = 5
+
+// ErrDownloadTooLarge is returned when a remote image exceeds the download size limit
+var ErrDownloadTooLarge = errors.New("image download exceeds size limit")
+
+// DownloadStatusError is returned when a remote image responds with a status other than 200 OK
+type DownloadStatusError struct {
+	StatusCode int
+}
+
+func (e *DownloadStatusError) Error() string {
+	return fmt.Sprintf("status code %d", e.StatusCode)
+}
+
+// ImageDownloader downloads remote images (e.g. DALL-E URLs) with a timeout and a size limit
+type ImageDownloader struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// NewImageDownloader creates a new image downloader
+// timeout bounds a whole download including redirects, maxBytes the size of the image
+func NewImageDownloader(timeout time.Duration, maxBytes int64) *ImageDownloader {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+	transport.MaxResponseHeaderBytes = 64 << 10
+
+	return &ImageDownloader{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxDownloadRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxDownloadRedirects)
+				}
+				return nil
+			},
+		},
+		maxBytes: maxBytes,
+	}
+}
+
+// Download fetches an http(s) URL and returns the body and its content type
+func (d *ImageDownloader) Download(ctx context.Context, imageURL string) ([]byte, string, error) {
+	parsed, err := url.Parse(imageURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, "", fmt.Errorf("unsupported image URL %q", imageURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", &DownloadStatusError{StatusCode: resp.StatusCode}
+	}
+	if resp.ContentLength > d.maxBytes {
+		return nil, "", fmt.Errorf("%w (%d bytes, limit %d)", ErrDownloadTooLarge, resp.ContentLength, d.maxBytes)
+	}
+
+	// Read one byte past the limit to detect bodies without (or with a wrong) Content-Length
+	data, err := io.ReadAll(io.LimitReader(resp.Body, d.maxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > d.maxBytes {
+		return nil, "", fmt.Errorf("%w (limit %d bytes)", ErrDownloadTooLarge, d.maxBytes)
+	}
+
+	return data, resp.Header.Get("Content-Type"), nil
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"chefly/models"
+	"chefly/store"
+)
+
+// imageGCPrefixes are the store prefixes holding recipe images, everything else (e.g. quarantine) is left alone
+var imageGCPrefixes = []string{"images/full/", "images/thumbnails/", imageVariantPrefix}
+
+// imageQuarantinePrefix is where quarantined orphans are moved, they are never deleted automatically
+const imageQuarantinePrefix = "quarantine/"
+
+// ImageGCService removes stored images that no recipe refers to
+// Orphans are left behind when a recipe INSERT fails after its image was written, or when deleting images of a
+// deleted recipe or user failed
+type ImageGCService struct {
+	images      ImageStore
+	recipes     store.RecipeStore
+	photos      store.PhotoStore
+	gracePeriod time.Duration
+	quarantine  bool
+}
+
+// NewImageGCService creates a new image garbage collector
+// Unreferenced images younger than gracePeriod are kept: their recipe may still be generating
+// With quarantine set, orphans are moved below "quarantine/" instead of being deleted
+func NewImageGCService(images ImageStore, recipes store.RecipeStore, photos store.PhotoStore, gracePeriod time.Duration, quarantine bool) *ImageGCService {
+	return &ImageGCService{
+		images:      images,
+		recipes:     recipes,
+		photos:      photos,
+		gracePeriod: gracePeriod,
+		quarantine:  quarantine,
+	}
+}
+
+// Run reconciles stored images with recipe images, photos and their variants and removes orphans older than the grace period
+// With dryRun set, orphans are only reported
+func (s *ImageGCService) Run(dryRun bool) (*models.ImageGCReport, error) {
+	report := &models.ImageGCReport{
+		DryRun:      dryRun,
+		Quarantine:  s.quarantine,
+		GracePeriod: s.gracePeriod.String(),
+		Orphans:     []models.OrphanedImage{},
+		FailedKeys:  []string{},
+	}
+
+	// List images before references: an image written after this point is not considered at all,
+	// and one referenced after it is covered by the grace period
+	var stored []ImageObject
+	for _, prefix := range imageGCPrefixes {
+		objects, err := s.images.List(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list images: %w", err)
+		}
+		stored = append(stored, objects...)
+	}
+
+	recipeImages, err := s.recipes.ListAllImages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipe images: %w", err)
+	}
+	photoImages, err := s.photos.ListAllImages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipe photos: %w", err)
+	}
+	recipeImages = append(recipeImages, photoImages...)
+	referenced := make(map[string]bool, len(recipeImages)*2)
+	for _, recipe := range recipeImages {
+		for _, imagePath := range recipe.Paths() {
+			if key, ok := ImageKeyFromPath(imagePath); ok {
+				referenced[key] = true
+			}
+		}
+	}
+
+	cutoff := time.Now().Add(-s.gracePeriod)
+	report.ScannedCount = len(stored)
+	for _, object := range stored {
+		if referenced[object.Key] {
+			report.ReferencedCount++
+			continue
+		}
+		if object.ModTime.After(cutoff) {
+			report.RecentCount++
+			continue
+		}
+
+		report.Orphans = append(report.Orphans, models.OrphanedImage{
+			Key:       object.Key,
+			SizeBytes: object.Size,
+			ModTime:   object.ModTime,
+		})
+		report.OrphanBytes += object.Size
+		if dryRun {
+			continue
+		}
+
+		if err := s.remove(object.Key); err != nil && !errors.Is(err, ErrImageNotFound) {
+			report.FailedKeys = append(report.FailedKeys, object.Key)
+			continue
+		}
+		report.RemovedCount++
+	}
+
+	return report, nil
+}
+
+// remove deletes an orphan, or moves it to quarantine
+func (s *ImageGCService) remove(key string) error {
+	if s.quarantine {
+		reader, image, err := s.images.Get(key)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+		if err := s.images.Put(imageQuarantinePrefix+key, data, image.ContentType); err != nil {
+			return err
+		}
+	}
+	return s.images.Delete(key)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"chefly/models"
+)
+
+// Image generation qualities, providers map them to their own settings (DALL-E quality, Stable Diffusion steps)
+const (
+	ImageQualityStandard = "standard"
+	ImageQualityHD       = "hd"
+)
+
+// ImageGenerator generates recipe images
+// Implementations: OpenAIService (DALL-E), StableDiffusionService (Automatic1111 API), OfflineImageGenerator
+type ImageGenerator interface {
+	// GenerateFoodImage returns the generated image as a data: URL or a URL to download it from
+	// Cancelling ctx aborts the generation
+	GenerateFoodImage(ctx context.Context, recipe FoodImageRequest, spec ImageSpec) (string, error)
+}
+
+// FoodImageRequest describes the dish to generate an image of
+type FoodImageRequest struct {
+	Title       string
+	CuisineType string
+	Description string
+	Style       models.ImageStyle
+}
+
+// ImageSpec is the size and quality of generated images
+type ImageSpec struct {
+	Width   int
+	Height  int
+	Quality string // ImageQualityStandard or ImageQualityHD
+}
+
+// ParseImageSpec parses a size (e.g. "1024x1024") and quality ("standard" or "hd")
+func ParseImageSpec(size, quality string) (ImageSpec, error) {
+	spec := ImageSpec{Quality: strings.ToLower(strings.TrimSpace(quality))}
+
+	width, height, found := strings.Cut(strings.ToLower(strings.TrimSpace(size)), "x")
+	var err error
+	if spec.Width, err = strconv.Atoi(width); !found || err != nil || spec.Width < 64 || spec.Width > 4096 {
+		return spec, fmt.Errorf("invalid image size %q (e.g. 1024x1024, 64-4096px)", size)
+	}
+	if spec.Height, err = strconv.Atoi(height); err != nil || spec.Height < 64 || spec.Height > 4096 {
+		return spec, fmt.Errorf("invalid image size %q (e.g. 1024x1024, 64-4096px)", size)
+	}
+
+	if spec.Quality != ImageQualityStandard && spec.Quality != ImageQualityHD {
+		return spec, fmt.Errorf("invalid image quality %q (supported: standard, hd)", quality)
+	}
+	return spec, nil
+}
+
+// buildImagePrompt creates a detailed prompt for food photography
+// Style hints (plating, angle, background) replace the defaults of the prompt
+func buildImagePrompt(recipe FoodImageRequest) string {
+	plating := "a clean white plate"
+	if hint := styleHint(recipe.Style.Plating); hint != "" {
+		plating = hint
+	}
+	angle := "top-down view"
+	if hint := styleHint(recipe.Style.Angle); hint != "" {
+		angle = hint
+	}
+	background := ""
+	if hint := styleHint(recipe.Style.Background); hint != "" {
+		background = fmt.Sprintf("Background: %s.\n", hint)
+	}
+
+	prompt := fmt.Sprintf(`Professional food photography of %s.
+%s cuisine style dish.
+High-quality, realistic, appetizing presentation on %s.
+Natural lighting, restaurant quality, %s.
+%sSharp focus on the food with shallow depth of field.
+Garnished beautifully with fresh ingredients.
+Professional chef presentation, magazine quality photo.
+%s
+No text, no watermarks, no people, just the delicious food.`,
+		recipe.Title,
+		recipe.CuisineType,
+		plating,
+		angle,
+		background,
+		recipe.Description)
+
+	return prompt
+}
+
+// styleHint normalizes a user provided style hint to a single line
+func styleHint(hint string) string {
+	return strings.Join(strings.Fields(hint), " ")
+}
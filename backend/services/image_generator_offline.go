@@ -0,0 +1,39 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+
+	"github.com/disintegration/imaging"
+)
+
+// OfflineImageGenerator renders images locally without any external service
+// The same recipe always gives the same image, which makes it suited for tests and offline development
+type OfflineImageGenerator struct{}
+
+// NewOfflineImageGenerator creates a new offline image generator
+func NewOfflineImageGenerator() *OfflineImageGenerator {
+	return &OfflineImageGenerator{}
+}
+
+// GenerateFoodImage renders the placeholder design of the recipe as a PNG data URL
+// Style hints and quality are ignored
+func (g *OfflineImageGenerator) GenerateFoodImage(ctx context.Context, recipe FoodImageRequest, spec ImageSpec) (string, error) {
+	if err := contextError(ctx); err != nil {
+		return "", err
+	}
+
+	// The design is square, crop it to the requested aspect ratio
+	img := renderPlaceholder(recipe.Title, recipe.CuisineType, "", max(spec.Width, spec.Height))
+	img = imaging.CropCenter(img, spec.Width, spec.Height)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"chefly/models"
+)
+
+// maxStableDiffusionResponse limits the txt2img response (base64 images and generation info)
+const maxStableDiffusionResponse = 64 << 20
+
+// stableDiffusionNegativePrompt keeps Stable Diffusion from adding what the DALL-E prompt rules out in words
+const stableDiffusionNegativePrompt = "text, watermark, logo, people, hands, blurry, low quality, deformed, cartoon, illustration"
+
+// StableDiffusionService generates images with a Stable Diffusion server exposing the Automatic1111 web UI API
+// (started with --api, also implemented by SD.Next and Forge)
+type StableDiffusionService struct {
+	baseURL    string
+	username   string
+	password   string
+	timeout    time.Duration
+	httpClient *http.Client
+	resilience *AIResilience
+	usage      *AIUsageRecorder
+}
+
+// NewStableDiffusionService creates a new Stable Diffusion service
+// auth is the "username:password" configured with --api-auth, empty if the API is not protected
+// timeout bounds generating one image including retries (0 = no limit besides the request context), generation on small GPUs is slow
+func NewStableDiffusionService(baseURL, auth string, timeout time.Duration, resilience *AIResilience, usage *AIUsageRecorder) *StableDiffusionService {
+	username, password, _ := strings.Cut(auth, ":")
+	return &StableDiffusionService{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		username:   username,
+		password:   password,
+		timeout:    timeout,
+		httpClient: NewAIHTTPClient(),
+		resilience: resilience,
+		usage:      usage,
+	}
+}
+
+// txt2imgRequest is the request body of /sdapi/v1/txt2img
+type txt2imgRequest struct {
+	Prompt         string  `json:"prompt"`
+	NegativePrompt string  `json:"negative_prompt"`
+	Width          int     `json:"width"`
+	Height         int     `json:"height"`
+	Steps          int     `json:"steps"`
+	CFGScale       float64 `json:"cfg_scale"`
+	BatchSize      int     `json:"batch_size"`
+}
+
+// txt2imgResponse is the response body of /sdapi/v1/txt2img
+type txt2imgResponse struct {
+	Images []string `json:"images"` // Base64 encoded PNGs
+}
+
+// GenerateFoodImage generates a realistic food image with Stable Diffusion
+// HD quality doubles the sampling steps, the size should be a multiple of 8
+func (s *StableDiffusionService) GenerateFoodImage(ctx context.Context, recipe FoodImageRequest, spec ImageSpec) (string, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	steps := 20
+	if spec.Quality == ImageQualityHD {
+		steps = 40
+	}
+
+	body, err := json.Marshal(txt2imgRequest{
+		Prompt:         buildImagePrompt(recipe),
+		NegativePrompt: stableDiffusionNegativePrompt,
+		Width:          spec.Width,
+		Height:         spec.Height,
+		Steps:          steps,
+		CFGScale:       7,
+		BatchSize:      1,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	var result *txt2imgResponse
+	err = s.resilience.Do(ctx, "image", func(ctx context.Context) error {
+		started := time.Now()
+		result, err = s.txt2img(ctx, body)
+		if err == nil {
+			// Self-hosted, priced only if AI_PRICES has an entry for stable-diffusion
+			s.usage.Record(ctx, models.AIUsage{
+				Provider:     "stable-diffusion",
+				Model:        "stable-diffusion",
+				Operation:    "image",
+				ImageCount:   len(result.Images),
+				ImageSize:    fmt.Sprintf("%dx%d", spec.Width, spec.Height),
+				ImageQuality: spec.Quality,
+			}, started)
+		}
+		return err
+	})
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return "", fmt.Errorf("%w: %v", ctxErr, err)
+		}
+		return "", fmt.Errorf("failed to generate image: %w", err)
+	}
+	if len(result.Images) == 0 || result.Images[0] == "" {
+		return "", fmt.Errorf("no image generated")
+	}
+
+	// Images are plain base64, some forks already return a data URL
+	if strings.HasPrefix(result.Images[0], "data:") {
+		return result.Images[0], nil
+	}
+	return "data:image/png;base64," + result.Images[0], nil
+}
+
+// txt2img sends one txt2img request
+func (s *StableDiffusionService) txt2img(ctx context.Context, body []byte) (*txt2imgResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/sdapi/v1/txt2img", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &ProviderStatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+
+	var result txt2imgResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxStableDiffusionResponse)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
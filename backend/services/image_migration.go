@@ -0,0 +1,115 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"chefly/models"
+	"chefly/store"
+)
+
+// MigrateLegacyImages moves images from the legacy uploads directory (./uploads, used before IMAGE_STORAGE_PATH
+// was honored) into the image store and rewrites recipe image paths that refer to files in that directory
+// Files whose key already exists in the store are left in place. Emptied directories are removed,
+// so once everything is moved the legacy directory is gone and later runs have nothing to do
+func MigrateLegacyImages(legacyDir string, images ImageStore, recipes store.RecipeStore) (*models.ImageMigrationReport, error) {
+	report := &models.ImageMigrationReport{FilesSkipped: []string{}}
+
+	var dirs []string
+	err := filepath.Walk(legacyDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && filePath == legacyDir {
+				return filepath.SkipDir // Nothing to migrate
+			}
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, filePath)
+			return nil
+		}
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(legacyDir, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+
+		exists, err := images.Exists(key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			report.FilesSkipped = append(report.FilesSkipped, key)
+			return nil
+		}
+
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		if err := images.Put(key, data, imageContentType(key)); err != nil {
+			return err
+		}
+		// Only remove the original once the store holds the copy
+		if err := os.Remove(filePath); err != nil {
+			return err
+		}
+		report.FilesMoved++
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to move legacy images: %w", err)
+	}
+
+	// Remove emptied directories, deepest first (non-empty ones fail and stay)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+
+	recipeImages, err := recipes.ListAllImages()
+	if err != nil {
+		return report, fmt.Errorf("failed to list recipe images: %w", err)
+	}
+	for _, recipe := range recipeImages {
+		imagePath := legacyImagePath(recipe.ImagePath, legacyDir)
+		thumbnailPath := legacyImagePath(recipe.ThumbnailPath, legacyDir)
+		if imagePath == recipe.ImagePath && thumbnailPath == recipe.ThumbnailPath {
+			continue
+		}
+		if err := recipes.UpdateImages(recipe.RecipeID, imagePath, thumbnailPath, recipe.Variants); err != nil {
+			return report, fmt.Errorf("failed to update images of recipe %s: %w", recipe.RecipeID, err)
+		}
+		report.RecipesUpdated++
+	}
+
+	return report, nil
+}
+
+// legacyImagePath rewrites an image path stored as a filesystem path in the legacy directory
+// (./uploads/images/full/abc.jpg, /app/uploads/images/full/abc.jpg) to its served path (/uploads/images/full/abc.jpg)
+// Served paths, inline data: URLs and external URLs are returned unchanged
+func legacyImagePath(imagePath, legacyDir string) string {
+	if imagePath == "" || strings.HasPrefix(imagePath, ImageURLPrefix) || strings.Contains(imagePath, ":") {
+		return imagePath
+	}
+
+	prefixes := []string{filepath.ToSlash(filepath.Clean(legacyDir)) + "/"}
+	if absDir, err := filepath.Abs(legacyDir); err == nil {
+		prefixes = append(prefixes, filepath.ToSlash(absDir)+"/")
+	}
+
+	cleaned := filepath.ToSlash(filepath.Clean(imagePath))
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(cleaned, prefix) {
+			if key, err := cleanImageKey(strings.TrimPrefix(cleaned, prefix)); err == nil {
+				return ImagePathFromKey(key)
+			}
+		}
+	}
+	return imagePath
+}
@@ -2,44 +2,59 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"image"
-	"image/jpeg"
-	"io/ioutil"
-	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 
+	"chefly/models"
+
 	"github.com/disintegration/imaging"
 	"github.com/google/uuid"
 )
 
+// imageVariantPrefix is the store prefix of image variants, stored as images/variants/<image id>/<width>.<extension>
+const imageVariantPrefix = "images/variants/"
+
+// ErrImageSourceGone is returned when a remote image is permanently unavailable (e.g. an expired DALL-E URL)
+var ErrImageSourceGone = errors.New("image source no longer available")
+
 // ImageOptimizer handles image optimization tasks
 type ImageOptimizer struct {
-	uploadsDir string
+	store      ImageStore
+	variants   ImageVariantConfig
+	downloader *ImageDownloader
+	rendered   *placeholderCache
 }
 
-// OptimizedImages contains paths to optimized image variants
+// OptimizedImages contains store keys and URLs of optimized image variants
+// The full image is the largest JPEG variant, the thumbnail the smallest
 type OptimizedImages struct {
-	FullImagePath      string
-	ThumbnailPath      string
+	FullImagePath string // Image store key
+	ThumbnailPath string // Image store key
 	FullImageURL       string
 	ThumbnailURL       string
+	Variants      []models.ImageVariant
+	SourceWidth   int
+	SourceHeight  int
 }
 
 // NewImageOptimizer creates a new ImageOptimizer instance
-func NewImageOptimizer(uploadsDir string) *ImageOptimizer {
+func NewImageOptimizer(store ImageStore, variants ImageVariantConfig, downloader *ImageDownloader) *ImageOptimizer {
 	return &ImageOptimizer{
-		uploadsDir: uploadsDir,
+		store:      store,
+		variants:   variants,
+		downloader: downloader,
+		rendered:   newPlaceholderCache(placeholderCacheBytes),
 	}
 }
 
 // OptimizeRecipeImage downloads and optimizes a recipe image from DALL-E
-// Returns both full-size (800x800) and thumbnail (200x200) JPEG images
-// Supports both HTTP URLs and base64 data URLs
-func (opt *ImageOptimizer) OptimizeRecipeImage(imageURL string) (*OptimizedImages, error) {
+// Returns square variants in every configured width and format (by default 200, 400 and 800px, JPEG and WebP)
+// Supports both HTTP URLs and base64 data URLs, ctx cancels downloads
+func (opt *ImageOptimizer) OptimizeRecipeImage(ctx context.Context, imageURL string) (*OptimizedImages, error) {
 	var imageData []byte
 	var err error
 
@@ -58,19 +73,14 @@ func (opt *ImageOptimizer) OptimizeRecipeImage(imageURL string) (*OptimizedImage
 		}
 	} else {
 		// Regular HTTP URL - download the image
-		resp, err := http.Get(imageURL)
-		if err != nil {
-			return nil, fmt.Errorf("failed to download image: %w", err)
+		imageData, _, err = opt.downloader.Download(ctx, imageURL)
+		var statusErr *DownloadStatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 {
+			// Client errors will not go away (DALL-E URLs expire after a few hours)
+			return nil, fmt.Errorf("failed to download image: %w (status code %d)", ErrImageSourceGone, statusErr.StatusCode)
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("failed to download image: status code %d", resp.StatusCode)
-		}
-
-		imageData, err = ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read image data: %w", err)
+			return nil, fmt.Errorf("failed to download image: %w", err)
 		}
 	}
 
@@ -80,69 +90,79 @@ func (opt *ImageOptimizer) OptimizeRecipeImage(imageURL string) (*OptimizedImage
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
 
-	// Generate unique filename
-	imageID := uuid.New().String()
-
-	// Create directories if they don't exist
-	fullDir := filepath.Join(opt.uploadsDir, "images", "full")
-	thumbDir := filepath.Join(opt.uploadsDir, "images", "thumbnails")
+	return opt.storeVariants(img)
+}
 
-	if err := os.MkdirAll(fullDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create full images directory: %w", err)
-	}
+// storeVariants stores square variants of a decoded image in every configured width and format
+func (opt *ImageOptimizer) storeVariants(img image.Image) (*OptimizedImages, error) {
+	// Generate unique image id
+	imageID := uuid.New().String()
 
-	if err := os.MkdirAll(thumbDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create thumbnails directory: %w", err)
+	bounds := img.Bounds()
+	sourceSize := bounds.Dx()
+	if bounds.Dy() < sourceSize {
+		sourceSize = bounds.Dy()
 	}
 
-	// Process full-size image (800x800)
-	fullImageFilename := fmt.Sprintf("%s.jpg", imageID)
-	fullImagePath := filepath.Join(fullDir, fullImageFilename)
-
-	if err := opt.createOptimizedImage(img, fullImagePath, 800, 85); err != nil {
-		return nil, fmt.Errorf("failed to create full-size image: %w", err)
+	var variants []models.ImageVariant
+	for _, width := range opt.variants.variantWidths(sourceSize) {
+		// Crop to a square and resize (recipe images are square)
+		resized := imaging.Fill(img, width, width, imaging.Center, imaging.Lanczos)
+
+		for _, format := range opt.variants.Formats {
+			variant, err := opt.storeVariant(resized, imageID, width, format)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create %dpx %s image: %w", width, format, err)
+			}
+			variants = append(variants, *variant)
+		}
 	}
 
-	// Process thumbnail (200x200)
-	thumbFilename := fmt.Sprintf("%s_thumb.jpg", imageID)
-	thumbPath := filepath.Join(thumbDir, thumbFilename)
-
-	if err := opt.createOptimizedImage(img, thumbPath, 200, 75); err != nil {
-		return nil, fmt.Errorf("failed to create thumbnail: %w", err)
+	// Widths are ascending and JPEG is always produced
+	var fullImage, thumbnail *models.ImageVariant
+	for i := range variants {
+		if variants[i].Format != ImageFormatJPEG {
+			continue
+		}
+		if thumbnail == nil {
+			thumbnail = &variants[i]
+		}
+		fullImage = &variants[i]
 	}
 
-	// Generate URLs for serving images
-	fullImageURL := fmt.Sprintf("/uploads/images/full/%s", fullImageFilename)
-	thumbnailURL := fmt.Sprintf("/uploads/images/thumbnails/%s", thumbFilename)
+	fullImageKey, _ := ImageKeyFromPath(fullImage.URL)
+	thumbKey, _ := ImageKeyFromPath(thumbnail.URL)
 
 	return &OptimizedImages{
-		FullImagePath:      fullImagePath,
-		ThumbnailPath:      thumbPath,
-		FullImageURL:       fullImageURL,
-		ThumbnailURL:       thumbnailURL,
+		FullImagePath: fullImageKey,
+		ThumbnailPath: thumbKey,
+		FullImageURL:  fullImage.URL,
+		ThumbnailURL:  thumbnail.URL,
+		Variants:      variants,
+		SourceWidth:   bounds.Dx(),
+		SourceHeight:  bounds.Dy(),
 	}, nil
 }
 
-// createOptimizedImage resizes and saves an image as JPEG
-func (opt *ImageOptimizer) createOptimizedImage(img image.Image, outputPath string, size int, quality int) error {
-	// Resize image maintaining aspect ratio (will be square for recipe images)
-	resized := imaging.Fill(img, size, size, imaging.Center, imaging.Lanczos)
-
-	// Create output file
-	outFile, err := os.Create(outputPath)
+// storeVariant encodes a resized image in a format and stores it
+func (opt *ImageOptimizer) storeVariant(img image.Image, imageID string, width int, format string) (*models.ImageVariant, error) {
+	data, err := opt.variants.encodeImage(img, format)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return nil, fmt.Errorf("failed to encode image: %w", err)
 	}
-	defer outFile.Close()
 
-	// Encode as JPEG with specified quality
-	// JPEG quality 85 for full images (~100-150KB)
-	// JPEG quality 75 for thumbnails (~15-20KB)
-	options := &jpeg.Options{Quality: quality}
-	err = jpeg.Encode(outFile, resized, options)
-	if err != nil {
-		return fmt.Errorf("failed to encode image: %w", err)
+	imageFormat := imageFormats[format]
+	key := fmt.Sprintf("%s%s/%d%s", imageVariantPrefix, imageID, width, imageFormat.extension)
+	if err := opt.store.Put(key, data, imageFormat.contentType); err != nil {
+		return nil, fmt.Errorf("failed to store image: %w", err)
 	}
 
-	return nil
+	return &models.ImageVariant{
+		URL:         ImagePathFromKey(key),
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Format:      format,
+		ContentType: imageFormat.contentType,
+		SizeBytes:   int64(len(data)),
+	}, nil
 }
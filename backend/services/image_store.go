@@ -0,0 +1,232 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrImageNotFound is returned when an image does not exist in the store
+	ErrImageNotFound = errors.New("image not found")
+	// ErrInvalidImageKey is returned for keys that are empty or escape the store (e.g. "../x")
+	ErrInvalidImageKey = errors.New("invalid image key")
+)
+
+// ImageURLPrefix is the URL path images are served under, stored image paths are ImageURLPrefix + key
+const ImageURLPrefix = "/uploads/"
+
+// ImageObject describes a stored image
+type ImageObject struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// ImageStore stores recipe image files under slash separated keys (e.g. "images/full/<id>.jpg")
+type ImageStore interface {
+	Put(key string, data []byte, contentType string) error
+	// Get opens an image for reading, the caller must close it
+	Get(key string) (io.ReadCloser, *ImageObject, error)
+	Delete(key string) error
+	Exists(key string) (bool, error)
+	// List returns all images whose key starts with prefix
+	List(prefix string) ([]ImageObject, error)
+	// URL returns a URL clients can download the image from directly (e.g. pre-signed),
+	// or "" if the image should be served through ImageURLPrefix
+	URL(key string) (string, error)
+}
+
+// ImageKeyFromPath converts a stored image path (/uploads/images/full/abc.jpg) to its store key (images/full/abc.jpg)
+// Returns false for paths not held by the image store (inline data: URLs, external URLs, empty)
+func ImageKeyFromPath(imagePath string) (string, bool) {
+	if !strings.HasPrefix(imagePath, ImageURLPrefix) {
+		return "", false
+	}
+	key, err := cleanImageKey(strings.TrimPrefix(imagePath, ImageURLPrefix))
+	if err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// ImagePathFromKey converts a store key to the image path stored in the database and served to clients
+func ImagePathFromKey(key string) string {
+	return ImageURLPrefix + key
+}
+
+// cleanImageKey normalizes a key and rejects keys escaping the store
+func cleanImageKey(key string) (string, error) {
+	cleaned := path.Clean("/" + strings.ReplaceAll(key, "\\", "/"))
+	cleaned = strings.TrimPrefix(cleaned, "/")
+	if cleaned == "" || cleaned == "." || strings.Contains(key, "..") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidImageKey, key)
+	}
+	return cleaned, nil
+}
+
+// imageContentType returns the content type for a key based on its extension
+func imageContentType(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// LocalImageStore stores images in a directory on the local filesystem
+type LocalImageStore struct {
+	baseDir string
+}
+
+// NewLocalImageStore creates an image store rooted at baseDir
+func NewLocalImageStore(baseDir string) *LocalImageStore {
+	return &LocalImageStore{baseDir: baseDir}
+}
+
+// filePath returns the filesystem path for a key
+func (s *LocalImageStore) filePath(key string) (string, string, error) {
+	cleaned, err := cleanImageKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return cleaned, filepath.Join(s.baseDir, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *LocalImageStore) Put(key string, data []byte, contentType string) error {
+	_, filePath, err := s.filePath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create image directory: %w", err)
+	}
+
+	// Write through a temporary file so the image is never served half written
+	temp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create image file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write image file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write image file: %w", err)
+	}
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write image file: %w", err)
+	}
+	return os.Rename(temp.Name(), filePath)
+}
+
+func (s *LocalImageStore) Get(key string) (io.ReadCloser, *ImageObject, error) {
+	cleaned, filePath, err := s.filePath(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, nil, ErrImageNotFound
+	}
+
+	return file, &ImageObject{
+		Key:         cleaned,
+		Size:        info.Size(),
+		ContentType: imageContentType(cleaned),
+		ModTime:     info.ModTime(),
+	}, nil
+}
+
+func (s *LocalImageStore) Delete(key string) error {
+	_, filePath, err := s.filePath(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filePath)
+	if os.IsNotExist(err) {
+		return ErrImageNotFound
+	}
+	return err
+}
+
+func (s *LocalImageStore) Exists(key string) (bool, error) {
+	_, filePath, err := s.filePath(key)
+	if err != nil {
+		return false, err
+	}
+
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.Mode().IsRegular(), nil
+}
+
+func (s *LocalImageStore) List(prefix string) ([]ImageObject, error) {
+	objects := []ImageObject{}
+
+	err := filepath.Walk(s.baseDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && filePath == s.baseDir {
+				return filepath.SkipDir // Nothing stored yet
+			}
+			return err
+		}
+		// Skip temporary files of writes in progress
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.baseDir, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		objects = append(objects, ImageObject{
+			Key:         key,
+			Size:        info.Size(),
+			ContentType: imageContentType(key),
+			ModTime:     info.ModTime(),
+		})
+		return nil
+	})
+
+	return objects, err
+}
+
+func (s *LocalImageStore) URL(key string) (string, error) {
+	// Local images are always served by Chefly
+	return "", nil
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config configures an S3-compatible image store (AWS S3, MinIO, Ceph RGW, ...)
+type S3Config struct {
+	Endpoint        string // Host and optional port, without scheme (e.g: s3.eu-central-1.amazonaws.com, minio:9000)
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+	Prefix          string        // Optional key prefix, lets several instances share a bucket
+	PresignExpiry   time.Duration // > 0: clients download images directly with pre-signed URLs valid this long
+	Timeout         time.Duration // Bounds every request, including reading a downloaded image (0 = defaultS3Timeout)
+}
+
+// defaultS3Timeout bounds S3 requests when no timeout is configured
+const defaultS3Timeout = 30 * time.Second
+
+// S3ImageStore stores images in an S3-compatible bucket
+type S3ImageStore struct {
+	client        *minio.Client
+	bucket        string
+	prefix        string
+	presignExpiry time.Duration
+	timeout       time.Duration
+}
+
+// NewS3ImageStore connects to the bucket, creating it if it does not exist yet
+func NewS3ImageStore(cfg S3Config) (*S3ImageStore, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 image store requires an endpoint and a bucket")
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultS3Timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access S3 bucket %s: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("failed to create S3 bucket %s: %w", cfg.Bucket, err)
+		}
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &S3ImageStore{
+		client:        client,
+		bucket:        cfg.Bucket,
+		prefix:        prefix,
+		presignExpiry: cfg.PresignExpiry,
+		timeout:       timeout,
+	}, nil
+}
+
+// requestContext bounds one request, so a stalled bucket fails requests, backups and the image GC instead of hanging them
+func (s *S3ImageStore) requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.timeout)
+}
+
+// cancelOnClose releases the request context of a download once the caller has read it
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.cancel()
+	return err
+}
+
+// objectName returns the bucket object name for a key
+func (s *S3ImageStore) objectName(key string) (string, string, error) {
+	cleaned, err := cleanImageKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return cleaned, s.prefix + cleaned, nil
+}
+
+// isNotFound reports whether an S3 error means the object does not exist
+func isNotFound(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NotFound"
+}
+
+func (s *S3ImageStore) Put(key string, data []byte, contentType string) error {
+	_, object, err := s.objectName(key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	// Keys are unique per image, so clients may cache them forever
+	_, err = s.client.PutObject(ctx, s.bucket, object, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: "public, max-age=31536000, immutable",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload image: %w", err)
+	}
+	return nil
+}
+
+func (s *S3ImageStore) Get(key string) (io.ReadCloser, *ImageObject, error) {
+	cleaned, object, err := s.objectName(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The context stays alive until the caller closes the reader
+	ctx, cancel := s.requestContext()
+	reader, err := s.client.GetObject(ctx, s.bucket, object, minio.GetObjectOptions{})
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("failed to download image: %w", err)
+	}
+
+	// GetObject is lazy, Stat performs the request and reports missing objects
+	info, err := reader.Stat()
+	if err != nil {
+		reader.Close()
+		cancel()
+		if isNotFound(err) {
+			return nil, nil, ErrImageNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to download image: %w", err)
+	}
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = imageContentType(cleaned)
+	}
+
+	return &cancelOnClose{ReadCloser: reader, cancel: cancel}, &ImageObject{
+		Key:         cleaned,
+		Size:        info.Size,
+		ContentType: contentType,
+		ModTime:     info.LastModified,
+	}, nil
+}
+
+func (s *S3ImageStore) Delete(key string) error {
+	exists, err := s.Exists(key)
+	if err != nil {
+		return err
+	}
+	// S3 deletes are idempotent, check first so callers can tell missing images apart like with local storage
+	if !exists {
+		return ErrImageNotFound
+	}
+
+	_, object, err := s.objectName(key)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := s.requestContext()
+	defer cancel()
+	if err := s.client.RemoveObject(ctx, s.bucket, object, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete image: %w", err)
+	}
+	return nil
+}
+
+func (s *S3ImageStore) Exists(key string) (bool, error) {
+	_, object, err := s.objectName(key)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	_, err = s.client.StatObject(ctx, s.bucket, object, minio.StatObjectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check image: %w", err)
+	}
+	return true, nil
+}
+
+func (s *S3ImageStore) List(prefix string) ([]ImageObject, error) {
+	objects := []ImageObject{}
+
+	// The timeout bounds the whole listing, which pages through the bucket
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.prefix + prefix,
+		Recursive: true,
+	}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("failed to list images: %w", info.Err)
+		}
+
+		key := strings.TrimPrefix(info.Key, s.prefix)
+		objects = append(objects, ImageObject{
+			Key:         key,
+			Size:        info.Size,
+			ContentType: imageContentType(key),
+			ModTime:     info.LastModified,
+		})
+	}
+
+	return objects, nil
+}
+
+func (s *S3ImageStore) URL(key string) (string, error) {
+	// Without pre-signing, images are proxied through Chefly so the bucket can stay private
+	if s.presignExpiry <= 0 {
+		return "", nil
+	}
+
+	_, object, err := s.objectName(key)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	presigned, err := s.client.PresignedGetObject(ctx, s.bucket, object, s.presignExpiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to pre-sign image URL: %w", err)
+	}
+	return presigned.String(), nil
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// S3 integration tests run against the bucket of a local MinIO when S3_TEST_ENDPOINT is set, see the README
+const (
+	s3TestEndpointEnv  = "S3_TEST_ENDPOINT"
+	s3TestAccessKeyEnv = "S3_TEST_ACCESS_KEY_ID"
+	s3TestSecretKeyEnv = "S3_TEST_SECRET_ACCESS_KEY"
+	s3TestBucketEnv    = "S3_TEST_BUCKET"
+)
+
+// newTestS3ImageStore connects to the test bucket below a prefix unique to the test, its images are deleted afterwards
+func newTestS3ImageStore(t *testing.T, presignExpiry time.Duration) *S3ImageStore {
+	t.Helper()
+	endpoint := os.Getenv(s3TestEndpointEnv)
+	if endpoint == "" {
+		t.Skipf("%s is not set", s3TestEndpointEnv)
+	}
+	bucket := os.Getenv(s3TestBucketEnv)
+	if bucket == "" {
+		bucket = "chefly-test"
+	}
+
+	store, err := NewS3ImageStore(S3Config{
+		Endpoint:        endpoint,
+		Region:          "us-east-1",
+		Bucket:          bucket,
+		AccessKeyID:     os.Getenv(s3TestAccessKeyEnv),
+		SecretAccessKey: os.Getenv(s3TestSecretKeyEnv),
+		Prefix:          fmt.Sprintf("test-%d", time.Now().UnixNano()),
+		PresignExpiry:   presignExpiry,
+		Timeout:         10 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewS3ImageStore: %v", err)
+	}
+	t.Cleanup(func() {
+		objects, err := store.List("")
+		if err != nil {
+			t.Errorf("List: %v", err)
+			return
+		}
+		for _, object := range objects {
+			if err := store.Delete(object.Key); err != nil {
+				t.Errorf("Delete(%s): %v", object.Key, err)
+			}
+		}
+	})
+	return store
+}
+
+func TestS3ImageStore(t *testing.T) {
+	store := newTestS3ImageStore(t, 0)
+
+	if err := store.Put("images/a.jpg", []byte("jpeg"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put("images/variants/b/400.webp", []byte("webp data"), "image/webp"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put("../escape.jpg", []byte("jpeg"), "image/jpeg"); err == nil {
+		t.Error("Put accepted a key outside the store")
+	}
+
+	reader, object, err := store.Get("images/a.jpg")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || string(data) != "jpeg" {
+		t.Errorf("Get data = %q, %v", data, err)
+	}
+	if object.Key != "images/a.jpg" || object.Size != 4 || object.ContentType != "image/jpeg" || object.ModTime.IsZero() {
+		t.Errorf("Get object = %+v", object)
+	}
+	if _, _, err := store.Get("images/missing.jpg"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrImageNotFound", err)
+	}
+
+	if exists, err := store.Exists("images/a.jpg"); err != nil || !exists {
+		t.Errorf("Exists = %v, %v, want true", exists, err)
+	}
+	if exists, err := store.Exists("images/missing.jpg"); err != nil || exists {
+		t.Errorf("Exists(missing) = %v, %v, want false", exists, err)
+	}
+
+	objects, err := store.List("images/variants/")
+	if err != nil || len(objects) != 1 || objects[0].Key != "images/variants/b/400.webp" || objects[0].Size != 9 {
+		t.Errorf("List(variants) = %+v, %v", objects, err)
+	}
+	if objects, err := store.List(""); err != nil || len(objects) != 2 {
+		t.Errorf("List = %+v, %v, want 2 images", objects, err)
+	}
+
+	// Without pre-signing images are served through Chefly
+	if url, err := store.URL("images/a.jpg"); err != nil || url != "" {
+		t.Errorf("URL = %q, %v, want none", url, err)
+	}
+
+	if err := store.Delete("images/a.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := store.Delete("images/a.jpg"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("second Delete error = %v, want ErrImageNotFound", err)
+	}
+}
+
+func TestS3ImageStorePresignedURL(t *testing.T) {
+	store := newTestS3ImageStore(t, time.Hour)
+	if err := store.Put("images/a.jpg", []byte("jpeg"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	url, err := store.URL("images/a.jpg")
+	if err != nil || !strings.Contains(url, "X-Amz-Signature=") {
+		t.Fatalf("URL = %q, %v, want a pre-signed URL", url, err)
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET pre-signed URL: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(data) != "jpeg" {
+		t.Errorf("pre-signed download = %d %q", resp.StatusCode, data)
+	}
+}
+
+func TestS3ImageStoreTimesOutOnStalledBucket(t *testing.T) {
+	// The bucket exists, every other request stalls until the client gives up
+	stalled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && strings.Trim(r.URL.Path, "/") == "chefly" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-stalled:
+		}
+	}))
+	defer server.Close()
+	defer close(stalled)
+
+	store, err := NewS3ImageStore(S3Config{
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		Region:          "us-east-1",
+		Bucket:          "chefly",
+		AccessKeyID:     "chefly",
+		SecretAccessKey: "secret123",
+		Timeout:         200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewS3ImageStore: %v", err)
+	}
+
+	calls := map[string]func() error{
+		"Put":    func() error { return store.Put("images/a.jpg", []byte("jpeg"), "image/jpeg") },
+		"Get":    func() error { _, _, err := store.Get("images/a.jpg"); return err },
+		"Exists": func() error { _, err := store.Exists("images/a.jpg"); return err },
+		"List":   func() error { _, err := store.List(""); return err },
+	}
+	for name, call := range calls {
+		done := make(chan error, 1)
+		go func() { done <- call() }()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("%s succeeded against a stalled bucket", name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s still waits after 5s, want it to give up after the 200ms timeout", name)
+		}
+	}
+}
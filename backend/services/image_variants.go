@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chai2010/webp"
+)
+
+const (
+	// ImageFormatJPEG is always produced, it is the fallback for clients without WebP support
+	ImageFormatJPEG = "jpeg"
+	ImageFormatWebP = "webp"
+)
+
+// imageFormat describes how a variant format is stored
+type imageFormat struct {
+	extension   string
+	contentType string
+}
+
+// imageFormats lists the supported variant formats
+var imageFormats = map[string]imageFormat{
+	ImageFormatJPEG: {extension: ".jpg", contentType: "image/jpeg"},
+	ImageFormatWebP: {extension: ".webp", contentType: "image/webp"},
+}
+
+// ImageVariantConfig configures the renditions produced for every recipe image
+type ImageVariantConfig struct {
+	Widths      []int    // Square renditions in ascending order, widths above the source size are skipped
+	Formats     []string // Variant formats, JPEG first
+	JPEGQuality int
+	WebPQuality int
+}
+
+// DefaultImageVariantConfig matches the previous full-size (800px) and thumbnail (200px) images, plus WebP
+func DefaultImageVariantConfig() ImageVariantConfig {
+	return ImageVariantConfig{
+		Widths:      []int{200, 400, 800},
+		Formats:     []string{ImageFormatJPEG, ImageFormatWebP},
+		JPEGQuality: 85,
+		WebPQuality: 80,
+	}
+}
+
+// ParseImageVariantConfig parses comma separated widths (e.g. "200,400,800") and formats (e.g. "webp,jpeg")
+// JPEG is added when missing, so every recipe keeps a universally supported image
+func ParseImageVariantConfig(widths, formats string, jpegQuality, webpQuality int) (ImageVariantConfig, error) {
+	config := ImageVariantConfig{JPEGQuality: jpegQuality, WebPQuality: webpQuality}
+
+	seenWidths := map[int]bool{}
+	for _, value := range strings.Split(widths, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		width, err := strconv.Atoi(value)
+		if err != nil || width < 16 || width > 4096 {
+			return config, fmt.Errorf("invalid image variant width %q (must be 16-4096)", value)
+		}
+		if !seenWidths[width] {
+			seenWidths[width] = true
+			config.Widths = append(config.Widths, width)
+		}
+	}
+	if len(config.Widths) == 0 {
+		return config, fmt.Errorf("at least one image variant width is required")
+	}
+	sort.Ints(config.Widths)
+
+	config.Formats = []string{ImageFormatJPEG}
+	for _, value := range strings.Split(formats, ",") {
+		format := strings.ToLower(strings.TrimSpace(value))
+		if format == "jpg" {
+			format = ImageFormatJPEG
+		}
+		if format == "" || format == ImageFormatJPEG {
+			continue
+		}
+		if _, ok := imageFormats[format]; !ok {
+			return config, fmt.Errorf("unsupported image variant format %q (supported: jpeg, webp)", value)
+		}
+		config.Formats = append(config.Formats, format)
+	}
+
+	for _, quality := range []int{jpegQuality, webpQuality} {
+		if quality < 1 || quality > 100 {
+			return config, fmt.Errorf("invalid image quality %d (must be 1-100)", quality)
+		}
+	}
+
+	return config, nil
+}
+
+// encodeImage encodes an image in a variant format
+func (c ImageVariantConfig) encodeImage(img image.Image, format string) ([]byte, error) {
+	var encoded bytes.Buffer
+	var err error
+
+	switch format {
+	case ImageFormatWebP:
+		err = webp.Encode(&encoded, img, &webp.Options{Quality: float32(c.WebPQuality)})
+	default:
+		err = jpeg.Encode(&encoded, img, &jpeg.Options{Quality: c.JPEGQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return encoded.Bytes(), nil
+}
+
+// variantWidths returns the configured widths that do not upscale an image of the given size
+// Falls back to the source size when it is smaller than every configured width
+func (c ImageVariantConfig) variantWidths(sourceSize int) []int {
+	widths := []int{}
+	for _, width := range c.Widths {
+		if width <= sourceSize {
+			widths = append(widths, width)
+		}
+	}
+	if len(widths) == 0 {
+		widths = append(widths, sourceSize)
+	}
+	return widths
+}
+
+// NegotiatedImageKey returns the key of a sibling variant in a format preferred by the Accept header
+// (e.g. images/variants/<id>/800.webp for images/variants/<id>/800.jpg), or "" if the client prefers JPEG
+// negotiable reports whether the response for key depends on the Accept header at all
+func NegotiatedImageKey(key, accept string) (alternative string, negotiable bool) {
+	jpegExtension := imageFormats[ImageFormatJPEG].extension
+	if !strings.HasPrefix(key, imageVariantPrefix) || !strings.HasSuffix(key, jpegExtension) {
+		return "", false
+	}
+	if !acceptsMediaType(accept, imageFormats[ImageFormatWebP].contentType) {
+		return "", true
+	}
+	return strings.TrimSuffix(key, jpegExtension) + imageFormats[ImageFormatWebP].extension, true
+}
+
+// acceptsMediaType reports whether an Accept header explicitly lists a media type with a non-zero quality
+func acceptsMediaType(accept, mediaType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), mediaType) {
+			continue
+		}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.TrimSpace(name) == "q" {
+				if quality, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && quality <= 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"chefly/models"
+)
+
+// Notifier delivers notifications to users
+// Implementations must be safe for concurrent use
+type Notifier interface {
+	Send(notification models.Notification) error
+}
+
+// smtpTimeout bounds connecting to the SMTP server and the whole delivery of one message,
+// so a stalled server can't block the digest run forever
+const smtpTimeout = 30 * time.Second
+
+// SMTPNotifier delivers notifications as plain text email over SMTP
+type SMTPNotifier struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	timeout  time.Duration
+}
+
+// NewSMTPNotifier creates a new SMTP notifier
+// Authentication is skipped when username is empty (e.g. local relay or test server)
+func NewSMTPNotifier(host, port, username, password, from string) *SMTPNotifier {
+	return &SMTPNotifier{
+		addr:     net.JoinHostPort(host, port),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+		timeout:  smtpTimeout,
+	}
+}
+
+// Send delivers a notification as a single email
+func (n *SMTPNotifier) Send(notification models.Notification) error {
+	if notification.To == "" {
+		return fmt.Errorf("notification has no recipient")
+	}
+
+	// Envelope sender must be a bare address, header may contain a display name
+	sender, err := mail.ParseAddress(n.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	// Header injection: addresses end up in SMTP commands and headers
+	if strings.ContainsAny(notification.To, "\r\n") {
+		return fmt.Errorf("invalid recipient address")
+	}
+
+	message := n.buildMessage(sender, notification)
+	if err := n.deliver(sender.Address, notification.To, message); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// deliver sends one message like smtp.SendMail, but with a dial timeout and a deadline for the whole session
+func (n *SMTPNotifier) deliver(from, to string, message []byte) error {
+	conn, err := net.DialTimeout("tcp", n.addr, n.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(n.timeout)); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Upgrade to TLS when offered, PlainAuth refuses to send credentials unencrypted except to localhost
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return err
+		}
+	}
+	if n.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.username, n.password, n.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage renders the RFC 5322 message including headers
+func (n *SMTPNotifier) buildMessage(sender *mail.Address, notification models.Notification) []byte {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("From: %s\r\n", sender.String()))
+	builder.WriteString(fmt.Sprintf("To: %s\r\n", notification.To))
+	builder.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Subject)))
+	builder.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	builder.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	if notification.UnsubscribeURL != "" {
+		builder.WriteString(fmt.Sprintf("List-Unsubscribe: <%s>\r\n", notification.UnsubscribeURL))
+		// RFC 8058 one-click: mail clients unsubscribe with a POST, link scanners only ever GET
+		builder.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	builder.WriteString("\r\n")
+
+	// Normalize line endings to CRLF as required by SMTP
+	body := strings.ReplaceAll(notification.Body, "\r\n", "\n")
+	builder.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return []byte(builder.String())
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chefly/models"
+)
+
+// fakeSMTPMessage is a message received by fakeSMTPServer
+type fakeSMTPMessage struct {
+	from       string
+	recipients []string
+	data       string
+}
+
+// fakeSMTPServer speaks just enough SMTP to accept messages without TLS or authentication
+type fakeSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []fakeSMTPMessage
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &fakeSMTPServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost fake SMTP")
+
+	var message fakeSMTPMessage
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			text.PrintfLine("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			message.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			message.recipients = append(message.recipients, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			text.PrintfLine("250 OK")
+		case command == "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			message.data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			s.mu.Unlock()
+			message = fakeSMTPMessage{}
+			text.PrintfLine("250 OK")
+		case command == "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("250 OK")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) received() []fakeSMTPMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeSMTPMessage(nil), s.messages...)
+}
+
+func (s *fakeSMTPServer) notifier(t *testing.T) *SMTPNotifier {
+	t.Helper()
+	host, port, err := net.SplitHostPort(s.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("invalid listener address: %v", err)
+	}
+	return NewSMTPNotifier(host, port, "", "", "Chefly <chefly@example.com>")
+}
+
+func TestSMTPNotifierSend(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	notifier := server.notifier(t)
+
+	unsubscribeURL := "https://chefly.example.com/api/notifications/unsubscribe/abc123"
+	err := notifier.Send(models.Notification{
+		To:             "cook@example.com",
+		Subject:        "Your weekly Chefly digest",
+		Body:           "3 new recipes\nTo stop receiving these emails, visit: " + unsubscribeURL + "\n",
+		UnsubscribeURL: unsubscribeURL,
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	message := messages[0]
+
+	if message.from != "chefly@example.com" {
+		t.Errorf("envelope sender = %q, want the bare address chefly@example.com", message.from)
+	}
+	if len(message.recipients) != 1 || message.recipients[0] != "cook@example.com" {
+		t.Errorf("recipients = %v, want [cook@example.com]", message.recipients)
+	}
+
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(message.data)))
+	headers, err := reader.ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("failed to parse headers: %v", err)
+	}
+	wantHeaders := map[string]string{
+		"From":                  `"Chefly" <chefly@example.com>`,
+		"To":                    "cook@example.com",
+		"Subject":               "Your weekly Chefly digest",
+		"Content-Type":          "text/plain; charset=UTF-8",
+		"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	for name, want := range wantHeaders {
+		if got := headers.Get(name); got != want {
+			t.Errorf("header %s = %q, want %q", name, got, want)
+		}
+	}
+	if headers.Get("Date") == "" {
+		t.Error("Date header is missing")
+	}
+
+	body := message.data[strings.Index(message.data, "\n\n")+2:]
+	if !strings.Contains(body, "visit: "+unsubscribeURL) {
+		t.Errorf("body does not contain the unsubscribe link:\n%s", body)
+	}
+}
+
+func TestSMTPNotifierRejectsInvalidRecipients(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	notifier := server.notifier(t)
+
+	for _, to := range []string{"", "cook@example.com\r\nBcc: victim@example.com"} {
+		if err := notifier.Send(models.Notification{To: to, Subject: "Digest", Body: "Hello"}); err == nil {
+			t.Errorf("Send(%q) succeeded, want an error", to)
+		}
+	}
+	if messages := server.received(); len(messages) != 0 {
+		t.Errorf("got %d messages, want none", len(messages))
+	}
+}
+
+func TestSMTPNotifierTimesOutOnStalledServer(t *testing.T) {
+	// Accepts connections but never sends the greeting
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	var conns []net.Conn
+	var mu sync.Mutex
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	notifier := NewSMTPNotifier(host, port, "", "", "chefly@example.com")
+	notifier.timeout = 200 * time.Millisecond
+
+	started := time.Now()
+	err = notifier.Send(models.Notification{To: "cook@example.com", Subject: "Digest", Body: "Hello"})
+	if err == nil {
+		t.Fatal("Send succeeded against a stalled server, want a timeout error")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Send returned after %s, want it bounded by the timeout", elapsed)
+	}
+}
@@ -0,0 +1,414 @@
+package services
+
+import (
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"chefly/models"
+)
+
+//go:embed data/food_composition.csv
+var foodCompositionCSV string
+
+// FoodComposition holds nutritional values per 100 g of a food
+type FoodComposition struct {
+	Name          string  `json:"name"`
+	Calories      float64 `json:"calories"`
+	Protein       float64 `json:"protein_g"`
+	Fat           float64 `json:"fat_g"`
+	Carbohydrates float64 `json:"carbohydrates_g"`
+	Fiber         float64 `json:"fiber_g"`
+	Sodium        float64 `json:"sodium_mg"`
+	GramsPerPiece float64 `json:"grams_per_piece"`  // Weight of one piece/clove/slice (0 = unknown)
+	Density       float64 `json:"density_g_per_ml"` // Used to convert ml to g (0 = assume water)
+}
+
+// NutritionEstimator estimates food composition for ingredients missing from the bundled table
+type NutritionEstimator interface {
+	EstimateFoodComposition(ctx context.Context, ingredientNames []string) (map[string]FoodComposition, error)
+}
+
+// NutritionService computes recipe nutrition from structured ingredients
+type NutritionService struct {
+	foods     map[string]*FoodComposition // alias → food
+	aliases   []string                    // sorted longest first for greedy matching
+	estimator NutritionEstimator
+
+	mu            sync.RWMutex
+	estimateCache map[string]FoodComposition // normalized ingredient name → AI estimate
+}
+
+// NewNutritionService creates a nutrition service backed by the bundled food table
+// estimator may be nil to disable the AI fallback
+func NewNutritionService(estimator NutritionEstimator) *NutritionService {
+	s := &NutritionService{
+		foods:         map[string]*FoodComposition{},
+		estimator:     estimator,
+		estimateCache: map[string]FoodComposition{},
+	}
+	s.loadFoodTable(foodCompositionCSV)
+	return s
+}
+
+// loadFoodTable parses the embedded CSV food composition table
+// Columns: name,aliases,kcal,protein_g,fat_g,carbohydrates_g,fiber_g,sodium_mg,grams_per_piece,density_g_per_ml
+// Aliases are separated by semicolons, lines starting with # are comments and malformed rows are skipped
+func (s *NutritionService) loadFoodTable(data string) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 10
+	reader.TrimLeadingSpace = true
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil || fields[0] == "name" {
+			// Malformed row or the header
+			continue
+		}
+
+		food := &FoodComposition{
+			Name:          fields[0],
+			Calories:      parseFloatOrZero(fields[2]),
+			Protein:       parseFloatOrZero(fields[3]),
+			Fat:           parseFloatOrZero(fields[4]),
+			Carbohydrates: parseFloatOrZero(fields[5]),
+			Fiber:         parseFloatOrZero(fields[6]),
+			Sodium:        parseFloatOrZero(fields[7]),
+			GramsPerPiece: parseFloatOrZero(fields[8]),
+			Density:       parseFloatOrZero(fields[9]),
+		}
+
+		names := []string{fields[0]}
+		if fields[1] != "" {
+			names = append(names, strings.Split(fields[1], ";")...)
+		}
+		for _, name := range names {
+			key := normalizeIngredientName(name)
+			if key == "" {
+				continue
+			}
+			if _, exists := s.foods[key]; !exists {
+				s.foods[key] = food
+				s.aliases = append(s.aliases, key)
+			}
+		}
+	}
+
+	// Longest alias first so "sweet potato" wins over "potato"
+	sort.Slice(s.aliases, func(i, j int) bool {
+		return len(s.aliases[i]) > len(s.aliases[j])
+	})
+}
+
+// CalculateRecipeNutrition computes total and per-serving nutrition for a recipe
+// Unknown ingredients are estimated by AI, ctx cancels the estimation
+func (s *NutritionService) CalculateRecipeNutrition(ctx context.Context, ingredients []models.Ingredient, servings int) *models.RecipeNutrition {
+	result := &models.RecipeNutrition{
+		Servings:             servings,
+		EstimatedIngredients: []string{},
+		UnmatchedIngredients: []string{},
+	}
+
+	// Resolve ingredients against the bundled table first
+	resolved := make([]*FoodComposition, len(ingredients))
+	var unknown []string
+	for i, ing := range ingredients {
+		if food := s.lookupFood(ing.Name); food != nil {
+			resolved[i] = food
+			continue
+		}
+		unknown = append(unknown, ing.Name)
+	}
+
+	// Fall back to AI estimates for anything the table does not know
+	estimates := s.estimateUnknown(ctx, unknown)
+
+	for i, ing := range ingredients {
+		food := resolved[i]
+		estimated := false
+		if food == nil {
+			if estimate, ok := estimates[normalizeIngredientName(ing.Name)]; ok {
+				food = &estimate
+				estimated = true
+			}
+		}
+		if food == nil {
+			result.UnmatchedIngredients = append(result.UnmatchedIngredients, ing.Name)
+			continue
+		}
+
+		grams, ok := ingredientGrams(ing.Quantity, ing.Unit, food)
+		if !ok {
+			result.UnmatchedIngredients = append(result.UnmatchedIngredients, ing.Name)
+			continue
+		}
+		if estimated {
+			result.EstimatedIngredients = append(result.EstimatedIngredients, ing.Name)
+		}
+
+		factor := grams / 100
+		result.PerRecipe.Calories += food.Calories * factor
+		result.PerRecipe.Protein += food.Protein * factor
+		result.PerRecipe.Fat += food.Fat * factor
+		result.PerRecipe.Carbohydrates += food.Carbohydrates * factor
+		result.PerRecipe.Fiber += food.Fiber * factor
+		result.PerRecipe.Sodium += food.Sodium * factor
+	}
+
+	divisor := float64(servings)
+	if servings <= 0 {
+		divisor = 1
+	}
+
+	result.PerServing = models.NutritionFacts{
+		Calories:      roundNutrient(result.PerRecipe.Calories / divisor),
+		Protein:       roundNutrient(result.PerRecipe.Protein / divisor),
+		Fat:           roundNutrient(result.PerRecipe.Fat / divisor),
+		Carbohydrates: roundNutrient(result.PerRecipe.Carbohydrates / divisor),
+		Fiber:         roundNutrient(result.PerRecipe.Fiber / divisor),
+		Sodium:        roundNutrient(result.PerRecipe.Sodium / divisor),
+	}
+	result.PerRecipe = models.NutritionFacts{
+		Calories:      roundNutrient(result.PerRecipe.Calories),
+		Protein:       roundNutrient(result.PerRecipe.Protein),
+		Fat:           roundNutrient(result.PerRecipe.Fat),
+		Carbohydrates: roundNutrient(result.PerRecipe.Carbohydrates),
+		Fiber:         roundNutrient(result.PerRecipe.Fiber),
+		Sodium:        roundNutrient(result.PerRecipe.Sodium),
+	}
+
+	return result
+}
+
+// lookupFood finds the best matching table entry for an ingredient name
+func (s *NutritionService) lookupFood(name string) *FoodComposition {
+	normalized := normalizeIngredientName(name)
+	if normalized == "" {
+		return nil
+	}
+
+	// Exact match
+	if food, ok := s.foods[normalized]; ok {
+		return food
+	}
+
+	// Longest alias contained as whole words (e.g. "fresh chicken breast, diced")
+	padded := " " + normalized + " "
+	for _, alias := range s.aliases {
+		if strings.Contains(padded, " "+alias+" ") {
+			return s.foods[alias]
+		}
+	}
+
+	return nil
+}
+
+// estimateUnknown asks the estimator for ingredients not in the table, using a cache
+func (s *NutritionService) estimateUnknown(ctx context.Context, names []string) map[string]FoodComposition {
+	estimates := map[string]FoodComposition{}
+	if len(names) == 0 {
+		return estimates
+	}
+
+	var missing []string
+	s.mu.RLock()
+	for _, name := range names {
+		key := normalizeIngredientName(name)
+		if cached, ok := s.estimateCache[key]; ok {
+			estimates[key] = cached
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(missing) == 0 || s.estimator == nil {
+		return estimates
+	}
+
+	fetched, err := s.estimator.EstimateFoodComposition(ctx, missing)
+	if err != nil {
+		// Estimation is best effort, ingredients stay unmatched
+		return estimates
+	}
+
+	s.mu.Lock()
+	for name, food := range fetched {
+		key := normalizeIngredientName(name)
+		s.estimateCache[key] = food
+		estimates[key] = food
+	}
+	s.mu.Unlock()
+
+	return estimates
+}
+
+// ingredientGrams converts a quantity and unit into grams
+// Returns false when the amount cannot be determined
+func ingredientGrams(quantity, unit string, food *FoodComposition) (float64, bool) {
+	unit = strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(unit), ".")))
+	amount, hasAmount := parseQuantity(quantity)
+
+	// "to taste" style amounts contribute nothing but are not errors
+	if unit == "to taste" || strings.Contains(strings.ToLower(quantity), "taste") {
+		return 0, true
+	}
+	if !hasAmount {
+		// Unit-only amounts like "pinch" imply one
+		if unit == "" {
+			return 0, false
+		}
+		amount = 1
+	}
+
+	density := food.Density
+	if density <= 0 {
+		density = 1
+	}
+
+	switch unit {
+	case "g", "gram", "grams", "gr":
+		return amount, true
+	case "kg", "kilogram", "kilograms":
+		return amount * 1000, true
+	case "mg":
+		return amount / 1000, true
+	case "ml", "milliliter", "milliliters", "millilitre", "millilitres":
+		return amount * density, true
+	case "cl":
+		return amount * 10 * density, true
+	case "dl":
+		return amount * 100 * density, true
+	case "l", "liter", "liters", "litre", "litres":
+		return amount * 1000 * density, true
+	case "tsp", "teaspoon", "teaspoons":
+		return amount * 5 * density, true
+	case "tbsp", "tablespoon", "tablespoons":
+		return amount * 15 * density, true
+	case "cup", "cups":
+		return amount * 240 * density, true
+	case "oz", "ounce", "ounces":
+		return amount * 28.35, true
+	case "lb", "lbs", "pound", "pounds":
+		return amount * 453.6, true
+	case "pinch", "pinches":
+		return amount * 0.4, true
+	case "handful", "handfuls":
+		return amount * 30, true
+	case "bunch", "bunches":
+		return amount * 50, true
+	case "sprig", "sprigs":
+		return amount * 1, true
+	case "can", "cans", "tin", "tins":
+		return amount * 400, true
+	case "", "piece", "pieces", "pc", "pcs", "ks", "kus", "kusy", "whole", "clove", "cloves", "slice", "slices", "fillet", "fillets", "stalk", "stalks", "leaf", "leaves":
+		if food.GramsPerPiece > 0 {
+			return amount * food.GramsPerPiece, true
+		}
+		return 0, false
+	}
+
+	return 0, false
+}
+
+// maxQuantity bounds a parsed quantity, larger numbers in model output are mistakes
+// and could overflow the nutrition totals to +Inf, which cannot be encoded as JSON
+const maxQuantity = 100000
+
+// parseQuantity parses quantities like "500", "1.5", "0,5", "1/2", "1 1/2", "½" and "2-3"
+// Negative, non-finite ("inf", "nan") and implausibly large quantities are rejected
+func parseQuantity(quantity string) (float64, bool) {
+	q := strings.TrimSpace(strings.ToLower(quantity))
+	if q == "" {
+		return 0, false
+	}
+
+	replacer := strings.NewReplacer("½", " 1/2", "¼", " 1/4", "¾", " 3/4", "⅓", " 1/3", "⅔", " 2/3", ",", ".")
+	q = strings.TrimSpace(replacer.Replace(q))
+
+	// Ranges use the midpoint
+	for _, sep := range []string{"-", "–", " to "} {
+		if parts := strings.SplitN(q, sep, 2); len(parts) == 2 {
+			low, okLow := parseQuantity(parts[0])
+			high, okHigh := parseQuantity(parts[1])
+			if okLow && okHigh {
+				return (low + high) / 2, true
+			}
+		}
+	}
+
+	total := 0.0
+	parsed := false
+	for _, part := range strings.Fields(q) {
+		if num, den, found := strings.Cut(part, "/"); found {
+			n, errN := strconv.ParseFloat(num, 64)
+			d, errD := strconv.ParseFloat(den, 64)
+			if errN != nil || errD != nil || !validQuantity(n) || !validQuantity(d) || d == 0 {
+				return 0, false
+			}
+			total += n / d
+			parsed = true
+			continue
+		}
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			// Stop at the first word (e.g. "2 large")
+			break
+		}
+		if !validQuantity(value) {
+			return 0, false
+		}
+		total += value
+		parsed = true
+	}
+
+	if total > maxQuantity {
+		return 0, false
+	}
+	return total, parsed
+}
+
+// validQuantity reports whether a number of a quantity is finite and between 0 and maxQuantity
+func validQuantity(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0) && value >= 0 && value <= maxQuantity
+}
+
+// normalizeIngredientName lowercases and strips punctuation for table lookups
+func normalizeIngredientName(name string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			builder.WriteRune(r)
+		case r == '-':
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+// parseFloatOrZero parses a float, returning 0 for empty or invalid values
+func parseFloatOrZero(value string) float64 {
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0
+	}
+	return parsed
+}
+
+// roundNutrient rounds a nutrient value to one decimal place
+func roundNutrient(value float64) float64 {
+	return math.Round(value*10) / 10
+}
@@ -0,0 +1,44 @@
+package services
+
+import "testing"
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		quantity string
+		want     float64
+		ok       bool
+	}{
+		{"500", 500, true},
+		{"1.5", 1.5, true},
+		{"0,5", 0.5, true},
+		{"1/2", 0.5, true},
+		{"1 1/2", 1.5, true},
+		{"½", 0.5, true},
+		{"1½", 1.5, true},
+		{"2-3", 2.5, true},
+		{"2 to 4", 3, true},
+		{"2 large", 2, true},
+		{"100000", 100000, true},
+		{"", 0, false},
+		{"a pinch", 0, false},
+		{"1/0", 0, false},
+		{"inf", 0, false},
+		{"+Inf", 0, false},
+		{"nan", 0, false},
+		{"1e308", 0, false},
+		{"1e308 1e308", 0, false},
+		{"100001", 0, false},
+		{"60000 60000", 0, false},
+		{"-5", 0, false},
+		{"-1/2", 0, false},
+		{"1/-2", 0, false},
+		{"1/inf", 0, false},
+	}
+
+	for _, test := range tests {
+		got, ok := parseQuantity(test.quantity)
+		if got != test.want || ok != test.ok {
+			t.Errorf("parseQuantity(%q) = %v, %v, want %v, %v", test.quantity, got, ok, test.want, test.ok)
+		}
+	}
+}
@@ -4,45 +4,89 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
-	"io"
-	"net/http"
+	"time"
+
+	"chefly/models"
 
 	openai "github.com/sashabaranov/go-openai"
 )
 
-// OpenAIService handles OpenAI API interactions for image generation
+// OpenAIService handles OpenAI API interactions for image generation (DALL-E)
 type OpenAIService struct {
-	client *openai.Client
-	model  string
+	client     *openai.Client
+	model      string
+	timeout    time.Duration
+	downloader *ImageDownloader
+	resilience *AIResilience
+	usage      *AIUsageRecorder
 }
 
 // NewOpenAIService creates a new OpenAI service
-func NewOpenAIService(apiKey, model string) *OpenAIService {
+// baseURL overrides the API endpoint (empty = api.openai.com), e.g. for proxies
+// timeout bounds generating (including retries) and downloading one image (0 = no limit besides the request context)
+func NewOpenAIService(apiKey, model, baseURL string, timeout time.Duration, downloader *ImageDownloader, resilience *AIResilience, usage *AIUsageRecorder) *OpenAIService {
+	config := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		config.BaseURL = baseURL
+	}
+	config.HTTPClient = NewAIHTTPClient()
+
 	return &OpenAIService{
-		client: openai.NewClient(apiKey),
-		model:  model,
+		client:     openai.NewClientWithConfig(config),
+		model:      model,
+		timeout:    timeout,
+		downloader: downloader,
+		resilience: resilience,
+		usage:      usage,
 	}
 }
 
-// GenerateFoodImage generates a realistic food image using DALL-E 3
-func (s *OpenAIService) GenerateFoodImage(recipeTitle, cuisineType, description string) (string, error) {
+// GenerateFoodImage generates a realistic food image using DALL-E
+// The size must be supported by the model (DALL-E 3: 1024x1024, 1792x1024 or 1024x1792)
+func (s *OpenAIService) GenerateFoodImage(ctx context.Context, recipe FoodImageRequest, spec ImageSpec) (string, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
 	// Build a detailed prompt for realistic food photography
-	prompt := s.buildImagePrompt(recipeTitle, cuisineType, description)
+	prompt := buildImagePrompt(recipe)
+
+	quality := openai.CreateImageQualityStandard
+	if spec.Quality == ImageQualityHD {
+		quality = openai.CreateImageQualityHD
+	}
 
 	// Call OpenAI API using configured model
 	req := openai.ImageRequest{
 		Prompt:         prompt,
 		Model:          s.model,
 		N:              1,
-		Size:           openai.CreateImageSize1024x1024,
-		Quality:        openai.CreateImageQualityStandard,
+		Size:           fmt.Sprintf("%dx%d", spec.Width, spec.Height),
+		Quality:        quality,
 		Style:          openai.CreateImageStyleNatural,
 		ResponseFormat: openai.CreateImageResponseFormatURL,
 	}
 
-	ctx := context.Background()
-	resp, err := s.client.CreateImage(ctx, req)
+	var resp openai.ImageResponse
+	err := s.resilience.Do(ctx, "image", func(ctx context.Context) error {
+		started := time.Now()
+		var err error
+		resp, err = s.client.CreateImage(ctx, req)
+		if err == nil {
+			s.usage.Record(ctx, models.AIUsage{
+				Provider:     "openai",
+				Model:        s.model,
+				Operation:    "image",
+				ImageCount:   len(resp.Data),
+				ImageSize:    req.Size,
+				ImageQuality: spec.Quality,
+			}, started)
+		}
+		return err
+	})
 	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return "", fmt.Errorf("%w: %v", ctxErr, err)
+		}
 		return "", fmt.Errorf("failed to generate image: %w", err)
 	}
 
@@ -54,7 +98,7 @@ func (s *OpenAIService) GenerateFoodImage(recipeTitle, cuisineType, description
 	imageURL := resp.Data[0].URL
 
 	// Download and convert to base64 data URL for storage
-	dataURL, err := s.downloadAndConvertToDataURL(imageURL)
+	dataURL, err := s.downloadAndConvertToDataURL(ctx, imageURL)
 	if err != nil {
 		// If download fails, return the URL as fallback
 		return imageURL, nil
@@ -63,48 +107,18 @@ func (s *OpenAIService) GenerateFoodImage(recipeTitle, cuisineType, description
 	return dataURL, nil
 }
 
-// buildImagePrompt creates a detailed prompt for food photography
-func (s *OpenAIService) buildImagePrompt(recipeTitle, cuisineType, description string) string {
-	prompt := fmt.Sprintf(`Professional food photography of %s.
-%s cuisine style dish.
-High-quality, realistic, appetizing presentation on a clean white plate.
-Natural lighting, restaurant quality, top-down view.
-Sharp focus on the food with shallow depth of field.
-Garnished beautifully with fresh ingredients.
-Professional chef presentation, magazine quality photo.
-%s
-No text, no watermarks, no people, just the delicious food.`,
-		recipeTitle,
-		cuisineType,
-		description)
-
-	return prompt
-}
-
 // downloadAndConvertToDataURL downloads an image and converts it to a base64 data URL
-func (s *OpenAIService) downloadAndConvertToDataURL(imageURL string) (string, error) {
+func (s *OpenAIService) downloadAndConvertToDataURL(ctx context.Context, imageURL string) (string, error) {
 	// Download the image
-	resp, err := http.Get(imageURL)
+	imageData, contentType, err := s.downloader.Download(ctx, imageURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to download image: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("bad status: %s", resp.Status)
-	}
-
-	// Read image data
-	imageData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read image: %w", err)
-	}
 
 	// Convert to base64 data URL
 	base64Data := base64.StdEncoding.EncodeToString(imageData)
 
 	// Determine content type from response
-	contentType := resp.Header.Get("Content-Type")
 	if contentType == "" {
 		contentType = "image/png"
 	}
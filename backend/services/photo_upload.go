@@ -0,0 +1,65 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+
+	"github.com/disintegration/imaging"
+)
+
+var (
+	// ErrUnsupportedImageType is returned for uploads that are not JPEG, PNG or WebP images
+	ErrUnsupportedImageType = errors.New("unsupported image type")
+	// ErrInvalidImageDimensions is returned for uploads that are too small or too large
+	ErrInvalidImageDimensions = errors.New("invalid image dimensions")
+)
+
+// allowedPhotoTypes are the content types accepted for photo uploads, sniffed from the uploaded data
+var allowedPhotoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// PhotoLimits restricts the dimensions of uploaded photos
+type PhotoLimits struct {
+	MinDimension int // Minimum length of the shorter side in pixels
+	MaxDimension int // Maximum length of the longer side in pixels, checked before decoding (decompression bombs)
+}
+
+// OptimizeUploadedPhoto validates an uploaded photo and stores it as square variants like generated images
+// The type is sniffed from the data, the declared content type and file name are not trusted
+// The EXIF orientation is applied and only pixels are re-encoded, so EXIF (including GPS), XMP and other
+// metadata of the upload never reach the image store
+func (opt *ImageOptimizer) OptimizeUploadedPhoto(data []byte, limits PhotoLimits) (*OptimizedImages, error) {
+	contentType := http.DetectContentType(data)
+	if !allowedPhotoTypes[contentType] {
+		return nil, fmt.Errorf("%w: %s (supported: JPEG, PNG, WebP)", ErrUnsupportedImageType, contentType)
+	}
+
+	// Check dimensions from the header before allocating the decoded image
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImageType, err)
+	}
+	shorter, longer := config.Width, config.Height
+	if shorter > longer {
+		shorter, longer = longer, shorter
+	}
+	if shorter < limits.MinDimension {
+		return nil, fmt.Errorf("%w: %dx%d is smaller than %dpx", ErrInvalidImageDimensions, config.Width, config.Height, limits.MinDimension)
+	}
+	if limits.MaxDimension > 0 && longer > limits.MaxDimension {
+		return nil, fmt.Errorf("%w: %dx%d is larger than %dpx", ErrInvalidImageDimensions, config.Width, config.Height, limits.MaxDimension)
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImageType, err)
+	}
+
+	return opt.storeVariants(img)
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"chefly/models"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// PlaceholderURLPrefix is the route serving placeholder images of recipes without an image:
+// /placeholders/<recipe id>/<width>.<extension> for renditions and /placeholders/<recipe id>/placeholder.svg
+const PlaceholderURLPrefix = "/placeholders/"
+
+// PlaceholderSVGFile is the file name of the vector placeholder
+const PlaceholderSVGFile = "placeholder.svg"
+
+// placeholderSize is the size of the placeholder design, renditions are scaled from it
+const placeholderSize = 800
+
+// placeholderTitleLength is the number of characters of the title shown on placeholders
+const placeholderTitleLength = 30
+
+// placeholderCacheBytes bounds the memory of rendered placeholders kept for reuse
+const placeholderCacheBytes = 32 << 20
+
+// ErrInvalidPlaceholder is returned for placeholder files that are not a configured rendition
+var ErrInvalidPlaceholder = errors.New("invalid placeholder image")
+
+// PlaceholderImages returns the placeholder image path, thumbnail path and renditions of a recipe without an image
+// Placeholders are rendered on request and never stored, so the paths must not be saved with the recipe
+func (opt *ImageOptimizer) PlaceholderImages(recipeID string) (string, string, []models.ImageVariant) {
+	variants := []models.ImageVariant{}
+	for _, width := range opt.variants.Widths {
+		for _, format := range opt.variants.Formats {
+			imageFormat := imageFormats[format]
+			variants = append(variants, models.ImageVariant{
+				URL:         fmt.Sprintf("%s%s/%d%s", PlaceholderURLPrefix, recipeID, width, imageFormat.extension),
+				Width:       width,
+				Height:      width,
+				Format:      format,
+				ContentType: imageFormat.contentType,
+			})
+		}
+	}
+
+	jpegExtension := imageFormats[ImageFormatJPEG].extension
+	widths := opt.variants.Widths
+	imagePath := fmt.Sprintf("%s%s/%d%s", PlaceholderURLPrefix, recipeID, widths[len(widths)-1], jpegExtension)
+	thumbnailPath := fmt.Sprintf("%s%s/%d%s", PlaceholderURLPrefix, recipeID, widths[0], jpegExtension)
+	return imagePath, thumbnailPath, variants
+}
+
+// PlaceholderETag returns the ETag of a placeholder file, the output only depends on the recipe's title, cuisine and meat type
+func PlaceholderETag(title, cuisine, meatType, file string) string {
+	return fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(title+"\x00"+cuisine+"\x00"+meatType+"\x00"+file)))
+}
+
+// RenderPlaceholder renders a placeholder file (e.g. "400.jpg", "placeholder.svg") and returns it with its content type
+// Only configured widths and formats are rendered, so requests cannot pick arbitrary sizes
+// The placeholder route is public and rendering a large rendition is slow, recently rendered files are served from memory
+func (opt *ImageOptimizer) RenderPlaceholder(title, cuisine, meatType, file string) ([]byte, string, error) {
+	key := PlaceholderETag(title, cuisine, meatType, file)
+	if data, contentType, ok := opt.rendered.get(key); ok {
+		return data, contentType, nil
+	}
+
+	data, contentType, err := opt.renderPlaceholderFile(title, cuisine, meatType, file)
+	if err != nil {
+		return nil, "", err
+	}
+	opt.rendered.add(key, data, contentType)
+	return data, contentType, nil
+}
+
+// renderPlaceholderFile renders a placeholder file without the cache
+func (opt *ImageOptimizer) renderPlaceholderFile(title, cuisine, meatType, file string) ([]byte, string, error) {
+	if file == PlaceholderSVGFile {
+		return []byte(PlaceholderSVG(title, cuisine, meatType)), "image/svg+xml", nil
+	}
+
+	name, extension, found := strings.Cut(file, ".")
+	if !found {
+		return nil, "", ErrInvalidPlaceholder
+	}
+	width, err := strconv.Atoi(name)
+	if err != nil || !slices.Contains(opt.variants.Widths, width) {
+		return nil, "", ErrInvalidPlaceholder
+	}
+	for _, format := range opt.variants.Formats {
+		imageFormat := imageFormats[format]
+		if imageFormat.extension != "."+extension {
+			continue
+		}
+		data, err := opt.variants.encodeImage(renderPlaceholder(title, cuisine, meatType, width), format)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode placeholder: %w", err)
+		}
+		return data, imageFormat.contentType, nil
+	}
+	return nil, "", ErrInvalidPlaceholder
+}
+
+// placeholderCache keeps rendered placeholder files up to a total size, evicting the least recently used
+type placeholderCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	entries  map[string]*list.Element
+	order    *list.List // Most recently used first
+}
+
+// placeholderCacheEntry is a rendered placeholder file
+type placeholderCacheEntry struct {
+	key         string
+	data        []byte
+	contentType string
+}
+
+func newPlaceholderCache(maxBytes int) *placeholderCache {
+	return &placeholderCache{
+		maxBytes: maxBytes,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// get returns a cached file and marks it as recently used
+func (c *placeholderCache) get(key string) ([]byte, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, "", false
+	}
+	c.order.MoveToFront(element)
+	entry := element.Value.(*placeholderCacheEntry)
+	return entry.data, entry.contentType, true
+}
+
+// add caches a file, evicting the least recently used files until it fits
+func (c *placeholderCache) add(key string, data []byte, contentType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok || len(data) > c.maxBytes {
+		return
+	}
+	for c.size+len(data) > c.maxBytes {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*placeholderCacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.size -= len(entry.data)
+	}
+	c.entries[key] = c.order.PushFront(&placeholderCacheEntry{key: key, data: data, contentType: contentType})
+	c.size += len(data)
+}
+
+// PlaceholderSVG returns a square cuisine colored SVG showing the recipe title and cuisine
+func PlaceholderSVG(title, cuisine, meatType string) string {
+	colors := placeholderColors(cuisine, meatType)
+
+	cuisineLine := ""
+	if cuisine != "" {
+		cuisineLine = fmt.Sprintf(`
+	<text x="400" y="610" font-family="Arial, sans-serif" font-size="24" fill="rgba(255,255,255,0.9)" text-anchor="middle">%s Cuisine</text>`,
+			xmlEscape(cuisine))
+	}
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="800" height="800" viewBox="0 0 800 800">
+	<defs>
+		<linearGradient id="grad1" x1="0%%" y1="0%%" x2="100%%" y2="100%%">
+			<stop offset="0%%" style="stop-color:%s;stop-opacity:1" />
+			<stop offset="100%%" style="stop-color:%s;stop-opacity:1" />
+		</linearGradient>
+	</defs>
+	<rect width="800" height="800" fill="url(#grad1)"/>
+	<circle cx="400" cy="330" r="120" fill="rgba(255,255,255,0.1)" />
+	<circle cx="400" cy="330" r="100" fill="rgba(255,255,255,0.15)" />
+	<circle cx="400" cy="330" r="80" fill="rgba(255,255,255,0.2)" />
+	<text x="400" y="560" font-family="Arial, sans-serif" font-size="36" font-weight="bold" fill="white" text-anchor="middle">%s</text>%s
+	<text x="400" y="660" font-family="Arial, sans-serif" font-size="20" fill="rgba(255,255,255,0.8)" text-anchor="middle">Professional Recipe</text>
+</svg>`, colors[0], colors[1], xmlEscape(placeholderTitle(title)), cuisineLine)
+}
+
+// placeholderColors returns the gradient colors of a placeholder based on cuisine and meat type
+func placeholderColors(cuisine, meatType string) [2]string {
+	cuisineColors := map[string][2]string{
+		"Italian":        {"#E74C3C", "#C0392B"}, // Red tones
+		"Mexican":        {"#E67E22", "#D35400"}, // Orange tones
+		"Chinese":        {"#E74C3C", "#922B21"}, // Deep red
+		"Indian":         {"#F39C12", "#D68910"}, // Golden
+		"Japanese":       {"#EC7063", "#CB4335"}, // Salmon red
+		"Thai":           {"#28B463", "#1E8449"}, // Green
+		"Mediterranean":  {"#3498DB", "#2874A6"}, // Blue
+		"American":       {"#E67E22", "#CA6F1E"}, // Orange
+		"French":         {"#8E44AD", "#6C3483"}, // Purple
+		"Greek":          {"#3498DB", "#21618C"}, // Blue
+		"Korean":         {"#E74C3C", "#A93226"}, // Red
+		"Vietnamese":     {"#2ECC71", "#27AE60"}, // Green
+		"Spanish":        {"#E67E22", "#BA4A00"}, // Orange-red
+		"Middle Eastern": {"#F4D03F", "#D4AC0D"}, // Gold
+	}
+
+	// Return cuisine-specific colors or default
+	if colors, ok := cuisineColors[cuisine]; ok {
+		return colors
+	}
+
+	// Default professional food colors
+	return [2]string{"#FF6B6B", "#C44569"}
+}
+
+// placeholderTitle shortens a title to placeholderTitleLength characters (not bytes, titles may be Slovak)
+func placeholderTitle(title string) string {
+	runes := []rune(strings.TrimSpace(title))
+	if len(runes) <= placeholderTitleLength {
+		return string(runes)
+	}
+	return strings.TrimSpace(string(runes[:placeholderTitleLength-3])) + "..."
+}
+
+// xmlEscape escapes text for use in XML content and attribute values
+func xmlEscape(text string) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
+
+// placeholderFonts holds the parsed fonts of raster placeholders
+var placeholderFonts struct {
+	once    sync.Once
+	bold    *opentype.Font
+	regular *opentype.Font
+	err     error
+}
+
+// renderPlaceholder draws the placeholder design (see PlaceholderSVG) as a square image of the given size
+func renderPlaceholder(title, cuisine, meatType string, size int) image.Image {
+	colors := placeholderColors(cuisine, meatType)
+	from, to := parseHexColor(colors[0]), parseHexColor(colors[1])
+	scale := float64(size) / placeholderSize
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	// Diagonal gradient with translucent white circles
+	centerX, centerY := 400*scale, 330*scale
+	circles := []struct{ radius, alpha float64 }{{120, 0.1}, {100, 0.15}, {80, 0.2}}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			t := float64(x+y) / float64(2*size-2)
+			r := float64(from.R) + (float64(to.R)-float64(from.R))*t
+			g := float64(from.G) + (float64(to.G)-float64(from.G))*t
+			b := float64(from.B) + (float64(to.B)-float64(from.B))*t
+
+			distance := math.Hypot(float64(x)+0.5-centerX, float64(y)+0.5-centerY)
+			for _, circle := range circles {
+				// Coverage of the circle edge gives antialiasing
+				coverage := math.Max(0, math.Min(1, circle.radius*scale-distance+0.5))
+				alpha := circle.alpha * coverage
+				r += (255 - r) * alpha
+				g += (255 - g) * alpha
+				b += (255 - b) * alpha
+			}
+
+			offset := img.PixOffset(x, y)
+			img.Pix[offset] = uint8(r + 0.5)
+			img.Pix[offset+1] = uint8(g + 0.5)
+			img.Pix[offset+2] = uint8(b + 0.5)
+			img.Pix[offset+3] = 255
+		}
+	}
+
+	placeholderFonts.once.Do(func() {
+		placeholderFonts.bold, placeholderFonts.err = opentype.Parse(gobold.TTF)
+		if placeholderFonts.err == nil {
+			placeholderFonts.regular, placeholderFonts.err = opentype.Parse(goregular.TTF)
+		}
+	})
+	if placeholderFonts.err != nil {
+		return img // Embedded fonts always parse, a plain placeholder is still better than none
+	}
+
+	drawCenteredText(img, placeholderFonts.bold, placeholderTitle(title), 36*scale, 560*scale, 255)
+	if cuisine != "" {
+		drawCenteredText(img, placeholderFonts.regular, cuisine+" Cuisine", 24*scale, 610*scale, 230)
+	}
+	drawCenteredText(img, placeholderFonts.regular, "Professional Recipe", 20*scale, 660*scale, 204)
+
+	return img
+}
+
+// drawCenteredText draws white text horizontally centered on the given baseline
+func drawCenteredText(img *image.RGBA, typeface *opentype.Font, text string, size, baseline float64, alpha uint8) {
+	face, err := opentype.NewFace(typeface, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return
+	}
+	defer face.Close()
+
+	drawer := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(color.NRGBA{R: 255, G: 255, B: 255, A: alpha}),
+		Face: face,
+	}
+	width := drawer.MeasureString(text)
+	drawer.Dot = fixed.Point26_6{
+		X: (fixed.I(img.Bounds().Dx()) - width) / 2,
+		Y: fixed.Int26_6(baseline * 64),
+	}
+	drawer.DrawString(text)
+}
+
+// parseHexColor parses a "#RRGGBB" color
+func parseHexColor(hex string) color.RGBA {
+	value, _ := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 255}
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderPlaceholderIsCached(t *testing.T) {
+	optimizer := NewImageOptimizer(NewLocalImageStore(filepath.Join(t.TempDir(), "uploads")), DefaultImageVariantConfig(), nil)
+
+	first, contentType, err := optimizer.RenderPlaceholder("Chicken Curry", "indian", "chicken", "800.jpg")
+	if err != nil || contentType != "image/jpeg" {
+		t.Fatalf("RenderPlaceholder = %s, %v", contentType, err)
+	}
+	second, _, err := optimizer.RenderPlaceholder("Chicken Curry", "indian", "chicken", "800.jpg")
+	if err != nil {
+		t.Fatalf("RenderPlaceholder: %v", err)
+	}
+	if &first[0] != &second[0] {
+		t.Error("second request rendered the placeholder again")
+	}
+
+	other, _, err := optimizer.RenderPlaceholder("Beef Stew", "french", "beef", "800.jpg")
+	if err != nil || bytes.Equal(first, other) {
+		t.Errorf("placeholder of another recipe = %d bytes, %v, want a different image", len(other), err)
+	}
+	if _, _, err := optimizer.RenderPlaceholder("Chicken Curry", "indian", "chicken", "799.jpg"); !errors.Is(err, ErrInvalidPlaceholder) {
+		t.Errorf("RenderPlaceholder(799.jpg) error = %v, want ErrInvalidPlaceholder", err)
+	}
+	if size := optimizer.rendered.size; size != len(first)+len(other) {
+		t.Errorf("cache holds %d bytes, want the two rendered placeholders", size)
+	}
+}
+
+func TestPlaceholderCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newPlaceholderCache(10)
+	cache.add("a", []byte("aaaa"), "image/jpeg")
+	cache.add("b", []byte("bbbb"), "image/jpeg")
+	cache.get("a")
+	cache.add("c", []byte("cccc"), "image/jpeg")
+	cache.add("huge", []byte("too large to cache"), "image/jpeg")
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "huge": false} {
+		if _, _, ok := cache.get(key); ok != want {
+			t.Errorf("cached %s = %v, want %v", key, ok, want)
+		}
+	}
+	if cache.size != 8 {
+		t.Errorf("cache size = %d, want 8", cache.size)
+	}
+}
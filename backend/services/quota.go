@@ -0,0 +1,309 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"chefly/models"
+	"chefly/store"
+)
+
+// quotaUnlimited is the policy without limits
+const quotaUnlimited = "unlimited"
+
+// quotaWindows are the named rolling windows of quota rules
+var quotaWindows = map[string]time.Duration{
+	"hour":  time.Hour,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+}
+
+// quotaRule allows limit generations within a rolling window (0 = lifetime)
+type quotaRule struct {
+	limit  int
+	window time.Duration
+}
+
+// QuotaPolicy limits how many generations of one kind a user may make
+// A policy is written as comma separated rules that must all be satisfied, e.g: "10/day,100/month"
+type QuotaPolicy struct {
+	spec  string
+	rules []quotaRule // Empty = unlimited
+}
+
+// String returns the normalized policy
+func (p QuotaPolicy) String() string {
+	return p.spec
+}
+
+// ParseQuotaPolicy parses a quota policy
+// "unlimited" (or empty) has no limit, "0" blocks generation, "N/window" allows N generations per rolling window
+// (hour, day, week, month = 30 days, or a duration such as 6h) and "N/lifetime" N generations in total
+// A plain "N" uses defaultWindow (0 = lifetime)
+func ParseQuotaPolicy(spec string, defaultWindow time.Duration) (QuotaPolicy, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "" || spec == quotaUnlimited {
+		return QuotaPolicy{spec: quotaUnlimited}, nil
+	}
+
+	var rules []quotaRule
+	var normalized []string
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		limitValue, windowValue, hasWindow := strings.Cut(part, "/")
+
+		limit, err := strconv.Atoi(strings.TrimSpace(limitValue))
+		if err != nil || limit < 0 {
+			return QuotaPolicy{}, fmt.Errorf("invalid quota %q: the limit must be 0 or a positive number", part)
+		}
+
+		window := defaultWindow
+		if hasWindow {
+			window, err = parseQuotaWindow(strings.TrimSpace(windowValue))
+			if err != nil {
+				return QuotaPolicy{}, fmt.Errorf("invalid quota %q: %w", part, err)
+			}
+		}
+
+		rules = append(rules, quotaRule{limit: limit, window: window})
+		normalized = append(normalized, fmt.Sprintf("%d/%s", limit, quotaWindowName(window)))
+	}
+
+	return QuotaPolicy{spec: strings.Join(normalized, ","), rules: rules}, nil
+}
+
+// parseQuotaWindow parses a window name or duration
+func parseQuotaWindow(value string) (time.Duration, error) {
+	if value == "lifetime" {
+		return 0, nil
+	}
+	if window, ok := quotaWindows[value]; ok {
+		return window, nil
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window < time.Minute {
+		return 0, fmt.Errorf("the window must be hour, day, week, month, lifetime or a duration of at least 1m")
+	}
+	return window, nil
+}
+
+// quotaWindowName returns the name of a window, or its duration if it has none
+func quotaWindowName(window time.Duration) string {
+	if window == 0 {
+		return "lifetime"
+	}
+	for name, duration := range quotaWindows {
+		if duration == window {
+			return name
+		}
+	}
+	return window.String()
+}
+
+// QuotaService enforces recipe and image generation quotas
+// Generations are counted from the generation event log, personal policies override the global ones
+type QuotaService struct {
+	users       store.UserStore
+	generations store.GenerationStore
+	global      map[string]QuotaPolicy
+	// defaultWindows are the windows of plain "N" policies per kind
+	defaultWindows map[string]time.Duration
+}
+
+// NewQuotaService creates a quota service with the global policies of RECIPE_GENERATION_LIMIT and IMAGE_REGENERATION_LIMIT
+// A plain number limits recipes over the user's lifetime and image regenerations per imageWindow
+func NewQuotaService(users store.UserStore, generations store.GenerationStore, textPolicy, imagePolicy string, imageWindow time.Duration) (*QuotaService, error) {
+	service := &QuotaService{
+		users:       users,
+		generations: generations,
+		global:      map[string]QuotaPolicy{},
+		defaultWindows: map[string]time.Duration{
+			models.QuotaText:  0,
+			models.QuotaImage: imageWindow,
+		},
+	}
+
+	var err error
+	if service.global[models.QuotaText], err = service.ParsePolicy(models.QuotaText, textPolicy); err != nil {
+		return nil, fmt.Errorf("RECIPE_GENERATION_LIMIT: %w", err)
+	}
+	if service.global[models.QuotaImage], err = service.ParsePolicy(models.QuotaImage, imagePolicy); err != nil {
+		return nil, fmt.Errorf("IMAGE_REGENERATION_LIMIT: %w", err)
+	}
+	return service, nil
+}
+
+// ParsePolicy parses a policy of a generation kind, a plain number uses the kind's default window
+func (s *QuotaService) ParsePolicy(kind, spec string) (QuotaPolicy, error) {
+	return ParseQuotaPolicy(spec, s.defaultWindows[kind])
+}
+
+// GlobalPolicy returns the policy of users without a personal policy
+func (s *QuotaService) GlobalPolicy(kind string) QuotaPolicy {
+	return s.global[kind]
+}
+
+// policyFor returns the policy that applies to the user and where it comes from ("user" or "global")
+func (s *QuotaService) policyFor(userID, kind string) (QuotaPolicy, string, error) {
+	user, err := s.users.GetByID(userID)
+	if err != nil {
+		return QuotaPolicy{}, "", err
+	}
+
+	personal := user.TextQuota
+	if kind == models.QuotaImage {
+		personal = user.ImageQuota
+	}
+	if personal != nil {
+		// Personal policies are validated when set, an invalid one falls back to the global policy
+		if parsed, err := s.ParsePolicy(kind, *personal); err == nil {
+			return parsed, "user", nil
+		}
+	}
+	return s.global[kind], "global", nil
+}
+
+// Status returns the user's allowance for a kind of generation
+func (s *QuotaService) Status(userID, kind string) (*models.QuotaStatus, error) {
+	policy, source, err := s.policyFor(userID, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.QuotaStatus{
+		Policy:    policy.String(),
+		Source:    source,
+		Unlimited: len(policy.rules) == 0,
+		Remaining: -1,
+		Rules:     []models.QuotaRule{},
+	}
+	if status.Unlimited {
+		return status, nil
+	}
+
+	now := time.Now()
+	neverResets := false
+	for _, rule := range policy.rules {
+		ruleStatus, err := s.ruleStatus(userID, kind, rule, now)
+		if err != nil {
+			return nil, err
+		}
+		status.Rules = append(status.Rules, ruleStatus)
+
+		if status.Remaining == -1 || ruleStatus.Remaining < status.Remaining {
+			status.Remaining = ruleStatus.Remaining
+		}
+		if ruleStatus.Remaining == 0 && ruleStatus.ResetsAt == nil {
+			neverResets = true
+		}
+	}
+
+	// Exhausted: allowance returns once every exhausted rule has reset
+	// Otherwise: the next time any rule frees up a generation
+	for _, rule := range status.Rules {
+		if rule.ResetsAt == nil || (status.Remaining == 0 && rule.Remaining > 0) {
+			continue
+		}
+		if status.ResetsAt == nil ||
+			(status.Remaining == 0 && rule.ResetsAt.After(*status.ResetsAt)) ||
+			(status.Remaining > 0 && rule.ResetsAt.Before(*status.ResetsAt)) {
+			status.ResetsAt = rule.ResetsAt
+		}
+	}
+	if status.Remaining == 0 && neverResets {
+		status.ResetsAt = nil
+	}
+
+	return status, nil
+}
+
+// ruleStatus counts the generations within a rule's window
+func (s *QuotaService) ruleStatus(userID, kind string, rule quotaRule, now time.Time) (models.QuotaRule, error) {
+	status := models.QuotaRule{
+		Limit:  rule.limit,
+		Window: quotaWindowName(rule.window),
+	}
+
+	var since time.Time
+	if rule.window > 0 {
+		since = now.Add(-rule.window)
+	}
+	used, err := s.generations.CountSince(userID, kind, since)
+	if err != nil {
+		return status, err
+	}
+	status.Used = used
+	if used < rule.limit {
+		status.Remaining = rule.limit - used
+	}
+
+	// Lifetime and blocking rules never reset
+	if rule.window == 0 || rule.limit == 0 || used == 0 {
+		return status, nil
+	}
+
+	// The oldest generation leaves the window first, when exhausted the one that brings usage below the limit
+	n := 0
+	if used >= rule.limit {
+		n = used - rule.limit
+	}
+	oldest, err := s.generations.NthSince(userID, kind, since, n)
+	if errors.Is(err, store.ErrNotFound) {
+		// Left the window since it was counted
+		return status, nil
+	}
+	if err != nil {
+		return status, err
+	}
+	resetsAt := oldest.Add(rule.window).UTC()
+	status.ResetsAt = &resetsAt
+	return status, nil
+}
+
+// UserQuota returns the user's allowance for all kinds of generations
+func (s *QuotaService) UserQuota(userID string) (*models.UserQuota, error) {
+	text, err := s.Status(userID, models.QuotaText)
+	if err != nil {
+		return nil, err
+	}
+	image, err := s.Status(userID, models.QuotaImage)
+	if err != nil {
+		return nil, err
+	}
+	return &models.UserQuota{Text: text, Image: image}, nil
+}
+
+// Record counts a generation against the user's quota
+func (s *QuotaService) Record(userID, kind, recipeID string) error {
+	return s.generations.Record(userID, kind, recipeID)
+}
+
+// Reserve counts a generation against the user's quota before it runs
+// The quota is checked in the same transaction, so concurrent requests cannot exceed it together
+// Returns the reservation to Release if the generation fails, or store.ErrLimitReached
+func (s *QuotaService) Reserve(userID, kind, recipeID string) (string, error) {
+	policy, _, err := s.policyFor(userID, kind)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	limits := make([]store.GenerationLimit, 0, len(policy.rules))
+	for _, rule := range policy.rules {
+		limit := store.GenerationLimit{Limit: rule.limit}
+		if rule.window > 0 {
+			limit.Since = now.Add(-rule.window)
+		}
+		limits = append(limits, limit)
+	}
+	return s.generations.Reserve(userID, kind, recipeID, limits)
+}
+
+// Release gives back a reserved generation that failed
+func (s *QuotaService) Release(reservation string) error {
+	return s.generations.Release(reservation)
+}
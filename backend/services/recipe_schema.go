@@ -0,0 +1,203 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"chefly/models"
+)
+
+// Limits of a valid generated recipe
+const (
+	maxRecipeTitleLength = 200
+	maxRecipeServings    = 100
+	maxRecipeIngredients = 60
+	maxRecipeSteps       = 40
+	maxRecipeMinutes     = 24 * 60 // Cooking plus prep time, longer waits (marinating overnight) belong in the steps
+)
+
+// recipeDifficulties are the accepted difficulty levels
+var recipeDifficulties = []string{"easy", "medium", "hard"}
+
+// RecipeValidationError lists what is wrong with a generated recipe, the problems are sent back to Claude for repair
+// Unwraps to ErrInvalidJSON if the response was not a JSON object, ErrParsingFailed otherwise
+type RecipeValidationError struct {
+	Problems    []string
+	InvalidJSON bool
+}
+
+func (e *RecipeValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+func (e *RecipeValidationError) Unwrap() error {
+	if e.InvalidJSON {
+		return ErrInvalidJSON
+	}
+	return ErrParsingFailed
+}
+
+// recipeResponse is the JSON format Claude is asked to return (see buildRecipePrompt)
+type recipeResponse struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ServingSize int    `json:"serving_size"`
+	CookingTime int    `json:"cooking_time"`
+	PrepTime    int    `json:"prep_time"`
+	Difficulty  string `json:"difficulty"`
+	Ingredients []struct {
+		Name     string          `json:"name"`
+		Quantity json.RawMessage `json:"quantity"` // Can be string or number
+		Unit     string          `json:"unit"`
+	} `json:"ingredients"`
+	Steps []struct {
+		StepNumber  int    `json:"step_number"`
+		Instruction string `json:"instruction"`
+		Timing      string `json:"timing"`
+		Temperature string `json:"temperature"`
+	} `json:"steps"`
+	Tips        []string `json:"tips"`
+	CuisineType string   `json:"cuisine_type"`
+	MeatType    string   `json:"meat_type"`
+}
+
+// extractJSONObject returns the first complete JSON object of a response
+// Claude sometimes wraps it in a ```json fence or adds text before or after it
+func extractJSONObject(response string) (json.RawMessage, error) {
+	start := strings.Index(response, "{")
+	if start == -1 {
+		return nil, fmt.Errorf("no JSON object found")
+	}
+
+	// The decoder stops at the end of the first value, braces inside strings are handled
+	var object json.RawMessage
+	if err := json.NewDecoder(strings.NewReader(response[start:])).Decode(&object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// decodeRecipeResponse extracts, decodes and validates a recipe response
+func decodeRecipeResponse(response string, req models.RecipeGenerationRequest) (*recipeResponse, error) {
+	object, err := extractJSONObject(response)
+	if err != nil {
+		return nil, &RecipeValidationError{
+			Problems:    []string{fmt.Sprintf("The response is not a valid JSON object: %v", err)},
+			InvalidJSON: true,
+		}
+	}
+
+	var recipe recipeResponse
+	if err := json.Unmarshal(object, &recipe); err != nil {
+		// Usually a number given as string or the other way around
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, &RecipeValidationError{Problems: []string{fmt.Sprintf("%s must be %s, not a JSON %s", typeErr.Field, jsonTypeName(typeErr.Type.Kind()), typeErr.Value)}}
+		}
+		return nil, &RecipeValidationError{Problems: []string{fmt.Sprintf("The JSON does not match the required format: %v", err)}}
+	}
+
+	normalizeRecipeResponse(&recipe)
+	if problems := validateRecipeResponse(&recipe, req); len(problems) > 0 {
+		return nil, &RecipeValidationError{Problems: problems}
+	}
+	return &recipe, nil
+}
+
+// jsonTypeName names the JSON type of a Go kind for repair prompts (e.g. "a number")
+func jsonTypeName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	default:
+		return "a number"
+	}
+}
+
+// normalizeRecipeResponse fixes what does not need another round-trip (whitespace, case, step numbering)
+func normalizeRecipeResponse(recipe *recipeResponse) {
+	recipe.Title = strings.TrimSpace(recipe.Title)
+	recipe.Description = strings.TrimSpace(recipe.Description)
+	recipe.Difficulty = strings.ToLower(strings.TrimSpace(recipe.Difficulty))
+	for i := range recipe.Ingredients {
+		recipe.Ingredients[i].Name = strings.TrimSpace(recipe.Ingredients[i].Name)
+	}
+	for i := range recipe.Steps {
+		recipe.Steps[i].StepNumber = i + 1
+		recipe.Steps[i].Instruction = strings.TrimSpace(recipe.Steps[i].Instruction)
+	}
+}
+
+// validateRecipeResponse checks required fields and sane values, returning one problem per violation
+func validateRecipeResponse(recipe *recipeResponse, req models.RecipeGenerationRequest) []string {
+	var problems []string
+
+	if recipe.Title == "" {
+		problems = append(problems, "title is missing")
+	} else if utf8.RuneCountInString(recipe.Title) > maxRecipeTitleLength {
+		problems = append(problems, fmt.Sprintf("title is longer than %d characters", maxRecipeTitleLength))
+	}
+	if recipe.Description == "" {
+		problems = append(problems, "description is missing")
+	}
+
+	if recipe.ServingSize < 1 || recipe.ServingSize > maxRecipeServings {
+		problems = append(problems, fmt.Sprintf("serving_size must be a number between 1 and %d", maxRecipeServings))
+	} else if req.Servings > 0 && recipe.ServingSize != req.Servings {
+		problems = append(problems, fmt.Sprintf("serving_size must be %d as requested (quantities scaled accordingly)", req.Servings))
+	}
+
+	if recipe.CookingTime < 0 || recipe.PrepTime < 0 {
+		problems = append(problems, "cooking_time and prep_time must not be negative")
+	} else if total := recipe.CookingTime + recipe.PrepTime; total < 1 || total > maxRecipeMinutes {
+		problems = append(problems, fmt.Sprintf("cooking_time plus prep_time must be between 1 and %d minutes (got %d)", maxRecipeMinutes, total))
+	}
+
+	if !slices.Contains(recipeDifficulties, recipe.Difficulty) {
+		problems = append(problems, fmt.Sprintf("difficulty must be one of %s", strings.Join(recipeDifficulties, ", ")))
+	}
+
+	if len(recipe.Ingredients) == 0 {
+		problems = append(problems, "ingredients must not be empty")
+	} else if len(recipe.Ingredients) > maxRecipeIngredients {
+		problems = append(problems, fmt.Sprintf("at most %d ingredients are allowed", maxRecipeIngredients))
+	}
+	for i, ingredient := range recipe.Ingredients {
+		if ingredient.Name == "" {
+			problems = append(problems, fmt.Sprintf("ingredient %d has no name", i+1))
+		}
+	}
+
+	if len(recipe.Steps) == 0 {
+		problems = append(problems, "steps must not be empty")
+	} else if len(recipe.Steps) > maxRecipeSteps {
+		problems = append(problems, fmt.Sprintf("at most %d steps are allowed", maxRecipeSteps))
+	}
+	for i, step := range recipe.Steps {
+		if step.Instruction == "" {
+			problems = append(problems, fmt.Sprintf("step %d has no instruction", i+1))
+		}
+	}
+
+	return problems
+}
+
+// buildRepairPrompt asks Claude to correct its previous response
+func buildRepairPrompt(problems []string) string {
+	var builder strings.Builder
+	builder.WriteString("Your response could not be used because of these problems:\n")
+	for _, problem := range problems {
+		builder.WriteString(fmt.Sprintf("- %s\n", problem))
+	}
+	builder.WriteString("\nReturn the corrected recipe as ONLY valid JSON in exactly the format requested before, without any other text.")
+	return builder.String()
+}
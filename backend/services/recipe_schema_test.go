@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chefly/models"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// testRecipe returns a valid recipe response as a JSON object, change applies test specific edits
+func testRecipe(t *testing.T, change func(recipe map[string]interface{})) string {
+	t.Helper()
+	recipe := map[string]interface{}{
+		"title":        "Chicken Paprikash",
+		"description":  "Chicken in a creamy paprika sauce",
+		"serving_size": 4,
+		"cooking_time": 40,
+		"prep_time":    15,
+		"difficulty":   "Medium",
+		"ingredients": []map[string]interface{}{
+			{"name": "chicken thighs", "quantity": 800, "unit": "g"},
+			{"name": "sweet paprika", "quantity": "2", "unit": "tbsp"},
+		},
+		"steps": []map[string]interface{}{
+			{"step_number": 1, "instruction": "Brown the chicken in a pot", "timing": "10 minutes"},
+			{"step_number": 3, "instruction": "Stir in paprika and simmer", "timing": "30 minutes"},
+		},
+		"tips":         []string{"Serve with dumplings"},
+		"cuisine_type": "hungarian",
+		"meat_type":    "chicken",
+	}
+	if change != nil {
+		change(recipe)
+	}
+	data, err := json.Marshal(recipe)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return string(data)
+}
+
+func TestExtractJSONObject(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string // "" = error
+	}{
+		{"plain object", `{"title":"Soup"}`, `{"title":"Soup"}`},
+		{"code fence", "```json\n{\"title\":\"Soup\"}\n```", `{"title":"Soup"}`},
+		{"text before", `Here is your recipe: {"title":"Soup"}`, `{"title":"Soup"}`},
+		{"trailing prose", `{"title":"Soup"} Enjoy! Let me know if you want {variations}.`, `{"title":"Soup"}`},
+		{"braces in strings", `{"title":"Soup {spicy}","tips":["}"]} done`, `{"title":"Soup {spicy}","tips":["}"]}`},
+		{"nested objects", `{"a":{"b":{}}} {"c":1}`, `{"a":{"b":{}}}`},
+		{"no object", "Sorry, I can't help with that.", ""},
+		{"truncated", `{"title":"Soup","steps":[`, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			object, err := extractJSONObject(test.response)
+			if test.want == "" {
+				if err == nil {
+					t.Errorf("extractJSONObject = %s, want an error", object)
+				}
+				return
+			}
+			if err != nil || string(object) != test.want {
+				t.Errorf("extractJSONObject = %s, %v, want %s", object, err, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRecipeResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    string
+		request     models.RecipeGenerationRequest
+		wantProblem string // "" = valid
+		invalidJSON bool
+	}{
+		{name: "valid", response: testRecipe(t, nil)},
+		{name: "trailing prose", response: testRecipe(t, nil) + "\n\nEnjoy your meal! Tip: {season to taste}"},
+		{name: "requested servings", response: testRecipe(t, nil), request: models.RecipeGenerationRequest{Servings: 4}},
+		{name: "missing title", response: testRecipe(t, func(r map[string]interface{}) { delete(r, "title") }), wantProblem: "title is missing"},
+		{name: "blank title", response: testRecipe(t, func(r map[string]interface{}) { r["title"] = "   " }), wantProblem: "title is missing"},
+		{name: "long title", response: testRecipe(t, func(r map[string]interface{}) { r["title"] = strings.Repeat("a", 201) }), wantProblem: "title is longer than 200 characters"},
+		{name: "missing description", response: testRecipe(t, func(r map[string]interface{}) { r["description"] = "" }), wantProblem: "description is missing"},
+		{name: "zero ingredients", response: testRecipe(t, func(r map[string]interface{}) { r["ingredients"] = []string{} }), wantProblem: "ingredients must not be empty"},
+		{name: "unnamed ingredient", response: testRecipe(t, func(r map[string]interface{}) {
+			r["ingredients"] = []map[string]interface{}{{"name": " ", "quantity": 1}}
+		}), wantProblem: "ingredient 1 has no name"},
+		{name: "too many ingredients", response: testRecipe(t, func(r map[string]interface{}) {
+			ingredients := make([]map[string]interface{}, 61)
+			for i := range ingredients {
+				ingredients[i] = map[string]interface{}{"name": "salt"}
+			}
+			r["ingredients"] = ingredients
+		}), wantProblem: "at most 60 ingredients are allowed"},
+		{name: "no steps", response: testRecipe(t, func(r map[string]interface{}) { delete(r, "steps") }), wantProblem: "steps must not be empty"},
+		{name: "empty step", response: testRecipe(t, func(r map[string]interface{}) {
+			r["steps"] = []map[string]interface{}{{"instruction": ""}}
+		}), wantProblem: "step 1 has no instruction"},
+		{name: "zero servings", response: testRecipe(t, func(r map[string]interface{}) { r["serving_size"] = 0 }), wantProblem: "serving_size must be a number between 1 and 100"},
+		{name: "too many servings", response: testRecipe(t, func(r map[string]interface{}) { r["serving_size"] = 101 }), wantProblem: "serving_size must be a number between 1 and 100"},
+		{name: "servings not as requested", response: testRecipe(t, nil), request: models.RecipeGenerationRequest{Servings: 2},
+			wantProblem: "serving_size must be 2 as requested (quantities scaled accordingly)"},
+		{name: "negative time", response: testRecipe(t, func(r map[string]interface{}) { r["prep_time"] = -5 }), wantProblem: "cooking_time and prep_time must not be negative"},
+		{name: "no time", response: testRecipe(t, func(r map[string]interface{}) { r["cooking_time"], r["prep_time"] = 0, 0 }),
+			wantProblem: "cooking_time plus prep_time must be between 1 and 1440 minutes (got 0)"},
+		{name: "too long", response: testRecipe(t, func(r map[string]interface{}) { r["cooking_time"] = 1430 }),
+			wantProblem: "cooking_time plus prep_time must be between 1 and 1440 minutes (got 1445)"},
+		{name: "unknown difficulty", response: testRecipe(t, func(r map[string]interface{}) { r["difficulty"] = "expert" }), wantProblem: "difficulty must be one of easy, medium, hard"},
+		{name: "number as string", response: testRecipe(t, func(r map[string]interface{}) { r["serving_size"] = "4" }), wantProblem: "serving_size must be a number, not a JSON string"},
+		{name: "not JSON", response: "I cannot create that recipe.", wantProblem: "The response is not a valid JSON object", invalidJSON: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recipe, err := decodeRecipeResponse(test.response, test.request)
+			if test.wantProblem == "" {
+				if err != nil {
+					t.Fatalf("decodeRecipeResponse error = %v, want valid", err)
+				}
+				if recipe.Difficulty != "medium" || recipe.Steps[1].StepNumber != 2 {
+					t.Errorf("recipe = %+v, want normalized difficulty and step numbers", recipe)
+				}
+				return
+			}
+
+			var validationErr *RecipeValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("decodeRecipeResponse error = %v, want a RecipeValidationError", err)
+			}
+			if len(validationErr.Problems) != 1 || !strings.HasPrefix(validationErr.Problems[0], test.wantProblem) {
+				t.Errorf("problems = %q, want %q", validationErr.Problems, test.wantProblem)
+			}
+			wantErr := ErrParsingFailed
+			if test.invalidJSON {
+				wantErr = ErrInvalidJSON
+			}
+			if !errors.Is(err, wantErr) {
+				t.Errorf("error %v does not unwrap to %v", err, wantErr)
+			}
+		})
+	}
+}
+
+// scriptedClaude is a Messages API answering with scripted texts and recording the requests, the last text repeats
+type scriptedClaude struct {
+	mu       sync.Mutex
+	texts    []string
+	requests []anthropic.MessageNewParams
+}
+
+func (c *scriptedClaude) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params anthropic.MessageNewParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	text := c.texts[0]
+	if len(c.texts) > 1 {
+		c.texts = c.texts[1:]
+	}
+	c.requests = append(c.requests, params)
+	c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":          "msg_test",
+		"type":        "message",
+		"role":        "assistant",
+		"model":       "claude-test",
+		"content":     []map[string]interface{}{{"type": "text", "text": text}},
+		"stop_reason": "end_turn",
+		"usage":       map[string]interface{}{"input_tokens": 100, "output_tokens": 200},
+	})
+}
+
+// newScriptedClaudeService returns a Claude service talking to a scripted Messages API
+func newScriptedClaudeService(t *testing.T, texts ...string) (*ClaudeService, *scriptedClaude) {
+	t.Helper()
+	api := &scriptedClaude{texts: texts}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	client := anthropic.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	return &ClaudeService{
+		client:     &client,
+		model:      "claude-test",
+		resilience: NewAIResilience("claude", ResilienceConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, nil),
+	}, api
+}
+
+// messageText returns the text of a request message
+func messageText(message anthropic.MessageParam) string {
+	var text strings.Builder
+	for _, block := range message.Content {
+		if block.OfText != nil {
+			text.WriteString(block.OfText.Text)
+		}
+	}
+	return text.String()
+}
+
+func TestGenerateRecipeRepairsInvalidResponse(t *testing.T) {
+	invalid := testRecipe(t, func(r map[string]interface{}) { delete(r, "title"); r["ingredients"] = []string{} })
+	service, api := newScriptedClaudeService(t, invalid, testRecipe(t, nil))
+
+	recipe, err := service.GenerateRecipe(context.Background(), models.RecipeGenerationRequest{MeatType: "chicken"})
+	if err != nil {
+		t.Fatalf("GenerateRecipe: %v", err)
+	}
+	if recipe.Title != "Chicken Paprikash" || len(recipe.Ingredients) != 2 {
+		t.Errorf("recipe = %+v, want the repaired response", recipe)
+	}
+
+	if len(api.requests) != 2 {
+		t.Fatalf("requests = %d, want the generation and one repair", len(api.requests))
+	}
+	// The repair continues the conversation with the invalid response and its problems
+	messages := api.requests[1].Messages
+	if len(messages) != 3 || messages[1].Role != anthropic.MessageParamRoleAssistant || messageText(messages[1]) != invalid {
+		t.Fatalf("repair messages = %+v, want the prompt, the invalid response and the problems", messages)
+	}
+	repair := messageText(messages[2])
+	for _, problem := range []string{"- title is missing", "- ingredients must not be empty"} {
+		if !strings.Contains(repair, problem) {
+			t.Errorf("repair prompt %q does not list %q", repair, problem)
+		}
+	}
+}
+
+func TestGenerateRecipeStopsAfterRepairLimit(t *testing.T) {
+	service, api := newScriptedClaudeService(t, "Here is a lovely recipe without any JSON.")
+
+	_, err := service.GenerateRecipe(context.Background(), models.RecipeGenerationRequest{MeatType: "chicken"})
+	var validationErr *RecipeValidationError
+	if !errors.As(err, &validationErr) || !errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("GenerateRecipe error = %v, want the validation error of the last response", err)
+	}
+	if len(api.requests) != maxRecipeRepairs+1 {
+		t.Errorf("requests = %d, want the generation and %d repairs", len(api.requests), maxRecipeRepairs)
+	}
+	if messages := api.requests[len(api.requests)-1].Messages; len(messages) != 1+2*maxRecipeRepairs {
+		t.Errorf("last request has %d messages, want %d", len(messages), 1+2*maxRecipeRepairs)
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"chefly/models"
+	"chefly/store"
+)
+
+// ErrMigrationRunning is returned when a migration is started while one is already running
+var ErrMigrationRunning = errors.New("image migration already running")
+
+// unmanagedImageBatchSize is how many recipes are loaded at once, data: URLs are megabytes each
+const unmanagedImageBatchSize = 10
+
+// UnmanagedImageMigrator moves recipe images stored as data: URLs or external URLs into the image store
+// Earlier versions stored these when image optimization failed while generating a recipe: the raw
+// DALL-E data URL, or the temporary OpenAI URL which expires within hours
+type UnmanagedImageMigrator struct {
+	recipes      store.RecipeStore
+	optimizer    *ImageOptimizer
+	imageCleanup *ImageCleanupService
+	auditLogger  *AuditLogger
+
+	mu       sync.Mutex
+	progress models.UnmanagedImageMigrationProgress
+}
+
+// NewUnmanagedImageMigrator creates a new migrator
+func NewUnmanagedImageMigrator(recipes store.RecipeStore, optimizer *ImageOptimizer, imageStore ImageStore, auditLogger *AuditLogger) *UnmanagedImageMigrator {
+	return &UnmanagedImageMigrator{
+		recipes:      recipes,
+		optimizer:    optimizer,
+		imageCleanup: NewImageCleanupService(imageStore, auditLogger),
+		auditLogger:  auditLogger,
+	}
+}
+
+// Start runs the migration in the background
+// Returns ErrMigrationRunning if a run is in progress
+func (m *UnmanagedImageMigrator) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.progress.Running {
+		return ErrMigrationRunning
+	}
+	startedAt := time.Now().UTC()
+	m.progress = models.UnmanagedImageMigrationProgress{Running: true, StartedAt: &startedAt}
+
+	go m.run()
+	return nil
+}
+
+// Progress returns the state of the current or last run
+func (m *UnmanagedImageMigrator) Progress() models.UnmanagedImageMigrationProgress {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.progress
+}
+
+// run migrates all recipes with unmanaged images one at a time
+func (m *UnmanagedImageMigrator) run() {
+	defer m.finish()
+
+	total, err := m.recipes.CountUnmanagedImages()
+	if err != nil {
+		m.update(func(progress *models.UnmanagedImageMigrationProgress) { progress.LastError = err.Error() })
+		if m.auditLogger != nil {
+			m.auditLogger.Error("system.images.unmanaged_migration_failed", "Failed to count recipes with unmanaged images", err, nil)
+		}
+		return
+	}
+	m.update(func(progress *models.UnmanagedImageMigrationProgress) { progress.Total = total })
+	if total == 0 {
+		return
+	}
+
+	if m.auditLogger != nil {
+		m.auditLogger.Info("system.images.unmanaged_migration_started", "Moving data: URL and external recipe images to image storage", &models.AuditContext{
+			Metadata: map[string]interface{}{
+				"total": total,
+			},
+		})
+	}
+
+	// Page by recipe id, so failed rows (which still match) are not loaded again
+	afterID := ""
+	for {
+		batch, err := m.recipes.ListUnmanagedImages(afterID, unmanagedImageBatchSize)
+		if err != nil {
+			m.update(func(progress *models.UnmanagedImageMigrationProgress) { progress.LastError = err.Error() })
+			if m.auditLogger != nil {
+				m.auditLogger.Error("system.images.unmanaged_migration_failed", "Failed to list recipes with unmanaged images", err, nil)
+			}
+			return
+		}
+		if len(batch) == 0 {
+			return
+		}
+
+		for _, recipe := range batch {
+			m.migrateRecipe(recipe)
+			afterID = recipe.RecipeID
+		}
+	}
+}
+
+// migrateRecipe optimizes a recipe's image into the image store and rewrites its row
+// The row is only rewritten while it still holds the migrated image, a regenerated image is kept
+func (m *UnmanagedImageMigrator) migrateRecipe(recipe models.RecipeImage) {
+	optimized, err := m.optimizer.OptimizeRecipeImage(context.Background(), recipe.ImagePath)
+	if err == nil {
+		err = m.recipes.ReplaceImages(recipe.RecipeID, recipe.ImagePath, optimized.FullImageURL, optimized.ThumbnailURL, optimized.Variants)
+		if err == nil {
+			m.update(func(progress *models.UnmanagedImageMigrationProgress) { progress.Migrated++ })
+			return
+		}
+		// Nothing refers to the new files
+		m.imageCleanup.DeleteRecipeImages(optimized.FullImageURL, optimized.ThumbnailURL, optimized.Variants, recipe.RecipeID, "", "")
+	} else if errors.Is(err, ErrImageSourceGone) {
+		// The recipe shows no image rather than a broken one
+		err = m.recipes.ReplaceImages(recipe.RecipeID, recipe.ImagePath, "", "", nil)
+		if err == nil {
+			m.update(func(progress *models.UnmanagedImageMigrationProgress) { progress.Cleared++ })
+			if m.auditLogger != nil {
+				m.auditLogger.Warn("system.images.unmanaged_image_cleared", "External recipe image is no longer available, removed from recipe", &models.AuditContext{
+					Metadata: map[string]interface{}{
+						"recipe_id": recipe.RecipeID,
+					},
+				})
+			}
+			return
+		}
+	}
+
+	// Recipes deleted or given a new image meanwhile are simply skipped
+	if errors.Is(err, store.ErrNotFound) {
+		m.update(func(progress *models.UnmanagedImageMigrationProgress) { progress.Total-- })
+		return
+	}
+
+	m.update(func(progress *models.UnmanagedImageMigrationProgress) {
+		progress.Failed++
+		progress.LastError = err.Error()
+	})
+	if m.auditLogger != nil {
+		m.auditLogger.Warn("system.images.unmanaged_image_failed", "Failed to move recipe image to image storage", &models.AuditContext{
+			Metadata: map[string]interface{}{
+				"recipe_id": recipe.RecipeID,
+				"error":     err.Error(),
+			},
+		})
+	}
+}
+
+// update changes the progress under the lock and keeps Processed in sync
+func (m *UnmanagedImageMigrator) update(change func(progress *models.UnmanagedImageMigrationProgress)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	change(&m.progress)
+	m.progress.Processed = m.progress.Migrated + m.progress.Cleared + m.progress.Failed
+}
+
+// finish marks the run as finished and logs its outcome
+func (m *UnmanagedImageMigrator) finish() {
+	m.mu.Lock()
+	finishedAt := time.Now().UTC()
+	m.progress.Running = false
+	m.progress.FinishedAt = &finishedAt
+	progress := m.progress
+	m.mu.Unlock()
+
+	if m.auditLogger != nil && progress.Total > 0 {
+		m.auditLogger.Info("system.images.unmanaged_migration_completed", "Finished moving recipe images to image storage", &models.AuditContext{
+			Metadata: map[string]interface{}{
+				"total":       progress.Total,
+				"migrated":    progress.Migrated,
+				"cleared":     progress.Cleared,
+				"failed":      progress.Failed,
+				"duration_ms": finishedAt.Sub(*progress.StartedAt).Milliseconds(),
+			},
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"path/filepath"
+	"testing"
+
+	"chefly/models"
+	"chefly/store"
+	"chefly/store/memstore"
+)
+
+// testImageDataURL returns a PNG data URL as stored by earlier versions
+func testImageDataURL(t *testing.T) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: 128, B: uint8(y * 4), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func newTestImageMigrator(t *testing.T) (*UnmanagedImageMigrator, *store.Store, ImageStore) {
+	t.Helper()
+	stores := memstore.New(nil)
+	if err := stores.Users.Create(&models.User{ID: "cook", Email: "cook@example.com", Username: "cook"}); err != nil {
+		t.Fatalf("Users.Create: %v", err)
+	}
+	imageStore := NewLocalImageStore(filepath.Join(t.TempDir(), "uploads"))
+	optimizer := NewImageOptimizer(imageStore, DefaultImageVariantConfig(), nil)
+	return NewUnmanagedImageMigrator(stores.Recipes, optimizer, imageStore, nil), stores, imageStore
+}
+
+func TestMigrateUnmanagedImage(t *testing.T) {
+	migrator, stores, imageStore := newTestImageMigrator(t)
+	dataURL := testImageDataURL(t)
+	if err := stores.Recipes.Create(&models.RecipeDetail{ID: "r1", UserID: "cook", Title: "Soup", ImagePath: dataURL}); err != nil {
+		t.Fatalf("Recipes.Create: %v", err)
+	}
+
+	migrator.migrateRecipe(models.RecipeImage{RecipeID: "r1", ImagePath: dataURL})
+
+	recipe, err := stores.Recipes.Get("r1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if recipe.ImagePath == dataURL || recipe.ThumbnailPath == "" || len(recipe.ImageVariants) == 0 {
+		t.Errorf("recipe images = %q, %q, %d variants, want stored images", recipe.ImagePath, recipe.ThumbnailPath, len(recipe.ImageVariants))
+	}
+	images, err := imageStore.List("")
+	if err != nil || len(images) != len(recipe.ImageVariants) {
+		t.Errorf("stored images = %d, %v, want %d", len(images), err, len(recipe.ImageVariants))
+	}
+	if progress := migrator.Progress(); progress.Migrated != 1 || progress.Failed != 0 {
+		t.Errorf("progress = %+v, want 1 migrated", progress)
+	}
+}
+
+func TestMigrateUnmanagedImageKeepsNewerImage(t *testing.T) {
+	migrator, stores, imageStore := newTestImageMigrator(t)
+	dataURL := testImageDataURL(t)
+
+	// The image was regenerated after the migration listed the recipe
+	regenerated := "/uploads/images/variants/new/800.jpg"
+	if err := stores.Recipes.Create(&models.RecipeDetail{ID: "r1", UserID: "cook", Title: "Soup", ImagePath: regenerated}); err != nil {
+		t.Fatalf("Recipes.Create: %v", err)
+	}
+	migrator.update(func(progress *models.UnmanagedImageMigrationProgress) { progress.Total = 1 })
+
+	migrator.migrateRecipe(models.RecipeImage{RecipeID: "r1", ImagePath: dataURL})
+
+	recipe, err := stores.Recipes.Get("r1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if recipe.ImagePath != regenerated {
+		t.Errorf("image path = %q, want the regenerated image %q", recipe.ImagePath, regenerated)
+	}
+	if images, err := imageStore.List(""); err != nil || len(images) != 0 {
+		t.Errorf("stored images = %+v, %v, want the migrated copy deleted", images, err)
+	}
+	if progress := migrator.Progress(); progress.Total != 0 || progress.Migrated != 0 || progress.Failed != 0 {
+		t.Errorf("progress = %+v, want the recipe skipped", progress)
+	}
+}
@@ -0,0 +1,759 @@
+// Package memstore implements the user, recipe and generation stores in memory
+// It lets handlers and services be tested without a database, see the SQL stores for the reference behavior
+package memstore
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chefly/models"
+	"chefly/store"
+
+	"github.com/google/uuid"
+)
+
+// data is the data set shared by the stores of one New call
+type data struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	users   map[string]*userRecord
+	recipes map[string]*recipeRecord
+	events  []generationEvent
+}
+
+// userRecord is a user with the settings stored alongside it
+type userRecord struct {
+	user             models.User
+	digestOptIn      bool
+	unsubscribeToken string
+	digestLastSentAt *time.Time
+	preferences      *models.UserPreferences
+}
+
+// recipeRecord is a recipe with its stored nutrition
+type recipeRecord struct {
+	recipe    models.RecipeDetail
+	nutrition *models.RecipeNutrition
+}
+
+// generationEvent is one entry of the generation log
+type generationEvent struct {
+	id        string
+	userID    string
+	kind      string
+	recipeID  string
+	createdAt time.Time
+}
+
+// New returns a store whose Users, Recipes and Generations share one in-memory data set
+// The other stores are nil. now is the clock of created_at timestamps (nil = time.Now)
+func New(now func() time.Time) *store.Store {
+	if now == nil {
+		now = time.Now
+	}
+	d := &data{
+		now:     now,
+		users:   map[string]*userRecord{},
+		recipes: map[string]*recipeRecord{},
+	}
+	return &store.Store{
+		Users:       &userStore{d},
+		Recipes:     &recipeStore{d},
+		Generations: &generationStore{d},
+	}
+}
+
+// userStore is the in-memory implementation of store.UserStore
+type userStore struct {
+	*data
+}
+
+var _ store.UserStore = (*userStore)(nil)
+
+func (s *userStore) Create(user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range s.users {
+		if record.user.ID == user.ID || record.user.Email == user.Email {
+			return errors.New("UNIQUE constraint failed: users")
+		}
+	}
+
+	// First user becomes admin
+	user.IsAdmin = len(s.users) == 0
+	user.CreatedAt = s.now()
+	user.UpdatedAt = user.CreatedAt
+	s.users[user.ID] = &userRecord{user: *user}
+	return nil
+}
+
+func (s *userStore) GetByID(id string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	user := record.user
+	return &user, nil
+}
+
+func (s *userStore) GetByEmail(email string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range s.users {
+		if record.user.Email == email {
+			user := record.user
+			return &user, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *userStore) EmailExists(email string) (bool, error) {
+	_, err := s.GetByEmail(email)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *userStore) UpdateUsername(id, username string) error {
+	return s.update(id, func(record *userRecord) {
+		record.user.Username = username
+		record.user.UpdatedAt = s.now()
+	})
+}
+
+func (s *userStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.users, id)
+
+	// Like ON DELETE CASCADE
+	for recipeID, record := range s.recipes {
+		if record.recipe.UserID == id {
+			delete(s.recipes, recipeID)
+		}
+	}
+	events := s.events[:0]
+	for _, event := range s.events {
+		if event.userID != id {
+			events = append(events, event)
+		}
+	}
+	s.events = events
+	return nil
+}
+
+func (s *userStore) SetQuotaPolicy(id, kind string, policy *string) error {
+	return s.update(id, func(record *userRecord) {
+		var value *string
+		if policy != nil {
+			copied := *policy
+			value = &copied
+		}
+		if kind == models.QuotaImage {
+			record.user.ImageQuota = value
+		} else {
+			record.user.TextQuota = value
+		}
+	})
+}
+
+func (s *userStore) ListWithStats() ([]models.UserWithStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := []models.UserWithStats{}
+	for _, record := range s.users {
+		user := models.UserWithStats{
+			ID:         record.user.ID,
+			Email:      record.user.Email,
+			Username:   record.user.Username,
+			IsAdmin:    record.user.IsAdmin,
+			CreatedAt:  record.user.CreatedAt,
+			TextQuota:  record.user.TextQuota,
+			ImageQuota: record.user.ImageQuota,
+		}
+		var lastRecipe time.Time
+		for _, recipe := range s.recipes {
+			if recipe.recipe.UserID != user.ID {
+				continue
+			}
+			user.RecipeCount++
+			if recipe.recipe.CreatedAt.After(lastRecipe) {
+				lastRecipe = recipe.recipe.CreatedAt
+			}
+		}
+		if user.RecipeCount > 0 {
+			lastRecipeDate := lastRecipe.Format(time.RFC3339)
+			user.LastRecipeDate = &lastRecipeDate
+		}
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].CreatedAt.Before(users[j].CreatedAt) })
+	return users, nil
+}
+
+func (s *userStore) AdminStats(registeredSince time.Time) (*models.AdminStats, error) {
+	users, err := s.ListWithStats()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.AdminStats{TotalUsers: len(users), FirstUserDate: time.Now()}
+	for i, user := range users {
+		stats.TotalRecipes += user.RecipeCount
+		if !user.CreatedAt.Before(registeredSince) {
+			stats.RecentRegistrations++
+		}
+		if i == 0 {
+			stats.FirstUserDate = user.CreatedAt
+		}
+		if stats.MostActiveUser == nil || user.RecipeCount > stats.MostActiveUserCount {
+			username := user.Username
+			stats.MostActiveUser = &username
+			stats.MostActiveUserCount = user.RecipeCount
+		}
+	}
+	if stats.TotalUsers > 0 {
+		stats.AverageRecipesUser = float64(stats.TotalRecipes) / float64(stats.TotalUsers)
+	}
+	return stats, nil
+}
+
+func (s *userStore) GetDigestSettings(id string) (*models.DigestSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &models.DigestSettings{OptIn: record.digestOptIn, LastSentAt: record.digestLastSentAt}, nil
+}
+
+func (s *userStore) SetDigestOptIn(id string, optIn bool, unsubscribeToken string) error {
+	return s.update(id, func(record *userRecord) {
+		record.digestOptIn = optIn
+		if unsubscribeToken != "" {
+			record.unsubscribeToken = unsubscribeToken
+		}
+	})
+}
+
+func (s *userStore) GetUnsubscribeToken(id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.users[id]
+	if !ok {
+		return "", store.ErrNotFound
+	}
+	return record.unsubscribeToken, nil
+}
+
+func (s *userStore) SetUnsubscribeToken(id, token string) error {
+	return s.update(id, func(record *userRecord) {
+		record.unsubscribeToken = token
+	})
+}
+
+func (s *userStore) FindByUnsubscribeToken(token string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, record := range s.users {
+		if token != "" && record.unsubscribeToken == token {
+			return id, nil
+		}
+	}
+	return "", store.ErrNotFound
+}
+
+func (s *userStore) ListDigestRecipients(sentBefore time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var userIDs []string
+	for id, record := range s.users {
+		if record.digestOptIn && (record.digestLastSentAt == nil || record.digestLastSentAt.Before(sentBefore)) {
+			userIDs = append(userIDs, id)
+		}
+	}
+	sort.Strings(userIDs)
+	return userIDs, nil
+}
+
+func (s *userStore) ClaimDigest(id string, claimedAt, sentBefore time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.users[id]
+	if !ok || !record.digestOptIn || (record.digestLastSentAt != nil && !record.digestLastSentAt.Before(sentBefore)) {
+		return false, nil
+	}
+	record.digestLastSentAt = &claimedAt
+	return true, nil
+}
+
+func (s *userStore) ReleaseDigestClaim(id string, claimedAt time.Time, previous *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.users[id]
+	if !ok || record.digestLastSentAt == nil || !record.digestLastSentAt.Equal(claimedAt) {
+		return store.ErrNotFound
+	}
+	record.digestLastSentAt = previous
+	return nil
+}
+
+func (s *userStore) GetPreferences(id string) (*models.UserPreferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.users[id]
+	if !ok || record.preferences == nil {
+		return nil, nil
+	}
+	prefs := clonePreferences(*record.preferences)
+	return &prefs, nil
+}
+
+func (s *userStore) SavePreferences(id string, prefs *models.UserPreferences) error {
+	return s.update(id, func(record *userRecord) {
+		saved := clonePreferences(*prefs)
+		saved.UpdatedAt = s.now()
+		record.preferences = &saved
+	})
+}
+
+// update applies a change to a user, ErrNotFound if the user does not exist
+func (s *userStore) update(id string, change func(record *userRecord)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.users[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	change(record)
+	return nil
+}
+
+// recipeStore is the in-memory implementation of store.RecipeStore
+type recipeStore struct {
+	*data
+}
+
+var _ store.RecipeStore = (*recipeStore)(nil)
+
+func (s *recipeStore) Create(recipe *models.RecipeDetail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.recipes[recipe.ID]; ok {
+		return errors.New("UNIQUE constraint failed: recipes.id")
+	}
+	if _, ok := s.users[recipe.UserID]; !ok {
+		return errors.New("FOREIGN KEY constraint failed")
+	}
+
+	recipe.IsFavorite = false
+	recipe.CreatedAt = s.now()
+	stored := cloneRecipe(*recipe)
+	stored.Nutrition, stored.Photos = nil, nil
+	s.recipes[recipe.ID] = &recipeRecord{recipe: stored}
+	return nil
+}
+
+func (s *recipeStore) Get(id string) (*models.RecipeDetail, error) {
+	return s.get(id, "")
+}
+
+func (s *recipeStore) GetForUser(id, userID string) (*models.RecipeDetail, error) {
+	return s.get(id, userID)
+}
+
+// get returns a copy of a recipe, of any user if userID is empty
+func (s *recipeStore) get(id, userID string) (*models.RecipeDetail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.recipes[id]
+	if !ok || (userID != "" && record.recipe.UserID != userID) {
+		return nil, store.ErrNotFound
+	}
+	recipe := cloneRecipe(record.recipe)
+	return &recipe, nil
+}
+
+func (s *recipeStore) List(userID string, filter models.NutritionFilter) ([]models.RecipeSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recipes := []models.RecipeSummary{}
+	for _, record := range s.recipes {
+		if record.recipe.UserID != userID || !matchesNutrition(record.nutrition, filter) {
+			continue
+		}
+		recipe := record.recipe
+		summary := models.RecipeSummary{
+			ID:            recipe.ID,
+			Title:         recipe.Title,
+			Description:   recipe.Description,
+			CuisineType:   recipe.CuisineType,
+			Difficulty:    recipe.Difficulty,
+			CookingTime:   recipe.CookingTime,
+			IsFavorite:    recipe.IsFavorite,
+			ImagePath:     recipe.ImagePath,
+			ThumbnailPath: recipe.ThumbnailPath,
+			ImageVariants: cloneVariants(recipe.ImageVariants),
+			CreatedAt:     recipe.CreatedAt,
+		}
+		if record.nutrition != nil {
+			calories := record.nutrition.PerServing.Calories
+			summary.Calories = &calories
+		}
+		recipes = append(recipes, summary)
+	}
+
+	sort.Slice(recipes, func(i, j int) bool { return recipes[i].CreatedAt.After(recipes[j].CreatedAt) })
+	return recipes, nil
+}
+
+// matchesNutrition reports whether stored nutrition passes the filters, recipes without nutrition only pass no filters
+func matchesNutrition(nutrition *models.RecipeNutrition, filter models.NutritionFilter) bool {
+	conditions := []struct {
+		limit *float64
+		value func(facts models.NutritionFacts) float64
+		max   bool
+	}{
+		{filter.MaxCalories, func(f models.NutritionFacts) float64 { return f.Calories }, true},
+		{filter.MinProtein, func(f models.NutritionFacts) float64 { return f.Protein }, false},
+		{filter.MaxFat, func(f models.NutritionFacts) float64 { return f.Fat }, true},
+		{filter.MaxCarbohydrates, func(f models.NutritionFacts) float64 { return f.Carbohydrates }, true},
+		{filter.MinFiber, func(f models.NutritionFacts) float64 { return f.Fiber }, false},
+		{filter.MaxSodium, func(f models.NutritionFacts) float64 { return f.Sodium }, true},
+	}
+	for _, condition := range conditions {
+		if condition.limit == nil {
+			continue
+		}
+		if nutrition == nil {
+			return false
+		}
+		value := condition.value(nutrition.PerServing)
+		if (condition.max && value > *condition.limit) || (!condition.max && value < *condition.limit) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *recipeStore) Delete(id, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.recipes[id]
+	if !ok || record.recipe.UserID != userID {
+		return store.ErrNotFound
+	}
+	delete(s.recipes, id)
+	return nil
+}
+
+func (s *recipeStore) ToggleFavorite(id, userID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.recipes[id]
+	if !ok || record.recipe.UserID != userID {
+		return false, store.ErrNotFound
+	}
+	record.recipe.IsFavorite = !record.recipe.IsFavorite
+	return record.recipe.IsFavorite, nil
+}
+
+func (s *recipeStore) CountByUser(userID string) (int, error) {
+	return s.count(func(recipe *models.RecipeDetail) bool { return recipe.UserID == userID }), nil
+}
+
+func (s *recipeStore) CountFavorites(userID string) (int, error) {
+	return s.count(func(recipe *models.RecipeDetail) bool { return recipe.UserID == userID && recipe.IsFavorite }), nil
+}
+
+func (s *recipeStore) CountCreatedSince(userID string, since time.Time) (int, error) {
+	return s.count(func(recipe *models.RecipeDetail) bool {
+		return recipe.UserID == userID && !recipe.CreatedAt.Before(since)
+	}), nil
+}
+
+// count counts the recipes matching a condition
+func (s *recipeStore) count(matches func(recipe *models.RecipeDetail) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, record := range s.recipes {
+		if matches(&record.recipe) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *recipeStore) RecentTitles(userID string, since time.Time, limit int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var recipes []models.RecipeDetail
+	for _, record := range s.recipes {
+		if record.recipe.UserID == userID && !record.recipe.CreatedAt.Before(since) {
+			recipes = append(recipes, record.recipe)
+		}
+	}
+	sort.Slice(recipes, func(i, j int) bool { return recipes[i].CreatedAt.After(recipes[j].CreatedAt) })
+
+	titles := []string{}
+	for _, recipe := range recipes {
+		if len(titles) == limit {
+			break
+		}
+		titles = append(titles, recipe.Title)
+	}
+	return titles, nil
+}
+
+func (s *recipeStore) ListImages(userID string) ([]models.RecipeImage, error) {
+	return s.images(func(recipe *models.RecipeDetail) bool { return recipe.UserID == userID }), nil
+}
+
+func (s *recipeStore) ListAllImages() ([]models.RecipeImage, error) {
+	return s.images(func(recipe *models.RecipeDetail) bool { return true }), nil
+}
+
+func (s *recipeStore) UpdateImages(id, imagePath, thumbnailPath string, variants []models.ImageVariant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.recipes[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	record.recipe.ImagePath = imagePath
+	record.recipe.ThumbnailPath = thumbnailPath
+	record.recipe.ImageVariants = cloneVariants(variants)
+	return nil
+}
+
+func (s *recipeStore) ReplaceImages(id, previousImagePath, imagePath, thumbnailPath string, variants []models.ImageVariant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.recipes[id]
+	if !ok || record.recipe.ImagePath != previousImagePath {
+		return store.ErrNotFound
+	}
+	record.recipe.ImagePath = imagePath
+	record.recipe.ThumbnailPath = thumbnailPath
+	record.recipe.ImageVariants = cloneVariants(variants)
+	return nil
+}
+
+func (s *recipeStore) ListUnmanagedImages(afterID string, limit int) ([]models.RecipeImage, error) {
+	images := s.images(func(recipe *models.RecipeDetail) bool {
+		return recipe.ID > afterID && isUnmanagedImage(recipe.ImagePath)
+	})
+	if len(images) > limit {
+		images = images[:limit]
+	}
+	return images, nil
+}
+
+func (s *recipeStore) CountUnmanagedImages() (int, error) {
+	return s.count(func(recipe *models.RecipeDetail) bool { return isUnmanagedImage(recipe.ImagePath) }), nil
+}
+
+// isUnmanagedImage reports whether an image is a data: or external URL, like unmanagedImageCondition of the SQL store
+func isUnmanagedImage(imagePath string) bool {
+	return strings.HasPrefix(imagePath, "data:") || strings.HasPrefix(imagePath, "http://") || strings.HasPrefix(imagePath, "https://")
+}
+
+// images returns the images of the recipes matching a condition, ordered by recipe id
+func (s *recipeStore) images(matches func(recipe *models.RecipeDetail) bool) []models.RecipeImage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var images []models.RecipeImage
+	for _, record := range s.recipes {
+		if matches(&record.recipe) {
+			images = append(images, models.RecipeImage{
+				RecipeID:      record.recipe.ID,
+				ImagePath:     record.recipe.ImagePath,
+				ThumbnailPath: record.recipe.ThumbnailPath,
+				Variants:      cloneVariants(record.recipe.ImageVariants),
+			})
+		}
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].RecipeID < images[j].RecipeID })
+	return images
+}
+
+func (s *recipeStore) GetNutrition(recipeID string) (*models.RecipeNutrition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.recipes[recipeID]
+	if !ok || record.nutrition == nil {
+		return nil, nil
+	}
+	nutrition := *record.nutrition
+	return &nutrition, nil
+}
+
+func (s *recipeStore) SaveNutrition(recipeID string, nutrition *models.RecipeNutrition) error {
+	if nutrition == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.recipes[recipeID]
+	if !ok {
+		return errors.New("FOREIGN KEY constraint failed")
+	}
+	saved := *nutrition
+	record.nutrition = &saved
+	return nil
+}
+
+// generationStore is the in-memory implementation of store.GenerationStore
+type generationStore struct {
+	*data
+}
+
+var _ store.GenerationStore = (*generationStore)(nil)
+
+func (s *generationStore) Record(userID, kind, recipeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return errors.New("FOREIGN KEY constraint failed")
+	}
+	s.events = append(s.events, generationEvent{
+		id:        uuid.New().String(),
+		userID:    userID,
+		kind:      kind,
+		recipeID:  recipeID,
+		createdAt: s.now(),
+	})
+	return nil
+}
+
+func (s *generationStore) Reserve(userID, kind, recipeID string, limits []store.GenerationLimit) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return "", store.ErrNotFound
+	}
+	for _, limit := range limits {
+		if len(s.since(userID, kind, limit.Since)) >= limit.Limit {
+			return "", store.ErrLimitReached
+		}
+	}
+	event := generationEvent{
+		id:        uuid.New().String(),
+		userID:    userID,
+		kind:      kind,
+		recipeID:  recipeID,
+		createdAt: s.now(),
+	}
+	s.events = append(s.events, event)
+	return event.id, nil
+}
+
+func (s *generationStore) Release(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, event := range s.events {
+		if event.id == id {
+			s.events = append(s.events[:i], s.events[i+1:]...)
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (s *generationStore) CountSince(userID, kind string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.since(userID, kind, since)), nil
+}
+
+func (s *generationStore) NthSince(userID, kind string, since time.Time, n int) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := s.since(userID, kind, since)
+	if n < 0 || n >= len(events) {
+		return time.Time{}, store.ErrNotFound
+	}
+	return events[n].createdAt, nil
+}
+
+// since returns a user's events of a kind created at or after since, oldest first
+// The caller holds the lock
+func (s *generationStore) since(userID, kind string, since time.Time) []generationEvent {
+	var events []generationEvent
+	for _, event := range s.events {
+		if event.userID == userID && event.kind == kind && !event.createdAt.Before(since) {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].createdAt.Before(events[j].createdAt) })
+	return events
+}
+
+// cloneRecipe copies a recipe so callers can't modify stored slices
+func cloneRecipe(recipe models.RecipeDetail) models.RecipeDetail {
+	recipe.Ingredients = append([]models.Ingredient{}, recipe.Ingredients...)
+	recipe.Steps = append([]models.CookingStep{}, recipe.Steps...)
+	recipe.DietaryTags = append([]string{}, recipe.DietaryTags...)
+	recipe.Allergens = append([]string{}, recipe.Allergens...)
+	recipe.ImageVariants = cloneVariants(recipe.ImageVariants)
+	return recipe
+}
+
+// cloneVariants copies image variants, never returning nil like decodeVariants of the SQL store
+func cloneVariants(variants []models.ImageVariant) []models.ImageVariant {
+	return append([]models.ImageVariant{}, variants...)
+}
+
+// clonePreferences copies a dietary profile
+func clonePreferences(prefs models.UserPreferences) models.UserPreferences {
+	prefs.Allergies = append([]string{}, prefs.Allergies...)
+	prefs.Diets = append([]string{}, prefs.Diets...)
+	prefs.DislikedIngredients = append([]string{}, prefs.DislikedIngredients...)
+	prefs.PreferredCuisines = append([]string{}, prefs.PreferredCuisines...)
+	prefs.KitchenEquipment = append([]string{}, prefs.KitchenEquipment...)
+	return prefs
+}
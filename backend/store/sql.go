@@ -0,0 +1,140 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"chefly/database"
+)
+
+// New creates SQL implementations of all stores for the database dialect
+// Queries are written once with "?" placeholders and TRUE/FALSE literals, which both SQLite and PostgreSQL accept
+func New(db *sql.DB, dialect database.Dialect) *Store {
+	c := &conn{db: db, dialect: dialect}
+	return &Store{
+		Users:        &sqlUserStore{db: c},
+		Recipes:      &sqlRecipeStore{db: c},
+		Photos:       &sqlPhotoStore{db: c},
+		ShoppingList: &sqlShoppingListStore{db: c},
+		Tokens:       &sqlTokenStore{db: c},
+		AIUsage:      &sqlAIUsageStore{db: c},
+		Generations:  &sqlGenerationStore{db: c},
+	}
+}
+
+// conn wraps a database handle and rewrites queries for its dialect
+type conn struct {
+	db      *sql.DB
+	dialect database.Dialect
+}
+
+func (c *conn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.db.Exec(c.dialect.Rebind(query), args...)
+}
+
+func (c *conn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.Query(c.dialect.Rebind(query), args...)
+}
+
+func (c *conn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.db.QueryRow(c.dialect.Rebind(query), args...)
+}
+
+func (c *conn) Begin() (*tx, error) {
+	sqlTx, err := c.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &tx{tx: sqlTx, dialect: c.dialect}, nil
+}
+
+// timeArg converts a time to an argument comparable with timestamp columns
+func (c *conn) timeArg(t time.Time) interface{} {
+	return c.dialect.TimeArg(t)
+}
+
+// tx wraps a transaction and rewrites queries for its dialect
+type tx struct {
+	tx      *sql.Tx
+	dialect database.Dialect
+}
+
+func (t *tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.Exec(t.dialect.Rebind(query), args...)
+}
+
+func (t *tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRow(t.dialect.Rebind(query), args...)
+}
+
+// timeArg converts a time to an argument comparable with timestamp columns
+func (t *tx) timeArg(tm time.Time) interface{} {
+	return t.dialect.TimeArg(tm)
+}
+
+func (t *tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// notFound converts sql.ErrNoRows to ErrNotFound
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// affectedOrNotFound returns ErrNotFound when a statement matched no rows
+func affectedOrNotFound(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// nullStringPtr converts a nullable column to a pointer (nil for NULL)
+func nullStringPtr(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+// encodeList encodes a list as a JSON array (never "null")
+func encodeList(values []string) string {
+	if values == nil {
+		return "[]"
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "[]"
+	}
+	return string(encoded)
+}
+
+// decodeList decodes a JSON encoded string array, returning an empty list on error
+func decodeList(value string) []string {
+	list := []string{}
+	json.Unmarshal([]byte(value), &list)
+	if list == nil {
+		list = []string{}
+	}
+	return list
+}
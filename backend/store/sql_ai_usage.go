@@ -0,0 +1,149 @@
+package store
+
+import (
+	"database/sql"
+	"math"
+	"time"
+
+	"chefly/models"
+
+	"github.com/google/uuid"
+)
+
+// sqlAIUsageStore is the SQL implementation of AIUsageStore
+type sqlAIUsageStore struct {
+	db *conn
+}
+
+// aiUsageTotalsColumns aggregates ai_usage rows in the order scanned by scanAIUsageTotals
+const aiUsageTotalsColumns = `
+	COUNT(*),
+	COALESCE(SUM(a.input_tokens), 0),
+	COALESCE(SUM(a.output_tokens), 0),
+	COALESCE(SUM(a.image_count), 0),
+	COALESCE(SUM(a.cost_usd), 0),
+	COALESCE(SUM(CASE WHEN a.cost_usd IS NULL THEN 1 ELSE 0 END), 0),
+	COALESCE(AVG(a.latency_ms), 0)`
+
+func (s *sqlAIUsageStore) Record(usage *models.AIUsage) error {
+	if usage.ID == "" {
+		usage.ID = uuid.New().String()
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO ai_usage (
+			id, user_id, provider, model, operation, input_tokens, output_tokens,
+			image_count, image_size, image_quality, cost_usd, latency_ms
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, usage.ID, usage.UserID, usage.Provider, usage.Model, usage.Operation, usage.InputTokens, usage.OutputTokens,
+		usage.ImageCount, usage.ImageSize, usage.ImageQuality, usage.CostUSD, usage.LatencyMS)
+	return err
+}
+
+func (s *sqlAIUsageStore) Totals(since time.Time) (*models.AIUsageTotals, error) {
+	var totals models.AIUsageTotals
+	row := s.db.QueryRow("SELECT "+aiUsageTotalsColumns+" FROM ai_usage a WHERE a.created_at >= ?", s.db.timeArg(since))
+	if err := scanAIUsageTotals(row, &totals); err != nil {
+		return nil, err
+	}
+	return &totals, nil
+}
+
+func (s *sqlAIUsageStore) ByDay(since time.Time) ([]models.AIUsageByDay, error) {
+	day := s.db.dialect.DayExpr("a.created_at")
+	rows, err := s.db.Query(`
+		SELECT `+day+`, `+aiUsageTotalsColumns+`
+		FROM ai_usage a
+		WHERE a.created_at >= ?
+		GROUP BY `+day+`
+		ORDER BY `+day,
+		s.db.timeArg(since),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []models.AIUsageByDay{}
+	for rows.Next() {
+		var usage models.AIUsageByDay
+		if err := scanAIUsageTotals(rows, &usage.AIUsageTotals, &usage.Date); err != nil {
+			return nil, err
+		}
+		days = append(days, usage)
+	}
+	return days, rows.Err()
+}
+
+func (s *sqlAIUsageStore) ByUser(since time.Time, limit int) ([]models.AIUsageByUser, error) {
+	rows, err := s.db.Query(`
+		SELECT a.user_id, u.username, u.email, `+aiUsageTotalsColumns+`
+		FROM ai_usage a
+		LEFT JOIN users u ON u.id = a.user_id
+		WHERE a.created_at >= ?
+		GROUP BY a.user_id, u.username, u.email
+		ORDER BY COALESCE(SUM(a.cost_usd), 0) DESC, COUNT(*) DESC
+		LIMIT ?
+	`, s.db.timeArg(since), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.AIUsageByUser{}
+	for rows.Next() {
+		var usage models.AIUsageByUser
+		var userID, username, email sql.NullString
+		if err := scanAIUsageTotals(rows, &usage.AIUsageTotals, &userID, &username, &email); err != nil {
+			return nil, err
+		}
+		usage.UserID = userID.String
+		usage.Username = username.String
+		usage.Email = email.String
+		users = append(users, usage)
+	}
+	return users, rows.Err()
+}
+
+func (s *sqlAIUsageStore) ByModel(since time.Time) ([]models.AIUsageByModel, error) {
+	rows, err := s.db.Query(`
+		SELECT a.provider, a.model, `+aiUsageTotalsColumns+`
+		FROM ai_usage a
+		WHERE a.created_at >= ?
+		GROUP BY a.provider, a.model
+		ORDER BY COALESCE(SUM(a.cost_usd), 0) DESC, COUNT(*) DESC
+	`, s.db.timeArg(since))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usageByModel := []models.AIUsageByModel{}
+	for rows.Next() {
+		var usage models.AIUsageByModel
+		if err := scanAIUsageTotals(rows, &usage.AIUsageTotals, &usage.Provider, &usage.Model); err != nil {
+			return nil, err
+		}
+		usageByModel = append(usageByModel, usage)
+	}
+	return usageByModel, rows.Err()
+}
+
+// scanAIUsageTotals scans the grouping columns (selected first) followed by aiUsageTotalsColumns
+func scanAIUsageTotals(row rowScanner, totals *models.AIUsageTotals, groupBy ...interface{}) error {
+	var avgLatency float64
+	dest := append(groupBy,
+		&totals.Calls,
+		&totals.InputTokens,
+		&totals.OutputTokens,
+		&totals.Images,
+		&totals.CostUSD,
+		&totals.UnpricedCalls,
+		&avgLatency,
+	)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	totals.CostUSD = math.Round(totals.CostUSD*1e6) / 1e6 // Summing floats adds noise below a millionth of a dollar
+	totals.AvgLatencyMS = int64(math.Round(avgLatency))
+	return nil
+}
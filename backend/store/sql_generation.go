@@ -0,0 +1,80 @@
+package store
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sqlGenerationStore is the SQL implementation of GenerationStore
+type sqlGenerationStore struct {
+	db *conn
+}
+
+func (s *sqlGenerationStore) Record(userID, kind, recipeID string) error {
+	_, err := s.db.Exec("INSERT INTO generation_events (id, user_id, kind, recipe_id) VALUES (?, ?, ?, ?)",
+		uuid.New().String(), userID, kind, recipeID)
+	return err
+}
+
+func (s *sqlGenerationStore) Reserve(userID, kind, recipeID string, limits []GenerationLimit) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Locks the user's row, so concurrent reservations of the user count each other
+	// (SQLite write transactions are serialized anyway)
+	if err := affectedOrNotFound(tx.Exec("UPDATE users SET updated_at = updated_at WHERE id = ?", userID)); err != nil {
+		return "", err
+	}
+
+	for _, limit := range limits {
+		var count int
+		err := tx.QueryRow(
+			"SELECT COUNT(*) FROM generation_events WHERE user_id = ? AND kind = ? AND created_at >= ?",
+			userID, kind, tx.timeArg(limit.Since),
+		).Scan(&count)
+		if err != nil {
+			return "", err
+		}
+		if count >= limit.Limit {
+			return "", ErrLimitReached
+		}
+	}
+
+	id := uuid.New().String()
+	if _, err := tx.Exec("INSERT INTO generation_events (id, user_id, kind, recipe_id) VALUES (?, ?, ?, ?)",
+		id, userID, kind, recipeID); err != nil {
+		return "", err
+	}
+	return id, tx.Commit()
+}
+
+func (s *sqlGenerationStore) Release(id string) error {
+	return affectedOrNotFound(s.db.Exec("DELETE FROM generation_events WHERE id = ?", id))
+}
+
+func (s *sqlGenerationStore) CountSince(userID, kind string, since time.Time) (int, error) {
+	var count int
+	err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM generation_events WHERE user_id = ? AND kind = ? AND created_at >= ?",
+		userID, kind, s.db.timeArg(since),
+	).Scan(&count)
+	return count, err
+}
+
+func (s *sqlGenerationStore) NthSince(userID, kind string, since time.Time, n int) (time.Time, error) {
+	var createdAt time.Time
+	err := s.db.QueryRow(`
+		SELECT created_at FROM generation_events
+		WHERE user_id = ? AND kind = ? AND created_at >= ?
+		ORDER BY created_at
+		LIMIT 1 OFFSET ?
+	`, userID, kind, s.db.timeArg(since), n).Scan(&createdAt)
+	if err != nil {
+		return time.Time{}, notFound(err)
+	}
+	return createdAt, nil
+}
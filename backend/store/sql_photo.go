@@ -0,0 +1,170 @@
+package store
+
+import (
+	"database/sql"
+
+	"chefly/models"
+)
+
+// sqlPhotoStore is the SQL implementation of PhotoStore
+type sqlPhotoStore struct {
+	db *conn
+}
+
+// photoColumns is the column list scanned by scanPhoto
+const photoColumns = `id, recipe_id, user_id, image_path, thumbnail_path, COALESCE(image_variants, '[]'),
+	width, height, is_cover, created_at`
+
+// scanPhoto scans a row selected with photoColumns
+func scanPhoto(row rowScanner) (*models.RecipePhoto, error) {
+	var photo models.RecipePhoto
+	var variantsJSON string
+
+	err := row.Scan(&photo.ID, &photo.RecipeID, &photo.UserID, &photo.ImagePath, &photo.ThumbnailPath,
+		&variantsJSON, &photo.Width, &photo.Height, &photo.IsCover, &photo.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	photo.ImageVariants = decodeVariants(variantsJSON)
+
+	return &photo, nil
+}
+
+func (s *sqlPhotoStore) Create(photo *models.RecipePhoto) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if photo.IsCover {
+		if _, err := tx.Exec("UPDATE recipe_photos SET is_cover = FALSE WHERE recipe_id = ?", photo.RecipeID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO recipe_photos (
+			id, recipe_id, user_id, image_path, thumbnail_path, image_variants, width, height, is_cover
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, photo.ID, photo.RecipeID, photo.UserID, photo.ImagePath, photo.ThumbnailPath,
+		encodeVariants(photo.ImageVariants), photo.Width, photo.Height, photo.IsCover)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.QueryRow("SELECT created_at FROM recipe_photos WHERE id = ?", photo.ID).Scan(&photo.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlPhotoStore) Get(id, recipeID string) (*models.RecipePhoto, error) {
+	return scanPhoto(s.db.QueryRow("SELECT "+photoColumns+" FROM recipe_photos WHERE id = ? AND recipe_id = ?", id, recipeID))
+}
+
+func (s *sqlPhotoStore) List(recipeID string) ([]models.RecipePhoto, error) {
+	rows, err := s.db.Query("SELECT "+photoColumns+" FROM recipe_photos WHERE recipe_id = ? ORDER BY created_at, id", recipeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	photos := []models.RecipePhoto{}
+	for rows.Next() {
+		photo, err := scanPhoto(rows)
+		if err != nil {
+			continue
+		}
+		photos = append(photos, *photo)
+	}
+	return photos, rows.Err()
+}
+
+func (s *sqlPhotoStore) ListCovers(userID string) (map[string]models.RecipePhoto, error) {
+	rows, err := s.db.Query("SELECT "+photoColumns+" FROM recipe_photos WHERE user_id = ? AND is_cover = TRUE", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	covers := map[string]models.RecipePhoto{}
+	for rows.Next() {
+		photo, err := scanPhoto(rows)
+		if err != nil {
+			continue
+		}
+		covers[photo.RecipeID] = *photo
+	}
+	return covers, rows.Err()
+}
+
+func (s *sqlPhotoStore) CountByRecipe(recipeID string) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM recipe_photos WHERE recipe_id = ?", recipeID).Scan(&count)
+	return count, err
+}
+
+func (s *sqlPhotoStore) SetCover(id, recipeID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := affectedOrNotFound(tx.Exec("UPDATE recipe_photos SET is_cover = TRUE WHERE id = ? AND recipe_id = ?", id, recipeID)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE recipe_photos SET is_cover = FALSE WHERE recipe_id = ? AND id <> ?", recipeID, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlPhotoStore) Delete(id, recipeID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var isCover bool
+	err = tx.QueryRow("SELECT is_cover FROM recipe_photos WHERE id = ? AND recipe_id = ?", id, recipeID).Scan(&isCover)
+	if err != nil {
+		return notFound(err)
+	}
+	if _, err := tx.Exec("DELETE FROM recipe_photos WHERE id = ?", id); err != nil {
+		return err
+	}
+
+	// Keep a cover while the recipe has photos
+	if isCover {
+		var nextID string
+		err = tx.QueryRow("SELECT id FROM recipe_photos WHERE recipe_id = ? ORDER BY created_at, id LIMIT 1", recipeID).Scan(&nextID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil {
+			if _, err := tx.Exec("UPDATE recipe_photos SET is_cover = TRUE WHERE id = ?", nextID); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlPhotoStore) ListImages(userID string) ([]models.RecipeImage, error) {
+	rows, err := s.db.Query("SELECT recipe_id, image_path, thumbnail_path, COALESCE(image_variants, '[]') FROM recipe_photos WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecipeImages(rows)
+}
+
+func (s *sqlPhotoStore) ListAllImages() ([]models.RecipeImage, error) {
+	rows, err := s.db.Query("SELECT recipe_id, image_path, thumbnail_path, COALESCE(image_variants, '[]') FROM recipe_photos")
+	if err != nil {
+		return nil, err
+	}
+	return scanRecipeImages(rows)
+}
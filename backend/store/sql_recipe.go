@@ -0,0 +1,335 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"chefly/models"
+)
+
+// sqlRecipeStore is the SQL implementation of RecipeStore
+type sqlRecipeStore struct {
+	db *conn
+}
+
+// recipeColumns is the column list scanned by scanRecipe
+const recipeColumns = `id, user_id, title, description, ingredients, steps, cuisine_type, meat_type, difficulty,
+	dietary_tags, COALESCE(allergens, '[]'), cooking_time, is_favorite, image_path, COALESCE(thumbnail_path, ''),
+	COALESCE(image_variants, '[]'), COALESCE(servings, 0), created_at`
+
+// scanRecipe scans a row selected with recipeColumns
+func scanRecipe(row rowScanner) (*models.RecipeDetail, error) {
+	var recipe models.RecipeDetail
+	var ingredientsJSON, stepsJSON, dietaryTagsJSON, allergensJSON, variantsJSON string
+
+	err := row.Scan(&recipe.ID, &recipe.UserID, &recipe.Title, &recipe.Description, &ingredientsJSON, &stepsJSON,
+		&recipe.CuisineType, &recipe.MeatType, &recipe.Difficulty, &dietaryTagsJSON, &allergensJSON,
+		&recipe.CookingTime, &recipe.IsFavorite, &recipe.ImagePath, &recipe.ThumbnailPath,
+		&variantsJSON, &recipe.Servings, &recipe.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	// Parse JSON fields
+	json.Unmarshal([]byte(ingredientsJSON), &recipe.Ingredients)
+	json.Unmarshal([]byte(stepsJSON), &recipe.Steps)
+	json.Unmarshal([]byte(dietaryTagsJSON), &recipe.DietaryTags)
+	json.Unmarshal([]byte(allergensJSON), &recipe.Allergens)
+	recipe.ImageVariants = decodeVariants(variantsJSON)
+
+	return &recipe, nil
+}
+
+func (s *sqlRecipeStore) Create(recipe *models.RecipeDetail) error {
+	// Encode ingredients and steps as JSON
+	ingredientsJSON, err := json.Marshal(recipe.Ingredients)
+	if err != nil {
+		return err
+	}
+
+	stepsJSON, err := json.Marshal(recipe.Steps)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO recipes (
+			id, user_id, title, description, ingredients, steps,
+			cooking_time, difficulty, cuisine_type, meat_type,
+			dietary_tags, is_favorite, image_path, thumbnail_path, image_variants, servings, allergens
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, FALSE, ?, ?, ?, ?, ?)
+	`, recipe.ID, recipe.UserID, recipe.Title, recipe.Description,
+		string(ingredientsJSON), string(stepsJSON),
+		recipe.CookingTime, recipe.Difficulty, recipe.CuisineType,
+		recipe.MeatType, encodeList(recipe.DietaryTags), recipe.ImagePath, recipe.ThumbnailPath,
+		encodeVariants(recipe.ImageVariants), recipe.Servings, encodeList(recipe.Allergens))
+	if err != nil {
+		return err
+	}
+
+	return s.db.QueryRow("SELECT created_at FROM recipes WHERE id = ?", recipe.ID).Scan(&recipe.CreatedAt)
+}
+
+func (s *sqlRecipeStore) Get(id string) (*models.RecipeDetail, error) {
+	return scanRecipe(s.db.QueryRow("SELECT "+recipeColumns+" FROM recipes WHERE id = ?", id))
+}
+
+func (s *sqlRecipeStore) GetForUser(id, userID string) (*models.RecipeDetail, error) {
+	return scanRecipe(s.db.QueryRow("SELECT "+recipeColumns+" FROM recipes WHERE id = ? AND user_id = ?", id, userID))
+}
+
+func (s *sqlRecipeStore) List(userID string, filter models.NutritionFilter) ([]models.RecipeSummary, error) {
+	query := `
+		SELECT r.id, r.title, r.description, r.cuisine_type, r.difficulty, r.cooking_time, r.is_favorite, r.image_path, r.thumbnail_path, COALESCE(r.image_variants, '[]'), r.created_at, n.calories
+		FROM recipes r
+		LEFT JOIN recipe_nutrition n ON n.recipe_id = r.id
+		WHERE r.user_id = ?`
+	args := []interface{}{userID}
+
+	// Nutrition filters only match recipes that have stored nutrition
+	nutritionConditions := []struct {
+		value  *float64
+		clause string
+	}{
+		{filter.MaxCalories, "n.calories <= ?"},
+		{filter.MinProtein, "n.protein >= ?"},
+		{filter.MaxFat, "n.fat <= ?"},
+		{filter.MaxCarbohydrates, "n.carbohydrates <= ?"},
+		{filter.MinFiber, "n.fiber >= ?"},
+		{filter.MaxSodium, "n.sodium <= ?"},
+	}
+	for _, condition := range nutritionConditions {
+		if condition.value != nil {
+			query += " AND " + condition.clause
+			args = append(args, *condition.value)
+		}
+	}
+	query += " ORDER BY r.created_at DESC"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipes := []models.RecipeSummary{}
+	for rows.Next() {
+		var recipe models.RecipeSummary
+		var calories sql.NullFloat64
+		var variantsJSON string
+
+		err := rows.Scan(&recipe.ID, &recipe.Title, &recipe.Description, &recipe.CuisineType, &recipe.Difficulty,
+			&recipe.CookingTime, &recipe.IsFavorite, &recipe.ImagePath, &recipe.ThumbnailPath, &variantsJSON, &recipe.CreatedAt, &calories)
+		if err != nil {
+			continue
+		}
+		recipe.ImageVariants = decodeVariants(variantsJSON)
+
+		if calories.Valid {
+			recipe.Calories = &calories.Float64
+		}
+		recipes = append(recipes, recipe)
+	}
+
+	return recipes, rows.Err()
+}
+
+func (s *sqlRecipeStore) Delete(id, userID string) error {
+	return affectedOrNotFound(s.db.Exec("DELETE FROM recipes WHERE id = ? AND user_id = ?", id, userID))
+}
+
+func (s *sqlRecipeStore) ToggleFavorite(id, userID string) (bool, error) {
+	err := affectedOrNotFound(s.db.Exec(`
+		UPDATE recipes
+		SET is_favorite = NOT is_favorite
+		WHERE id = ? AND user_id = ?
+	`, id, userID))
+	if err != nil {
+		return false, err
+	}
+
+	var isFavorite bool
+	err = s.db.QueryRow("SELECT is_favorite FROM recipes WHERE id = ? AND user_id = ?", id, userID).Scan(&isFavorite)
+	return isFavorite, notFound(err)
+}
+
+func (s *sqlRecipeStore) CountByUser(userID string) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM recipes WHERE user_id = ?", userID).Scan(&count)
+	return count, err
+}
+
+func (s *sqlRecipeStore) CountFavorites(userID string) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM recipes WHERE user_id = ? AND is_favorite = TRUE", userID).Scan(&count)
+	return count, err
+}
+
+func (s *sqlRecipeStore) CountCreatedSince(userID string, since time.Time) (int, error) {
+	var count int
+	err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM recipes WHERE user_id = ? AND created_at >= ?",
+		userID, s.db.timeArg(since),
+	).Scan(&count)
+	return count, err
+}
+
+func (s *sqlRecipeStore) RecentTitles(userID string, since time.Time, limit int) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT title
+		FROM recipes
+		WHERE user_id = ? AND created_at >= ?
+		ORDER BY created_at DESC
+		LIMIT ?
+	`, userID, s.db.timeArg(since), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := []string{}
+	for rows.Next() {
+		var title string
+		if err := rows.Scan(&title); err != nil {
+			continue
+		}
+		titles = append(titles, title)
+	}
+	return titles, rows.Err()
+}
+
+func (s *sqlRecipeStore) ListImages(userID string) ([]models.RecipeImage, error) {
+	rows, err := s.db.Query("SELECT id, COALESCE(image_path, ''), COALESCE(thumbnail_path, ''), COALESCE(image_variants, '[]') FROM recipes WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecipeImages(rows)
+}
+
+func (s *sqlRecipeStore) ListAllImages() ([]models.RecipeImage, error) {
+	rows, err := s.db.Query("SELECT id, COALESCE(image_path, ''), COALESCE(thumbnail_path, ''), COALESCE(image_variants, '[]') FROM recipes")
+	if err != nil {
+		return nil, err
+	}
+	return scanRecipeImages(rows)
+}
+
+func (s *sqlRecipeStore) UpdateImages(id, imagePath, thumbnailPath string, variants []models.ImageVariant) error {
+	return affectedOrNotFound(s.db.Exec("UPDATE recipes SET image_path = ?, thumbnail_path = ?, image_variants = ? WHERE id = ?",
+		imagePath, thumbnailPath, encodeVariants(variants), id))
+}
+
+func (s *sqlRecipeStore) ReplaceImages(id, previousImagePath, imagePath, thumbnailPath string, variants []models.ImageVariant) error {
+	return affectedOrNotFound(s.db.Exec("UPDATE recipes SET image_path = ?, thumbnail_path = ?, image_variants = ? WHERE id = ? AND image_path = ?",
+		imagePath, thumbnailPath, encodeVariants(variants), id, previousImagePath))
+}
+
+// unmanagedImageCondition matches recipes whose image is not held by the image store
+const unmanagedImageCondition = "(image_path LIKE 'data:%' OR image_path LIKE 'http://%' OR image_path LIKE 'https://%')"
+
+func (s *sqlRecipeStore) ListUnmanagedImages(afterID string, limit int) ([]models.RecipeImage, error) {
+	rows, err := s.db.Query(`
+		SELECT id, COALESCE(image_path, ''), COALESCE(thumbnail_path, ''), COALESCE(image_variants, '[]')
+		FROM recipes
+		WHERE id > ? AND `+unmanagedImageCondition+`
+		ORDER BY id
+		LIMIT ?
+	`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecipeImages(rows)
+}
+
+func (s *sqlRecipeStore) CountUnmanagedImages() (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM recipes WHERE " + unmanagedImageCondition).Scan(&count)
+	return count, err
+}
+
+// scanRecipeImages scans rows of recipe id, image path, thumbnail path and image variants
+func scanRecipeImages(rows *sql.Rows) ([]models.RecipeImage, error) {
+	defer rows.Close()
+
+	var images []models.RecipeImage
+	for rows.Next() {
+		var image models.RecipeImage
+		var variantsJSON string
+		if err := rows.Scan(&image.RecipeID, &image.ImagePath, &image.ThumbnailPath, &variantsJSON); err != nil {
+			continue
+		}
+		image.Variants = decodeVariants(variantsJSON)
+		images = append(images, image)
+	}
+	return images, rows.Err()
+}
+
+// encodeVariants encodes image variants as a JSON array
+func encodeVariants(variants []models.ImageVariant) string {
+	if variants == nil {
+		return "[]"
+	}
+	encoded, err := json.Marshal(variants)
+	if err != nil {
+		return "[]"
+	}
+	return string(encoded)
+}
+
+// decodeVariants decodes a JSON array of image variants, returning an empty list on error
+func decodeVariants(value string) []models.ImageVariant {
+	variants := []models.ImageVariant{}
+	json.Unmarshal([]byte(value), &variants)
+	if variants == nil {
+		variants = []models.ImageVariant{}
+	}
+	return variants
+}
+
+func (s *sqlRecipeStore) GetNutrition(recipeID string) (*models.RecipeNutrition, error) {
+	var detailsJSON string
+	err := s.db.QueryRow("SELECT details FROM recipe_nutrition WHERE recipe_id = ?", recipeID).Scan(&detailsJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var nutrition models.RecipeNutrition
+	if err := json.Unmarshal([]byte(detailsJSON), &nutrition); err != nil {
+		return nil, err
+	}
+	return &nutrition, nil
+}
+
+func (s *sqlRecipeStore) SaveNutrition(recipeID string, nutrition *models.RecipeNutrition) error {
+	if nutrition == nil {
+		return nil
+	}
+
+	detailsJSON, err := json.Marshal(nutrition)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO recipe_nutrition (
+			recipe_id, servings, calories, protein, fat, carbohydrates, fiber, sodium, details
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(recipe_id) DO UPDATE SET
+			servings = excluded.servings,
+			calories = excluded.calories,
+			protein = excluded.protein,
+			fat = excluded.fat,
+			carbohydrates = excluded.carbohydrates,
+			fiber = excluded.fiber,
+			sodium = excluded.sodium,
+			details = excluded.details
+	`, recipeID, nutrition.Servings,
+		nutrition.PerServing.Calories, nutrition.PerServing.Protein, nutrition.PerServing.Fat,
+		nutrition.PerServing.Carbohydrates, nutrition.PerServing.Fiber, nutrition.PerServing.Sodium,
+		string(detailsJSON))
+	return err
+}
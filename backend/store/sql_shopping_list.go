@@ -0,0 +1,110 @@
+package store
+
+import (
+	"chefly/models"
+)
+
+// sqlShoppingListStore is the SQL implementation of ShoppingListStore
+type sqlShoppingListStore struct {
+	db *conn
+}
+
+func (s *sqlShoppingListStore) List(userID string) ([]models.ShoppingListItem, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, COALESCE(recipe_id, ''), COALESCE(recipe_title, ''),
+		       ingredient_name, quantity, unit, is_checked, created_at
+		FROM shopping_list_items
+		WHERE user_id = ?
+		ORDER BY is_checked ASC, created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ShoppingListItem{}
+	for rows.Next() {
+		var item models.ShoppingListItem
+		err := rows.Scan(
+			&item.ID,
+			&item.UserID,
+			&item.RecipeID,
+			&item.RecipeTitle,
+			&item.IngredientName,
+			&item.Quantity,
+			&item.Unit,
+			&item.IsChecked,
+			&item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func (s *sqlShoppingListStore) AddItems(items []models.ShoppingListItem) (int, error) {
+	addedCount := 0
+	for _, item := range items {
+		_, err := s.db.Exec(`
+			INSERT INTO shopping_list_items (id, user_id, recipe_id, recipe_title, ingredient_name, quantity, unit)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, item.ID, item.UserID, item.RecipeID, item.RecipeTitle, item.IngredientName, item.Quantity, item.Unit)
+		if err != nil {
+			continue // Skip if fails (e.g., duplicate)
+		}
+		addedCount++
+	}
+	return addedCount, nil
+}
+
+func (s *sqlShoppingListStore) ToggleChecked(id, userID string) error {
+	return affectedOrNotFound(s.db.Exec(`
+		UPDATE shopping_list_items
+		SET is_checked = NOT is_checked
+		WHERE id = ? AND user_id = ?
+	`, id, userID))
+}
+
+func (s *sqlShoppingListStore) Delete(id, userID string) error {
+	return affectedOrNotFound(s.db.Exec(`
+		DELETE FROM shopping_list_items
+		WHERE id = ? AND user_id = ?
+	`, id, userID))
+}
+
+func (s *sqlShoppingListStore) ClearChecked(userID string) (int64, error) {
+	result, err := s.db.Exec(`
+		DELETE FROM shopping_list_items
+		WHERE user_id = ? AND is_checked = TRUE
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqlShoppingListStore) ClearAll(userID string) (int64, error) {
+	result, err := s.db.Exec(`
+		DELETE FROM shopping_list_items
+		WHERE user_id = ?
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqlShoppingListStore) CountByUser(userID string) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM shopping_list_items WHERE user_id = ?", userID).Scan(&count)
+	return count, err
+}
+
+func (s *sqlShoppingListStore) CountUnchecked(userID string) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM shopping_list_items WHERE user_id = ? AND is_checked = FALSE", userID).Scan(&count)
+	return count, err
+}
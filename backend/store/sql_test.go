@@ -0,0 +1,717 @@
+package store_test
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"chefly/database"
+	"chefly/models"
+	"chefly/store"
+)
+
+// testDatabaseURLEnv names a PostgreSQL database the store tests may wipe, see the README
+const testDatabaseURLEnv = "TEST_DATABASE_URL"
+
+// testDatabase is a migrated database the store tests run against
+type testDatabase struct {
+	db       *sql.DB
+	dialect  database.Dialect
+	migrator *database.Migrator
+	stores   *store.Store
+}
+
+// forEachDatabase runs a test against a fresh SQLite database and, when TEST_DATABASE_URL is set, PostgreSQL
+func forEachDatabase(t *testing.T, test func(t *testing.T, tdb *testDatabase)) {
+	t.Run("sqlite", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chefly.db")
+		test(t, openTestDatabase(t, database.SQLite, path))
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		url := os.Getenv(testDatabaseURLEnv)
+		if url == "" {
+			t.Skipf("%s is not set", testDatabaseURLEnv)
+		}
+		test(t, openTestDatabase(t, database.Postgres, url))
+	})
+}
+
+// openTestDatabase connects and migrates a database, the schema is rolled back before and after the test
+func openTestDatabase(t *testing.T, dialect database.Dialect, dsn string) *testDatabase {
+	t.Helper()
+
+	db, err := database.InitDB(dialect, dsn, database.Options{MaxOpenConns: 4, MaxIdleConns: 4, BusyTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	migrator := database.NewMigrator(db, dialect)
+
+	// A previous run against PostgreSQL may have been interrupted
+	if _, err := migrator.Rollback(len(allMigrations(t, migrator))); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if _, err := migrator.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := migrator.Rollback(len(allMigrations(t, migrator))); err != nil {
+			t.Errorf("Rollback: %v", err)
+		}
+		db.Close()
+	})
+
+	return &testDatabase{db: db, dialect: dialect, migrator: migrator, stores: store.New(db, dialect)}
+}
+
+func allMigrations(t *testing.T, migrator *database.Migrator) []database.MigrationStatus {
+	t.Helper()
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	return statuses
+}
+
+// createUser creates a user with an id derived email
+func (tdb *testDatabase) createUser(t *testing.T, id string) *models.User {
+	t.Helper()
+	user := &models.User{ID: id, Email: id + "@example.com", Username: id, PasswordHash: "hash"}
+	if err := tdb.stores.Users.Create(user); err != nil {
+		t.Fatalf("Users.Create(%s): %v", id, err)
+	}
+	return user
+}
+
+// createRecipe creates a recipe with an image path
+func (tdb *testDatabase) createRecipe(t *testing.T, id, userID, imagePath string) *models.RecipeDetail {
+	t.Helper()
+	recipe := &models.RecipeDetail{
+		ID:          id,
+		UserID:      userID,
+		Title:       "Recipe " + id,
+		Description: "A test recipe",
+		Ingredients: []models.Ingredient{{Name: "chicken breast", Quantity: "200", Unit: "g"}},
+		Steps:       []models.CookingStep{{StepNumber: 1, Instruction: "Cook it"}},
+		CookingTime: 30,
+		Difficulty:  "easy",
+		CuisineType: "italian",
+		MeatType:    "chicken",
+		DietaryTags: []string{"high-protein"},
+		Allergens:   []string{},
+		ImagePath:   imagePath,
+		Servings:    2,
+	}
+	if err := tdb.stores.Recipes.Create(recipe); err != nil {
+		t.Fatalf("Recipes.Create(%s): %v", id, err)
+	}
+	return recipe
+}
+
+// tableExists reports whether a table can be queried
+func (tdb *testDatabase) tableExists(table string) bool {
+	rows, err := tdb.db.Query("SELECT 1 FROM " + table + " LIMIT 1")
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+func TestMigrations(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, tdb *testDatabase) {
+		statuses := allMigrations(t, tdb.migrator)
+		if len(statuses) == 0 {
+			t.Fatal("no migrations")
+		}
+		for _, status := range statuses {
+			if !status.Applied || status.Modified {
+				t.Errorf("migration %d (%s): applied=%v modified=%v", status.Version, status.Name, status.Applied, status.Modified)
+			}
+		}
+
+		// Migrating again is a no-op
+		if applied, err := tdb.migrator.Migrate(); err != nil || applied != 0 {
+			t.Errorf("second Migrate = %d, %v, want 0, nil", applied, err)
+		}
+
+		// Data survives rolling back and reapplying the latest migration
+		tdb.createUser(t, "keeper")
+		policy := "5"
+		if err := tdb.stores.Users.SetQuotaPolicy("keeper", models.QuotaText, &policy); err != nil {
+			t.Fatalf("SetQuotaPolicy: %v", err)
+		}
+		tdb.createRecipe(t, "kept-recipe", "keeper", "")
+		if rolledBack, err := tdb.migrator.Rollback(1); err != nil || rolledBack != 1 {
+			t.Fatalf("Rollback(1) = %d, %v", rolledBack, err)
+		}
+		if applied, err := tdb.migrator.Migrate(); err != nil || applied != 1 {
+			t.Fatalf("Migrate = %d, %v, want 1", applied, err)
+		}
+		user, err := tdb.stores.Users.GetByID("keeper")
+		if err != nil {
+			t.Fatalf("GetByID after down/up: %v", err)
+		}
+		if user.TextQuota == nil || *user.TextQuota != "5" {
+			t.Errorf("text quota after down/up = %v, want 5", user.TextQuota)
+		}
+		if count, err := tdb.stores.Generations.CountSince("keeper", models.QuotaText, time.Time{}); err != nil || count != 1 {
+			t.Errorf("text generations after down/up = %d, %v, want the recipe counted once", count, err)
+		}
+
+		// Rolling everything back drops every table
+		if rolledBack, err := tdb.migrator.Rollback(len(statuses)); err != nil || rolledBack != len(statuses) {
+			t.Fatalf("Rollback(all) = %d, %v, want %d", rolledBack, err, len(statuses))
+		}
+		for _, table := range []string{"users", "recipes", "refresh_tokens", "ai_usage", "generation_events"} {
+			if tdb.tableExists(table) {
+				t.Errorf("table %s exists after rolling back all migrations", table)
+			}
+		}
+		if applied, err := tdb.migrator.Migrate(); err != nil || applied != len(statuses) {
+			t.Fatalf("Migrate after full rollback = %d, %v, want %d", applied, err, len(statuses))
+		}
+	})
+}
+
+func TestMigrateReplicasConcurrently(t *testing.T) {
+	url := os.Getenv(testDatabaseURLEnv)
+	if url == "" {
+		t.Skipf("%s is not set", testDatabaseURLEnv)
+	}
+	tdb := openTestDatabase(t, database.Postgres, url)
+	statuses := allMigrations(t, tdb.migrator)
+	if _, err := tdb.migrator.Rollback(len(statuses)); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	// Replicas booting together each migrate through their own connection pool
+	const replicas = 4
+	var wg sync.WaitGroup
+	applied := make(chan int, replicas)
+	errs := make(chan error, replicas)
+	for i := 0; i < replicas; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db, err := database.InitDB(database.Postgres, url, database.Options{MaxOpenConns: 2})
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer db.Close()
+			count, err := database.NewMigrator(db, database.Postgres).Migrate()
+			applied <- count
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(applied)
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Migrate: %v", err)
+		}
+	}
+	total := 0
+	for count := range applied {
+		total += count
+	}
+	if total != len(statuses) {
+		t.Errorf("replicas applied %d migrations in total, want each of the %d once", total, len(statuses))
+	}
+}
+
+func TestUserStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, tdb *testDatabase) {
+		users := tdb.stores.Users
+
+		admin := tdb.createUser(t, "admin")
+		cook := tdb.createUser(t, "cook")
+		if !admin.IsAdmin || cook.IsAdmin {
+			t.Errorf("is_admin = %v, %v, want only the first user to be admin", admin.IsAdmin, cook.IsAdmin)
+		}
+		if admin.CreatedAt.IsZero() {
+			t.Error("created_at was not filled in")
+		}
+		if err := users.Create(&models.User{ID: "dup", Email: "cook@example.com", Username: "dup"}); err == nil {
+			t.Error("creating a user with a taken email succeeded")
+		}
+
+		got, err := users.GetByEmail("cook@example.com")
+		if err != nil || got.ID != "cook" {
+			t.Fatalf("GetByEmail = %v, %v", got, err)
+		}
+		if _, err := users.GetByID("missing"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetByID(missing) error = %v, want ErrNotFound", err)
+		}
+		if exists, err := users.EmailExists("cook@example.com"); err != nil || !exists {
+			t.Errorf("EmailExists = %v, %v, want true", exists, err)
+		}
+		if exists, err := users.EmailExists("nobody@example.com"); err != nil || exists {
+			t.Errorf("EmailExists(nobody) = %v, %v, want false", exists, err)
+		}
+
+		if err := users.UpdateUsername("cook", "chef"); err != nil {
+			t.Fatalf("UpdateUsername: %v", err)
+		}
+		if err := users.UpdateUsername("missing", "chef"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("UpdateUsername(missing) error = %v, want ErrNotFound", err)
+		}
+
+		// Quota policies
+		policy := "10/day,100/month"
+		if err := users.SetQuotaPolicy("cook", models.QuotaImage, &policy); err != nil {
+			t.Fatalf("SetQuotaPolicy: %v", err)
+		}
+		got, _ = users.GetByID("cook")
+		if got.Username != "chef" || got.TextQuota != nil || got.ImageQuota == nil || *got.ImageQuota != policy {
+			t.Errorf("user = %+v, want username chef and only an image quota", got)
+		}
+		if err := users.SetQuotaPolicy("cook", models.QuotaImage, nil); err != nil {
+			t.Fatalf("SetQuotaPolicy(nil): %v", err)
+		}
+		if got, _ = users.GetByID("cook"); got.ImageQuota != nil {
+			t.Errorf("image quota = %v after clearing, want nil", *got.ImageQuota)
+		}
+
+		// Stats
+		tdb.createRecipe(t, "r1", "cook", "")
+		tdb.createRecipe(t, "r2", "cook", "")
+		list, err := users.ListWithStats()
+		if err != nil || len(list) != 2 {
+			t.Fatalf("ListWithStats = %v, %v", list, err)
+		}
+		if list[1].ID != "cook" || list[1].RecipeCount != 2 || list[1].LastRecipeDate == nil {
+			t.Errorf("cook stats = %+v, want 2 recipes and a last recipe date", list[1])
+		}
+		stats, err := users.AdminStats(time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatalf("AdminStats: %v", err)
+		}
+		if stats.TotalUsers != 2 || stats.TotalRecipes != 2 || stats.RecentRegistrations != 2 ||
+			stats.MostActiveUser == nil || *stats.MostActiveUser != "chef" {
+			t.Errorf("admin stats = %+v", stats)
+		}
+
+		// Digest subscription
+		if err := users.SetDigestOptIn("cook", true, "token-1"); err != nil {
+			t.Fatalf("SetDigestOptIn: %v", err)
+		}
+		if userID, err := users.FindByUnsubscribeToken("token-1"); err != nil || userID != "cook" {
+			t.Errorf("FindByUnsubscribeToken = %q, %v", userID, err)
+		}
+		if recipients, err := users.ListDigestRecipients(time.Now()); err != nil || len(recipients) != 1 || recipients[0] != "cook" {
+			t.Errorf("ListDigestRecipients = %v, %v, want [cook]", recipients, err)
+		}
+		// Only one of two replicas claims the digest, a released claim can be claimed again
+		claimedAt := time.Now().UTC().Truncate(time.Second)
+		sentBefore := claimedAt.Add(-time.Hour)
+		if claimed, err := users.ClaimDigest("cook", claimedAt, sentBefore); err != nil || !claimed {
+			t.Fatalf("ClaimDigest = %v, %v, want claimed", claimed, err)
+		}
+		if claimed, err := users.ClaimDigest("cook", claimedAt, sentBefore); err != nil || claimed {
+			t.Errorf("second ClaimDigest = %v, %v, want not claimed", claimed, err)
+		}
+		if err := users.ReleaseDigestClaim("cook", claimedAt, nil); err != nil {
+			t.Fatalf("ReleaseDigestClaim: %v", err)
+		}
+		if claimed, err := users.ClaimDigest("cook", claimedAt, sentBefore); err != nil || !claimed {
+			t.Errorf("ClaimDigest after releasing = %v, %v, want claimed", claimed, err)
+		}
+		if claimed, err := users.ClaimDigest("admin", claimedAt, sentBefore); err != nil || claimed {
+			t.Errorf("ClaimDigest of an opted-out user = %v, %v, want not claimed", claimed, err)
+		}
+		if recipients, _ := users.ListDigestRecipients(sentBefore); len(recipients) != 0 {
+			t.Errorf("recipients = %v after sending, want none", recipients)
+		}
+		settings, err := users.GetDigestSettings("cook")
+		if err != nil || !settings.OptIn || settings.LastSentAt == nil || !settings.LastSentAt.Equal(claimedAt) {
+			t.Errorf("digest settings = %+v, %v, want sent at %v", settings, err, claimedAt)
+		}
+
+		// Preferences
+		if prefs, err := users.GetPreferences("cook"); err != nil || prefs != nil {
+			t.Errorf("GetPreferences before saving = %v, %v, want nil", prefs, err)
+		}
+		saved := &models.UserPreferences{Allergies: []string{"peanuts"}, HouseholdSize: 4, SkillLevel: "beginner"}
+		for i := 0; i < 2; i++ {
+			if err := users.SavePreferences("cook", saved); err != nil {
+				t.Fatalf("SavePreferences: %v", err)
+			}
+		}
+		prefs, err := users.GetPreferences("cook")
+		if err != nil || prefs == nil || len(prefs.Allergies) != 1 || prefs.HouseholdSize != 4 || len(prefs.Diets) != 0 {
+			t.Errorf("GetPreferences = %+v, %v", prefs, err)
+		}
+
+		// Deleting a user removes their recipes
+		if err := users.Delete("cook"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := tdb.stores.Recipes.Get("r1"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("recipe of a deleted user: error = %v, want ErrNotFound", err)
+		}
+		if err := users.Delete("cook"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("second Delete error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestRecipeStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, tdb *testDatabase) {
+		recipes := tdb.stores.Recipes
+		tdb.createUser(t, "cook")
+		tdb.createUser(t, "other")
+
+		created := tdb.createRecipe(t, "r1", "cook", "/images/r1.jpg")
+		if created.CreatedAt.IsZero() {
+			t.Error("created_at was not filled in")
+		}
+		tdb.createRecipe(t, "r2", "cook", "data:image/png;base64,AAAA")
+		tdb.createRecipe(t, "r3", "cook", "https://example.com/r3.png")
+
+		got, err := recipes.GetForUser("r1", "cook")
+		if err != nil {
+			t.Fatalf("GetForUser: %v", err)
+		}
+		if got.Title != "Recipe r1" || len(got.Ingredients) != 1 || len(got.Steps) != 1 ||
+			len(got.DietaryTags) != 1 || got.Servings != 2 || got.IsFavorite {
+			t.Errorf("recipe = %+v", got)
+		}
+		if _, err := recipes.GetForUser("r1", "other"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetForUser of another user's recipe: error = %v, want ErrNotFound", err)
+		}
+		if _, err := recipes.Get("r1"); err != nil {
+			t.Errorf("Get: %v", err)
+		}
+
+		// Favorites
+		if favorite, err := recipes.ToggleFavorite("r1", "cook"); err != nil || !favorite {
+			t.Errorf("ToggleFavorite = %v, %v, want true", favorite, err)
+		}
+		if _, err := recipes.ToggleFavorite("r1", "other"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("ToggleFavorite by another user: error = %v, want ErrNotFound", err)
+		}
+		if count, err := recipes.CountFavorites("cook"); err != nil || count != 1 {
+			t.Errorf("CountFavorites = %d, %v, want 1", count, err)
+		}
+
+		// Counts use time arguments
+		if count, err := recipes.CountByUser("cook"); err != nil || count != 3 {
+			t.Errorf("CountByUser = %d, %v, want 3", count, err)
+		}
+		if count, err := recipes.CountCreatedSince("cook", time.Now().Add(-time.Hour)); err != nil || count != 3 {
+			t.Errorf("CountCreatedSince(1h ago) = %d, %v, want 3", count, err)
+		}
+		if count, err := recipes.CountCreatedSince("cook", time.Now().Add(time.Hour)); err != nil || count != 0 {
+			t.Errorf("CountCreatedSince(in 1h) = %d, %v, want 0", count, err)
+		}
+		if titles, err := recipes.RecentTitles("cook", time.Now().Add(-time.Hour), 2); err != nil || len(titles) != 2 {
+			t.Errorf("RecentTitles = %v, %v, want 2 titles", titles, err)
+		}
+
+		// Nutrition and filters
+		nutrition := &models.RecipeNutrition{Servings: 2, PerServing: models.NutritionFacts{Calories: 450, Protein: 40}}
+		if err := recipes.SaveNutrition("r1", nutrition); err != nil {
+			t.Fatalf("SaveNutrition: %v", err)
+		}
+		if stored, err := recipes.GetNutrition("r1"); err != nil || stored == nil || stored.PerServing.Calories != 450 {
+			t.Errorf("GetNutrition = %+v, %v", stored, err)
+		}
+		if stored, err := recipes.GetNutrition("r2"); err != nil || stored != nil {
+			t.Errorf("GetNutrition without nutrition = %+v, %v, want nil", stored, err)
+		}
+		all, err := recipes.List("cook", models.NutritionFilter{})
+		if err != nil || len(all) != 3 {
+			t.Fatalf("List = %v, %v, want 3 recipes", all, err)
+		}
+		maxCalories, minProtein := 500.0, 50.0
+		if filtered, err := recipes.List("cook", models.NutritionFilter{MaxCalories: &maxCalories}); err != nil || len(filtered) != 1 ||
+			filtered[0].ID != "r1" || filtered[0].Calories == nil {
+			t.Errorf("List(max 500 kcal) = %+v, %v, want r1 with calories", filtered, err)
+		}
+		if filtered, err := recipes.List("cook", models.NutritionFilter{MinProtein: &minProtein}); err != nil || len(filtered) != 0 {
+			t.Errorf("List(min 50 g protein) = %+v, %v, want none", filtered, err)
+		}
+
+		// Images
+		if count, err := recipes.CountUnmanagedImages(); err != nil || count != 2 {
+			t.Errorf("CountUnmanagedImages = %d, %v, want 2", count, err)
+		}
+		unmanaged, err := recipes.ListUnmanagedImages("", 1)
+		if err != nil || len(unmanaged) != 1 || unmanaged[0].RecipeID != "r2" {
+			t.Fatalf("ListUnmanagedImages = %+v, %v, want r2 first", unmanaged, err)
+		}
+		if next, err := recipes.ListUnmanagedImages("r2", 10); err != nil || len(next) != 1 || next[0].RecipeID != "r3" {
+			t.Errorf("ListUnmanagedImages after r2 = %+v, %v, want r3", next, err)
+		}
+		variants := []models.ImageVariant{{URL: "/images/r2-400.webp", Width: 400, Format: "webp"}}
+		if err := recipes.UpdateImages("r2", "/images/r2.jpg", "/images/r2-thumb.jpg", variants); err != nil {
+			t.Fatalf("UpdateImages: %v", err)
+		}
+		images, err := recipes.ListImages("cook")
+		if err != nil || len(images) != 3 {
+			t.Fatalf("ListImages = %v, %v", images, err)
+		}
+		for _, image := range images {
+			if image.RecipeID == "r2" && (image.ImagePath != "/images/r2.jpg" || len(image.Variants) != 1) {
+				t.Errorf("r2 images = %+v after UpdateImages", image)
+			}
+		}
+		if count, _ := recipes.CountUnmanagedImages(); count != 1 {
+			t.Errorf("CountUnmanagedImages after update = %d, want 1", count)
+		}
+		if err := recipes.ReplaceImages("r3", "https://example.com/old.png", "/images/r3.jpg", "", nil); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("ReplaceImages of a changed image: error = %v, want ErrNotFound", err)
+		}
+		if err := recipes.ReplaceImages("r3", "https://example.com/r3.png", "/images/r3.jpg", "/images/r3-thumb.jpg", nil); err != nil {
+			t.Errorf("ReplaceImages: %v", err)
+		}
+		if got, _ := recipes.Get("r3"); got == nil || got.ImagePath != "/images/r3.jpg" {
+			t.Errorf("r3 after ReplaceImages = %+v", got)
+		}
+
+		// Delete
+		if err := recipes.Delete("r1", "other"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Delete by another user: error = %v, want ErrNotFound", err)
+		}
+		if err := recipes.Delete("r1", "cook"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if stored, err := recipes.GetNutrition("r1"); err != nil || stored != nil {
+			t.Errorf("nutrition of a deleted recipe = %+v, %v, want nil", stored, err)
+		}
+	})
+}
+
+func TestTokenStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, tdb *testDatabase) {
+		tokens := tdb.stores.Tokens
+		tdb.createUser(t, "cook")
+
+		now := time.Now().UTC()
+		for _, token := range []*models.RefreshToken{
+			{ID: "t1", UserID: "cook", Token: "valid", ExpiresAt: now.Add(time.Hour)},
+			{ID: "t2", UserID: "cook", Token: "expired", ExpiresAt: now.Add(-time.Hour)},
+			{ID: "t3", UserID: "cook", Token: "revoked", ExpiresAt: now.Add(time.Hour)},
+		} {
+			if err := tokens.Create(token); err != nil {
+				t.Fatalf("Create(%s): %v", token.ID, err)
+			}
+		}
+
+		got, err := tokens.Get("valid")
+		if err != nil || got.ID != "t1" || got.UserID != "cook" || got.Revoked {
+			t.Fatalf("Get = %+v, %v", got, err)
+		}
+		if !got.ExpiresAt.Round(time.Second).Equal(now.Add(time.Hour).Round(time.Second)) {
+			t.Errorf("expires_at = %v, want %v", got.ExpiresAt, now.Add(time.Hour))
+		}
+		if _, err := tokens.Get("missing"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+		}
+
+		if err := tokens.Revoke("t3"); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+		if got, _ := tokens.Get("revoked"); got == nil || !got.Revoked {
+			t.Errorf("revoked token = %+v, want revoked", got)
+		}
+		if err := tokens.Revoke("missing"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Revoke(missing) error = %v, want ErrNotFound", err)
+		}
+		if existed, err := tokens.RevokeByToken("nope"); err != nil || existed {
+			t.Errorf("RevokeByToken(nope) = %v, %v, want false", existed, err)
+		}
+
+		// Expired and revoked tokens are removed, the valid one stays
+		if deleted, err := tokens.DeleteExpired(); err != nil || deleted != 2 {
+			t.Errorf("DeleteExpired = %d, %v, want 2", deleted, err)
+		}
+		if _, err := tokens.Get("valid"); err != nil {
+			t.Errorf("valid token was deleted: %v", err)
+		}
+		if existed, err := tokens.RevokeByToken("valid"); err != nil || !existed {
+			t.Errorf("RevokeByToken(valid) = %v, %v, want true", existed, err)
+		}
+	})
+}
+
+func TestAIUsageStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, tdb *testDatabase) {
+		usage := tdb.stores.AIUsage
+		tdb.createUser(t, "cook")
+
+		cookID := "cook"
+		cost := func(value float64) *float64 { return &value }
+		for _, record := range []models.AIUsage{
+			{UserID: &cookID, Provider: "anthropic", Model: "claude-sonnet-4", Operation: "recipe", InputTokens: 1000, OutputTokens: 500, CostUSD: cost(0.0105), LatencyMS: 100},
+			{UserID: &cookID, Provider: "anthropic", Model: "claude-sonnet-4", Operation: "nutrition", InputTokens: 200, OutputTokens: 100, CostUSD: cost(0.0021), LatencyMS: 300},
+			{UserID: &cookID, Provider: "openai", Model: "dall-e-3", Operation: "image", ImageCount: 1, ImageSize: "1024x1024", CostUSD: cost(0.04)},
+			{Provider: "stable-diffusion", Model: "sd-xl", Operation: "image", ImageCount: 1},
+		} {
+			record := record
+			if err := usage.Record(&record); err != nil {
+				t.Fatalf("Record: %v", err)
+			}
+		}
+
+		since := time.Now().Add(-time.Hour)
+		totals, err := usage.Totals(since)
+		if err != nil {
+			t.Fatalf("Totals: %v", err)
+		}
+		if totals.Calls != 4 || totals.InputTokens != 1200 || totals.OutputTokens != 600 || totals.Images != 2 ||
+			totals.CostUSD != 0.0526 || totals.UnpricedCalls != 1 || totals.AvgLatencyMS != 100 {
+			t.Errorf("totals = %+v", totals)
+		}
+		if future, err := usage.Totals(time.Now().Add(time.Hour)); err != nil || future.Calls != 0 {
+			t.Errorf("Totals(in 1h) = %+v, %v, want no calls", future, err)
+		}
+
+		days, err := usage.ByDay(since)
+		if err != nil || len(days) == 0 || len(days) > 2 {
+			t.Fatalf("ByDay = %+v, %v, want today (and yesterday around midnight)", days, err)
+		}
+		if last := days[len(days)-1]; len(last.Date) != len("2006-01-02") {
+			t.Errorf("day = %q, want YYYY-MM-DD", last.Date)
+		}
+
+		byUser, err := usage.ByUser(since, 10)
+		if err != nil || len(byUser) != 2 {
+			t.Fatalf("ByUser = %+v, %v, want the cook and calls without a user", byUser, err)
+		}
+		if byUser[0].UserID != "cook" || byUser[0].Username != "cook" || byUser[0].Calls != 3 {
+			t.Errorf("most expensive user = %+v, want cook with 3 calls", byUser[0])
+		}
+
+		byModel, err := usage.ByModel(since)
+		if err != nil || len(byModel) != 3 || byModel[0].Model != "dall-e-3" {
+			t.Errorf("ByModel = %+v, %v, want 3 models with dall-e-3 first", byModel, err)
+		}
+
+		// Usage outlives the user
+		if err := tdb.stores.Users.Delete("cook"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if totals, err := usage.Totals(since); err != nil || totals.Calls != 4 {
+			t.Errorf("Totals after deleting the user = %+v, %v, want 4 calls", totals, err)
+		}
+	})
+}
+
+func TestGenerationStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, tdb *testDatabase) {
+		generations := tdb.stores.Generations
+		tdb.createUser(t, "cook")
+		tdb.createRecipe(t, "r1", "cook", "")
+
+		for _, kind := range []string{models.QuotaText, models.QuotaText, models.QuotaImage} {
+			if err := generations.Record("cook", kind, "r1"); err != nil {
+				t.Fatalf("Record(%s): %v", kind, err)
+			}
+		}
+		if err := generations.Record("missing", models.QuotaText, ""); err == nil {
+			t.Error("recording a generation of an unknown user succeeded")
+		}
+
+		since := time.Now().Add(-time.Hour)
+		if count, err := generations.CountSince("cook", models.QuotaText, since); err != nil || count != 2 {
+			t.Errorf("CountSince(text) = %d, %v, want 2", count, err)
+		}
+		if count, err := generations.CountSince("cook", models.QuotaImage, since); err != nil || count != 1 {
+			t.Errorf("CountSince(image) = %d, %v, want 1", count, err)
+		}
+		if count, err := generations.CountSince("cook", models.QuotaText, time.Now().Add(time.Hour)); err != nil || count != 0 {
+			t.Errorf("CountSince(in 1h) = %d, %v, want 0", count, err)
+		}
+
+		oldest, err := generations.NthSince("cook", models.QuotaText, since, 0)
+		if err != nil {
+			t.Fatalf("NthSince(0): %v", err)
+		}
+		if oldest.Before(since) || oldest.After(time.Now().Add(time.Minute)) {
+			t.Errorf("oldest generation at %v, want within the last hour", oldest)
+		}
+		if _, err := generations.NthSince("cook", models.QuotaText, since, 2); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("NthSince(2) error = %v, want ErrNotFound", err)
+		}
+
+		// Reservations count against the limits they are checked with
+		limits := []store.GenerationLimit{{Limit: 3, Since: since}, {Limit: 10}}
+		reservation, err := generations.Reserve("cook", models.QuotaText, "r2", limits)
+		if err != nil {
+			t.Fatalf("Reserve: %v", err)
+		}
+		if _, err := generations.Reserve("cook", models.QuotaText, "r3", limits); !errors.Is(err, store.ErrLimitReached) {
+			t.Errorf("Reserve beyond the limit: error = %v, want ErrLimitReached", err)
+		}
+		if _, err := generations.Reserve("missing", models.QuotaText, "", nil); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Reserve of an unknown user: error = %v, want ErrNotFound", err)
+		}
+		if err := generations.Release(reservation); err != nil {
+			t.Fatalf("Release: %v", err)
+		}
+		if err := generations.Release(reservation); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("second Release error = %v, want ErrNotFound", err)
+		}
+		if count, _ := generations.CountSince("cook", models.QuotaText, since); count != 2 {
+			t.Errorf("CountSince after releasing = %d, want 2", count)
+		}
+
+		// Deleting the recipe keeps its generations, deleting the user removes them
+		if err := tdb.stores.Recipes.Delete("r1", "cook"); err != nil {
+			t.Fatalf("Recipes.Delete: %v", err)
+		}
+		if count, _ := generations.CountSince("cook", models.QuotaText, since); count != 2 {
+			t.Errorf("CountSince after deleting the recipe = %d, want 2", count)
+		}
+		if err := tdb.stores.Users.Delete("cook"); err != nil {
+			t.Fatalf("Users.Delete: %v", err)
+		}
+		if count, _ := generations.CountSince("cook", models.QuotaText, time.Time{}); count != 0 {
+			t.Errorf("CountSince after deleting the user = %d, want 0", count)
+		}
+	})
+}
+
+func TestGenerationStoreReserveConcurrently(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, tdb *testDatabase) {
+		tdb.createUser(t, "cook")
+		limits := []store.GenerationLimit{{Limit: 3, Since: time.Now().Add(-time.Hour)}}
+
+		const requests = 12
+		var wg sync.WaitGroup
+		errs := make(chan error, requests)
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := tdb.stores.Generations.Reserve("cook", models.QuotaImage, "", limits)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		reserved := 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				reserved++
+			case !errors.Is(err, store.ErrLimitReached):
+				t.Errorf("Reserve: %v", err)
+			}
+		}
+		count, err := tdb.stores.Generations.CountSince("cook", models.QuotaImage, time.Time{})
+		if reserved != 3 || err != nil || count != 3 {
+			t.Errorf("%d reservations succeeded and %d were recorded (%v), want 3", reserved, count, err)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"chefly/models"
+)
+
+// sqlTokenStore is the SQL implementation of TokenStore
+type sqlTokenStore struct {
+	db *conn
+}
+
+func (s *sqlTokenStore) Create(token *models.RefreshToken) error {
+	_, err := s.db.Exec(`
+		INSERT INTO refresh_tokens (id, user_id, token, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, token.ID, token.UserID, token.Token, token.ExpiresAt)
+	return err
+}
+
+func (s *sqlTokenStore) Get(token string) (*models.RefreshToken, error) {
+	var record models.RefreshToken
+	err := s.db.QueryRow(`
+		SELECT id, user_id, token, expires_at, created_at, revoked
+		FROM refresh_tokens
+		WHERE token = ?
+	`, token).Scan(
+		&record.ID,
+		&record.UserID,
+		&record.Token,
+		&record.ExpiresAt,
+		&record.CreatedAt,
+		&record.Revoked,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &record, nil
+}
+
+func (s *sqlTokenStore) Revoke(id string) error {
+	return affectedOrNotFound(s.db.Exec("UPDATE refresh_tokens SET revoked = TRUE WHERE id = ?", id))
+}
+
+func (s *sqlTokenStore) RevokeByToken(token string) (bool, error) {
+	result, err := s.db.Exec("UPDATE refresh_tokens SET revoked = TRUE WHERE token = ?", token)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+func (s *sqlTokenStore) DeleteExpired() (int64, error) {
+	result, err := s.db.Exec(`
+		DELETE FROM refresh_tokens
+		WHERE revoked = TRUE OR expires_at < CURRENT_TIMESTAMP
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,362 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"chefly/models"
+)
+
+// sqlUserStore is the SQL implementation of UserStore
+type sqlUserStore struct {
+	db *conn
+}
+
+// userColumns is the column list scanned by scanUser
+const userColumns = "id, email, password_hash, username, is_admin, text_quota, image_quota, created_at, updated_at"
+
+// scanUser scans a row selected with userColumns
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var textQuota, imageQuota sql.NullString
+	var updatedAt sql.NullTime
+
+	err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Username, &user.IsAdmin, &textQuota, &imageQuota, &user.CreatedAt, &updatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	user.UpdatedAt = user.CreatedAt
+	if updatedAt.Valid {
+		user.UpdatedAt = updatedAt.Time
+	}
+	user.TextQuota = nullStringPtr(textQuota)
+	user.ImageQuota = nullStringPtr(imageQuota)
+	return &user, nil
+}
+
+func (s *sqlUserStore) Create(user *models.User) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// First user becomes admin
+	var userCount int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users").Scan(&userCount); err != nil {
+		return err
+	}
+	user.IsAdmin = userCount == 0
+
+	_, err = tx.Exec(
+		"INSERT INTO users (id, email, password_hash, username, is_admin) VALUES (?, ?, ?, ?, ?)",
+		user.ID, user.Email, user.PasswordHash, user.Username, user.IsAdmin,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.QueryRow("SELECT created_at, updated_at FROM users WHERE id = ?", user.ID).Scan(&user.CreatedAt, &user.UpdatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlUserStore) GetByID(id string) (*models.User, error) {
+	return scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id))
+}
+
+func (s *sqlUserStore) GetByEmail(email string) (*models.User, error) {
+	return scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE email = ?", email))
+}
+
+func (s *sqlUserStore) EmailExists(email string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)", email).Scan(&exists)
+	return exists, err
+}
+
+func (s *sqlUserStore) UpdateUsername(id, username string) error {
+	return affectedOrNotFound(s.db.Exec(
+		"UPDATE users SET username = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		username, id,
+	))
+}
+
+func (s *sqlUserStore) Delete(id string) error {
+	// CASCADE removes recipes, shopping items, tokens and preferences
+	return affectedOrNotFound(s.db.Exec("DELETE FROM users WHERE id = ?", id))
+}
+
+func (s *sqlUserStore) SetQuotaPolicy(id, kind string, policy *string) error {
+	column := "text_quota"
+	if kind == models.QuotaImage {
+		column = "image_quota"
+	}
+	// A nil policy stores NULL (use the global policy)
+	return affectedOrNotFound(s.db.Exec("UPDATE users SET "+column+" = ? WHERE id = ?", policy, id))
+}
+
+func (s *sqlUserStore) ListWithStats() ([]models.UserWithStats, error) {
+	// All users with recipe count, shopping items, last recipe date, and quota policies
+	rows, err := s.db.Query(`
+		SELECT
+			u.id,
+			u.email,
+			u.username,
+			u.is_admin,
+			u.created_at,
+			u.text_quota,
+			u.image_quota,
+			COALESCE(COUNT(DISTINCT r.id), 0) as recipe_count,
+			COALESCE(COUNT(DISTINCT s.id), 0) as shopping_items,
+			MAX(r.created_at) as last_recipe_date
+		FROM users u
+		LEFT JOIN recipes r ON u.id = r.user_id
+		LEFT JOIN shopping_list_items s ON u.id = s.user_id
+		GROUP BY u.id, u.email, u.username, u.is_admin, u.created_at, u.text_quota, u.image_quota
+		ORDER BY u.created_at ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.UserWithStats{}
+	for rows.Next() {
+		var user models.UserWithStats
+		var lastRecipeDate sql.NullString
+		var textQuota, imageQuota sql.NullString
+
+		err := rows.Scan(
+			&user.ID,
+			&user.Email,
+			&user.Username,
+			&user.IsAdmin,
+			&user.CreatedAt,
+			&textQuota,
+			&imageQuota,
+			&user.RecipeCount,
+			&user.ShoppingItems,
+			&lastRecipeDate,
+		)
+		if err != nil {
+			continue
+		}
+
+		if lastRecipeDate.Valid {
+			user.LastRecipeDate = &lastRecipeDate.String
+		}
+		user.TextQuota = nullStringPtr(textQuota)
+		user.ImageQuota = nullStringPtr(imageQuota)
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (s *sqlUserStore) AdminStats(registeredSince time.Time) (*models.AdminStats, error) {
+	var stats models.AdminStats
+
+	// Total users
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&stats.TotalUsers); err != nil {
+		return nil, err
+	}
+
+	// Total recipes
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM recipes").Scan(&stats.TotalRecipes); err != nil {
+		stats.TotalRecipes = 0
+	}
+
+	// Total shopping items
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM shopping_list_items").Scan(&stats.TotalShoppingItems); err != nil {
+		stats.TotalShoppingItems = 0
+	}
+
+	// Average recipes per user
+	if stats.TotalUsers > 0 {
+		stats.AverageRecipesUser = float64(stats.TotalRecipes) / float64(stats.TotalUsers)
+	}
+
+	// Most active user (user with most recipes)
+	var mostActiveUser sql.NullString
+	err := s.db.QueryRow(`
+		SELECT u.username, COUNT(r.id) as recipe_count
+		FROM users u
+		LEFT JOIN recipes r ON u.id = r.user_id
+		GROUP BY u.id, u.username
+		ORDER BY recipe_count DESC
+		LIMIT 1
+	`).Scan(&mostActiveUser, &stats.MostActiveUserCount)
+	if err == nil && mostActiveUser.Valid {
+		stats.MostActiveUser = &mostActiveUser.String
+	}
+
+	// Recent registrations
+	err = s.db.QueryRow(
+		"SELECT COUNT(*) FROM users WHERE created_at >= ?",
+		s.db.timeArg(registeredSince),
+	).Scan(&stats.RecentRegistrations)
+	if err != nil {
+		stats.RecentRegistrations = 0
+	}
+
+	// First user registration date
+	if err := s.db.QueryRow("SELECT MIN(created_at) FROM users").Scan(&stats.FirstUserDate); err != nil {
+		stats.FirstUserDate = time.Now()
+	}
+
+	return &stats, nil
+}
+
+func (s *sqlUserStore) GetDigestSettings(id string) (*models.DigestSettings, error) {
+	var optIn bool
+	var lastSentAt sql.NullTime
+	err := s.db.QueryRow(
+		"SELECT digest_opt_in, digest_last_sent_at FROM users WHERE id = ?",
+		id,
+	).Scan(&optIn, &lastSentAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	settings := &models.DigestSettings{OptIn: optIn}
+	if lastSentAt.Valid {
+		settings.LastSentAt = &lastSentAt.Time
+	}
+	return settings, nil
+}
+
+func (s *sqlUserStore) SetDigestOptIn(id string, optIn bool, unsubscribeToken string) error {
+	if unsubscribeToken != "" {
+		return affectedOrNotFound(s.db.Exec(
+			"UPDATE users SET digest_opt_in = ?, digest_unsubscribe_token = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+			optIn, unsubscribeToken, id,
+		))
+	}
+	return affectedOrNotFound(s.db.Exec(
+		"UPDATE users SET digest_opt_in = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		optIn, id,
+	))
+}
+
+func (s *sqlUserStore) GetUnsubscribeToken(id string) (string, error) {
+	var token sql.NullString
+	if err := s.db.QueryRow("SELECT digest_unsubscribe_token FROM users WHERE id = ?", id).Scan(&token); err != nil {
+		return "", notFound(err)
+	}
+	return token.String, nil
+}
+
+func (s *sqlUserStore) SetUnsubscribeToken(id, token string) error {
+	return affectedOrNotFound(s.db.Exec("UPDATE users SET digest_unsubscribe_token = ? WHERE id = ?", token, id))
+}
+
+func (s *sqlUserStore) FindByUnsubscribeToken(token string) (string, error) {
+	var userID string
+	if err := s.db.QueryRow("SELECT id FROM users WHERE digest_unsubscribe_token = ?", token).Scan(&userID); err != nil {
+		return "", notFound(err)
+	}
+	return userID, nil
+}
+
+func (s *sqlUserStore) ListDigestRecipients(sentBefore time.Time) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT id
+		FROM users
+		WHERE digest_opt_in = TRUE
+		  AND (digest_last_sent_at IS NULL OR digest_last_sent_at < ?)
+	`, s.db.timeArg(sentBefore))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+func (s *sqlUserStore) ClaimDigest(id string, claimedAt, sentBefore time.Time) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE users SET digest_last_sent_at = ?
+		WHERE id = ?
+		  AND digest_opt_in = TRUE
+		  AND (digest_last_sent_at IS NULL OR digest_last_sent_at < ?)
+	`, s.db.timeArg(claimedAt), id, s.db.timeArg(sentBefore))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (s *sqlUserStore) ReleaseDigestClaim(id string, claimedAt time.Time, previous *time.Time) error {
+	var previousArg interface{}
+	if previous != nil {
+		previousArg = s.db.timeArg(*previous)
+	}
+	return affectedOrNotFound(s.db.Exec(
+		"UPDATE users SET digest_last_sent_at = ? WHERE id = ? AND digest_last_sent_at = ?",
+		previousArg, id, s.db.timeArg(claimedAt),
+	))
+}
+
+func (s *sqlUserStore) GetPreferences(id string) (*models.UserPreferences, error) {
+	var allergiesJSON, dietsJSON, dislikedJSON, cuisinesJSON, equipmentJSON string
+	var prefs models.UserPreferences
+
+	err := s.db.QueryRow(`
+		SELECT allergies, diets, disliked_ingredients, preferred_cuisines,
+		       household_size, skill_level, kitchen_equipment, updated_at
+		FROM user_preferences
+		WHERE user_id = ?
+	`, id).Scan(&allergiesJSON, &dietsJSON, &dislikedJSON, &cuisinesJSON,
+		&prefs.HouseholdSize, &prefs.SkillLevel, &equipmentJSON, &prefs.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefs.Allergies = decodeList(allergiesJSON)
+	prefs.Diets = decodeList(dietsJSON)
+	prefs.DislikedIngredients = decodeList(dislikedJSON)
+	prefs.PreferredCuisines = decodeList(cuisinesJSON)
+	prefs.KitchenEquipment = decodeList(equipmentJSON)
+
+	return &prefs, nil
+}
+
+func (s *sqlUserStore) SavePreferences(id string, prefs *models.UserPreferences) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_preferences (
+			user_id, allergies, diets, disliked_ingredients, preferred_cuisines,
+			household_size, skill_level, kitchen_equipment, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id) DO UPDATE SET
+			allergies = excluded.allergies,
+			diets = excluded.diets,
+			disliked_ingredients = excluded.disliked_ingredients,
+			preferred_cuisines = excluded.preferred_cuisines,
+			household_size = excluded.household_size,
+			skill_level = excluded.skill_level,
+			kitchen_equipment = excluded.kitchen_equipment,
+			updated_at = CURRENT_TIMESTAMP
+	`, id, encodeList(prefs.Allergies), encodeList(prefs.Diets), encodeList(prefs.DislikedIngredients),
+		encodeList(prefs.PreferredCuisines), prefs.HouseholdSize, prefs.SkillLevel, encodeList(prefs.KitchenEquipment))
+	return err
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"chefly/models"
+)
+
+// ErrNotFound is returned when the requested record does not exist (or belongs to another user)
+var ErrNotFound = errors.New("record not found")
+
+// ErrLimitReached is returned by GenerationStore.Reserve when a generation limit is reached
+var ErrLimitReached = errors.New("generation limit reached")
+
+// Store groups the data stores used by handlers and services
+type Store struct {
+	Users        UserStore
+	Recipes      RecipeStore
+	Photos       PhotoStore
+	ShoppingList ShoppingListStore
+	Tokens       TokenStore
+	AIUsage      AIUsageStore
+	Generations  GenerationStore
+}
+
+// UserStore manages user accounts and their settings
+type UserStore interface {
+	// Create inserts a new user, the first user ever created becomes admin
+	Create(user *models.User) error
+	GetByID(id string) (*models.User, error)
+	GetByEmail(email string) (*models.User, error)
+	EmailExists(email string) (bool, error)
+	UpdateUsername(id, username string) error
+	Delete(id string) error
+
+	// SetQuotaPolicy sets a personal quota policy of a generation kind (nil = use the global policy)
+	SetQuotaPolicy(id, kind string, policy *string) error
+	ListWithStats() ([]models.UserWithStats, error)
+	AdminStats(registeredSince time.Time) (*models.AdminStats, error)
+
+	GetDigestSettings(id string) (*models.DigestSettings, error)
+	// SetDigestOptIn updates the subscription, a non-empty token replaces the unsubscribe token
+	SetDigestOptIn(id string, optIn bool, unsubscribeToken string) error
+	GetUnsubscribeToken(id string) (string, error)
+	SetUnsubscribeToken(id, token string) error
+	FindByUnsubscribeToken(token string) (string, error)
+	// ListDigestRecipients returns opted-in users whose last digest was sent before the given time
+	ListDigestRecipients(sentBefore time.Time) ([]string, error)
+	// ClaimDigest records a digest as sent at claimedAt unless one was sent since sentBefore,
+	// only the caller that gets true sends it so replicas do not send the same digest
+	ClaimDigest(id string, claimedAt, sentBefore time.Time) (bool, error)
+	// ReleaseDigestClaim restores the previous send time of a claim whose digest could not be sent
+	ReleaseDigestClaim(id string, claimedAt time.Time, previous *time.Time) error
+
+	// GetPreferences returns the user's dietary profile, nil if none has been saved
+	GetPreferences(id string) (*models.UserPreferences, error)
+	SavePreferences(id string, prefs *models.UserPreferences) error
+}
+
+// RecipeStore manages recipes and their nutrition
+type RecipeStore interface {
+	Create(recipe *models.RecipeDetail) error
+	// Get returns a recipe regardless of its owner (public sharing)
+	Get(id string) (*models.RecipeDetail, error)
+	GetForUser(id, userID string) (*models.RecipeDetail, error)
+	List(userID string, filter models.NutritionFilter) ([]models.RecipeSummary, error)
+	Delete(id, userID string) error
+	// ToggleFavorite flips the favorite flag and returns the new value
+	ToggleFavorite(id, userID string) (bool, error)
+
+	CountByUser(userID string) (int, error)
+	CountFavorites(userID string) (int, error)
+	CountCreatedSince(userID string, since time.Time) (int, error)
+	RecentTitles(userID string, since time.Time, limit int) ([]string, error)
+	ListImages(userID string) ([]models.RecipeImage, error)
+	ListAllImages() ([]models.RecipeImage, error)
+	UpdateImages(id, imagePath, thumbnailPath string, variants []models.ImageVariant) error
+	// ReplaceImages updates the images only while the recipe's image is still previousImagePath,
+	// returns ErrNotFound if the recipe was deleted or its image changed meanwhile
+	ReplaceImages(id, previousImagePath, imagePath, thumbnailPath string, variants []models.ImageVariant) error
+	// ListUnmanagedImages returns recipes whose image is a data: or external URL instead of a stored image,
+	// ordered by recipe id and starting after afterID
+	ListUnmanagedImages(afterID string, limit int) ([]models.RecipeImage, error)
+	CountUnmanagedImages() (int, error)
+
+	// GetNutrition returns stored nutrition, nil if none has been stored
+	GetNutrition(recipeID string) (*models.RecipeNutrition, error)
+	SaveNutrition(recipeID string, nutrition *models.RecipeNutrition) error
+}
+
+// PhotoStore manages photos uploaded for recipes
+// Photos are addressed by recipe, callers check that the recipe belongs to the user
+type PhotoStore interface {
+	// Create inserts a photo, a cover photo replaces the recipe's previous cover
+	Create(photo *models.RecipePhoto) error
+	Get(id, recipeID string) (*models.RecipePhoto, error)
+	// List returns the photos of a recipe, oldest first
+	List(recipeID string) ([]models.RecipePhoto, error)
+	// ListCovers returns the cover photos of a user's recipes by recipe id
+	ListCovers(userID string) (map[string]models.RecipePhoto, error)
+	CountByRecipe(recipeID string) (int, error)
+	// SetCover marks a photo as the recipe's cover and unmarks the previous cover
+	SetCover(id, recipeID string) error
+	// Delete removes a photo, if it was the cover the oldest remaining photo becomes the cover
+	Delete(id, recipeID string) error
+
+	ListImages(userID string) ([]models.RecipeImage, error)
+	ListAllImages() ([]models.RecipeImage, error)
+}
+
+// ShoppingListStore manages shopping list items
+type ShoppingListStore interface {
+	List(userID string) ([]models.ShoppingListItem, error)
+	// AddItems inserts items, skipping those that fail, and returns how many were added
+	AddItems(items []models.ShoppingListItem) (int, error)
+	ToggleChecked(id, userID string) error
+	Delete(id, userID string) error
+	ClearChecked(userID string) (int64, error)
+	ClearAll(userID string) (int64, error)
+	CountByUser(userID string) (int, error)
+	CountUnchecked(userID string) (int, error)
+}
+
+// TokenStore manages JWT refresh tokens
+type TokenStore interface {
+	Create(token *models.RefreshToken) error
+	Get(token string) (*models.RefreshToken, error)
+	Revoke(id string) error
+	// RevokeByToken revokes a token by its value and reports whether it existed
+	RevokeByToken(token string) (bool, error)
+	// DeleteExpired removes expired and revoked tokens and returns how many were removed
+	DeleteExpired() (int64, error)
+}
+
+// AIUsageStore records AI provider requests for cost accounting
+type AIUsageStore interface {
+	Record(usage *models.AIUsage) error
+	Totals(since time.Time) (*models.AIUsageTotals, error)
+	// ByDay returns the usage per UTC day, oldest first, days without usage are omitted
+	ByDay(since time.Time) ([]models.AIUsageByDay, error)
+	// ByUser returns the usage per user, most expensive first
+	ByUser(since time.Time, limit int) ([]models.AIUsageByUser, error)
+	// ByModel returns the usage per provider and model, most expensive first
+	ByModel(since time.Time) ([]models.AIUsageByModel, error)
+}
+
+// GenerationLimit allows Limit generations since a point in time (zero time = lifetime)
+type GenerationLimit struct {
+	Limit int
+	Since time.Time
+}
+
+// GenerationStore logs recipe and image generations counted by quotas
+// Events outlive the recipe they created, deleting a recipe does not give the quota back
+type GenerationStore interface {
+	Record(userID, kind, recipeID string) error
+	// Reserve records an event unless the user already has Limit events of the kind since Since for one
+	// of the limits, checking and recording in one transaction. Returns the event id or ErrLimitReached
+	Reserve(userID, kind, recipeID string, limits []GenerationLimit) (string, error)
+	// Release deletes a reserved event of a generation that failed
+	Release(id string) error
+	CountSince(userID, kind string, since time.Time) (int, error)
+	// NthSince returns when the n-th (0 = oldest) event since the given time happened
+	NthSince(userID, kind string, since time.Time, n int) (time.Time, error)
+}
@@ -180,3 +180,52 @@ func ValidateDateFormat(date string) error {
 
 	return nil
 }
+
+// ValidatePreferenceList validates a dietary profile list (allergies, diets, ...)
+func ValidatePreferenceList(name string, values []string) error {
+	if len(values) > 50 {
+		return errors.New(name + " can have at most 50 entries")
+	}
+
+	for _, value := range values {
+		if len(value) > 100 {
+			return errors.New(name + " entries must be 100 characters or less")
+		}
+	}
+
+	return nil
+}
+
+// ValidateHouseholdSize validates household size (0 = not set)
+func ValidateHouseholdSize(size int) error {
+	if size < 0 || size > 20 {
+		return errors.New("household size must be between 0 and 20")
+	}
+
+	return nil
+}
+
+// ValidateServings validates the servings of a recipe generation request (0 = let the model decide)
+func ValidateServings(servings int) error {
+	if servings < 0 || servings > 20 {
+		return errors.New("servings must be 0 (not set) or between 1 and 20")
+	}
+
+	return nil
+}
+
+// ValidateSkillLevel validates cooking skill level enum (empty = not set)
+func ValidateSkillLevel(skillLevel string) error {
+	validLevels := map[string]bool{
+		"":             true,
+		"beginner":     true,
+		"intermediate": true,
+		"advanced":     true,
+	}
+
+	if !validLevels[strings.ToLower(skillLevel)] {
+		return errors.New("skill level must be one of: beginner, intermediate, advanced")
+	}
+
+	return nil
+}
@@ -11,6 +11,9 @@ import type {
   ShoppingListResponse,
   AdminStats,
   UsersResponse,
+  QuotaKind,
+  QuotaStatus,
+  UserQuota,
 } from '../types';
 
 // Use empty string for relative URLs (will use current host via Vite proxy in dev, or same host in production)
@@ -164,6 +167,11 @@ class APIClient {
     return response.data;
   }
 
+  async getQuota(): Promise<UserQuota> {
+    const response = await this.client.get<UserQuota>('/user/quota');
+    return response.data;
+  }
+
   // Recipe Generation
   async generateRecipe(data: RecipeGenerationRequest): Promise<Recipe> {
     const response = await this.client.post<Recipe>('/recipes/generate', data);
@@ -255,8 +263,9 @@ class APIClient {
     await this.client.delete(`/admin/users/${userId}`);
   }
 
-  async updateUserRecipeLimit(userId: string, recipeLimit: number | null): Promise<void> {
-    await this.client.put(`/admin/users/${userId}/recipe-limit`, { recipe_limit: recipeLimit });
+  async updateUserQuota(userId: string, kind: QuotaKind, policy: string | null): Promise<QuotaStatus> {
+    const response = await this.client.put<{ quota: QuotaStatus }>(`/admin/users/${userId}/quota`, { kind, policy });
+    return response.data.quota;
   }
 }
 
@@ -2,6 +2,7 @@ import React, { useState } from 'react';
 import { useNavigate } from 'react-router-dom';
 import { Clock, ChefHat, Heart } from 'lucide-react';
 import type { RecipeSummary } from '../types';
+import { buildSrcSet } from '../utils/images';
 
 interface RecipeCardProps {
   recipe: RecipeSummary;
@@ -34,6 +35,8 @@ export const RecipeCard: React.FC<RecipeCardProps> = ({ recipe, onToggleFavorite
           {/* Full image - loads lazily */}
           <img
             src={fullImageSrc}
+            srcSet={buildSrcSet(recipe.image_variants)}
+            sizes="(min-width: 1024px) 33vw, (min-width: 768px) 50vw, 100vw"
             alt={recipe.title}
             loading="lazy"
             className={`absolute inset-0 w-full h-full object-cover transition-opacity duration-300 ${
@@ -5,7 +5,7 @@ import { usePageTitle } from '../hooks/usePageTitle';
 import { apiClient } from '../api/client';
 import { ConfirmDialog } from '../components/ConfirmDialog';
 import { toast } from 'react-hot-toast';
-import type { AdminStats, UserWithStats } from '../types';
+import type { AdminStats, QuotaKind, UserWithStats } from '../types';
 
 export const AdminPortal: React.FC = () => {
   usePageTitle('Admin Portal');
@@ -17,10 +17,10 @@ export const AdminPortal: React.FC = () => {
   const [selectedUser, setSelectedUser] = useState<UserWithStats | null>(null);
   const [showDeleteConfirm, setShowDeleteConfirm] = useState(false);
   const [deleting, setDeleting] = useState(false);
-  const [editingLimitUserId, setEditingLimitUserId] = useState<string | null>(null);
-  const [limitValue, setLimitValue] = useState<string>('');
+  const [editingQuota, setEditingQuota] = useState<{ userId: string; kind: QuotaKind } | null>(null);
+  const [quotaValue, setQuotaValue] = useState<string>('');
   const [showCustomInput, setShowCustomInput] = useState(false);
-  const [customLimitValue, setCustomLimitValue] = useState<string>('');
+  const [customQuotaValue, setCustomQuotaValue] = useState<string>('');
 
   useEffect(() => {
     // Redirect if not admin
@@ -76,75 +76,159 @@ export const AdminPortal: React.FC = () => {
     }
   };
 
-  const handleEditLimit = (userItem: UserWithStats) => {
-    setEditingLimitUserId(userItem.id);
+  const quotaPresets = ['global', 'unlimited', '0', '5/day', '10/day', '20/day', '50/month', '100/month'];
+
+  const getUserQuota = (userItem: UserWithStats, kind: QuotaKind): string | null | undefined => {
+    return kind === 'text' ? userItem.text_quota : userItem.image_quota;
+  };
+
+  const handleEditQuota = (userItem: UserWithStats, kind: QuotaKind) => {
+    setEditingQuota({ userId: userItem.id, kind });
     setShowCustomInput(false);
-    setCustomLimitValue('');
-
-    // Set initial value: null/undefined -> 'global', -1 -> 'unlimited', 0 -> '0', >0 -> number
-    if (userItem.recipe_limit === null || userItem.recipe_limit === undefined) {
-      setLimitValue('global');
-    } else if (userItem.recipe_limit === -1) {
-      setLimitValue('unlimited');
-    } else if ([0, 5, 10, 20, 50, 100].includes(userItem.recipe_limit)) {
-      // If it's a predefined value, use it
-      setLimitValue(userItem.recipe_limit.toString());
+    setCustomQuotaValue('');
+
+    // Set initial value: null/undefined -> 'global', a preset policy -> the preset, anything else -> custom
+    const policy = getUserQuota(userItem, kind);
+    if (policy === null || policy === undefined) {
+      setQuotaValue('global');
+    } else if (quotaPresets.includes(policy)) {
+      setQuotaValue(policy);
     } else {
-      // Otherwise, it's a custom value
-      setLimitValue('custom');
+      setQuotaValue('custom');
       setShowCustomInput(true);
-      setCustomLimitValue(userItem.recipe_limit.toString());
+      setCustomQuotaValue(policy);
     }
   };
 
-  const handleSaveLimit = async (userId: string) => {
+  const handleSaveQuota = async () => {
+    if (!editingQuota) return;
+
     try {
-      let limitToSave: number | null;
-
-      if (limitValue === 'global') {
-        limitToSave = null;
-      } else if (limitValue === 'unlimited') {
-        limitToSave = -1;
-      } else if (limitValue === 'custom') {
-        // Use the custom input value
-        const parsed = parseInt(customLimitValue, 10);
-        if (isNaN(parsed) || parsed < 0) {
-          toast.error('Invalid custom limit value. Must be 0 or greater.');
+      let policyToSave: string | null;
+
+      if (quotaValue === 'global') {
+        policyToSave = null;
+      } else if (quotaValue === 'custom') {
+        // Use the custom input value, the server validates and normalizes it
+        policyToSave = customQuotaValue.trim();
+        if (!policyToSave) {
+          toast.error('Enter a quota such as 10/day or 5/day,50/month');
           return;
         }
-        limitToSave = parsed;
       } else {
-        // Use the dropdown value
-        const parsed = parseInt(limitValue, 10);
-        if (isNaN(parsed) || parsed < 0) {
-          toast.error('Invalid limit value');
-          return;
-        }
-        limitToSave = parsed;
+        policyToSave = quotaValue;
       }
 
-      await apiClient.updateUserRecipeLimit(userId, limitToSave);
-      toast.success('Recipe limit updated successfully');
-      setEditingLimitUserId(null);
+      await apiClient.updateUserQuota(editingQuota.userId, editingQuota.kind, policyToSave);
+      toast.success(`${editingQuota.kind === 'text' ? 'Recipe' : 'Image'} quota updated successfully`);
+      setEditingQuota(null);
       setShowCustomInput(false);
-      setCustomLimitValue('');
+      setCustomQuotaValue('');
       // Refresh data
       fetchData();
     } catch (error: any) {
-      toast.error(error.response?.data?.error || 'Failed to update limit');
+      toast.error(error.response?.data?.error || 'Failed to update quota');
     }
   };
 
   const handleCancelEdit = () => {
-    setEditingLimitUserId(null);
-    setLimitValue('');
+    setEditingQuota(null);
+    setQuotaValue('');
+    setShowCustomInput(false);
+    setCustomQuotaValue('');
   };
 
-  const formatRecipeLimit = (limit: number | null | undefined): string => {
-    if (limit === null || limit === undefined) return 'Global';
-    if (limit === -1) return 'Unlimited';
-    if (limit === 0) return 'Blocked';
-    return limit.toString();
+  const formatQuota = (policy: string | null | undefined): string => {
+    if (policy === null || policy === undefined) return 'Global';
+    if (policy === 'unlimited') return 'Unlimited';
+    if (policy === '0' || policy.startsWith('0/')) return 'Blocked';
+    return policy.split(',').join(', ');
+  };
+
+  const renderQuotaCell = (userItem: UserWithStats, kind: QuotaKind) => {
+    const policy = getUserQuota(userItem, kind);
+
+    if (editingQuota?.userId === userItem.id && editingQuota.kind === kind) {
+      return (
+        <div className="flex items-center gap-2">
+          <select
+            value={quotaValue}
+            onChange={(e) => {
+              const newValue = e.target.value;
+              setQuotaValue(newValue);
+              if (newValue === 'custom') {
+                setShowCustomInput(true);
+                setCustomQuotaValue('');
+              } else {
+                setShowCustomInput(false);
+                setCustomQuotaValue('');
+              }
+            }}
+            className="text-sm border border-gray-300 dark:border-gray-600 rounded px-2 py-1 bg-white dark:bg-gray-700 text-gray-900 dark:text-white"
+          >
+            <option value="global">Global</option>
+            <option value="unlimited">Unlimited</option>
+            <option value="0">Blocked</option>
+            <option value="5/day">5 / day</option>
+            <option value="10/day">10 / day</option>
+            <option value="20/day">20 / day</option>
+            <option value="50/month">50 / month</option>
+            <option value="100/month">100 / month</option>
+            <option value="custom">Custom...</option>
+          </select>
+          {showCustomInput && (
+            <input
+              type="text"
+              value={customQuotaValue}
+              onChange={(e) => setCustomQuotaValue(e.target.value)}
+              className="text-sm border border-gray-300 dark:border-gray-600 rounded px-2 py-1 bg-white dark:bg-gray-700 text-gray-900 dark:text-white w-36"
+              placeholder="5/day,50/month"
+              autoFocus
+            />
+          )}
+          <button
+            onClick={handleSaveQuota}
+            className="text-green-600 hover:text-green-800 dark:text-green-400 dark:hover:text-green-300"
+            title="Save"
+          >
+            ✓
+          </button>
+          <button
+            onClick={handleCancelEdit}
+            className="text-red-600 hover:text-red-800 dark:text-red-400 dark:hover:text-red-300"
+            title="Cancel"
+          >
+            ✗
+          </button>
+        </div>
+      );
+    }
+
+    const label = formatQuota(policy);
+    return (
+      <div className="flex items-center gap-2">
+        <span
+          className={`inline-flex items-center px-2.5 py-0.5 rounded-full text-xs font-medium ${
+            label === 'Blocked'
+              ? 'bg-red-100 text-red-800 dark:bg-red-900 dark:text-red-200'
+              : label === 'Unlimited'
+              ? 'bg-purple-100 text-purple-800 dark:bg-purple-900 dark:text-purple-200'
+              : label === 'Global'
+              ? 'bg-gray-100 text-gray-800 dark:bg-gray-700 dark:text-gray-200'
+              : 'bg-orange-100 text-orange-800 dark:bg-orange-900 dark:text-orange-200'
+          }`}
+        >
+          {label}
+        </span>
+        <button
+          onClick={() => handleEditQuota(userItem, kind)}
+          className="text-primary-600 hover:text-primary-800 dark:text-primary-400 dark:hover:text-primary-300 text-xs"
+          title="Edit quota"
+        >
+          Edit
+        </button>
+      </div>
+    );
   };
 
   const formatDate = (dateString: string) => {
@@ -276,7 +360,10 @@ export const AdminPortal: React.FC = () => {
                   Recipes
                 </th>
                 <th className="px-6 py-3 text-left text-xs font-medium text-gray-500 dark:text-gray-300 uppercase tracking-wider">
-                  Recipe Limit
+                  Recipe Quota
+                </th>
+                <th className="px-6 py-3 text-left text-xs font-medium text-gray-500 dark:text-gray-300 uppercase tracking-wider">
+                  Image Quota
                 </th>
                 <th className="px-6 py-3 text-left text-xs font-medium text-gray-500 dark:text-gray-300 uppercase tracking-wider">
                   Shopping
@@ -319,83 +406,10 @@ export const AdminPortal: React.FC = () => {
                     </span>
                   </td>
                   <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-600 dark:text-gray-300">
-                    {editingLimitUserId === userItem.id ? (
-                      <div className="flex items-center gap-2">
-                        <select
-                          value={limitValue}
-                          onChange={(e) => {
-                            const newValue = e.target.value;
-                            setLimitValue(newValue);
-                            if (newValue === 'custom') {
-                              setShowCustomInput(true);
-                              setCustomLimitValue('');
-                            } else {
-                              setShowCustomInput(false);
-                              setCustomLimitValue('');
-                            }
-                          }}
-                          className="text-sm border border-gray-300 dark:border-gray-600 rounded px-2 py-1 bg-white dark:bg-gray-700 text-gray-900 dark:text-white"
-                        >
-                          <option value="global">Global</option>
-                          <option value="unlimited">Unlimited</option>
-                          <option value="0">Blocked</option>
-                          <option value="5">5</option>
-                          <option value="10">10</option>
-                          <option value="20">20</option>
-                          <option value="50">50</option>
-                          <option value="100">100</option>
-                          <option value="custom">Custom...</option>
-                        </select>
-                        {showCustomInput && (
-                          <input
-                            type="number"
-                            value={customLimitValue}
-                            onChange={(e) => setCustomLimitValue(e.target.value)}
-                            min="0"
-                            className="text-sm border border-gray-300 dark:border-gray-600 rounded px-2 py-1 bg-white dark:bg-gray-700 text-gray-900 dark:text-white w-20"
-                            placeholder="Enter"
-                            autoFocus
-                          />
-                        )}
-                        <button
-                          onClick={() => handleSaveLimit(userItem.id)}
-                          className="text-green-600 hover:text-green-800 dark:text-green-400 dark:hover:text-green-300"
-                          title="Save"
-                        >
-                          ✓
-                        </button>
-                        <button
-                          onClick={handleCancelEdit}
-                          className="text-red-600 hover:text-red-800 dark:text-red-400 dark:hover:text-red-300"
-                          title="Cancel"
-                        >
-                          ✗
-                        </button>
-                      </div>
-                    ) : (
-                      <div className="flex items-center gap-2">
-                        <span
-                          className={`inline-flex items-center px-2.5 py-0.5 rounded-full text-xs font-medium ${
-                            userItem.recipe_limit === 0
-                              ? 'bg-red-100 text-red-800 dark:bg-red-900 dark:text-red-200'
-                              : userItem.recipe_limit === -1
-                              ? 'bg-purple-100 text-purple-800 dark:bg-purple-900 dark:text-purple-200'
-                              : userItem.recipe_limit === null || userItem.recipe_limit === undefined
-                              ? 'bg-gray-100 text-gray-800 dark:bg-gray-700 dark:text-gray-200'
-                              : 'bg-orange-100 text-orange-800 dark:bg-orange-900 dark:text-orange-200'
-                          }`}
-                        >
-                          {formatRecipeLimit(userItem.recipe_limit)}
-                        </span>
-                        <button
-                          onClick={() => handleEditLimit(userItem)}
-                          className="text-primary-600 hover:text-primary-800 dark:text-primary-400 dark:hover:text-primary-300 text-xs"
-                          title="Edit limit"
-                        >
-                          Edit
-                        </button>
-                      </div>
-                    )}
+                    {renderQuotaCell(userItem, 'text')}
+                  </td>
+                  <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-600 dark:text-gray-300">
+                    {renderQuotaCell(userItem, 'image')}
                   </td>
                   <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-600 dark:text-gray-300">
                     <span className="inline-flex items-center px-2.5 py-0.5 rounded-full text-xs font-medium bg-blue-100 text-blue-800 dark:bg-blue-900 dark:text-blue-200">
@@ -3,7 +3,7 @@ import { useLanguage } from '../contexts/LanguageContext';
 import { usePageTitle } from '../hooks/usePageTitle';
 import { apiClient } from '../api/client';
 import { Input } from '../components/Input';
-import type { User } from '../types';
+import type { QuotaStatus, User, UserQuota } from '../types';
 
 interface UserStats {
   total_recipes: number;
@@ -15,6 +15,7 @@ export const Profile: React.FC = () => {
   usePageTitle('Profile');
   const [user, setUser] = useState<User | null>(null);
   const [stats, setStats] = useState<UserStats | null>(null);
+  const [quota, setQuota] = useState<UserQuota | null>(null);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState('');
   const [success, setSuccess] = useState('');
@@ -29,12 +30,15 @@ export const Profile: React.FC = () => {
   const loadProfileData = async () => {
     try {
       setLoading(true);
-      const [profileData, statsData] = await Promise.all([
+      const [profileData, statsData, quotaData] = await Promise.all([
         apiClient.getProfile(),
         apiClient.getUserStats(),
+        // The profile still loads if the quota can't be fetched
+        apiClient.getQuota().catch(() => null),
       ]);
       setUser(profileData);
       setStats(statsData);
+      setQuota(quotaData);
       setUsername(profileData.username);
     } catch (err: any) {
       setError(err.response?.data?.error || 'Failed to load profile');
@@ -75,6 +79,21 @@ export const Profile: React.FC = () => {
     });
   };
 
+  const renderQuota = (label: string, status: QuotaStatus) => (
+    <div className="bg-white dark:bg-gray-800 rounded-lg shadow-md p-6">
+      <div className="text-sm font-medium text-gray-600 dark:text-gray-400 mb-2">{label}</div>
+      <div className="text-2xl font-bold text-gray-900 dark:text-white">
+        {status.unlimited ? t.profile.quotaUnlimited : `${status.remaining} ${t.profile.quotaRemaining}`}
+      </div>
+      {!status.unlimited && (
+        <div className="text-sm text-gray-500 dark:text-gray-400 mt-1">
+          {status.rules.map((rule) => `${rule.used}/${rule.limit} ${rule.window}`).join(', ')}
+          {status.resets_at && ` · ${t.profile.quotaResets} ${new Date(status.resets_at).toLocaleString()}`}
+        </div>
+      )}
+    </div>
+  );
+
   if (loading) {
     return (
       <div className="flex items-center justify-center min-h-screen">
@@ -123,6 +142,14 @@ export const Profile: React.FC = () => {
         </div>
       </div>
 
+      {/* Generation Quota */}
+      {quota && (
+        <div className="grid grid-cols-1 md:grid-cols-2 gap-6 mb-8">
+          {renderQuota(t.profile.recipeQuota, quota.text)}
+          {renderQuota(t.profile.imageQuota, quota.image)}
+        </div>
+      )}
+
       {/* Account Information Card */}
       <div className="bg-white dark:bg-gray-800 rounded-lg shadow-md p-8">
         <div className="flex justify-between items-center mb-6">
@@ -8,6 +8,7 @@ import { SkeletonRecipeDetail } from '../components/SkeletonRecipeDetail';
 import { ConfirmDialog } from '../components/ConfirmDialog';
 import { Clock, ChefHat, Heart, ArrowLeft, Trash2, ShoppingBag, Share2 } from 'lucide-react';
 import type { Recipe } from '../types';
+import { buildSrcSet } from '../utils/images';
 
 export const RecipeDetail: React.FC = () => {
   const { id } = useParams<{ id: string }>();
@@ -166,6 +167,8 @@ export const RecipeDetail: React.FC = () => {
           {/* Full image - loads lazily */}
           <img
             src={recipe.image_path}
+            srcSet={buildSrcSet(recipe.image_variants)}
+            sizes="(min-width: 896px) 896px, 100vw"
             alt={recipe.title}
             loading="lazy"
             className={`absolute inset-0 w-full h-full object-cover transition-opacity duration-300 ${
@@ -5,6 +5,7 @@ import { usePageTitle } from '../hooks/usePageTitle';
 import { Clock, ChefHat } from 'lucide-react';
 import axios from 'axios';
 import type { Recipe } from '../types';
+import { buildSrcSet } from '../utils/images';
 
 export const SharedRecipe: React.FC = () => {
   const { id } = useParams<{ id: string }>();
@@ -95,6 +96,8 @@ export const SharedRecipe: React.FC = () => {
             {/* Full image - loads lazily */}
             <img
               src={recipe.image_path}
+              srcSet={buildSrcSet(recipe.image_variants)}
+              sizes="(min-width: 896px) 896px, 100vw"
               alt={recipe.title}
               loading="lazy"
               className={`absolute inset-0 w-full h-full object-cover transition-opacity duration-300 ${
@@ -177,6 +177,11 @@ export const en = {
     updateProfile: 'Update Profile',
     profileUpdated: 'Profile updated successfully!',
     updateFailed: 'Failed to update profile',
+    recipeQuota: 'Recipe Generations',
+    imageQuota: 'Image Regenerations',
+    quotaUnlimited: 'Unlimited',
+    quotaRemaining: 'remaining',
+    quotaResets: 'more from',
   },
 
   // Shopping List
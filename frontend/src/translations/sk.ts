@@ -179,6 +179,11 @@ export const sk: TranslationsType = {
     updateProfile: 'Aktualizovať Profil',
     profileUpdated: 'Profil úspešne aktualizovaný!',
     updateFailed: 'Nepodarilo sa aktualizovať profil',
+    recipeQuota: 'Generovanie Receptov',
+    imageQuota: 'Pregenerovanie Obrázkov',
+    quotaUnlimited: 'Neobmedzené',
+    quotaRemaining: 'zostáva',
+    quotaResets: 'ďalšie od',
   },
 
   // Shopping List
@@ -38,6 +38,29 @@ export interface CookingStep {
   temperature?: string;
 }
 
+// Square rendition of a recipe image, used to build srcset
+export interface ImageVariant {
+  url: string;
+  width: number;
+  height: number;
+  format: string; // "jpeg" or "webp"
+  content_type: string;
+  size_bytes: number;
+}
+
+// Photo of the cooked dish uploaded by the recipe's owner
+export interface RecipePhoto {
+  id: string;
+  recipe_id: string;
+  image_path: string;
+  thumbnail_path: string;
+  image_variants: ImageVariant[];
+  width: number;
+  height: number;
+  is_cover: boolean; // Shown instead of the generated image
+  created_at: string;
+}
+
 export interface Recipe {
   id: string;
   user_id: string;
@@ -53,6 +76,8 @@ export interface Recipe {
   is_favorite: boolean;
   image_path: string;
   thumbnail_path: string;
+  image_variants?: ImageVariant[];
+  photos?: RecipePhoto[];
   created_at: string;
 }
 
@@ -76,6 +101,7 @@ export interface RecipeSummary {
   is_favorite: boolean;
   image_path: string;
   thumbnail_path: string;
+  image_variants?: ImageVariant[];
   created_at: string;
 }
 
@@ -107,6 +133,31 @@ export interface ShoppingListResponse {
   items: ShoppingListItem[];
 }
 
+// Generation quota types
+export type QuotaKind = 'text' | 'image';
+
+export interface QuotaRule {
+  limit: number;
+  window: string; // hour, day, week, month, lifetime or a duration such as 6h0m0s
+  used: number;
+  remaining: number;
+  resets_at?: string;
+}
+
+export interface QuotaStatus {
+  policy: string;
+  source: 'global' | 'user';
+  unlimited: boolean;
+  remaining: number; // -1 = unlimited
+  resets_at?: string;
+  rules: QuotaRule[];
+}
+
+export interface UserQuota {
+  text: QuotaStatus;
+  image: QuotaStatus;
+}
+
 // Admin types
 export interface UserWithStats {
   id: string;
@@ -117,7 +168,8 @@ export interface UserWithStats {
   recipe_count: number;
   shopping_items: number;
   last_recipe_date?: string;
-  recipe_limit?: number | null; // null = use global, -1 = unlimited, 0 = blocked, >0 = custom
+  text_quota?: string | null; // null = use global, otherwise a policy such as "unlimited", "0" or "10/day,100/month"
+  image_quota?: string | null;
 }
 
 export interface AdminStats {
@@ -129,6 +181,17 @@ export interface AdminStats {
   most_active_user_count: number;
   recent_registrations: number;
   first_user_date: string;
+  ai_usage?: AIUsageTotals; // Last 7 days
+}
+
+export interface AIUsageTotals {
+  calls: number;
+  input_tokens: number;
+  output_tokens: number;
+  images: number;
+  cost_usd: number;
+  unpriced_calls: number;
+  avg_latency_ms: number;
 }
 
 export interface UsersResponse {
@@ -0,0 +1,11 @@
+import type { ImageVariant } from '../types';
+
+// Builds a srcset from the JPEG renditions of a recipe image.
+// WebP is negotiated by the server through the Accept header, so the JPEG URLs are enough.
+export const buildSrcSet = (variants?: ImageVariant[]): string | undefined => {
+  const jpegVariants = (variants || []).filter((variant) => variant.format === 'jpeg');
+  if (jpegVariants.length === 0) {
+    return undefined;
+  }
+  return jpegVariants.map((variant) => `${variant.url} ${variant.width}w`).join(', ');
+};